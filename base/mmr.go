@@ -0,0 +1,82 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// MMR selects n candidates by maximal marginal relevance. At each step, the candidate maximizing
+//
+//	lambda * relevance - (1 - lambda) * max similarity to selected candidates
+//
+// is selected, where relevance is the min-max normalized score. The similarity between a candidate
+// and a selected candidate should be in [0, 1]. Ties are broken by the original order. Indices of
+// selected candidates are returned in selected order.
+func MMR(scores []float64, n int, lambda float64, similarity func(candidate, selected int) float64) []int {
+	if n > len(scores) {
+		n = len(scores)
+	}
+	// normalize relevance
+	minScore, maxScore := math.Inf(1), math.Inf(-1)
+	for _, score := range scores {
+		minScore = math.Min(minScore, score)
+		maxScore = math.Max(maxScore, score)
+	}
+	relevance := make([]float64, len(scores))
+	for i, score := range scores {
+		if maxScore > minScore {
+			relevance[i] = (score - minScore) / (maxScore - minScore)
+		} else {
+			relevance[i] = 1
+		}
+	}
+	// select candidates greedily
+	selected := make([]int, 0, n)
+	isSelected := make([]bool, len(scores))
+	maxSimilarity := make([]float64, len(scores))
+	for len(selected) < n {
+		best, bestValue := -1, math.Inf(-1)
+		for i := range scores {
+			if !isSelected[i] {
+				value := lambda*relevance[i] - (1-lambda)*maxSimilarity[i]
+				if value > bestValue {
+					best, bestValue = i, value
+				}
+			}
+		}
+		selected = append(selected, best)
+		isSelected[best] = true
+		if len(selected) < n {
+			for i := range scores {
+				if !isSelected[i] {
+					maxSimilarity[i] = math.Max(maxSimilarity[i], similarity(i, best))
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// Jaccard returns the Jaccard similarity between two sets.
+func Jaccard(a, b mapset.Set[string]) float64 {
+	union := a.Union(b).Cardinality()
+	if union == 0 {
+		return 0
+	}
+	return float64(a.Intersect(b).Cardinality()) / float64(union)
+}
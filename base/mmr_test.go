@@ -0,0 +1,43 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMMR(t *testing.T) {
+	// item 0 and item 1 are in the same series
+	similarity := func(i, j int) float64 {
+		if i+j == 1 {
+			return 1
+		}
+		return 0
+	}
+	scores := []float64{3, 2, 1}
+	assert.Equal(t, []int{0, 1, 2}, MMR(scores, 3, 1, similarity))
+	assert.Equal(t, []int{0, 2, 1}, MMR(scores, 3, 0.5, similarity))
+	assert.Equal(t, []int{0, 2}, MMR(scores, 2, 0.5, similarity))
+	assert.Equal(t, []int{0, 2, 1}, MMR(scores, 10, 0.5, similarity))
+	assert.Empty(t, MMR(nil, 10, 0.5, similarity))
+}
+
+func TestJaccard(t *testing.T) {
+	assert.Equal(t, 0.5, Jaccard(mapset.NewSet("a", "b", "c"), mapset.NewSet("b", "c", "d")))
+	assert.Equal(t, 0.0, Jaccard(mapset.NewSet[string](), mapset.NewSet[string]()))
+}
@@ -125,6 +125,9 @@ func (t *SubTask) Finish() {
 type Monitor struct {
 	TaskLock sync.Mutex
 	Tasks    map[string]*Task
+	// OnFail is called after a task fails. It is not called again if the task fails with the same error before it
+	// restarts.
+	OnFail func(name, err string)
 }
 
 // NewTaskMonitor creates a Monitor and add pending tasks.
@@ -192,8 +195,14 @@ func (tm *Monitor) Suspend(name string, flag bool) {
 
 func (tm *Monitor) Fail(name, err string) {
 	tm.TaskLock.Lock()
-	defer tm.TaskLock.Unlock()
-	tm.Tasks[name].Fail(err)
+	t := tm.Tasks[name]
+	repeated := t != nil && t.Status == StatusFailed && t.Error == err
+	t.Fail(err)
+	onFail := tm.OnFail
+	tm.TaskLock.Unlock()
+	if onFail != nil && !repeated {
+		onFail(name, err)
+	}
 }
 
 // List all tasks and remove tasks from disconnected workers.
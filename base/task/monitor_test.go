@@ -73,6 +73,21 @@ func TestTaskMonitor(t *testing.T) {
 	})
 }
 
+func TestTaskMonitor_OnFail(t *testing.T) {
+	var failures []string
+	taskMonitor := NewTaskMonitor()
+	taskMonitor.OnFail = func(name, err string) {
+		failures = append(failures, name+": "+err)
+	}
+	taskMonitor.Start("a", 100)
+	taskMonitor.Fail("a", "error")
+	taskMonitor.Fail("a", "error")
+	taskMonitor.Fail("a", "another error")
+	taskMonitor.Start("a", 100)
+	taskMonitor.Fail("a", "another error")
+	assert.Equal(t, []string{"a: error", "a: another error", "a: another error"}, failures)
+}
+
 func TestSubTask(t *testing.T) {
 	task := NewTask("a", 100)
 	task.Add(10)
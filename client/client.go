@@ -51,6 +51,14 @@ func (c *GorseClient) GetRecommend(ctx context.Context, userId string, category
 	return request[[]string, any](ctx, c, "GET", c.entryPoint+fmt.Sprintf("/api/recommend/%s/%s?n=%d", userId, category, n), nil)
 }
 
+func (c *GorseClient) BatchRecommend(ctx context.Context, userIds []string, category string, n int) (map[string]BatchRecommendResult, error) {
+	return request[map[string]BatchRecommendResult](ctx, c, "POST", c.entryPoint+"/api/recommend", BatchRecommendRequest{
+		UserIds:  userIds,
+		Category: category,
+		N:        n,
+	})
+}
+
 func (c *GorseClient) SessionRecommend(ctx context.Context, feedbacks []Feedback, n int) ([]Score, error) {
 	return request[[]Score](ctx, c, "POST", c.entryPoint+fmt.Sprintf("/api/session/recommend?n=%d", n), feedbacks)
 }
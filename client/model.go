@@ -17,10 +17,13 @@ package client
 import "time"
 
 type Feedback struct {
-	FeedbackType string `json:"FeedbackType"`
-	UserId       string `json:"UserId"`
-	ItemId       string `json:"ItemId"`
-	Timestamp    string `json:"Timestamp"`
+	FeedbackType string  `json:"FeedbackType"`
+	UserId       string  `json:"UserId"`
+	ItemId       string  `json:"ItemId"`
+	Timestamp    string  `json:"Timestamp"`
+	Value        float64 `json:"Value,omitempty"`
+
+	ContextLabels []string `json:"ContextLabels,omitempty"`
 }
 
 type ErrorMessage string
@@ -38,6 +41,18 @@ type Score struct {
 	Score int    `json:"Score"`
 }
 
+type BatchRecommendRequest struct {
+	UserIds  []string `json:"UserIds"`
+	Category string   `json:"Category"`
+	N        int      `json:"N"`
+	Offset   int      `json:"Offset"`
+}
+
+type BatchRecommendResult struct {
+	Items []string `json:"Items"`
+	Error string   `json:"Error,omitempty"`
+}
+
 type User struct {
 	UserId    string   `json:"UserId"`
 	Labels    []string `json:"Labels"`
@@ -100,6 +100,7 @@ var oneCommand = &cobra.Command{
 		cachePath, _ := cmd.PersistentFlags().GetString("cache-path")
 		managedMode, _ := cmd.PersistentFlags().GetBool("managed")
 		m := master.NewMaster(conf, cachePath, managedMode)
+		m.GrpcPort, _ = cmd.PersistentFlags().GetInt("grpc-port")
 		// Start worker
 		workerJobs, _ := cmd.PersistentFlags().GetInt("recommend-jobs")
 		w := worker.NewWorker(conf.Master.Host, conf.Master.Port, conf.Master.Host,
@@ -134,6 +135,7 @@ func init() {
 	oneCommand.PersistentFlags().StringP("config", "c", "", "configuration file path")
 	oneCommand.PersistentFlags().String("cache-path", "one_cache.data", "path of cache file")
 	oneCommand.PersistentFlags().Int("recommend-jobs", 1, "number of working jobs for recommendation tasks")
+	oneCommand.PersistentFlags().Int("grpc-port", 0, "port for gRPC APIs (disabled if 0)")
 }
 
 func main() {
@@ -45,8 +45,9 @@ var serverCommand = &cobra.Command{
 		masterHost, _ := cmd.PersistentFlags().GetString("master-host")
 		httpPort, _ := cmd.PersistentFlags().GetInt("http-port")
 		httpHost, _ := cmd.PersistentFlags().GetString("http-host")
+		grpcPort, _ := cmd.PersistentFlags().GetInt("grpc-port")
 		cachePath, _ := cmd.PersistentFlags().GetString("cache-path")
-		s := server.NewServer(masterHost, masterPort, httpHost, httpPort, cachePath)
+		s := server.NewServer(masterHost, masterPort, httpHost, httpPort, grpcPort, cachePath)
 
 		// stop server
 		done := make(chan struct{})
@@ -72,6 +73,7 @@ func init() {
 	serverCommand.PersistentFlags().String("master-host", "127.0.0.1", "host of master node")
 	serverCommand.PersistentFlags().Int("http-port", 8087, "host for RESTful APIs and Prometheus metrics export")
 	serverCommand.PersistentFlags().String("http-host", "127.0.0.1", "port for RESTful APIs and Prometheus metrics export")
+	serverCommand.PersistentFlags().Int("grpc-port", 0, "port for gRPC APIs (disabled if 0)")
 	serverCommand.PersistentFlags().Bool("debug", false, "use debug log mode")
 	serverCommand.PersistentFlags().String("cache-path", "server_cache.data", "path of cache file")
 }
@@ -19,8 +19,11 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -59,6 +62,7 @@ type Config struct {
 	Server    ServerConfig    `mapstructure:"server"`
 	Recommend RecommendConfig `mapstructure:"recommend"`
 	Tracing   TracingConfig   `mapstructure:"tracing"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
 }
 
 // DatabaseConfig is the configuration for the database.
@@ -85,16 +89,43 @@ type MasterConfig struct {
 	DashboardAuthServer string        `mapstructure:"dashboard_auth_server"`        // dashboard auth server
 	DashboardRedacted   bool          `mapstructure:"dashboard_redacted"`
 	AdminAPIKey         string        `mapstructure:"admin_api_key"`
+
+	// outgoing webhooks
+	Webhooks         []WebhookConfig `mapstructure:"webhooks" validate:"dive"`
+	WebhookRetries   int             `mapstructure:"webhook_retries" validate:"gte=0"`          // max retries of webhook deliveries
+	WebhookBackoff   time.Duration   `mapstructure:"webhook_backoff" validate:"gt=0"`           // initial backoff of webhook retries
+	PositiveRateDrop float64         `mapstructure:"positive_rate_drop" validate:"gte=0,lte=1"` // relative drop of the positive feedback rate to notify
+}
+
+const (
+	WebhookEventModelUpdated        = "model_updated"
+	WebhookEventTaskFailed          = "task_failed"
+	WebhookEventPositiveRateDropped = "positive_rate_dropped"
+	WebhookEventDataImported        = "data_imported"
+)
+
+// WebhookConfig is the configuration of an outgoing webhook.
+type WebhookConfig struct {
+	URL    string   `mapstructure:"url" validate:"required,url"`
+	Secret string   `mapstructure:"secret"` // key to sign payloads by HMAC-SHA256
+	Events []string `mapstructure:"events" validate:"dive,oneof=model_updated task_failed positive_rate_dropped data_imported"`
+}
+
+// Subscribe checks if the webhook subscribes the event. A webhook without events subscribes all events.
+func (config *WebhookConfig) Subscribe(event string) bool {
+	return len(config.Events) == 0 || lo.Contains(config.Events, event)
 }
 
 // ServerConfig is the configuration for the server.
 type ServerConfig struct {
-	APIKey         string        `mapstructure:"api_key"`                      // default number of returned items
-	DefaultN       int           `mapstructure:"default_n" validate:"gt=0"`    // secret key for RESTful APIs (SSL required)
-	ClockError     time.Duration `mapstructure:"clock_error" validate:"gte=0"` // clock error in the cluster in seconds
-	AutoInsertUser bool          `mapstructure:"auto_insert_user"`             // insert new users while inserting feedback
-	AutoInsertItem bool          `mapstructure:"auto_insert_item"`             // insert new items while inserting feedback
-	CacheExpire    time.Duration `mapstructure:"cache_expire" validate:"gt=0"` // server-side cache expire time
+	APIKey             string        `mapstructure:"api_key"`                             // default number of returned items
+	DefaultN           int           `mapstructure:"default_n" validate:"gt=0"`           // secret key for RESTful APIs (SSL required)
+	ClockError         time.Duration `mapstructure:"clock_error" validate:"gte=0"`        // clock error in the cluster in seconds
+	AutoInsertUser     bool          `mapstructure:"auto_insert_user"`                    // insert new users while inserting feedback
+	AutoInsertItem     bool          `mapstructure:"auto_insert_item"`                    // insert new items while inserting feedback
+	CacheExpire        time.Duration `mapstructure:"cache_expire" validate:"gt=0"`        // server-side cache expire time
+	EnableRankingModel bool          `mapstructure:"enable_ranking_model"`                // sync the ranking model to compute collaborative recommendations
+	IdempotencyKeyTTL  time.Duration `mapstructure:"idempotency_key_ttl" validate:"gt=0"` // time-to-live of results of requests with idempotency keys
 }
 
 // RecommendConfig is the configuration of recommendation setup.
@@ -103,25 +134,59 @@ type RecommendConfig struct {
 	CacheExpire   time.Duration       `mapstructure:"cache_expire" validate:"gt=0"`
 	DataSource    DataSourceConfig    `mapstructure:"data_source"`
 	Popular       PopularConfig       `mapstructure:"popular"`
+	Trending      TrendingConfig      `mapstructure:"trending"`
+	Geo           GeoConfig           `mapstructure:"geo"`
 	UserNeighbors NeighborsConfig     `mapstructure:"user_neighbors"`
 	ItemNeighbors NeighborsConfig     `mapstructure:"item_neighbors"`
 	Collaborative CollaborativeConfig `mapstructure:"collaborative"`
 	Replacement   ReplacementConfig   `mapstructure:"replacement"`
+	Diversity     DiversityConfig     `mapstructure:"diversity"`
 	Offline       OfflineConfig       `mapstructure:"offline"`
+	Bandit        BanditConfig        `mapstructure:"bandit"`
 	Online        OnlineConfig        `mapstructure:"online"`
+	Experiments   []ExperimentConfig  `mapstructure:"experiments" validate:"dive"`
+
+	// Scenes are named options of online recommendation selected by the "scene" parameter.
+	Scenes map[string]SceneConfig `mapstructure:"scenes" validate:"dive"`
 }
 
 type DataSourceConfig struct {
-	PositiveFeedbackTypes []string `mapstructure:"positive_feedback_types"`                // positive feedback type
-	ReadFeedbackTypes     []string `mapstructure:"read_feedback_types"`                    // feedback type for read event
-	PositiveFeedbackTTL   uint     `mapstructure:"positive_feedback_ttl" validate:"gte=0"` // time-to-live of positive feedbacks
-	ItemTTL               uint     `mapstructure:"item_ttl" validate:"gte=0"`              // item-to-live of items
+	PositiveFeedbackTypes []string           `mapstructure:"positive_feedback_types"`                // positive feedback type
+	ReadFeedbackTypes     []string           `mapstructure:"read_feedback_types"`                    // feedback type for read event
+	PositiveFeedbackTTL   uint               `mapstructure:"positive_feedback_ttl" validate:"gte=0"` // time-to-live of positive feedbacks
+	ItemTTL               uint               `mapstructure:"item_ttl" validate:"gte=0"`              // item-to-live of items
+	EnableFeedbackValue   bool               `mapstructure:"enable_feedback_value"`                  // use feedback values as confidence weights
+	FeedbackTypeWeights   map[string]float64 `mapstructure:"feedback_type_weights"`                  // weights of positive feedback types
+
+	NegativeFeedbackTypes []string `mapstructure:"negative_feedback_types"`                        // feedback types for "not interested" events
+	NegativeFeedbackDecay float64  `mapstructure:"negative_feedback_decay" validate:"gte=0,lte=1"` // decay of items similar to negative items
+}
+
+// FeedbackTypeWeight returns the weight of a positive feedback type. Unlisted types have a weight of 1.
+func (config *DataSourceConfig) FeedbackTypeWeight(feedbackType string) float64 {
+	if weight, exist := config.FeedbackTypeWeights[feedbackType]; exist && weight > 0 {
+		return weight
+	}
+	return 1
 }
 
 type PopularConfig struct {
 	PopularWindow time.Duration `mapstructure:"popular_window" validate:"gte=0"`
 }
 
+// TrendingConfig is the configuration of trending items. The feedback of trending items is counted with exponential
+// decay and compared with the average rate of feedback in the baseline window.
+type TrendingConfig struct {
+	HalfLife       time.Duration `mapstructure:"half_life" validate:"gt=0"`
+	BaselineWindow time.Duration `mapstructure:"baseline_window" validate:"gt=0"`
+}
+
+// GeoConfig is the configuration of geo-aware recommendation. Items are located by the reserved "location" label and
+// the scores of items are decayed by distances to the location of requests.
+type GeoConfig struct {
+	HalfDistance float64 `mapstructure:"half_distance" validate:"gte=0"` // distance in kilometers halving scores
+}
+
 type NeighborsConfig struct {
 	NeighborType  string  `mapstructure:"neighbor_type" validate:"oneof=auto similar related ''"`
 	EnableIndex   bool    `mapstructure:"enable_index"`
@@ -146,6 +211,12 @@ type ReplacementConfig struct {
 	ReadReplacementDecay     float64 `mapstructure:"read_replacement_decay" validate:"gt=0"`
 }
 
+type DiversityConfig struct {
+	EnableDiversity bool    `mapstructure:"enable_diversity"`
+	Lambda          float64 `mapstructure:"lambda" validate:"gte=0,lte=1"`
+	Similarity      string  `mapstructure:"similarity" validate:"oneof=labels neighbors"`
+}
+
 type OfflineConfig struct {
 	CheckRecommendPeriod         time.Duration      `mapstructure:"check_recommend_period" validate:"gt=0"`
 	RefreshRecommendPeriod       time.Duration      `mapstructure:"refresh_recommend_period" validate:"gt=0"`
@@ -159,9 +230,67 @@ type OfflineConfig struct {
 	exploreRecommendLock         sync.RWMutex
 }
 
+// BanditConfig is the configuration of exploration in offline recommendation. Items from exploration sources are
+// injected at the sum of rates in explore_recommend, and the source of each item is chosen by the policy.
+type BanditConfig struct {
+	Policy          string `mapstructure:"policy" validate:"oneof=random thompson_sampling ucb"` // policy choosing exploration sources
+	ExploreNewItems bool   `mapstructure:"explore_new_items"`                                    // choose latest items by bandits of items
+}
+
+// Enabled checks if exploration sources are chosen by bandits.
+func (config *BanditConfig) Enabled() bool {
+	return config.Policy == "thompson_sampling" || config.Policy == "ucb"
+}
+
 type OnlineConfig struct {
 	FallbackRecommend            []string `mapstructure:"fallback_recommend"`
 	NumFeedbackFallbackItemBased int      `mapstructure:"num_feedback_fallback_item_based" validate:"gt=0"`
+	SessionRecommend             string   `mapstructure:"session_recommend" validate:"oneof=item_based fold_in"`
+}
+
+// SceneConfig is the configuration of a recommendation scene, e.g. the homepage or the detail page. Unset options
+// follow the global configuration.
+type SceneConfig struct {
+	Recommenders []string `mapstructure:"recommenders"` // recommender chain in the format of "name" or "name:limit"
+	ExcludeRead  *bool    `mapstructure:"exclude_read"` // exclude items with feedback from the user
+
+	// Offline is the independent offline recommendation of the scene. The scene shares the global offline
+	// recommendation if it is nil.
+	Offline *OfflineSceneConfig `mapstructure:"offline"`
+}
+
+// OfflineSceneConfig is the configuration of the offline recommendation of a scene. Unset options follow the global
+// configuration.
+type OfflineSceneConfig struct {
+	PositiveFeedbackTypes        []string           `mapstructure:"positive_feedback_types"`
+	Recommenders                 []string           `mapstructure:"recommenders" validate:"dive,oneof=collaborative item_based user_based latest popular"`
+	ExploreRecommend             map[string]float64 `mapstructure:"explore_recommend"`
+	EnableClickThroughPrediction *bool              `mapstructure:"enable_click_through_prediction"`
+}
+
+// Variant returns options of the scene overridden by experiments as a variant. It should be placed before variants
+// of experiments.
+func (config *OfflineSceneConfig) Variant(name string) Variant {
+	return Variant{Experiment: "scene", VariantConfig: &VariantConfig{
+		Name:                         name,
+		EnableClickThroughPrediction: config.EnableClickThroughPrediction,
+		ExploreRecommend:             config.ExploreRecommend,
+	}}
+}
+
+// ExperimentConfig is the configuration of an A/B experiment. Users are split into variants by hashing.
+type ExperimentConfig struct {
+	Name     string          `mapstructure:"name" validate:"required"`
+	Variants []VariantConfig `mapstructure:"variants" validate:"required,dive"`
+}
+
+// VariantConfig is the configuration of a variant in an experiment. Unset options follow the global configuration.
+type VariantConfig struct {
+	Name                         string             `mapstructure:"name" validate:"required"`
+	Weight                       float64            `mapstructure:"weight" validate:"gt=0"`
+	FallbackRecommend            []string           `mapstructure:"fallback_recommend"`
+	EnableClickThroughPrediction *bool              `mapstructure:"enable_click_through_prediction"`
+	ExploreRecommend             map[string]float64 `mapstructure:"explore_recommend"`
 }
 
 type TracingConfig struct {
@@ -172,31 +301,65 @@ type TracingConfig struct {
 	Ratio             float64 `mapstructure:"ratio"`
 }
 
+// JWTConfig is the configuration of JWT authentication. Roles in the claim of a JWT are mapped to the dashboard
+// access and scopes of REST-ful APIs.
+type JWTConfig struct {
+	JWKS           string   `mapstructure:"jwks"`        // path or URL of the JSON web key set
+	Issuer         string   `mapstructure:"issuer"`      // expected issuer of JWTs
+	Audience       string   `mapstructure:"audience"`    // expected audience of JWTs
+	RolesClaim     string   `mapstructure:"roles_claim"` // claim of roles
+	DashboardRoles []string `mapstructure:"dashboard_roles"`
+	RecommendRoles []string `mapstructure:"recommend_roles"`
+	FeedbackRoles  []string `mapstructure:"feedback_roles"`
+	AdminRoles     []string `mapstructure:"admin_roles"`
+	RateLimit      float64  `mapstructure:"rate_limit" validate:"gte=0"` // requests per second of a subject on a server node
+	Burst          int      `mapstructure:"burst" validate:"gte=0"`      // maximal number of requests of a subject in a burst
+}
+
+// Enabled checks if JWT authentication is enabled.
+func (config *JWTConfig) Enabled() bool {
+	return config.JWKS != ""
+}
+
 func GetDefaultConfig() *Config {
 	return &Config{
 		Master: MasterConfig{
-			Port:            8086,
-			Host:            "0.0.0.0",
-			HttpPort:        8088,
-			HttpHost:        "0.0.0.0",
-			HttpCorsDomains: []string{".*"},
-			HttpCorsMethods: []string{"GET", "POST", "PUT", "DELETE", "PATCH"},
-			NumJobs:         1,
-			MetaTimeout:     10 * time.Second,
+			Port:             8086,
+			Host:             "0.0.0.0",
+			HttpPort:         8088,
+			HttpHost:         "0.0.0.0",
+			HttpCorsDomains:  []string{".*"},
+			HttpCorsMethods:  []string{"GET", "POST", "PUT", "DELETE", "PATCH"},
+			NumJobs:          1,
+			MetaTimeout:      10 * time.Second,
+			WebhookRetries:   3,
+			WebhookBackoff:   time.Second,
+			PositiveRateDrop: 0.2,
 		},
 		Server: ServerConfig{
-			DefaultN:       10,
-			ClockError:     5 * time.Second,
-			AutoInsertUser: true,
-			AutoInsertItem: true,
-			CacheExpire:    10 * time.Second,
+			DefaultN:          10,
+			ClockError:        5 * time.Second,
+			AutoInsertUser:    true,
+			AutoInsertItem:    true,
+			CacheExpire:       10 * time.Second,
+			IdempotencyKeyTTL: 24 * time.Hour,
 		},
 		Recommend: RecommendConfig{
 			CacheSize:   100,
 			CacheExpire: 72 * time.Hour,
+			DataSource: DataSourceConfig{
+				NegativeFeedbackDecay: 0.5,
+			},
 			Popular: PopularConfig{
 				PopularWindow: 180 * 24 * time.Hour,
 			},
+			Trending: TrendingConfig{
+				HalfLife:       24 * time.Hour,
+				BaselineWindow: 7 * 24 * time.Hour,
+			},
+			Geo: GeoConfig{
+				HalfDistance: 10,
+			},
 			UserNeighbors: NeighborsConfig{
 				NeighborType:  "auto",
 				EnableIndex:   true,
@@ -223,6 +386,11 @@ func GetDefaultConfig() *Config {
 				PositiveReplacementDecay: 0.8,
 				ReadReplacementDecay:     0.6,
 			},
+			Diversity: DiversityConfig{
+				EnableDiversity: false,
+				Lambda:          0.7,
+				Similarity:      "labels",
+			},
 			Offline: OfflineConfig{
 				CheckRecommendPeriod:         time.Minute,
 				RefreshRecommendPeriod:       120 * time.Hour,
@@ -233,15 +401,22 @@ func GetDefaultConfig() *Config {
 				EnableColRecommend:           true,
 				EnableClickThroughPrediction: false,
 			},
+			Bandit: BanditConfig{
+				Policy: "random",
+			},
 			Online: OnlineConfig{
 				FallbackRecommend:            []string{"latest"},
 				NumFeedbackFallbackItemBased: 10,
+				SessionRecommend:             "item_based",
 			},
 		},
 		Tracing: TracingConfig{
 			Exporter: "jaeger",
 			Sampler:  "always",
 		},
+		JWT: JWTConfig{
+			RolesClaim: "roles",
+		},
 	}
 }
 
@@ -294,6 +469,8 @@ type digestOptions struct {
 	itemNeighborDigest  string
 	enableCollaborative bool
 	enableRanking       bool
+	variants            []Variant
+	scene               *OfflineSceneConfig
 }
 
 type DigestOption func(option *digestOptions)
@@ -322,6 +499,19 @@ func WithRanking(v bool) DigestOption {
 	}
 }
 
+func WithVariants(variants []Variant) DigestOption {
+	return func(option *digestOptions) {
+		option.variants = variants
+	}
+}
+
+// WithScene adds options of the offline recommendation of a scene to the digest.
+func WithScene(scene *OfflineSceneConfig) DigestOption {
+	return func(option *digestOptions) {
+		option.scene = scene
+	}
+}
+
 func (config *Config) OfflineRecommendDigest(option ...DigestOption) string {
 	options := digestOptions{
 		userNeighborDigest:  config.UserNeighborDigest(),
@@ -364,6 +554,19 @@ func (config *Config) OfflineRecommendDigest(option ...DigestOption) string {
 		builder.WriteString(fmt.Sprintf("-%v-%v",
 			config.Recommend.Replacement.PositiveReplacementDecay, config.Recommend.Replacement.ReadReplacementDecay))
 	}
+	if config.Recommend.Diversity.EnableDiversity {
+		builder.WriteString(fmt.Sprintf("-%v-%v",
+			config.Recommend.Diversity.Lambda, config.Recommend.Diversity.Similarity))
+	}
+	for _, variant := range options.variants {
+		builder.WriteString(fmt.Sprintf("-%v-%v", variant, variant.ExploreRecommend))
+		if variant.EnableClickThroughPrediction != nil {
+			builder.WriteString(fmt.Sprintf("-%v", *variant.EnableClickThroughPrediction))
+		}
+	}
+	if options.scene != nil {
+		builder.WriteString(fmt.Sprintf("-%v-%v", options.scene.PositiveFeedbackTypes, options.scene.Recommenders))
+	}
 
 	digest := md5.Sum([]byte(builder.String()))
 	return hex.EncodeToString(digest[:])
@@ -387,6 +590,126 @@ func (config *OfflineConfig) GetExploreRecommend(key string) (value float64, exi
 	return
 }
 
+// Variant is the variant of an experiment assigned to a user.
+type Variant struct {
+	Experiment string
+	*VariantConfig
+}
+
+func (variant Variant) String() string {
+	return variant.Experiment + "=" + variant.Name
+}
+
+// Assign assigns a user to a variant. The assignment is decided by the hash of the experiment name and the user ID,
+// so that a user is always assigned to the same variant while variants are unchanged.
+func (config *ExperimentConfig) Assign(userId string) *VariantConfig {
+	if len(config.Variants) == 0 {
+		return nil
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(config.Name + "/" + userId))
+	totalWeight := 0.0
+	for _, variant := range config.Variants {
+		totalWeight += variant.Weight
+	}
+	point := float64(hash.Sum64()>>11) / (1 << 53) * totalWeight
+	for i := range config.Variants {
+		point -= config.Variants[i].Weight
+		if point < 0 {
+			return &config.Variants[i]
+		}
+	}
+	return &config.Variants[len(config.Variants)-1]
+}
+
+// AssignVariants assigns a user to a variant in each experiment.
+func (config *RecommendConfig) AssignVariants(userId string) []Variant {
+	var variants []Variant
+	for i := range config.Experiments {
+		if variant := config.Experiments[i].Assign(userId); variant != nil {
+			variants = append(variants, Variant{Experiment: config.Experiments[i].Name, VariantConfig: variant})
+		}
+	}
+	return variants
+}
+
+// GetFallbackRecommend returns fallback recommenders for a user in variants. Later variants take precedence.
+func (config *RecommendConfig) GetFallbackRecommend(variants []Variant) []string {
+	fallbackRecommend := config.Online.FallbackRecommend
+	for _, variant := range variants {
+		if variant.FallbackRecommend != nil {
+			fallbackRecommend = variant.FallbackRecommend
+		}
+	}
+	return fallbackRecommend
+}
+
+// GetEnableClickThroughPrediction returns whether click-through rate prediction is enabled for a user in variants.
+// Later variants take precedence.
+func (config *RecommendConfig) GetEnableClickThroughPrediction(variants []Variant) bool {
+	enable := config.Offline.EnableClickThroughPrediction
+	for _, variant := range variants {
+		if variant.EnableClickThroughPrediction != nil {
+			enable = *variant.EnableClickThroughPrediction
+		}
+	}
+	return enable
+}
+
+// OfflineScenes returns names of scenes with independent offline recommendation in order.
+func (config *RecommendConfig) OfflineScenes() []string {
+	var scenes []string
+	for name, scene := range config.Scenes {
+		if scene.Offline != nil {
+			scenes = append(scenes, name)
+		}
+	}
+	sort.Strings(scenes)
+	return scenes
+}
+
+// GetPositiveFeedbackTypes returns positive feedback types for the offline recommendation of a scene. The global
+// configuration is used if the scene is nil.
+func (config *RecommendConfig) GetPositiveFeedbackTypes(scene *OfflineSceneConfig) []string {
+	if scene != nil && scene.PositiveFeedbackTypes != nil {
+		return scene.PositiveFeedbackTypes
+	}
+	return config.DataSource.PositiveFeedbackTypes
+}
+
+// GetEnableOfflineRecommender returns whether an offline recommender is enabled for the offline recommendation of a
+// scene. The global configuration is used if the scene is nil.
+func (config *RecommendConfig) GetEnableOfflineRecommender(scene *OfflineSceneConfig, recommender string) bool {
+	if scene != nil && scene.Recommenders != nil {
+		return lo.Contains(scene.Recommenders, recommender)
+	}
+	switch recommender {
+	case "collaborative":
+		return config.Offline.EnableColRecommend
+	case "item_based":
+		return config.Offline.EnableItemBasedRecommend
+	case "user_based":
+		return config.Offline.EnableUserBasedRecommend
+	case "latest":
+		return config.Offline.EnableLatestRecommend
+	case "popular":
+		return config.Offline.EnablePopularRecommend
+	default:
+		return false
+	}
+}
+
+// GetExploreRecommend returns the exploration rate of a source for a user in variants. Later variants take precedence,
+// and sources unset in variants follow the global configuration.
+func (config *RecommendConfig) GetExploreRecommend(variants []Variant, key string) (value float64, exist bool) {
+	for i := len(variants) - 1; i >= 0; i-- {
+		if value, exist = variants[i].ExploreRecommend[key]; exist {
+			return
+		}
+	}
+	return config.Offline.GetExploreRecommend(key)
+}
+
 func (config *TracingConfig) NewTracerProvider() (trace.TracerProvider, error) {
 	if !config.EnableTracing {
 		return trace.NewNoopTracerProvider(), nil
@@ -465,6 +788,9 @@ func setDefault() {
 	viper.SetDefault("master.http_cors_methods", defaultConfig.Master.HttpCorsMethods)
 	viper.SetDefault("master.n_jobs", defaultConfig.Master.NumJobs)
 	viper.SetDefault("master.meta_timeout", defaultConfig.Master.MetaTimeout)
+	viper.SetDefault("master.webhook_retries", defaultConfig.Master.WebhookRetries)
+	viper.SetDefault("master.webhook_backoff", defaultConfig.Master.WebhookBackoff)
+	viper.SetDefault("master.positive_rate_drop", defaultConfig.Master.PositiveRateDrop)
 	// [server]
 	viper.SetDefault("server.api_key", defaultConfig.Server.APIKey)
 	viper.SetDefault("server.default_n", defaultConfig.Server.DefaultN)
@@ -472,11 +798,20 @@ func setDefault() {
 	viper.SetDefault("server.auto_insert_user", defaultConfig.Server.AutoInsertUser)
 	viper.SetDefault("server.auto_insert_item", defaultConfig.Server.AutoInsertItem)
 	viper.SetDefault("server.cache_expire", defaultConfig.Server.CacheExpire)
+	viper.SetDefault("server.enable_ranking_model", defaultConfig.Server.EnableRankingModel)
+	viper.SetDefault("server.idempotency_key_ttl", defaultConfig.Server.IdempotencyKeyTTL)
 	// [recommend]
 	viper.SetDefault("recommend.cache_size", defaultConfig.Recommend.CacheSize)
 	viper.SetDefault("recommend.cache_expire", defaultConfig.Recommend.CacheExpire)
+	// [recommend.data_source]
+	viper.SetDefault("recommend.data_source.negative_feedback_decay", defaultConfig.Recommend.DataSource.NegativeFeedbackDecay)
 	// [recommend.popular]
 	viper.SetDefault("recommend.popular.popular_window", defaultConfig.Recommend.Popular.PopularWindow)
+	// [recommend.trending]
+	viper.SetDefault("recommend.trending.half_life", defaultConfig.Recommend.Trending.HalfLife)
+	viper.SetDefault("recommend.trending.baseline_window", defaultConfig.Recommend.Trending.BaselineWindow)
+	// [recommend.geo]
+	viper.SetDefault("recommend.geo.half_distance", defaultConfig.Recommend.Geo.HalfDistance)
 	// [recommend.user_neighbors]
 	viper.SetDefault("recommend.user_neighbors.neighbor_type", defaultConfig.Recommend.UserNeighbors.NeighborType)
 	viper.SetDefault("recommend.user_neighbors.enable_index", defaultConfig.Recommend.UserNeighbors.EnableIndex)
@@ -499,6 +834,10 @@ func setDefault() {
 	viper.SetDefault("recommend.replacement.enable_replacement", defaultConfig.Recommend.Replacement.EnableReplacement)
 	viper.SetDefault("recommend.replacement.positive_replacement_decay", defaultConfig.Recommend.Replacement.PositiveReplacementDecay)
 	viper.SetDefault("recommend.replacement.read_replacement_decay", defaultConfig.Recommend.Replacement.ReadReplacementDecay)
+	// [recommend.diversity]
+	viper.SetDefault("recommend.diversity.enable_diversity", defaultConfig.Recommend.Diversity.EnableDiversity)
+	viper.SetDefault("recommend.diversity.lambda", defaultConfig.Recommend.Diversity.Lambda)
+	viper.SetDefault("recommend.diversity.similarity", defaultConfig.Recommend.Diversity.Similarity)
 	// [recommend.offline]
 	viper.SetDefault("recommend.offline.check_recommend_period", defaultConfig.Recommend.Offline.CheckRecommendPeriod)
 	viper.SetDefault("recommend.offline.refresh_recommend_period", defaultConfig.Recommend.Offline.RefreshRecommendPeriod)
@@ -508,12 +847,18 @@ func setDefault() {
 	viper.SetDefault("recommend.offline.enable_item_based_recommend", defaultConfig.Recommend.Offline.EnableItemBasedRecommend)
 	viper.SetDefault("recommend.offline.enable_collaborative_recommend", defaultConfig.Recommend.Offline.EnableColRecommend)
 	viper.SetDefault("recommend.offline.enable_click_through_prediction", defaultConfig.Recommend.Offline.EnableClickThroughPrediction)
+	// [recommend.bandit]
+	viper.SetDefault("recommend.bandit.policy", defaultConfig.Recommend.Bandit.Policy)
+	viper.SetDefault("recommend.bandit.explore_new_items", defaultConfig.Recommend.Bandit.ExploreNewItems)
 	// [recommend.online]
 	viper.SetDefault("recommend.online.fallback_recommend", defaultConfig.Recommend.Online.FallbackRecommend)
 	viper.SetDefault("recommend.online.num_feedback_fallback_item_based", defaultConfig.Recommend.Online.NumFeedbackFallbackItemBased)
+	viper.SetDefault("recommend.online.session_recommend", defaultConfig.Recommend.Online.SessionRecommend)
 	// [tracing]
 	viper.SetDefault("tracing.exporter", defaultConfig.Tracing.Exporter)
 	viper.SetDefault("tracing.sampler", defaultConfig.Tracing.Sampler)
+	// [jwt]
+	viper.SetDefault("jwt.roles_claim", defaultConfig.JWT.RolesClaim)
 }
 
 type configBinding struct {
@@ -544,6 +889,7 @@ func LoadConfig(path string, oneModel bool) (*Config, error) {
 		{"master.dashboard_redacted", "GORSE_DASHBOARD_REDACTED"},
 		{"master.admin_api_key", "GORSE_ADMIN_API_KEY"},
 		{"server.api_key", "GORSE_SERVER_API_KEY"},
+		{"jwt.jwks", "GORSE_JWT_JWKS"},
 	}
 	for _, binding := range bindings {
 		err := viper.BindEnv(binding.key, binding.env)
@@ -595,7 +941,7 @@ func (config *Config) Validate(oneModel bool) error {
 			storage.PostgreSQLPrefix,
 		}
 		if oneModel {
-			prefixes = append(prefixes, storage.SQLitePrefix)
+			prefixes = append(prefixes, storage.SQLitePrefix, storage.MemoryPrefix)
 		}
 		for _, prefix := range prefixes {
 			if strings.HasPrefix(fl.Field().String(), prefix) {
@@ -617,7 +963,7 @@ func (config *Config) Validate(oneModel bool) error {
 			storage.PostgreSQLPrefix,
 		}
 		if oneModel {
-			prefixes = append(prefixes, storage.SQLitePrefix)
+			prefixes = append(prefixes, storage.SQLitePrefix, storage.MemoryPrefix)
 		}
 		for _, prefix := range prefixes {
 			if strings.HasPrefix(fl.Field().String(), prefix) {
@@ -659,5 +1005,59 @@ func (config *Config) Validate(oneModel bool) error {
 			return errors.New(e.Translate(trans))
 		}
 	}
+	if err := config.Recommend.validateExperiments(); err != nil {
+		return errors.Trace(err)
+	}
+	return config.Recommend.validateScenes()
+}
+
+// validateExperiments checks that names of experiments and names of variants in each experiment are unique.
+func (config *RecommendConfig) validateExperiments() error {
+	experiments := make(map[string]struct{}, len(config.Experiments))
+	for _, experiment := range config.Experiments {
+		if _, exist := experiments[experiment.Name]; exist {
+			return errors.NotValidf("duplicate experiment `%s`", experiment.Name)
+		}
+		experiments[experiment.Name] = struct{}{}
+		variants := make(map[string]struct{}, len(experiment.Variants))
+		for _, variant := range experiment.Variants {
+			if _, exist := variants[variant.Name]; exist {
+				return errors.NotValidf("duplicate variant `%s` in experiment `%s`", variant.Name, experiment.Name)
+			}
+			variants[variant.Name] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// validateScenes checks that recommender chains of scenes consist of known recommenders with valid limits.
+func (config *RecommendConfig) validateScenes() error {
+	for _, name := range lo.Keys(config.Scenes) {
+		for _, stage := range config.Scenes[name].Recommenders {
+			recommender, _, err := ParseRecommenderStage(stage)
+			if err != nil {
+				return errors.Annotatef(err, "scene `%s`", name)
+			}
+			if !lo.Contains(onlineRecommenders, recommender) {
+				return errors.NotValidf("recommender `%s` in scene `%s`", recommender, name)
+			}
+		}
+	}
 	return nil
 }
+
+// onlineRecommenders are names of recommenders available in recommender chains.
+var onlineRecommenders = []string{"offline", "collaborative", "item_based", "user_based", "latest", "popular", "trending"}
+
+// ParseRecommenderStage parses a stage of the recommender chain in the format of "name" or "name:limit". The limit is
+// zero if it is not given.
+func ParseRecommenderStage(stage string) (name string, limit int, err error) {
+	name, limitString, hasLimit := strings.Cut(strings.TrimSpace(stage), ":")
+	if !hasLimit {
+		return name, 0, nil
+	}
+	if limit, err = strconv.Atoi(limitString); err != nil || limit <= 0 {
+		return "", 0, errors.NotValidf("limit of recommender stage `%s`", stage)
+	}
+	return name, limit, nil
+}
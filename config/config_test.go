@@ -19,10 +19,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/juju/errors"
+	"github.com/samber/lo"
 	"github.com/sclevine/yj/convert"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -73,6 +76,10 @@ func TestUnmarshal(t *testing.T) {
 			assert.Equal(t, "admin", config.Master.DashboardUserName)
 			assert.Equal(t, "password", config.Master.DashboardPassword)
 			assert.Equal(t, "super_api_key", config.Master.AdminAPIKey)
+			assert.Empty(t, config.Master.Webhooks)
+			assert.Equal(t, 3, config.Master.WebhookRetries)
+			assert.Equal(t, time.Second, config.Master.WebhookBackoff)
+			assert.Equal(t, 0.2, config.Master.PositiveRateDrop)
 			// [server]
 			assert.Equal(t, 10, config.Server.DefaultN)
 			assert.Equal(t, "19260817", config.Server.APIKey)
@@ -80,6 +87,8 @@ func TestUnmarshal(t *testing.T) {
 			assert.True(t, config.Server.AutoInsertUser)
 			assert.True(t, config.Server.AutoInsertItem)
 			assert.Equal(t, 10*time.Second, config.Server.CacheExpire)
+			assert.False(t, config.Server.EnableRankingModel)
+			assert.Equal(t, 24*time.Hour, config.Server.IdempotencyKeyTTL)
 			// [recommend]
 			assert.Equal(t, 100, config.Recommend.CacheSize)
 			assert.Equal(t, 72*time.Hour, config.Recommend.CacheExpire)
@@ -88,8 +97,18 @@ func TestUnmarshal(t *testing.T) {
 			assert.Equal(t, []string{"read"}, config.Recommend.DataSource.ReadFeedbackTypes)
 			assert.Equal(t, uint(0), config.Recommend.DataSource.PositiveFeedbackTTL)
 			assert.Equal(t, uint(0), config.Recommend.DataSource.ItemTTL)
+			assert.False(t, config.Recommend.DataSource.EnableFeedbackValue)
+			assert.Empty(t, config.Recommend.DataSource.FeedbackTypeWeights)
+			assert.Equal(t, 1.0, config.Recommend.DataSource.FeedbackTypeWeight("star"))
+			assert.Empty(t, config.Recommend.DataSource.NegativeFeedbackTypes)
+			assert.Equal(t, 0.5, config.Recommend.DataSource.NegativeFeedbackDecay)
 			// [recommend.popular]
 			assert.Equal(t, 30*24*time.Hour, config.Recommend.Popular.PopularWindow)
+			// [recommend.trending]
+			assert.Equal(t, 12*time.Hour, config.Recommend.Trending.HalfLife)
+			assert.Equal(t, 14*24*time.Hour, config.Recommend.Trending.BaselineWindow)
+			// [recommend.geo]
+			assert.Equal(t, 5.0, config.Recommend.Geo.HalfDistance)
 			// [recommend.user_neighbors]
 			assert.Equal(t, "similar", config.Recommend.UserNeighbors.NeighborType)
 			assert.True(t, config.Recommend.UserNeighbors.EnableIndex)
@@ -113,6 +132,10 @@ func TestUnmarshal(t *testing.T) {
 			assert.False(t, config.Recommend.Replacement.EnableReplacement)
 			assert.Equal(t, 0.8, config.Recommend.Replacement.PositiveReplacementDecay)
 			assert.Equal(t, 0.6, config.Recommend.Replacement.ReadReplacementDecay)
+			// [recommend.diversity]
+			assert.False(t, config.Recommend.Diversity.EnableDiversity)
+			assert.Equal(t, 0.7, config.Recommend.Diversity.Lambda)
+			assert.Equal(t, "labels", config.Recommend.Diversity.Similarity)
 			// [recommend.offline]
 			assert.Equal(t, time.Minute, config.Recommend.Offline.CheckRecommendPeriod)
 			assert.Equal(t, 24*time.Hour, config.Recommend.Offline.RefreshRecommendPeriod)
@@ -131,19 +154,45 @@ func TestUnmarshal(t *testing.T) {
 			assert.Equal(t, 0.2, value)
 			_, exist = config.Recommend.Offline.GetExploreRecommend("unknown")
 			assert.Equal(t, false, exist)
+			// [recommend.bandit]
+			assert.Equal(t, "thompson_sampling", config.Recommend.Bandit.Policy)
+			assert.True(t, config.Recommend.Bandit.ExploreNewItems)
 			// [recommend.online]
 			assert.Equal(t, []string{"item_based", "latest"}, config.Recommend.Online.FallbackRecommend)
 			assert.Equal(t, 10, config.Recommend.Online.NumFeedbackFallbackItemBased)
+			assert.Equal(t, "item_based", config.Recommend.Online.SessionRecommend)
 			// [tracing]
 			assert.False(t, config.Tracing.EnableTracing)
 			assert.Equal(t, "jaeger", config.Tracing.Exporter)
 			assert.Equal(t, "http://localhost:14268/api/traces", config.Tracing.CollectorEndpoint)
 			assert.Equal(t, "always", config.Tracing.Sampler)
 			assert.Equal(t, 1.0, config.Tracing.Ratio)
+			// [jwt]
+			assert.Empty(t, config.JWT.JWKS)
+			assert.False(t, config.JWT.Enabled())
+			assert.Equal(t, "roles", config.JWT.RolesClaim)
+			assert.Zero(t, config.JWT.RateLimit)
+			assert.Zero(t, config.JWT.Burst)
 		})
 	}
 }
 
+func TestWebhookConfig_Subscribe(t *testing.T) {
+	webhook := WebhookConfig{URL: "https://example.com"}
+	assert.True(t, webhook.Subscribe(WebhookEventTaskFailed))
+	webhook.Events = []string{WebhookEventModelUpdated}
+	assert.True(t, webhook.Subscribe(WebhookEventModelUpdated))
+	assert.False(t, webhook.Subscribe(WebhookEventTaskFailed))
+}
+
+func TestDataSourceConfig_FeedbackTypeWeight(t *testing.T) {
+	config := DataSourceConfig{FeedbackTypeWeights: map[string]float64{"star": 3, "like": 1, "invalid": 0}}
+	assert.Equal(t, 3.0, config.FeedbackTypeWeight("star"))
+	assert.Equal(t, 1.0, config.FeedbackTypeWeight("like"))
+	assert.Equal(t, 1.0, config.FeedbackTypeWeight("invalid"))
+	assert.Equal(t, 1.0, config.FeedbackTypeWeight("unknown"))
+}
+
 func TestSetDefault(t *testing.T) {
 	setDefault()
 	viper.SetConfigType("toml")
@@ -178,6 +227,7 @@ func TestBindEnv(t *testing.T) {
 		{"GORSE_DASHBOARD_REDACTED", "true"},
 		{"GORSE_ADMIN_API_KEY", "<admin_api_key>"},
 		{"GORSE_SERVER_API_KEY", "<server_api_key>"},
+		{"GORSE_JWT_JWKS", "https://example.com/.well-known/jwks.json"},
 	}
 	for _, variable := range variables {
 		t.Setenv(variable.key, variable.value)
@@ -201,6 +251,7 @@ func TestBindEnv(t *testing.T) {
 	assert.Equal(t, true, config.Master.DashboardRedacted)
 	assert.Equal(t, "<admin_api_key>", config.Master.AdminAPIKey)
 	assert.Equal(t, "<server_api_key>", config.Server.APIKey)
+	assert.Equal(t, "https://example.com/.well-known/jwks.json", config.JWT.JWKS)
 
 	// check default values
 	assert.Equal(t, 100, config.Recommend.CacheSize)
@@ -449,4 +500,161 @@ func TestConfig_OfflineRecommendDigest(t *testing.T) {
 	cfg1.Recommend.Replacement.PositiveReplacementDecay = 0.1
 	cfg2.Recommend.Replacement.PositiveReplacementDecay = 0.2
 	assert.Equal(t, cfg1.OfflineRecommendDigest(), cfg2.OfflineRecommendDigest())
+
+	// test diversity
+	cfg1, cfg2 = GetDefaultConfig(), GetDefaultConfig()
+	cfg1.Recommend.Diversity.EnableDiversity = true
+	cfg2.Recommend.Diversity.EnableDiversity = false
+	assert.NotEqual(t, cfg1.OfflineRecommendDigest(), cfg2.OfflineRecommendDigest())
+
+	cfg1, cfg2 = GetDefaultConfig(), GetDefaultConfig()
+	cfg1.Recommend.Diversity.EnableDiversity = true
+	cfg2.Recommend.Diversity.EnableDiversity = true
+	cfg1.Recommend.Diversity.Lambda = 0.5
+	cfg2.Recommend.Diversity.Lambda = 0.6
+	assert.NotEqual(t, cfg1.OfflineRecommendDigest(), cfg2.OfflineRecommendDigest())
+}
+
+func TestExperimentConfig_Assign(t *testing.T) {
+	experiment := ExperimentConfig{
+		Name: "fallback",
+		Variants: []VariantConfig{
+			{Name: "control", Weight: 1},
+			{Name: "popular", Weight: 3},
+		},
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		userId := strconv.Itoa(i)
+		variant := experiment.Assign(userId)
+		assert.Equal(t, variant, experiment.Assign(userId))
+		counts[variant.Name]++
+	}
+	assert.InDelta(t, 2500, counts["control"], 200)
+	assert.InDelta(t, 7500, counts["popular"], 200)
+	assert.Nil(t, (&ExperimentConfig{Name: "empty"}).Assign("0"))
+}
+
+func TestRecommendConfig_AssignVariants(t *testing.T) {
+	cfg := GetDefaultConfig()
+	cfg.Recommend.Online.FallbackRecommend = []string{"latest"}
+	cfg.Recommend.Offline.EnableClickThroughPrediction = true
+	cfg.Recommend.Offline.ExploreRecommend = map[string]float64{"popular": 0.1}
+	cfg.Recommend.Experiments = []ExperimentConfig{
+		{Name: "control", Variants: []VariantConfig{{Name: "control", Weight: 1}}},
+		{Name: "treatment", Variants: []VariantConfig{{
+			Name:                         "treatment",
+			Weight:                       1,
+			FallbackRecommend:            []string{"popular"},
+			EnableClickThroughPrediction: lo.ToPtr(false),
+			ExploreRecommend:             map[string]float64{"latest": 0.2},
+		}}},
+	}
+	// global configuration
+	assert.Equal(t, []string{"latest"}, cfg.Recommend.GetFallbackRecommend(nil))
+	assert.True(t, cfg.Recommend.GetEnableClickThroughPrediction(nil))
+	value, exist := cfg.Recommend.GetExploreRecommend(nil, "popular")
+	assert.True(t, exist)
+	assert.Equal(t, 0.1, value)
+	// variant configuration
+	variants := cfg.Recommend.AssignVariants("0")
+	assert.Equal(t, []string{"control=control", "treatment=treatment"}, lo.Map(variants, func(v Variant, _ int) string {
+		return v.String()
+	}))
+	assert.Equal(t, []string{"popular"}, cfg.Recommend.GetFallbackRecommend(variants))
+	assert.False(t, cfg.Recommend.GetEnableClickThroughPrediction(variants))
+	value, exist = cfg.Recommend.GetExploreRecommend(variants, "popular")
+	assert.True(t, exist)
+	assert.Equal(t, 0.1, value)
+	value, exist = cfg.Recommend.GetExploreRecommend(variants, "latest")
+	assert.True(t, exist)
+	assert.Equal(t, 0.2, value)
+	// digest
+	assert.NotEqual(t, cfg.OfflineRecommendDigest(), cfg.OfflineRecommendDigest(WithVariants(variants)))
+	assert.Equal(t, cfg.OfflineRecommendDigest(WithVariants(variants)), cfg.OfflineRecommendDigest(WithVariants(variants)))
+}
+
+func TestRecommendConfig_ValidateExperiments(t *testing.T) {
+	cfg := GetDefaultConfig()
+	cfg.Recommend.Experiments = []ExperimentConfig{
+		{Name: "explore", Variants: []VariantConfig{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}}},
+		{Name: "ctr", Variants: []VariantConfig{{Name: "control", Weight: 1}}},
+	}
+	assert.NoError(t, cfg.Recommend.validateExperiments())
+	cfg.Recommend.Experiments[1].Name = "explore"
+	assert.True(t, errors.IsNotValid(cfg.Recommend.validateExperiments()))
+	cfg.Recommend.Experiments[1].Name = "ctr"
+	cfg.Recommend.Experiments[0].Variants[1].Name = "control"
+	assert.True(t, errors.IsNotValid(cfg.Recommend.validateExperiments()))
+}
+
+func TestRecommendConfig_ValidateScenes(t *testing.T) {
+	cfg := GetDefaultConfig()
+	cfg.Recommend.Scenes = map[string]SceneConfig{
+		"homepage": {Recommenders: []string{"offline:10", "item_based", "popular"}},
+	}
+	assert.NoError(t, cfg.Recommend.validateScenes())
+	cfg.Recommend.Scenes["homepage"] = SceneConfig{Recommenders: []string{"offline", "unknown"}}
+	assert.True(t, errors.IsNotValid(cfg.Recommend.validateScenes()))
+	cfg.Recommend.Scenes["homepage"] = SceneConfig{Recommenders: []string{"offline:0"}}
+	assert.True(t, errors.IsNotValid(cfg.Recommend.validateScenes()))
+	cfg.Recommend.Scenes["homepage"] = SceneConfig{Recommenders: []string{"offline:x"}}
+	assert.True(t, errors.IsNotValid(cfg.Recommend.validateScenes()))
+}
+
+func TestParseRecommenderStage(t *testing.T) {
+	name, limit, err := ParseRecommenderStage("popular")
+	assert.NoError(t, err)
+	assert.Equal(t, "popular", name)
+	assert.Zero(t, limit)
+	name, limit, err = ParseRecommenderStage(" offline:10 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "offline", name)
+	assert.Equal(t, 10, limit)
+	_, _, err = ParseRecommenderStage("offline:-1")
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func TestRecommendConfig_OfflineScenes(t *testing.T) {
+	cfg := GetDefaultConfig()
+	cfg.Recommend.DataSource.PositiveFeedbackTypes = []string{"like"}
+	cfg.Recommend.Offline.EnableColRecommend = true
+	cfg.Recommend.Offline.ExploreRecommend = map[string]float64{"popular": 0.1}
+	cfg.Recommend.Scenes = map[string]SceneConfig{
+		"homepage": {Recommenders: []string{"offline"}},
+		"video": {Offline: &OfflineSceneConfig{
+			PositiveFeedbackTypes: []string{"watch"},
+			Recommenders:          []string{"item_based"},
+			ExploreRecommend:      map[string]float64{"latest": 0.2},
+		}},
+		"article": {Offline: &OfflineSceneConfig{}},
+	}
+	assert.Equal(t, []string{"article", "video"}, cfg.Recommend.OfflineScenes())
+
+	// unset options follow the global configuration
+	article := cfg.Recommend.Scenes["article"].Offline
+	assert.Equal(t, []string{"like"}, cfg.Recommend.GetPositiveFeedbackTypes(article))
+	assert.True(t, cfg.Recommend.GetEnableOfflineRecommender(article, "collaborative"))
+	value, exist := cfg.Recommend.GetExploreRecommend([]Variant{article.Variant("article")}, "popular")
+	assert.True(t, exist)
+	assert.Equal(t, 0.1, value)
+	// options of the scene
+	video := cfg.Recommend.Scenes["video"].Offline
+	assert.Equal(t, []string{"watch"}, cfg.Recommend.GetPositiveFeedbackTypes(video))
+	assert.False(t, cfg.Recommend.GetEnableOfflineRecommender(video, "collaborative"))
+	assert.True(t, cfg.Recommend.GetEnableOfflineRecommender(video, "item_based"))
+	value, exist = cfg.Recommend.GetExploreRecommend([]Variant{video.Variant("video")}, "latest")
+	assert.True(t, exist)
+	assert.Equal(t, 0.2, value)
+	// experiments take precedence over scenes
+	variants := []Variant{video.Variant("video"), {Experiment: "explore", VariantConfig: &VariantConfig{
+		Name:             "treatment",
+		ExploreRecommend: map[string]float64{"latest": 0.3},
+	}}}
+	value, exist = cfg.Recommend.GetExploreRecommend(variants, "latest")
+	assert.True(t, exist)
+	assert.Equal(t, 0.3, value)
+	// digest
+	assert.NotEqual(t, cfg.OfflineRecommendDigest(), cfg.OfflineRecommendDigest(WithScene(video)))
+	assert.NotEqual(t, cfg.OfflineRecommendDigest(WithScene(article)), cfg.OfflineRecommendDigest(WithScene(video)))
 }
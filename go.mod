@@ -3,6 +3,7 @@ module github.com/zhenghaoz/gorse
 go 1.18
 
 require (
+	github.com/MicahParks/keyfunc/v2 v2.1.0
 	github.com/ReneKroon/ttlcache/v2 v2.11.0
 	github.com/XSAM/otelsql v0.17.0
 	github.com/araddon/dateparse v0.0.0-20210429162001-6b43995a97de
@@ -19,6 +20,7 @@ require (
 	github.com/go-redis/redis/v9 v9.0.0-rc.1
 	github.com/go-resty/resty/v2 v2.7.0
 	github.com/go-sql-driver/mysql v1.6.0
+	github.com/golang-jwt/jwt/v5 v5.2.3
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/securecookie v1.1.1
 	github.com/gorse-io/dashboard v0.0.0-20230319140716-18e3dabe9366
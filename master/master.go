@@ -98,6 +98,14 @@ type Master struct {
 
 	scheduleState         ScheduleState
 	workerScheduleHandler http.HandlerFunc
+
+	// rulesMutex serializes updates of business rules
+	rulesMutex sync.Mutex
+	// apiKeysMutex serializes updates of API keys
+	apiKeysMutex sync.Mutex
+
+	// positiveRateDroppedDates are the last days alerted for drops of positive feedback rates by feedback types
+	positiveRateDroppedDates map[string]time.Time
 }
 
 // NewMaster creates a master node.
@@ -118,7 +126,7 @@ func NewMaster(cfg *config.Config, cacheFile string, managedMode bool) *Master {
 		TaskCacheGarbageCollection} {
 		taskMonitor.Pending(taskName)
 	}
-	return &Master{
+	m := &Master{
 		nodesInfo: make(map[string]*Node),
 		// create task monitor
 		cacheFile:     cacheFile,
@@ -158,6 +166,8 @@ func NewMaster(cfg *config.Config, cacheFile string, managedMode bool) *Master {
 		loadDataChan: parallel.NewConditionChannel(),
 		triggerChan:  parallel.NewConditionChannel(),
 	}
+	taskMonitor.OnFail = m.notifyTaskFailed
+	return m
 }
 
 // Serve starts the master node.
@@ -193,7 +203,7 @@ func (m *Master) Serve() {
 			zap.String("model_version", encoding.Hex(m.localCache.ClickModelVersion)),
 			zap.Float32("model_score", m.localCache.ClickModelScore.Precision),
 			zap.Any("params", m.localCache.ClickModel.GetParams()))
-		m.ClickModel = m.localCache.ClickModel
+		m.SetClickModel(m.localCache.ClickModel)
 		m.clickScore = m.localCache.ClickModelScore
 		m.ClickModelVersion = m.localCache.ClickModelVersion
 		RankingPrecision.Set(float64(m.clickScore.Precision))
@@ -255,6 +265,11 @@ func (m *Master) Serve() {
 		}
 	}()
 
+	// start grpc server of APIs
+	if m.GrpcPort > 0 {
+		go m.StartGrpcServer()
+	}
+
 	// start http server
 	m.StartHttpServer()
 }
@@ -267,6 +282,9 @@ func (m *Master) Shutdown() {
 	}
 	// stop grpc server
 	m.grpcServer.GracefulStop()
+	if m.GrpcServer != nil {
+		m.GrpcServer.GracefulStop()
+	}
 }
 
 func (m *Master) RunPrivilegedTasksLoop() {
@@ -464,10 +482,11 @@ func (m *Master) checkDataImported() bool {
 	return false
 }
 
-func (m *Master) notifyDataImported() {
+func (m *Master) notifyDataImported(dataType string, count int) {
 	ctx := context.Background()
 	err := m.CacheClient.Set(ctx, cache.Integer(cache.Key(cache.GlobalMeta, cache.DataImported), 1))
 	if err != nil {
 		log.Logger().Error("failed to write meta", zap.Error(err))
 	}
+	m.notify(config.WebhookEventDataImported, DataImportedEvent{Type: dataType, Count: count})
 }
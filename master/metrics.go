@@ -21,6 +21,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/base"
+	"github.com/zhenghaoz/gorse/config"
 	"github.com/zhenghaoz/gorse/storage/cache"
 )
 
@@ -227,7 +229,7 @@ var (
 
 type OnlineEvaluator struct {
 	ReadFeedbacks      []map[int32]mapset.Set[int32]
-	PositiveFeedbacks  map[string][]lo.Tuple3[int32, int32, time.Time]
+	PositiveFeedbacks  map[string][]lo.Tuple4[int32, int32, float64, time.Time]
 	ReverseIndex       map[lo.Tuple2[int32, int32]]time.Time
 	EvaluateDays       int
 	TruncatedDateToday time.Time
@@ -238,7 +240,7 @@ func NewOnlineEvaluator() *OnlineEvaluator {
 	evaluator.EvaluateDays = 30
 	evaluator.TruncatedDateToday = time.Now().Truncate(time.Hour * 24)
 	evaluator.ReverseIndex = make(map[lo.Tuple2[int32, int32]]time.Time)
-	evaluator.PositiveFeedbacks = make(map[string][]lo.Tuple3[int32, int32, time.Time])
+	evaluator.PositiveFeedbacks = make(map[string][]lo.Tuple4[int32, int32, float64, time.Time])
 	evaluator.ReadFeedbacks = make([]map[int32]mapset.Set[int32], evaluator.EvaluateDays)
 	for i := 0; i < evaluator.EvaluateDays; i++ {
 		evaluator.ReadFeedbacks[i] = make(map[int32]mapset.Set[int32])
@@ -260,47 +262,118 @@ func (evaluator *OnlineEvaluator) Read(userIndex, itemIndex int32, timestamp tim
 	}
 }
 
-func (evaluator *OnlineEvaluator) Positive(feedbackType string, userIndex, itemIndex int32, timestamp time.Time) {
-	evaluator.PositiveFeedbacks[feedbackType] = append(evaluator.PositiveFeedbacks[feedbackType], lo.Tuple3[int32, int32, time.Time]{userIndex, itemIndex, timestamp})
+// Positive records positive feedback with the weight of its feedback type. Positive feedback rates of feedback types
+// are not weighted, while the weighted positive feedback rate sums weights of positive feedback of all types.
+func (evaluator *OnlineEvaluator) Positive(feedbackType string, userIndex, itemIndex int32, weight float64, timestamp time.Time) {
+	evaluator.PositiveFeedbacks[feedbackType] = append(evaluator.PositiveFeedbacks[feedbackType], lo.Tuple4[int32, int32, float64, time.Time]{userIndex, itemIndex, weight, timestamp})
 }
 
 func (evaluator *OnlineEvaluator) Evaluate() []cache.TimeSeriesPoint {
 	var measurements []cache.TimeSeriesPoint
+	var allPositiveFeedbacks []lo.Tuple4[int32, int32, float64, time.Time]
 	for feedbackType, positiveFeedbacks := range evaluator.PositiveFeedbacks {
-		positiveFeedbackSets := make([]map[int32]mapset.Set[int32], evaluator.EvaluateDays)
+		positiveFeedbackSets := evaluator.positiveFeedbackSets(positiveFeedbacks, false)
 		for i := 0; i < evaluator.EvaluateDays; i++ {
-			positiveFeedbackSets[i] = make(map[int32]mapset.Set[int32])
+			measurements = append(measurements, cache.TimeSeriesPoint{
+				Name:      cache.Key(PositiveFeedbackRate, feedbackType),
+				Timestamp: evaluator.TruncatedDateToday.Add(-time.Hour * 24 * time.Duration(i)),
+				Value:     evaluator.positiveFeedbackRate(i, positiveFeedbackSets, nil),
+			})
+		}
+		allPositiveFeedbacks = append(allPositiveFeedbacks, positiveFeedbacks...)
+	}
+	// the weighted positive feedback rate is evaluated only if feedback types are weighted
+	if lo.ContainsBy(allPositiveFeedbacks, func(f lo.Tuple4[int32, int32, float64, time.Time]) bool { return f.C != 1 }) {
+		positiveFeedbackSets := evaluator.positiveFeedbackSets(allPositiveFeedbacks, true)
+		for i := 0; i < evaluator.EvaluateDays; i++ {
+			measurements = append(measurements, cache.TimeSeriesPoint{
+				Name:      WeightedPositiveFeedbackRate,
+				Timestamp: evaluator.TruncatedDateToday.Add(-time.Hour * 24 * time.Duration(i)),
+				Value:     evaluator.positiveFeedbackRate(i, positiveFeedbackSets, nil),
+			})
 		}
+	}
+	return measurements
+}
 
-		for _, f := range positiveFeedbacks {
-			if readTime, exist := evaluator.ReverseIndex[lo.Tuple2[int32, int32]{f.A, f.B}]; exist /* && readTime.Unix() <= f.C.Unix() */ {
-				// truncate timestamp to day
-				truncatedTime := readTime.Truncate(time.Hour * 24)
-				readIndex := int(evaluator.TruncatedDateToday.Sub(truncatedTime) / time.Hour / 24)
-				if positiveFeedbackSets[readIndex][f.A] == nil {
-					positiveFeedbackSets[readIndex][f.A] = mapset.NewSet[int32]()
+// EvaluateExperiments evaluates positive feedback rates of users in each variant of experiments. The name of
+// a time series is PositiveFeedbackRate/{feedback_type}/{experiment}/{variant}.
+func (evaluator *OnlineEvaluator) EvaluateExperiments(experiments []config.ExperimentConfig, userIndex base.Index) []cache.TimeSeriesPoint {
+	var measurements []cache.TimeSeriesPoint
+	for _, experiment := range experiments {
+		// assign users to variants
+		assignments := make(map[int32]string)
+		for i := 0; i < evaluator.EvaluateDays; i++ {
+			for user := range evaluator.ReadFeedbacks[i] {
+				if _, exist := assignments[user]; !exist {
+					assignments[user] = experiment.Assign(userIndex.ToName(user)).Name
 				}
-				positiveFeedbackSets[readIndex][f.A].Add(f.B)
 			}
 		}
-
-		for i := 0; i < evaluator.EvaluateDays; i++ {
-			var rate float64
-			if len(evaluator.ReadFeedbacks[i]) > 0 {
-				var sum float64
-				for userIndex, readSet := range evaluator.ReadFeedbacks[i] {
-					if positiveSet, exist := positiveFeedbackSets[i][userIndex]; exist {
-						sum += float64(positiveSet.Cardinality()) / float64(readSet.Cardinality())
-					}
+		for feedbackType, positiveFeedbacks := range evaluator.PositiveFeedbacks {
+			positiveFeedbackSets := evaluator.positiveFeedbackSets(positiveFeedbacks, false)
+			for _, variant := range experiment.Variants {
+				for i := 0; i < evaluator.EvaluateDays; i++ {
+					measurements = append(measurements, cache.TimeSeriesPoint{
+						Name:      cache.Key(PositiveFeedbackRate, feedbackType, experiment.Name, variant.Name),
+						Timestamp: evaluator.TruncatedDateToday.Add(-time.Hour * 24 * time.Duration(i)),
+						Value: evaluator.positiveFeedbackRate(i, positiveFeedbackSets, func(user int32) bool {
+							return assignments[user] == variant.Name
+						}),
+					})
 				}
-				rate = sum / float64(len(evaluator.ReadFeedbacks[i]))
 			}
-			measurements = append(measurements, cache.TimeSeriesPoint{
-				Name:      cache.Key(PositiveFeedbackRate, feedbackType),
-				Timestamp: evaluator.TruncatedDateToday.Add(-time.Hour * 24 * time.Duration(i)),
-				Value:     rate,
-			})
 		}
 	}
 	return measurements
 }
+
+// positiveFeedbackSets groups positive feedback on read items by days of reading. Weights of positive feedback on
+// an item are summed if weighted, otherwise each item counts once.
+func (evaluator *OnlineEvaluator) positiveFeedbackSets(positiveFeedbacks []lo.Tuple4[int32, int32, float64, time.Time], weighted bool) []map[int32]map[int32]float64 {
+	positiveFeedbackSets := make([]map[int32]map[int32]float64, evaluator.EvaluateDays)
+	for i := 0; i < evaluator.EvaluateDays; i++ {
+		positiveFeedbackSets[i] = make(map[int32]map[int32]float64)
+	}
+
+	for _, f := range positiveFeedbacks {
+		if readTime, exist := evaluator.ReverseIndex[lo.Tuple2[int32, int32]{f.A, f.B}]; exist /* && readTime.Unix() <= f.C.Unix() */ {
+			// truncate timestamp to day
+			truncatedTime := readTime.Truncate(time.Hour * 24)
+			readIndex := int(evaluator.TruncatedDateToday.Sub(truncatedTime) / time.Hour / 24)
+			if positiveFeedbackSets[readIndex][f.A] == nil {
+				positiveFeedbackSets[readIndex][f.A] = make(map[int32]float64)
+			}
+			if weighted {
+				positiveFeedbackSets[readIndex][f.A][f.B] += f.C
+			} else {
+				positiveFeedbackSets[readIndex][f.A][f.B] = 1
+			}
+		}
+	}
+	return positiveFeedbackSets
+}
+
+// positiveFeedbackRate computes the average positive feedback rate of users on a day. All users are counted if
+// the filter is nil.
+func (evaluator *OnlineEvaluator) positiveFeedbackRate(day int, positiveFeedbackSets []map[int32]map[int32]float64, filter func(user int32) bool) float64 {
+	var sum float64
+	var count int
+	for userIndex, readSet := range evaluator.ReadFeedbacks[day] {
+		if filter != nil && !filter(userIndex) {
+			continue
+		}
+		count++
+		if positiveSet, exist := positiveFeedbackSets[day][userIndex]; exist {
+			var weights float64
+			for _, weight := range positiveSet {
+				weights += weight
+			}
+			sum += weights / float64(readSet.Cardinality())
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
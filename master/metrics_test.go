@@ -18,7 +18,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/base"
+	"github.com/zhenghaoz/gorse/config"
 	"github.com/zhenghaoz/gorse/storage/cache"
 )
 
@@ -35,16 +38,16 @@ func TestOnlineEvaluator(t *testing.T) {
 	evaluator2.Read(1, 3, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(1, 4, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(1, 5, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("star", 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("like", 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("star", 1, 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("like", 1, 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(2, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(2, 2, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(2, 3, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
 	evaluator2.Read(2, 4, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("like", 2, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("star", 2, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("star", 2, 3, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
-	evaluator2.Positive("fork", 3, 3, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("like", 2, 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("star", 2, 1, 1, time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("star", 2, 3, 1, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator2.Positive("fork", 3, 3, 1, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
 	result = evaluator2.Evaluate()
 	assert.ElementsMatch(t, []cache.TimeSeriesPoint{
 		{"PositiveFeedbackRate/star", time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC), 0},
@@ -55,3 +58,60 @@ func TestOnlineEvaluator(t *testing.T) {
 		{"PositiveFeedbackRate/fork", time.Date(2005, 6, 15, 0, 0, 0, 0, time.UTC), 0},
 	}, result)
 }
+
+func TestOnlineEvaluator_Weight(t *testing.T) {
+	evaluator := NewOnlineEvaluator()
+	evaluator.TruncatedDateToday = time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC)
+	evaluator.EvaluateDays = 1
+	for i := int32(1); i <= 4; i++ {
+		evaluator.Read(1, i, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	}
+	evaluator.Positive("star", 1, 1, 3, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator.Positive("star", 1, 2, 3, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator.Positive("like", 1, 1, 0.5, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	assert.ElementsMatch(t, []cache.TimeSeriesPoint{
+		{"PositiveFeedbackRate/star", time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC), 0.5},
+		{"PositiveFeedbackRate/like", time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC), 0.25},
+		{"WeightedPositiveFeedbackRate", time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC), 1.625},
+	}, evaluator.Evaluate())
+}
+
+func TestOnlineEvaluator_EvaluateExperiments(t *testing.T) {
+	userIndex := base.NewMapIndex()
+	userIndex.Add("0")
+	userIndex.Add("1")
+	userIndex.Add("2")
+	evaluator := NewOnlineEvaluator()
+	evaluator.TruncatedDateToday = time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC)
+	evaluator.EvaluateDays = 1
+	for i := int32(1); i <= 4; i++ {
+		evaluator.Read(1, i, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+		evaluator.Read(2, i, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	}
+	evaluator.Positive("star", 1, 1, 1, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator.Positive("star", 2, 1, 1, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	evaluator.Positive("star", 2, 2, 1, time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC))
+	experiment := config.ExperimentConfig{
+		Name:     "test",
+		Variants: []config.VariantConfig{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}},
+	}
+	result := evaluator.EvaluateExperiments([]config.ExperimentConfig{experiment}, userIndex)
+	// compute expected rates of variants
+	rates := map[string]float64{"1": 0.25, "2": 0.5}
+	var expected []cache.TimeSeriesPoint
+	for _, variant := range experiment.Variants {
+		users := lo.Filter([]string{"1", "2"}, func(userId string, _ int) bool {
+			return experiment.Assign(userId).Name == variant.Name
+		})
+		var rate float64
+		for _, userId := range users {
+			rate += rates[userId] / float64(len(users))
+		}
+		expected = append(expected, cache.TimeSeriesPoint{
+			Name:      "PositiveFeedbackRate/star/test/" + variant.Name,
+			Timestamp: time.Date(2005, 6, 16, 0, 0, 0, 0, time.UTC),
+			Value:     rate,
+		})
+	}
+	assert.ElementsMatch(t, expected, result)
+}
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -63,10 +64,10 @@ func (m *Master) CreateWebService() {
 		Returns(http.StatusOK, "OK", []Node{}).
 		Writes([]Node{}))
 	ws.Route(ws.GET("/dashboard/categories").To(m.getCategories).
-		Doc("Get categories of items.").
+		Doc("Get the tree of categories of items.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
-		Returns(http.StatusOK, "OK", []string{}).
-		Writes([]string{}))
+		Returns(http.StatusOK, "OK", []CategoryNode{}).
+		Writes([]CategoryNode{}))
 	ws.Route(ws.GET("/dashboard/config").To(m.getConfig).
 		Doc("Get config.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
@@ -122,7 +123,7 @@ func (m *Master) CreateWebService() {
 		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
 		Returns(http.StatusOK, "OK", []ScoredItem{}).
 		Writes([]ScoredItem{}))
-	ws.Route(ws.GET("/dashboard/popular/{category}").To(m.getPopular).
+	ws.Route(ws.GET("/dashboard/popular/{category:*}").To(m.getPopular).
 		Doc("get popular items").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
 		Param(ws.PathParameter("category", "category of items").DataType("string")).
@@ -130,6 +131,22 @@ func (m *Master) CreateWebService() {
 		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
 		Returns(http.StatusOK, "OK", []ScoredItem{}).
 		Writes([]ScoredItem{}))
+	// Get trending items
+	ws.Route(ws.GET("/dashboard/trending/").To(m.getTrending).
+		Doc("get trending items").
+		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
+		Param(ws.QueryParameter("n", "number of returned items").DataType("int")).
+		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
+		Returns(http.StatusOK, "OK", []ScoredItem{}).
+		Writes([]ScoredItem{}))
+	ws.Route(ws.GET("/dashboard/trending/{category:*}").To(m.getTrending).
+		Doc("get trending items").
+		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
+		Param(ws.PathParameter("category", "category of items").DataType("string")).
+		Param(ws.QueryParameter("n", "number of returned items").DataType("int")).
+		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
+		Returns(http.StatusOK, "OK", []ScoredItem{}).
+		Writes([]ScoredItem{}))
 	// Get latest items
 	ws.Route(ws.GET("/dashboard/latest/").To(m.getLatest).
 		Doc("get latest items").
@@ -138,7 +155,7 @@ func (m *Master) CreateWebService() {
 		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
 		Returns(http.StatusOK, "OK", []ScoredItem{}).
 		Writes([]ScoredItem{}))
-	ws.Route(ws.GET("/dashboard/latest/{category}").To(m.getLatest).
+	ws.Route(ws.GET("/dashboard/latest/{category:*}").To(m.getLatest).
 		Doc("get latest items").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
 		Param(ws.PathParameter("category", "category of items").DataType("string")).
@@ -161,7 +178,7 @@ func (m *Master) CreateWebService() {
 		Param(ws.QueryParameter("n", "number of returned items").DataType("int")).
 		Returns(http.StatusOK, "OK", []data.Item{}).
 		Writes([]data.Item{}))
-	ws.Route(ws.GET("/dashboard/recommend/{user-id}/{recommender}/{category}").To(m.getRecommend).
+	ws.Route(ws.GET("/dashboard/recommend/{user-id}/{recommender}/{category:*}").To(m.getRecommend).
 		Doc("Get recommendation for user.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
 		Param(ws.PathParameter("user-id", "identifier of the user").DataType("string")).
@@ -178,7 +195,7 @@ func (m *Master) CreateWebService() {
 		Param(ws.QueryParameter("offset", "offset of the list").DataType("int")).
 		Returns(http.StatusOK, "OK", []ScoredItem{}).
 		Writes([]ScoredItem{}))
-	ws.Route(ws.GET("/dashboard/item/{item-id}/neighbors/{category}").To(m.getItemCategorizedNeighbors).
+	ws.Route(ws.GET("/dashboard/item/{item-id}/neighbors/{category:*}").To(m.getItemCategorizedNeighbors).
 		Doc("get neighbors of a item").
 		Metadata(restfulspec.KeyOpenAPITags, []string{"dashboard"}).
 		Param(ws.PathParameter("item-id", "identifier of the item").DataType("string")).
@@ -225,6 +242,8 @@ func (m *Master) StartHttpServer() {
 	container.Handle("/api/bulk/users", http.HandlerFunc(m.importExportUsers))
 	container.Handle("/api/bulk/items", http.HandlerFunc(m.importExportItems))
 	container.Handle("/api/bulk/feedback", http.HandlerFunc(m.importExportFeedback))
+	container.Handle("/api/admin/rules", http.HandlerFunc(m.rulesAPIHandler))
+	container.Handle("/api/admin/keys", http.HandlerFunc(m.apiKeysAPIHandler))
 	if m.workerScheduleHandler == nil {
 		container.Handle("/api/admin/schedule", http.HandlerFunc(m.scheduleAPIHandler))
 	} else {
@@ -341,6 +360,23 @@ func (m *Master) login(response http.ResponseWriter, request *http.Request) {
 		token := request.FormValue("token")
 		name := request.FormValue("user_name")
 		pass := request.FormValue("password")
+		if m.Config.JWT.Enabled() && token != "" && m.checkJWT(token) {
+			// save JWT to cookie
+			if encoded, err := cookieHandler.Encode("jwt", token); err != nil {
+				server.InternalServerError(restful.NewResponse(response), err)
+				return
+			} else {
+				cookie := &http.Cookie{
+					Name:  "jwt",
+					Value: encoded,
+					Path:  "/",
+				}
+				http.SetCookie(response, cookie)
+				http.Redirect(response, request, "/", http.StatusFound)
+				log.Logger().Info("POST /login", zap.Int("status_code", http.StatusFound))
+				return
+			}
+		}
 		if m.Config.Master.DashboardAuthServer != "" {
 			// check access token
 			if isValid, err := m.checkToken(token); err != nil {
@@ -390,6 +426,9 @@ func (m *Master) login(response http.ResponseWriter, request *http.Request) {
 				log.Logger().Info("POST /login", zap.Int("status_code", http.StatusFound))
 				return
 			}
+		} else if m.Config.JWT.Enabled() {
+			http.Redirect(response, request, "login?msg=incorrect", http.StatusFound)
+			log.Logger().Info("POST /login", zap.Int("status_code", http.StatusUnauthorized))
 		} else {
 			http.Redirect(response, request, "/", http.StatusFound)
 			log.Logger().Info("POST /login", zap.Int("status_code", http.StatusFound))
@@ -400,20 +439,22 @@ func (m *Master) login(response http.ResponseWriter, request *http.Request) {
 }
 
 func (m *Master) logout(response http.ResponseWriter, request *http.Request) {
-	cookie := &http.Cookie{
-		Name:   "session",
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
+	for _, name := range []string{"session", "jwt"} {
+		cookie := &http.Cookie{
+			Name:   name,
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		}
+		http.SetCookie(response, cookie)
 	}
-	http.SetCookie(response, cookie)
 	http.Redirect(response, request, "/login", http.StatusFound)
 	log.Logger().Info(fmt.Sprintf("%s %s", request.Method, request.RequestURI), zap.Int("status_code", http.StatusFound))
 }
 
 func (m *Master) LoginFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
 	if m.checkLogin(req.Request) {
-		req.Request.Header.Set("X-API-Key", m.Config.Server.APIKey)
+		req.SetAttribute(server.AdminAttribute, true)
 		chain.ProcessFilter(req, resp)
 	} else if !strings.HasPrefix(req.SelectedRoutePath(), "/api/dashboard") {
 		chain.ProcessFilter(req, resp)
@@ -428,6 +469,19 @@ func (m *Master) checkLogin(request *http.Request) bool {
 	if m.Config.Master.AdminAPIKey != "" && m.Config.Master.AdminAPIKey == request.Header.Get("X-Api-Key") {
 		return true
 	}
+	if m.Config.JWT.Enabled() {
+		if authorization := request.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+			if m.checkJWT(strings.TrimPrefix(authorization, "Bearer ")) {
+				return true
+			}
+		}
+		if jwtCookie, err := request.Cookie("jwt"); err == nil {
+			var token string
+			if err = cookieHandler.Decode("jwt", jwtCookie.Value, &token); err == nil && m.checkJWT(token) {
+				return true
+			}
+		}
+	}
 	if m.Config.Master.DashboardAuthServer != "" {
 		if tokenCookie, err := request.Cookie("token"); err == nil {
 			var token string
@@ -453,7 +507,19 @@ func (m *Master) checkLogin(request *http.Request) bool {
 		}
 		return false
 	}
-	return true
+	return !m.Config.JWT.Enabled()
+}
+
+// checkJWT checks if a JWT grants access to the dashboard.
+func (m *Master) checkJWT(token string) bool {
+	claims, err := m.VerifyJWT(token)
+	if err != nil {
+		if !errors.Is(err, errors.Unauthorized) {
+			log.Logger().Error("failed to verify JWT", zap.Error(err))
+		}
+		return false
+	}
+	return m.HasDashboardRole(claims)
 }
 
 func (m *Master) getCategories(request *restful.Request, response *restful.Response) {
@@ -466,7 +532,47 @@ func (m *Master) getCategories(request *restful.Request, response *restful.Respo
 		server.InternalServerError(response, err)
 		return
 	}
-	server.Ok(response, categories)
+	server.Ok(response, BuildCategoryTree(categories))
+}
+
+// CategoryNode is a node in the tree of hierarchical categories.
+type CategoryNode struct {
+	Name     string
+	Path     string
+	Children []*CategoryNode
+}
+
+// BuildCategoryTree builds the tree of hierarchical categories. Missing ancestors are added to the tree and children
+// are sorted by names.
+func BuildCategoryTree(categories []string) []*CategoryNode {
+	root := &CategoryNode{}
+	nodes := make(map[string]*CategoryNode)
+	for _, category := range data.ExpandCategories(categories) {
+		if category == "" {
+			continue
+		}
+		parent, name := root, category
+		if i := strings.LastIndex(category, data.CategorySeparator); i > 0 {
+			parent, name = nodes[category[:i]], category[i+len(data.CategorySeparator):]
+		}
+		node := &CategoryNode{Name: name, Path: category, Children: []*CategoryNode{}}
+		parent.Children = append(parent.Children, node)
+		nodes[category] = node
+	}
+	var sortChildren func(node *CategoryNode)
+	sortChildren = func(node *CategoryNode) {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Name < node.Children[j].Name
+		})
+		for _, child := range node.Children {
+			sortChildren(child)
+		}
+	}
+	sortChildren(root)
+	if root.Children == nil {
+		return []*CategoryNode{}
+	}
+	return root.Children
 }
 
 func (m *Master) getCluster(_ *restful.Request, response *restful.Response) {
@@ -671,6 +777,18 @@ func (m *Master) getRates(request *restful.Request, response *restful.Response)
 			server.InternalServerError(response, err)
 			return
 		}
+		// positive feedback rates of variants in experiments
+		for _, experiment := range m.Config.Recommend.Experiments {
+			for _, variant := range experiment.Variants {
+				name := cache.Key(feedbackType, experiment.Name, variant.Name)
+				measurements[name], err = m.CacheClient.GetTimeSeriesPoints(ctx, cache.Key(PositiveFeedbackRate, name),
+					time.Now().Add(-24*time.Hour*time.Duration(n)), time.Now())
+				if err != nil {
+					server.InternalServerError(response, err)
+					return
+				}
+			}
+		}
 	}
 	server.Ok(response, measurements)
 }
@@ -786,6 +904,8 @@ func (m *Master) getRecommend(request *restful.Request, response *restful.Respon
 				recommenders = append(recommenders, m.RecommendLatest)
 			case "popular":
 				recommenders = append(recommenders, m.RecommendPopular)
+			case "trending":
+				recommenders = append(recommenders, m.RecommendTrending)
 			default:
 				server.InternalServerError(response, fmt.Errorf("unknown fallback recommendation method `%s`", recommender))
 				return
@@ -917,6 +1037,12 @@ func (m *Master) getPopular(request *restful.Request, response *restful.Response
 	m.searchDocuments(cache.PopularItems, "", category, request, response, data.Item{})
 }
 
+// getTrending gets trending items from database.
+func (m *Master) getTrending(request *restful.Request, response *restful.Response) {
+	category := request.PathParameter("category")
+	m.searchDocuments(cache.TrendingItems, "", category, request, response, data.Item{})
+}
+
 func (m *Master) getLatest(request *restful.Request, response *restful.Response) {
 	category := request.PathParameter("category")
 	m.searchDocuments(cache.LatestItems, "", category, request, response, data.Item{})
@@ -1060,7 +1186,7 @@ func (m *Master) importUsers(ctx context.Context, response http.ResponseWriter,
 			return
 		}
 	}
-	m.notifyDataImported()
+	m.notifyDataImported("users", lineCount)
 	timeUsed := time.Since(timeStart)
 	log.Logger().Info("complete import users",
 		zap.Duration("time_used", timeUsed),
@@ -1223,7 +1349,7 @@ func (m *Master) importItems(ctx context.Context, response http.ResponseWriter,
 			return
 		}
 	}
-	m.notifyDataImported()
+	m.notifyDataImported("items", lineCount)
 	timeUsed := time.Since(timeStart)
 	log.Logger().Info("complete import items",
 		zap.Duration("time_used", timeUsed),
@@ -1392,7 +1518,7 @@ func (m *Master) importFeedback(ctx context.Context, response http.ResponseWrite
 			return
 		}
 	}
-	m.notifyDataImported()
+	m.notifyDataImported("feedback", lineCount)
 	timeUsed := time.Since(timeStart)
 	log.Logger().Info("complete import feedback",
 		zap.Duration("time_used", timeUsed),
@@ -1474,6 +1600,175 @@ func (m *Master) scheduleAPIHandler(writer http.ResponseWriter, request *http.Re
 	}
 }
 
+// rulesAPIHandler manages business rules applied to recommendations.
+//
+//	GET    /api/admin/rules            - list rules
+//	POST   /api/admin/rules            - insert or replace a rule by name
+//	DELETE /api/admin/rules?name=<name> - delete a rule
+func (m *Master) rulesAPIHandler(writer http.ResponseWriter, request *http.Request) {
+	if !m.checkAdmin(request) {
+		writeError(writer, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ctx := request.Context()
+	if request.Method != http.MethodGet {
+		// rules are read and written back as a whole, so that updates are serialized
+		m.rulesMutex.Lock()
+		defer m.rulesMutex.Unlock()
+		defer m.InvalidateRules()
+	}
+	rules, err := server.LoadRules(ctx, m.CacheClient)
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		if rules == nil {
+			rules = []server.Rule{}
+		}
+		bytes, err := json.Marshal(rules)
+		if err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+		if _, err = writer.Write(bytes); err != nil {
+			log.Logger().Error("failed to write rules", zap.Error(err))
+		}
+	case http.MethodPost:
+		var rule server.Rule
+		if err = json.NewDecoder(request.Body).Decode(&rule); err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err = rule.Validate(); err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		rules = lo.Filter(rules, func(r server.Rule, _ int) bool {
+			return r.Name != rule.Name
+		})
+		if err = server.SaveRules(ctx, m.CacheClient, append(rules, rule)); err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		name := request.FormValue("name")
+		if !lo.ContainsBy(rules, func(r server.Rule) bool { return r.Name == name }) {
+			writeError(writer, http.StatusNotFound, fmt.Sprintf("rule %s not found", name))
+			return
+		}
+		rules = lo.Filter(rules, func(r server.Rule, _ int) bool {
+			return r.Name != name
+		})
+		if err = server.SaveRules(ctx, m.CacheClient, rules); err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeError(writer, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// CreatedAPIKey is the API key returned once it is created.
+type CreatedAPIKey struct {
+	Name string
+	Key  string
+}
+
+// apiKeysAPIHandler manages API keys of server nodes. Server nodes reload API keys in seconds.
+//
+//	GET    /api/admin/keys             - list API keys
+//	POST   /api/admin/keys             - create or rotate an API key by name, the key is returned only once
+//	DELETE /api/admin/keys?name=<name> - revoke an API key
+func (m *Master) apiKeysAPIHandler(writer http.ResponseWriter, request *http.Request) {
+	if !m.checkAdmin(request) {
+		writeError(writer, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	ctx := request.Context()
+	if request.Method != http.MethodGet {
+		// API keys are read and written back as a whole, so that updates are serialized
+		m.apiKeysMutex.Lock()
+		defer m.apiKeysMutex.Unlock()
+	}
+	keys, err := server.LoadAPIKeys(ctx, m.CacheClient)
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, err.Error())
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		if keys == nil {
+			keys = []server.APIKey{}
+		}
+		// hashes of API keys are never exposed
+		for i := range keys {
+			keys[i].Hash = ""
+		}
+		bytes, err := json.Marshal(keys)
+		if err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+		if _, err = writer.Write(bytes); err != nil {
+			log.Logger().Error("failed to write API keys", zap.Error(err))
+		}
+	case http.MethodPost:
+		var key server.APIKey
+		if err = json.NewDecoder(request.Body).Decode(&key); err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err = key.Validate(); err != nil {
+			writeError(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		plaintext, err := server.GenerateAPIKey()
+		if err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		key.Hash = server.HashAPIKey(plaintext)
+		key.Revoked = false
+		keys = lo.Filter(keys, func(k server.APIKey, _ int) bool {
+			return k.Name != key.Name
+		})
+		if err = server.SaveAPIKeys(ctx, m.CacheClient, append(keys, key)); err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		bytes, err := json.Marshal(CreatedAPIKey{Name: key.Name, Key: plaintext})
+		if err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+		if _, err = writer.Write(bytes); err != nil {
+			log.Logger().Error("failed to write API key", zap.Error(err))
+		}
+	case http.MethodDelete:
+		name := request.FormValue("name")
+		if !lo.ContainsBy(keys, func(k server.APIKey) bool { return k.Name == name }) {
+			writeError(writer, http.StatusNotFound, fmt.Sprintf("API key %s not found", name))
+			return
+		}
+		for i := range keys {
+			if keys[i].Name == name {
+				keys[i].Revoked = true
+			}
+		}
+		if err = server.SaveAPIKeys(ctx, m.CacheClient, keys); err != nil {
+			writeError(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	default:
+		writeError(writer, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
 func writeError(response http.ResponseWriter, httpStatus int, message string) {
 	log.Logger().Error(strings.ToLower(http.StatusText(httpStatus)), zap.String("error", message))
 	response.Header().Set("Access-Control-Allow-Origin", "*")
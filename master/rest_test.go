@@ -17,12 +17,20 @@ package master
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"mime/multipart"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -34,7 +42,9 @@ import (
 	"github.com/samber/lo"
 	"github.com/steinfletcher/apitest"
 	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/base/task"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model"
 	"github.com/zhenghaoz/gorse/model/click"
 	"github.com/zhenghaoz/gorse/model/ranking"
 	"github.com/zhenghaoz/gorse/server"
@@ -572,7 +582,7 @@ func TestMaster_GetCategories(t *testing.T) {
 	defer s.Close(t)
 	ctx := context.Background()
 	// insert categories
-	err := s.CacheClient.SetSet(ctx, cache.ItemCategories, "a", "b", "c")
+	err := s.CacheClient.SetSet(ctx, cache.ItemCategories, "c", "a", "b/d/e", "b", "b/d", "b/c")
 	assert.NoError(t, err)
 	// get categories
 	apitest.New().
@@ -581,10 +591,31 @@ func TestMaster_GetCategories(t *testing.T) {
 		Header("Cookie", cookie).
 		Expect(t).
 		Status(http.StatusOK).
-		Body(marshal(t, []string{"a", "b", "c"})).
+		Body(marshal(t, []*CategoryNode{
+			{Name: "a", Path: "a", Children: []*CategoryNode{}},
+			{Name: "b", Path: "b", Children: []*CategoryNode{
+				{Name: "c", Path: "b/c", Children: []*CategoryNode{}},
+				{Name: "d", Path: "b/d", Children: []*CategoryNode{
+					{Name: "e", Path: "b/d/e", Children: []*CategoryNode{}},
+				}},
+			}},
+			{Name: "c", Path: "c", Children: []*CategoryNode{}},
+		})).
 		End()
 }
 
+func TestBuildCategoryTree(t *testing.T) {
+	// missing ancestors are added
+	assert.Equal(t, []*CategoryNode{
+		{Name: "books", Path: "books", Children: []*CategoryNode{
+			{Name: "fiction", Path: "books/fiction", Children: []*CategoryNode{
+				{Name: "scifi", Path: "books/fiction/scifi", Children: []*CategoryNode{}},
+			}},
+		}},
+	}, BuildCategoryTree([]string{"books/fiction/scifi", ""}))
+	assert.Empty(t, BuildCategoryTree(nil))
+}
+
 func TestMaster_GetUsers(t *testing.T) {
 	s, cookie := newMockServer(t)
 	defer s.Close(t)
@@ -644,6 +675,8 @@ func TestServer_SearchDocumentsOfItems(t *testing.T) {
 		{"Popular Items", cache.PopularItems, "", "", "/api/dashboard/popular/"},
 		{"Latest Items in Category", cache.LatestItems, "", "*", "/api/dashboard/latest/*"},
 		{"Popular Items in Category", cache.PopularItems, "", "*", "/api/dashboard/popular/*"},
+		{"Trending Items", cache.TrendingItems, "", "", "/api/dashboard/trending/"},
+		{"Trending Items in Category", cache.TrendingItems, "", "*", "/api/dashboard/trending/*"},
 	}
 	for i, operator := range operators {
 		t.Run(operator.Name, func(t *testing.T) {
@@ -807,6 +840,68 @@ func TestServer_GetRecommends(t *testing.T) {
 		End()
 }
 
+func TestServer_RecommendWhileFittingClickModel(t *testing.T) {
+	s, _ := newMockServer(t)
+	defer s.Close(t)
+	ctx := context.Background()
+	s.Config.Master.NumJobs = 1
+	s.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"positive"}
+	s.Config.Recommend.DataSource.ReadFeedbackTypes = []string{"negative"}
+	s.Config.Recommend.Offline.EnableClickThroughPrediction = true
+	s.taskMonitor = task.NewTaskMonitor()
+	s.localCache = &LocalCache{path: filepath.Join(t.TempDir(), "cache.data")}
+	s.clickModelSearcher = click.NewModelSearcher(1, 1, false)
+	s.SetClickModel(click.NewFM(click.FMClassification, model.Params{model.NEpochs: 1}))
+	// insert items, users and feedback
+	var (
+		items     []data.Item
+		users     []data.User
+		feedback  []data.Feedback
+		documents []cache.Document
+	)
+	for i := 0; i < 10; i++ {
+		items = append(items, data.Item{ItemId: strconv.Itoa(i), Labels: []string{strconv.Itoa(i % 3)}})
+		users = append(users, data.User{UserId: strconv.Itoa(i), Labels: []string{strconv.Itoa(i % 5)}})
+		documents = append(documents, cache.Document{Id: strconv.Itoa(i), Score: float64(10 - i), Categories: []string{""}})
+		for j := 0; j < 10; j++ {
+			feedbackType := "negative"
+			if j <= i {
+				feedbackType = "positive"
+			}
+			feedback = append(feedback, data.Feedback{
+				FeedbackKey:   data.FeedbackKey{FeedbackType: feedbackType, UserId: strconv.Itoa(j), ItemId: strconv.Itoa(i)},
+				Timestamp:     time.Now(),
+				ContextLabels: []string{"device.mobile"},
+			})
+		}
+	}
+	assert.NoError(t, s.DataClient.BatchInsertItems(ctx, items))
+	assert.NoError(t, s.DataClient.BatchInsertUsers(ctx, users))
+	assert.NoError(t, s.DataClient.BatchInsertFeedback(ctx, feedback, false, false, true))
+	assert.NoError(t, s.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "100", documents))
+	assert.NoError(t, s.runLoadDatasetTask())
+
+	// recommend under context labels while the click model is replaced
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, NewFitClickModelTask(&s.Master).run(task.NewConstantJobsAllocator(1)))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		apitest.New().
+			Handler(s.handler).
+			Get("/api/recommend/100").
+			QueryParams(map[string]string{"context": "device.mobile", "n": "3"}).
+			Expect(t).
+			Status(http.StatusOK).
+			End()
+	}
+	<-done
+	assert.False(t, s.ClickModel.Invalid())
+}
+
 func TestMaster_Purge(t *testing.T) {
 	s, cookie := newMockServer(t)
 	defer s.Close(t)
@@ -884,6 +979,139 @@ func TestMaster_Purge(t *testing.T) {
 	assert.Empty(t, feedbacks)
 }
 
+func TestMaster_Rules(t *testing.T) {
+	s, _ := newMockServer(t)
+	defer s.Close(t)
+	s.Config.Master.AdminAPIKey = "admin"
+	ctx := context.Background()
+
+	// unauthorized
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	w := httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	// empty rules
+	req = httptest.NewRequest("GET", "https://example.com/?X-API-Key=admin", nil)
+	w = httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+	// insert rules
+	for _, rule := range []server.Rule{
+		{Name: "sponsored", Action: server.RuleActionPin, ItemIds: []string{"1"}, Position: 3},
+		{Name: "promo", Action: server.RuleActionBoost, Labels: []string{"promo"}},
+		{Name: "sponsored", Action: server.RuleActionPin, ItemIds: []string{"2"}, Position: 3},
+	} {
+		req = httptest.NewRequest("POST", "https://example.com/?X-API-Key=admin", strings.NewReader(marshal(t, rule)))
+		w = httptest.NewRecorder()
+		s.rulesAPIHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	rules, err := server.LoadRules(ctx, s.CacheClient)
+	assert.NoError(t, err)
+	assert.Equal(t, []server.Rule{
+		{Name: "promo", Action: server.RuleActionBoost, Labels: []string{"promo"}},
+		{Name: "sponsored", Action: server.RuleActionPin, ItemIds: []string{"2"}, Position: 3},
+	}, rules)
+	// insert invalid rule
+	req = httptest.NewRequest("POST", "https://example.com/?X-API-Key=admin",
+		strings.NewReader(marshal(t, server.Rule{Name: "invalid", Action: server.RuleActionPin})))
+	w = httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// list rules
+	req = httptest.NewRequest("GET", "https://example.com/?X-API-Key=admin", nil)
+	w = httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, marshal(t, rules), w.Body.String())
+	// delete rule
+	req = httptest.NewRequest("DELETE", "https://example.com/?X-API-Key=admin&name=promo", nil)
+	w = httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req = httptest.NewRequest("DELETE", "https://example.com/?X-API-Key=admin&name=promo", nil)
+	w = httptest.NewRecorder()
+	s.rulesAPIHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	rules, err = server.LoadRules(ctx, s.CacheClient)
+	assert.NoError(t, err)
+	assert.Equal(t, []server.Rule{
+		{Name: "sponsored", Action: server.RuleActionPin, ItemIds: []string{"2"}, Position: 3},
+	}, rules)
+}
+
+func TestMaster_APIKeys(t *testing.T) {
+	s, _ := newMockServer(t)
+	defer s.Close(t)
+	s.Config.Master.AdminAPIKey = "admin"
+	ctx := context.Background()
+
+	// unauthorized
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	w := httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	// empty keys
+	req = httptest.NewRequest("GET", "https://example.com/?X-API-Key=admin", nil)
+	w = httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+	// create keys
+	plaintexts := make(map[string]string)
+	for _, key := range []server.APIKey{
+		{Name: "app", Scopes: []string{server.ScopeRecommend}},
+		{Name: "etl", Scopes: []string{server.ScopeFeedback}, RateLimit: 100, Burst: 10},
+		{Name: "app", Scopes: []string{server.ScopeRecommend, server.ScopeFeedback}},
+	} {
+		req = httptest.NewRequest("POST", "https://example.com/?X-API-Key=admin", strings.NewReader(marshal(t, key)))
+		w = httptest.NewRecorder()
+		s.apiKeysAPIHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var created CreatedAPIKey
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, key.Name, created.Name)
+		assert.NotEmpty(t, created.Key)
+		plaintexts[created.Name] = created.Key
+	}
+	keys, err := server.LoadAPIKeys(ctx, s.CacheClient)
+	assert.NoError(t, err)
+	assert.Equal(t, []server.APIKey{
+		{Name: "etl", Hash: server.HashAPIKey(plaintexts["etl"]), Scopes: []string{server.ScopeFeedback}, RateLimit: 100, Burst: 10},
+		{Name: "app", Hash: server.HashAPIKey(plaintexts["app"]), Scopes: []string{server.ScopeRecommend, server.ScopeFeedback}},
+	}, keys)
+	// list keys without hashes
+	req = httptest.NewRequest("GET", "https://example.com/?X-API-Key=admin", nil)
+	w = httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, marshal(t, []server.APIKey{
+		{Name: "etl", Scopes: []string{server.ScopeFeedback}, RateLimit: 100, Burst: 10},
+		{Name: "app", Scopes: []string{server.ScopeRecommend, server.ScopeFeedback}},
+	}), w.Body.String())
+	assert.NotContains(t, w.Body.String(), "Hash")
+	// create invalid key
+	req = httptest.NewRequest("POST", "https://example.com/?X-API-Key=admin",
+		strings.NewReader(marshal(t, server.APIKey{Name: "invalid"})))
+	w = httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// revoke key
+	req = httptest.NewRequest("DELETE", "https://example.com/?X-API-Key=admin&name=app", nil)
+	w = httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	req = httptest.NewRequest("DELETE", "https://example.com/?X-API-Key=admin&name=unknown", nil)
+	w = httptest.NewRecorder()
+	s.apiKeysAPIHandler(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	keys, err = server.LoadAPIKeys(ctx, s.CacheClient)
+	assert.NoError(t, err)
+	assert.False(t, keys[0].Revoked)
+	assert.True(t, keys[1].Revoked)
+}
+
 func TestMaster_GetConfig(t *testing.T) {
 	s, cookie := newMockServer(t)
 	defer s.Close(t)
@@ -991,3 +1219,75 @@ func TestMaster_TokenLogin(t *testing.T) {
 		Status(http.StatusOK).
 		End()
 }
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"rsa","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(marshal(t, claims)))
+	digest := sha256.Sum256([]byte(header + "." + payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestMaster_JWTLogin(t *testing.T) {
+	s, _ := newMockServer(t)
+	defer s.Close(t)
+
+	// write JWKS
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := marshal(t, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "rsa",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	s.Config.JWT.JWKS = filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(s.Config.JWT.JWKS, []byte(jwks), 0644))
+	s.Config.JWT.RolesClaim = "groups"
+	s.Config.JWT.DashboardRoles = []string{"gorse-admin"}
+	adminToken := signJWT(t, key, map[string]any{"sub": "alice", "groups": []string{"gorse-admin"}, "exp": time.Now().Add(time.Hour).Unix()})
+	userToken := signJWT(t, key, map[string]any{"sub": "bob", "groups": []string{"users"}, "exp": time.Now().Add(time.Hour).Unix()})
+
+	// login fail
+	req := httptest.NewRequest("POST", "https://example.com/",
+		strings.NewReader("token="+userToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.login(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+
+	// login success
+	req = httptest.NewRequest("POST", "https://example.com/",
+		strings.NewReader("token="+adminToken))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	s.login(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Set-Cookie"))
+
+	// validate cookie
+	apitest.New().
+		Handler(s.handler).
+		Get("/api/dashboard/config").
+		Header("Cookie", w.Header().Get("Set-Cookie")).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	// validate bearer tokens
+	apitest.New().
+		Handler(s.handler).
+		Get("/api/dashboard/config").
+		Header("Authorization", "Bearer "+adminToken).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(s.handler).
+		Get("/api/dashboard/config").
+		Header("Authorization", "Bearer "+userToken).
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		End()
+}
@@ -17,6 +17,7 @@ package master
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -33,8 +34,10 @@ import (
 	"github.com/zhenghaoz/gorse/base/search"
 	"github.com/zhenghaoz/gorse/base/task"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model/bandit"
 	"github.com/zhenghaoz/gorse/model/click"
 	"github.com/zhenghaoz/gorse/model/ranking"
+	"github.com/zhenghaoz/gorse/server"
 	"github.com/zhenghaoz/gorse/storage/cache"
 	"github.com/zhenghaoz/gorse/storage/data"
 	"go.uber.org/atomic"
@@ -43,8 +46,12 @@ import (
 	"modernc.org/sortutil"
 )
 
+// exploredItemsTTL is the time-to-live of explored items rewarding exploration sources.
+const exploredItemsTTL = 30 * 24 * time.Hour
+
 const (
-	PositiveFeedbackRate = "PositiveFeedbackRate"
+	PositiveFeedbackRate         = "PositiveFeedbackRate"
+	WeightedPositiveFeedbackRate = "WeightedPositiveFeedbackRate"
 
 	TaskLoadDataset            = "Load dataset"
 	TaskFindItemNeighbors      = "Find neighbors of items"
@@ -75,7 +82,7 @@ func (m *Master) runLoadDatasetTask() error {
 		zap.Uint("item_ttl", m.Config.Recommend.DataSource.ItemTTL),
 		zap.Uint("feedback_ttl", m.Config.Recommend.DataSource.PositiveFeedbackTTL))
 	evaluator := NewOnlineEvaluator()
-	rankingDataset, clickDataset, latestItems, popularItems, err := m.LoadDataFromDatabase(m.DataClient,
+	rankingDataset, clickDataset, latestItems, popularItems, trendingItems, banditState, err := m.LoadDataFromDatabase(m.DataClient,
 		m.Config.Recommend.DataSource.PositiveFeedbackTypes,
 		m.Config.Recommend.DataSource.ReadFeedbackTypes,
 		m.Config.Recommend.DataSource.ItemTTL,
@@ -96,6 +103,17 @@ func (m *Master) runLoadDatasetTask() error {
 		log.Logger().Error("failed to write latest update popular items time", zap.Error(err))
 	}
 
+	// save trending items to cache
+	if err = m.CacheClient.AddDocuments(ctx, cache.TrendingItems, "", trendingItems.ToSlice()); err != nil {
+		log.Logger().Error("failed to cache trending items", zap.Error(err))
+	}
+	if err = m.CacheClient.DeleteDocuments(ctx, []string{cache.TrendingItems}, cache.DocumentCondition{Before: &trendingItems.Timestamp}); err != nil {
+		log.Logger().Error("failed to reclaim outdated items", zap.Error(err))
+	}
+	if err = m.CacheClient.Set(ctx, cache.Time(cache.Key(cache.GlobalMeta, cache.LastUpdateTrendingItemsTime), time.Now())); err != nil {
+		log.Logger().Error("failed to write latest update trending items time", zap.Error(err))
+	}
+
 	// save the latest items to cache
 	if err = m.CacheClient.AddDocuments(ctx, cache.LatestItems, "", latestItems.ToSlice()); err != nil {
 		log.Logger().Error("failed to cache latest items", zap.Error(err))
@@ -107,6 +125,15 @@ func (m *Master) runLoadDatasetTask() error {
 		log.Logger().Error("failed to write latest update latest items time", zap.Error(err))
 	}
 
+	// save bandit state to cache
+	if err = bandit.SaveState(ctx, m.CacheClient, banditState); err != nil {
+		log.Logger().Error("failed to cache bandit state", zap.Error(err))
+	}
+	exploredTime := time.Now().Add(-exploredItemsTTL)
+	if err = m.CacheClient.DeleteDocuments(ctx, []string{cache.ExploredItems}, cache.DocumentCondition{Before: &exploredTime}); err != nil {
+		log.Logger().Error("failed to reclaim outdated explored items", zap.Error(err))
+	}
+
 	// write statistics to database
 	UsersTotal.Set(float64(rankingDataset.UserCount()))
 	if err = m.CacheClient.Set(ctx, cache.Integer(cache.Key(cache.GlobalMeta, cache.NumUsers), rankingDataset.UserCount())); err != nil {
@@ -140,9 +167,11 @@ func (m *Master) runLoadDatasetTask() error {
 
 	// evaluate positive feedback rate
 	points := evaluator.Evaluate()
+	points = append(points, evaluator.EvaluateExperiments(m.Config.Recommend.Experiments, rankingDataset.UserIndex)...)
 	if err = m.CacheClient.AddTimeSeriesPoints(ctx, points); err != nil {
 		log.Logger().Error("failed to insert measurement", zap.Error(err))
 	}
+	m.notifyPositiveRateDropped(evaluator)
 
 	// collect active users and items
 	activeUsers, activeItems, inactiveUsers, inactiveItems := 0, 0, 0, 0
@@ -404,6 +433,7 @@ func (m *Master) findItemNeighborsBruteForce(dataset *ranking.DataSet, labeledIt
 			}
 			aggregator.Add(category, recommends, scores)
 		}
+		aggregator.Locate(locateItems(dataset))
 		if err := m.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, itemId, aggregator.ToSlice()); err != nil {
 			return errors.Trace(err)
 		}
@@ -508,6 +538,7 @@ func (m *Master) findItemNeighborsIVF(dataset *ranking.DataSet, labelIDF, userID
 				aggregator.Add(category, resultValues, resultScores)
 			}
 		}
+		aggregator.Locate(locateItems(dataset))
 		if err := m.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, itemId, aggregator.ToSlice()); err != nil {
 			return errors.Trace(err)
 		}
@@ -1053,9 +1084,11 @@ func (t *FitRankingModelTask) run(j *task.JobsAllocator) error {
 	t.RankingModel = rankingModel
 	t.RankingModelVersion++
 	t.rankingScore = score
+	rankingModelName, rankingModelVersion := t.rankingModelName, t.RankingModelVersion
 	t.rankingModelMutex.Unlock()
 	log.Logger().Info("fit ranking model complete",
-		zap.String("version", fmt.Sprintf("%x", t.RankingModelVersion)))
+		zap.String("version", fmt.Sprintf("%x", rankingModelVersion)))
+	t.notifyModelUpdated("ranking", rankingModelName, rankingModelVersion, score)
 	CollaborativeFilteringNDCG10.Set(float64(score.NDCG))
 	CollaborativeFilteringRecall10.Set(float64(score.Recall))
 	CollaborativeFilteringPrecision10.Set(float64(score.Precision))
@@ -1140,7 +1173,7 @@ func (t *FitClickModelTask) run(j *task.JobsAllocator) error {
 		// 1. best click model must have been found.
 		// 2. best click model must be different from current model
 		// 3. best click model must perform better than current model
-		t.ClickModel = bestClickModel
+		t.SetClickModel(bestClickModel)
 		t.clickScore = bestClickScore
 		shouldFit = true
 		log.Logger().Info("find better click model",
@@ -1164,16 +1197,18 @@ func (t *FitClickModelTask) run(j *task.JobsAllocator) error {
 
 	// update match model
 	t.clickModelMutex.Lock()
-	t.ClickModel = clickModel
+	t.SetClickModel(clickModel)
 	t.clickScore = score
 	t.ClickModelVersion++
+	clickModelVersion := t.ClickModelVersion
 	t.clickModelMutex.Unlock()
 	log.Logger().Info("fit click model complete",
-		zap.String("version", fmt.Sprintf("%x", t.ClickModelVersion)))
+		zap.String("version", fmt.Sprintf("%x", clickModelVersion)))
+	t.notifyModelUpdated("click", "fm", clickModelVersion, score)
 	RankingPrecision.Set(float64(score.Precision))
 	RankingRecall.Set(float64(score.Recall))
 	RankingAUC.Set(float64(score.AUC))
-	MemoryInUseBytesVec.WithLabelValues("ranking_model").Set(float64(t.ClickModel.Bytes()))
+	MemoryInUseBytesVec.WithLabelValues("ranking_model").Set(float64(clickModel.Bytes()))
 	if err := t.CacheClient.Set(ctx, cache.Time(cache.Key(cache.GlobalMeta, cache.LastFitRankingModelTime), time.Now())); err != nil {
 		log.Logger().Error("failed to write meta", zap.Error(err))
 	}
@@ -1412,6 +1447,22 @@ func (t *CacheGarbageCollectionTask) run(j *task.JobsAllocator) error {
 				return errors.Trace(err)
 			}
 			reclaimCount++
+		case cache.IdempotencyKeys:
+			// delete expired results of requests with idempotency keys
+			key := strings.TrimPrefix(s, cache.IdempotencyKeys+"/")
+			_, err := server.LoadIdempotencyRecord(ctx, t.CacheClient, key, t.Config.Server.IdempotencyKeyTTL)
+			if errors.Is(err, errors.NotValid) {
+				log.Logger().Warn("delete corrupted idempotency record", zap.String("key", key), zap.Error(err))
+			} else if !errors.Is(err, errors.NotFound) {
+				if err != nil {
+					log.Logger().Error("failed to load idempotency record", zap.String("key", key), zap.Error(err))
+				}
+				return err
+			}
+			if err = t.CacheClient.Delete(ctx, s); err != nil {
+				return errors.Trace(err)
+			}
+			reclaimCount++
 		}
 		return nil
 	})
@@ -1424,7 +1475,8 @@ func (t *CacheGarbageCollectionTask) run(j *task.JobsAllocator) error {
 
 // LoadDataFromDatabase loads dataset from data store.
 func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes, readTypes []string, itemTTL, positiveFeedbackTTL uint, evaluator *OnlineEvaluator) (
-	rankingDataset *ranking.DataSet, clickDataset *click.Dataset, latestItems *cache.DocumentAggregator, popularItems *cache.DocumentAggregator, err error) {
+	rankingDataset *ranking.DataSet, clickDataset *click.Dataset, latestItems *cache.DocumentAggregator, popularItems *cache.DocumentAggregator,
+	trendingItems *cache.DocumentAggregator, banditState *bandit.State, err error) {
 	startLoadTime := time.Now()
 	m.taskMonitor.Start(TaskLoadDataset, 5)
 	ctx := context.Background()
@@ -1442,6 +1494,8 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 	if m.Config.Recommend.Popular.PopularWindow > 0 {
 		timeWindowLimit = time.Now().Add(-m.Config.Recommend.Popular.PopularWindow)
 	}
+	trendingNow := time.Now()
+	baselineWindowLimit := trendingNow.Add(-m.Config.Recommend.Trending.BaselineWindow)
 	rankingDataset = ranking.NewMapIndexDataset()
 
 	// create filers for latest items
@@ -1484,7 +1538,7 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 		}
 	}
 	if err = <-errChan; err != nil {
-		return nil, nil, nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, nil, nil, errors.Trace(err)
 	}
 	rankingDataset.NumUserLabels = userLabelIndex.Len()
 	m.taskMonitor.Update(TaskLoadDataset, 1)
@@ -1507,6 +1561,8 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 			if len(rankingDataset.ItemLabels) == int(itemIndex) {
 				rankingDataset.ItemLabels = append(rankingDataset.ItemLabels, nil)
 				rankingDataset.HiddenItems = append(rankingDataset.HiddenItems, false)
+				// items belong to ancestors of their categories
+				item.Categories = data.ExpandCategories(item.Categories)
 				rankingDataset.ItemCategories = append(rankingDataset.ItemCategories, item.Categories)
 				rankingDataset.CategorySet.Append(item.Categories...)
 			}
@@ -1530,11 +1586,14 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 					rankingDataset.ItemLabels[itemIndex] = append(rankingDataset.ItemLabels[itemIndex], itemLabelIndex.ToNumber(label))
 				}
 			}
+			if latitude, longitude, ok := data.ParseLocation(item.Labels); ok {
+				rankingDataset.SetItemLocation(item.ItemId, latitude, longitude)
+			}
 			if item.IsHidden { // set hidden flag
 				rankingDataset.HiddenItems[itemIndex] = true
 			} else if !item.Timestamp.IsZero() { // add items to the latest items filter
 				latestItemsFilters[""].Push(item.ItemId, float64(item.Timestamp.Unix()))
-				for _, category := range item.Categories {
+				for _, category := range rankingDataset.ItemCategories[itemIndex] {
 					if _, exist := latestItemsFilters[category]; !exist {
 						latestItemsFilters[category] = heap.NewTopKFilter[string, float64](m.Config.Recommend.CacheSize)
 					}
@@ -1544,7 +1603,7 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 		}
 	}
 	if err = <-errChan; err != nil {
-		return nil, nil, nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, nil, nil, errors.Trace(err)
 	}
 	rankingDataset.NumItemLabels = itemLabelIndex.Len()
 	m.taskMonitor.Update(TaskLoadDataset, 2)
@@ -1555,11 +1614,18 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 	LoadDatasetStepSecondsVec.WithLabelValues("load_items").Set(time.Since(start).Seconds())
 
 	// create positive set
-	popularCount := make([]int32, rankingDataset.ItemCount())
-	positiveSet := make([]mapset.Set[int32], rankingDataset.UserCount())
+	popularCount := make([]float64, rankingDataset.ItemCount())
+	trendingCount := make([]float64, rankingDataset.ItemCount())
+	baselineCount := make([]float64, rankingDataset.ItemCount())
+	positiveSet := make([]map[int32]float32, rankingDataset.UserCount())
 	for i := range positiveSet {
-		positiveSet[i] = mapset.NewSet[int32]()
+		positiveSet[i] = make(map[int32]float32)
 	}
+	isWeighted := m.Config.Recommend.DataSource.EnableFeedbackValue || len(m.Config.Recommend.DataSource.FeedbackTypeWeights) > 0
+	// context labels of feedback are features of the click model
+	ctxLabelIndex := base.NewMapIndex()
+	positiveContexts := make([]map[int32][]string, rankingDataset.UserCount())
+	negativeContexts := make([]map[int32][]string, rankingDataset.UserCount())
 
 	// STEP 3: pull positive feedback
 	var feedbackCount float64
@@ -1568,7 +1634,11 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 	for feedback := range feedbackChan {
 		for _, f := range feedback {
 			feedbackCount++
-			rankingDataset.AddFeedback(f.UserId, f.ItemId, false)
+			weight := m.Config.Recommend.DataSource.FeedbackTypeWeight(f.FeedbackType)
+			if m.Config.Recommend.DataSource.EnableFeedbackValue && f.Value > 0 {
+				weight *= f.Value
+			}
+			rankingDataset.AddWeightedFeedback(f.UserId, f.ItemId, float32(weight), false)
 			// insert feedback to positive set
 			userIndex := rankingDataset.UserIndex.ToNumber(f.UserId)
 			if userIndex == base.NotId {
@@ -1578,16 +1648,37 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 			if itemIndex == base.NotId {
 				continue
 			}
-			positiveSet[userIndex].Add(itemIndex)
+			// keep the largest weight if an item received multiple types of positive feedback
+			if float32(weight) > positiveSet[userIndex][itemIndex] {
+				positiveSet[userIndex][itemIndex] = float32(weight)
+			}
+			// insert context labels of feedback
+			if len(f.ContextLabels) > 0 {
+				if positiveContexts[userIndex] == nil {
+					positiveContexts[userIndex] = make(map[int32][]string)
+				}
+				positiveContexts[userIndex][itemIndex] = f.ContextLabels
+				for _, label := range f.ContextLabels {
+					ctxLabelIndex.Add(label)
+				}
+			}
 			// insert feedback to popularity counter
 			if f.Timestamp.After(timeWindowLimit) && !rankingDataset.HiddenItems[itemIndex] {
-				popularCount[itemIndex]++
+				popularCount[itemIndex] += weight
 			}
-			evaluator.Positive(f.FeedbackType, userIndex, itemIndex, f.Timestamp)
+			// insert feedback to trending counters
+			if m.Config.Recommend.Trending.HalfLife > 0 && !rankingDataset.HiddenItems[itemIndex] {
+				age := math.Max(trendingNow.Sub(f.Timestamp).Hours(), 0)
+				trendingCount[itemIndex] += weight * math.Pow(0.5, age/m.Config.Recommend.Trending.HalfLife.Hours())
+				if f.Timestamp.After(baselineWindowLimit) {
+					baselineCount[itemIndex] += weight
+				}
+			}
+			evaluator.Positive(f.FeedbackType, userIndex, itemIndex, m.Config.Recommend.DataSource.FeedbackTypeWeight(f.FeedbackType), f.Timestamp)
 		}
 	}
 	if err = <-errChan; err != nil {
-		return nil, nil, nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, nil, nil, errors.Trace(err)
 	}
 	m.taskMonitor.Update(TaskLoadDataset, 3)
 	log.Logger().Debug("pulled positive feedback from database",
@@ -1603,7 +1694,13 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 
 	// STEP 4: pull negative feedback
 	start = time.Now()
-	feedbackChan, errChan = database.GetFeedbackStream(ctx, batchSize, feedbackTimeLimit, m.Config.Now(), readTypes...)
+	negativeTypes := m.Config.Recommend.DataSource.NegativeFeedbackTypes
+	negativeFeedbackTypes := readTypes
+	if len(readTypes) > 0 {
+		// all feedback is pulled if read types are empty
+		negativeFeedbackTypes = lo.Union(readTypes, negativeTypes)
+	}
+	feedbackChan, errChan = database.GetFeedbackStream(ctx, batchSize, feedbackTimeLimit, m.Config.Now(), negativeFeedbackTypes...)
 	for feedback := range feedbackChan {
 		for _, f := range feedback {
 			feedbackCount++
@@ -1615,14 +1712,28 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 			if itemIndex == base.NotId {
 				continue
 			}
-			if !positiveSet[userIndex].Contains(itemIndex) {
+			if _, exist := positiveSet[userIndex][itemIndex]; !exist {
 				negativeSet[userIndex].Add(itemIndex)
+				// items with negative feedback are hard negatives for collaborative filtering
+				if lo.Contains(negativeTypes, f.FeedbackType) {
+					rankingDataset.AddHardNegative(f.UserId, f.ItemId)
+				}
+				// insert context labels of feedback
+				if len(f.ContextLabels) > 0 {
+					if negativeContexts[userIndex] == nil {
+						negativeContexts[userIndex] = make(map[int32][]string)
+					}
+					negativeContexts[userIndex][itemIndex] = f.ContextLabels
+					for _, label := range f.ContextLabels {
+						ctxLabelIndex.Add(label)
+					}
+				}
 			}
 			evaluator.Read(userIndex, itemIndex, f.Timestamp)
 		}
 	}
 	if err = <-errChan; err != nil {
-		return nil, nil, nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, nil, nil, errors.Trace(err)
 	}
 	m.taskMonitor.Update(TaskLoadDataset, 4)
 	FeedbacksTotal.Set(feedbackCount)
@@ -1630,6 +1741,36 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 		zap.Duration("used_time", time.Since(start)))
 	LoadDatasetStepSecondsVec.WithLabelValues("load_negative_feedback").Set(time.Since(start).Seconds())
 
+	// learn rewards of exploration sources from feedback on explored items, and the explored latest items are arms of
+	// new items. Users who read explored items and gave positive feedback to them are trials and rewards of bandits.
+	banditState = bandit.NewState(startLoadTime)
+	if m.Config.Recommend.Bandit.Enabled() {
+		for userIndex, userId := range rankingDataset.UserIndex.GetNames() {
+			exploredItems, err := m.CacheClient.SearchDocuments(ctx, cache.ExploredItems, userId, []string{""}, 0, -1)
+			if err != nil {
+				return nil, nil, nil, nil, nil, nil, errors.Trace(err)
+			}
+			for _, item := range exploredItems {
+				itemIndex := rankingDataset.ItemIndex.ToNumber(item.Id)
+				if itemIndex == base.NotId {
+					continue
+				}
+				var arm bandit.Arm
+				if _, exist := positiveSet[userIndex][itemIndex]; exist {
+					arm = bandit.Arm{Trials: 1, Rewards: 1}
+				} else if negativeSet[userIndex].Contains(itemIndex) {
+					arm = bandit.Arm{Trials: 1}
+				} else {
+					continue
+				}
+				banditState.Sources[item.Source] = banditState.Sources[item.Source].Add(arm)
+				if item.Source == "latest" {
+					banditState.Items[item.Id] = banditState.Items[item.Id].Add(arm)
+				}
+			}
+		}
+	}
+
 	// STEP 5: create click dataset
 	start = time.Now()
 	unifiedIndex := click.NewUnifiedMapIndexBuilder()
@@ -1637,24 +1778,42 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 	unifiedIndex.UserIndex = rankingDataset.UserIndex
 	unifiedIndex.ItemLabelIndex = itemLabelIndex
 	unifiedIndex.UserLabelIndex = userLabelIndex
+	unifiedIndex.CtxLabelIndex = ctxLabelIndex
 	clickDataset = &click.Dataset{
 		Index:        unifiedIndex.Build(),
 		UserFeatures: rankingDataset.UserLabels,
 		ItemFeatures: rankingDataset.ItemLabels,
 	}
+	appendContext := func(labels []string) {
+		if ctxLabelIndex.Len() == 0 {
+			return
+		}
+		features := make([]int32, 0, len(labels))
+		values := make([]float32, 0, len(labels))
+		for _, label := range labels {
+			features = append(features, clickDataset.Index.EncodeContextLabel(label))
+			values = append(values, 1)
+		}
+		clickDataset.CtxFeatures = append(clickDataset.CtxFeatures, features)
+		clickDataset.CtxValues = append(clickDataset.CtxValues, values)
+	}
 	for userIndex := range positiveSet {
-		if positiveSet[userIndex].Cardinality() == 0 || negativeSet[userIndex].Cardinality() == 0 {
+		if len(positiveSet[userIndex]) == 0 || negativeSet[userIndex].Cardinality() == 0 {
 			// release positive set and negative set
 			positiveSet[userIndex] = nil
 			negativeSet[userIndex] = nil
 			continue
 		}
 		// insert positive feedback
-		for _, itemIndex := range positiveSet[userIndex].ToSlice() {
+		for itemIndex, weight := range positiveSet[userIndex] {
 			clickDataset.Users.Append(int32(userIndex))
 			clickDataset.Items.Append(itemIndex)
 			clickDataset.NormValues.Append(1 / math32.Sqrt(float32(len(clickDataset.UserFeatures[userIndex])+len(clickDataset.ItemFeatures[itemIndex]))))
 			clickDataset.Target.Append(1)
+			if isWeighted {
+				clickDataset.Weights.Append(weight)
+			}
+			appendContext(positiveContexts[userIndex][itemIndex])
 			clickDataset.PositiveCount++
 		}
 		// insert negative feedback
@@ -1663,11 +1822,17 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 			clickDataset.Items.Append(itemIndex)
 			clickDataset.NormValues.Append(1 / math32.Sqrt(float32(len(clickDataset.UserFeatures[userIndex])+len(clickDataset.ItemFeatures[itemIndex]))))
 			clickDataset.Target.Append(-1)
+			if isWeighted {
+				clickDataset.Weights.Append(1)
+			}
+			appendContext(negativeContexts[userIndex][itemIndex])
 			clickDataset.NegativeCount++
 		}
 		// release positive set and negative set
 		positiveSet[userIndex] = nil
 		negativeSet[userIndex] = nil
+		positiveContexts[userIndex] = nil
+		negativeContexts[userIndex] = nil
 	}
 	log.Logger().Debug("created ranking dataset",
 		zap.Int("n_valid_positive", clickDataset.PositiveCount),
@@ -1688,12 +1853,12 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 	popularItemFilters[""] = heap.NewTopKFilter[string, float64](m.Config.Recommend.CacheSize)
 	for itemIndex, val := range popularCount {
 		itemId := rankingDataset.ItemIndex.ToName(int32(itemIndex))
-		popularItemFilters[""].Push(itemId, float64(val))
+		popularItemFilters[""].Push(itemId, val)
 		for _, category := range rankingDataset.ItemCategories[itemIndex] {
 			if _, exist := popularItemFilters[category]; !exist {
 				popularItemFilters[category] = heap.NewTopKFilter[string, float64](m.Config.Recommend.CacheSize)
 			}
-			popularItemFilters[category].Push(itemId, float64(val))
+			popularItemFilters[category].Push(itemId, val)
 		}
 	}
 	popularItems = cache.NewDocumentAggregator(startLoadTime)
@@ -1702,6 +1867,59 @@ func (m *Master) LoadDataFromDatabase(database data.Database, posFeedbackTypes,
 		popularItems.Add(category, items, scores)
 	}
 
+	// collect trending items
+	trendingItemFilters := make(map[string]*heap.TopKFilter[string, float64])
+	trendingItemFilters[""] = heap.NewTopKFilter[string, float64](m.Config.Recommend.CacheSize)
+	for itemIndex, val := range trendingCount {
+		if val <= 0 {
+			continue
+		}
+		itemId := rankingDataset.ItemIndex.ToName(int32(itemIndex))
+		score := trendingScore(val, baselineCount[itemIndex], m.Config.Recommend.Trending.HalfLife, m.Config.Recommend.Trending.BaselineWindow)
+		trendingItemFilters[""].Push(itemId, score)
+		for _, category := range rankingDataset.ItemCategories[itemIndex] {
+			if _, exist := trendingItemFilters[category]; !exist {
+				trendingItemFilters[category] = heap.NewTopKFilter[string, float64](m.Config.Recommend.CacheSize)
+			}
+			trendingItemFilters[category].Push(itemId, score)
+		}
+	}
+	trendingItems = cache.NewDocumentAggregator(startLoadTime)
+	for category, trendingItemFilter := range trendingItemFilters {
+		items, scores := trendingItemFilter.PopAll()
+		trendingItems.Add(category, items, scores)
+	}
+
+	// locate items
+	latestItems.Locate(locateItems(rankingDataset))
+	popularItems.Locate(locateItems(rankingDataset))
+	trendingItems.Locate(locateItems(rankingDataset))
+
 	m.taskMonitor.Finish(TaskLoadDataset)
-	return rankingDataset, clickDataset, latestItems, popularItems, nil
+	return rankingDataset, clickDataset, latestItems, popularItems, trendingItems, banditState, nil
+}
+
+// locateItems returns a function returning locations of items in a dataset.
+func locateItems(dataset *ranking.DataSet) func(string) *cache.Location {
+	return func(itemId string) *cache.Location {
+		if latitude, longitude, ok := dataset.ItemLocation(dataset.ItemIndex.ToNumber(itemId)); ok {
+			return &cache.Location{Latitude: latitude, Longitude: longitude}
+		}
+		return nil
+	}
+}
+
+// trendingScore computes the trending score of an item from the exponentially decayed count of its feedback and the
+// count of its feedback in the baseline window. The velocity is the ratio of the decayed count to the decayed count
+// expected at the average feedback rate in the baseline window, and the trending score is the decayed count multiplied
+// by the velocity. The expected count is smoothed by one so that items without history are not overrated.
+func trendingScore(decayedCount, baselineCount float64, halfLife, baselineWindow time.Duration) float64 {
+	var expectedCount float64
+	if baselineWindow > 0 {
+		// the decayed count of feedback arriving at a constant rate is the rate multiplied by the mean lifetime
+		meanLifetime := halfLife.Hours() / math.Ln2
+		expectedCount = baselineCount / baselineWindow.Hours() * meanLifetime
+	}
+	velocity := decayedCount / (expectedCount + 1)
+	return decayedCount * velocity
 }
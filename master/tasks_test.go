@@ -16,12 +16,16 @@ package master
 
 import (
 	"context"
+	"math"
 	"strconv"
+	"testing"
 	"time"
 
 	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
 	"github.com/zhenghaoz/gorse/base/task"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model/bandit"
 	"github.com/zhenghaoz/gorse/storage/cache"
 	"github.com/zhenghaoz/gorse/storage/data"
 )
@@ -81,7 +85,7 @@ func (s *MasterTestSuite) TestFindItemNeighborsBruteForce() {
 	}
 
 	// load mock dataset
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -192,7 +196,7 @@ func (s *MasterTestSuite) TestFindItemNeighborsIVF() {
 	}
 
 	// load mock dataset
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -265,7 +269,7 @@ func (s *MasterTestSuite) TestFindItemNeighborsIVF_ZeroIDF() {
 		{FeedbackKey: data.FeedbackKey{FeedbackType: "FeedbackType", UserId: "0", ItemId: "1"}},
 	}, true, true, true)
 	s.NoError(err)
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -325,7 +329,7 @@ func (s *MasterTestSuite) TestFindUserNeighborsBruteForce() {
 	s.NoError(err)
 	err = s.DataClient.BatchInsertFeedback(ctx, feedbacks, true, true, true)
 	s.NoError(err)
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -411,7 +415,7 @@ func (s *MasterTestSuite) TestFindUserNeighborsIVF() {
 	s.NoError(err)
 	err = s.DataClient.BatchInsertFeedback(ctx, feedbacks, true, true, true)
 	s.NoError(err)
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -476,7 +480,7 @@ func (s *MasterTestSuite) TestFindUserNeighborsIVF_ZeroIDF() {
 		{FeedbackKey: data.FeedbackKey{FeedbackType: "FeedbackType", UserId: "1", ItemId: "0"}},
 	}, true, true, true)
 	s.NoError(err)
-	dataset, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"FeedbackType"}, nil, 0, 0, NewOnlineEvaluator())
 	s.NoError(err)
 	s.rankingTrainSet = dataset
 
@@ -636,6 +640,328 @@ func (s *MasterTestSuite) TestLoadDataFromDatabase() {
 	s.Equal([]string{"0", "1", "2"}, categories)
 }
 
+func (s *MasterTestSuite) TestLoadDataFromDatabase_FeedbackTypeWeights() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 3
+	s.Config.Recommend.DataSource.FeedbackTypeWeights = map[string]float64{"like": 1, "share": 5}
+
+	// insert items and users
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "0"}, {ItemId: "1"}, {ItemId: "2"}})
+	s.NoError(err)
+	err = s.DataClient.BatchInsertUsers(ctx, []data.User{{UserId: "0"}, {UserId: "1"}, {UserId: "2"}})
+	s.NoError(err)
+
+	// insert feedback
+	// item 0: like by user 0, 1, 2
+	// item 1: share by user 0, like by user 0
+	// item 2: like by user 0
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "1", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "2", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "share", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "2"}, Timestamp: time.Now()},
+	}, false, false, true)
+	s.NoError(err)
+
+	// load dataset
+	dataset, _, _, popularItems, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like", "share"}, nil, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	s.Equal(6, dataset.Count())
+	s.True(dataset.IsWeighted())
+	userIndex := dataset.UserIndex.ToNumber("0")
+	weights := make(map[string]float32)
+	for k, itemIndex := range dataset.UserFeedback[userIndex] {
+		weights[dataset.ItemIndex.ToName(itemIndex)] += dataset.UserFeedbackWeight(userIndex, k)
+	}
+	s.Equal(map[string]float32{"0": 1, "1": 6, "2": 1}, weights)
+
+	// check popular items
+	popular := make(map[string]float64)
+	for _, document := range popularItems.ToSlice() {
+		popular[document.Id] = document.Score
+	}
+	s.Equal(map[string]float64{"0": 3, "1": 6, "2": 1}, popular)
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_NegativeFeedback() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 3
+	s.Config.Recommend.DataSource.NegativeFeedbackTypes = []string{"dislike"}
+
+	// insert items and users
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "0"}, {ItemId: "1"}, {ItemId: "2"}})
+	s.NoError(err)
+	err = s.DataClient.BatchInsertUsers(ctx, []data.User{{UserId: "0"}, {UserId: "1"}})
+	s.NoError(err)
+
+	// insert feedback
+	// user 0: like item 0, dislike item 1, read item 2
+	// user 1: like and dislike item 0
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "dislike", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "2"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "1", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "dislike", UserId: "1", ItemId: "0"}, Timestamp: time.Now()},
+	}, false, false, true)
+	s.NoError(err)
+
+	// load dataset
+	dataset, _, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, []string{"read"}, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	s.Equal(2, dataset.Count())
+	hardNegatives := lo.Map(dataset.UserHardNegatives(dataset.UserIndex.ToNumber("0")), func(itemIndex int32, _ int) string {
+		return dataset.ItemIndex.ToName(itemIndex)
+	})
+	s.Equal([]string{"1"}, hardNegatives)
+	// positive items are not hard negatives
+	s.Empty(dataset.UserHardNegatives(dataset.UserIndex.ToNumber("1")))
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_BanditState() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 2
+	s.Config.Recommend.Bandit.Policy = bandit.ThompsonSampling
+
+	// insert items
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "0", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ItemId: "1", Timestamp: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{ItemId: "2", Timestamp: time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC)},
+	})
+	s.NoError(err)
+
+	// insert feedback
+	// item 0: liked by user 0 and user 1
+	// item 1: read by user 0, liked by user 1
+	// item 2: read by user 2
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "1", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "1", ItemId: "1"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "2", ItemId: "2"}, Timestamp: time.Now()},
+	}, true, false, true)
+	s.NoError(err)
+
+	// insert explored items
+	// user 0: item 0 from popular, item 1 from latest
+	// user 1: item 1 from latest
+	// user 2: item 2 from trending, item 0 from popular
+	for userId, items := range map[string][]cache.Document{
+		"0": {{Id: "0", Source: "popular"}, {Id: "1", Source: "latest"}},
+		"1": {{Id: "1", Source: "latest"}},
+		"2": {{Id: "2", Source: "trending"}, {Id: "0", Source: "popular"}},
+	} {
+		for i := range items {
+			items[i].Categories = []string{""}
+			items[i].Timestamp = time.Now()
+		}
+		err = s.CacheClient.AddDocuments(ctx, cache.ExploredItems, userId, items)
+		s.NoError(err)
+	}
+
+	// load dataset
+	_, _, _, _, _, banditState, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, []string{"read"}, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	// only explored items with feedback are counted
+	s.Equal(map[string]bandit.Arm{
+		"popular":  {Trials: 1, Rewards: 1},
+		"latest":   {Trials: 2, Rewards: 1},
+		"trending": {Trials: 1, Rewards: 0},
+	}, banditState.Sources)
+	s.Equal(map[string]bandit.Arm{
+		"1": {Trials: 2, Rewards: 1},
+	}, banditState.Items)
+
+	// explored items are not learned by the random policy
+	s.Config.Recommend.Bandit.Policy = bandit.Random
+	_, _, _, _, _, banditState, err = s.LoadDataFromDatabase(s.DataClient, []string{"like"}, []string{"read"}, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	s.Empty(banditState.Sources)
+	s.Empty(banditState.Items)
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_ContextLabels() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 2
+
+	// insert items
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "0"}, {ItemId: "1"}, {ItemId: "2"}})
+	s.NoError(err)
+
+	// insert feedback with context labels
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now(), ContextLabels: []string{"device.mobile"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "1"}, Timestamp: time.Now(), ContextLabels: []string{"device.desktop"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "2"}, Timestamp: time.Now()},
+	}, true, false, true)
+	s.NoError(err)
+
+	// load dataset
+	_, clickDataset, _, _, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, []string{"read"}, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	s.ElementsMatch([]string{"device.mobile", "device.desktop"}, clickDataset.Index.GetContextLabels())
+	s.Equal(3, clickDataset.Count())
+	contexts := make(map[int32][]int32)
+	for i := 0; i < clickDataset.Count(); i++ {
+		contexts[clickDataset.Items.Get(i)] = clickDataset.CtxFeatures[i]
+		for _, value := range clickDataset.CtxValues[i] {
+			s.Equal(float32(1), value)
+		}
+	}
+	s.Equal([]int32{clickDataset.Index.EncodeContextLabel("device.mobile")}, contexts[0])
+	s.Equal([]int32{clickDataset.Index.EncodeContextLabel("device.desktop")}, contexts[1])
+	s.Empty(contexts[2])
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_TrendingItems() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 3
+	s.Config.Recommend.Trending.HalfLife = 24 * time.Hour
+	s.Config.Recommend.Trending.BaselineWindow = 7 * 24 * time.Hour
+
+	// insert items and users
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "0", Categories: []string{"a"}}, {ItemId: "1"}, {ItemId: "2", IsHidden: true}})
+	s.NoError(err)
+	var feedback []data.Feedback
+	for i := 0; i < 7; i++ {
+		// item 0: liked once a day in the last week
+		feedback = append(feedback, data.Feedback{
+			FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: strconv.Itoa(i), ItemId: "0"},
+			Timestamp:   time.Now().Add(-time.Duration(i*24+12) * time.Hour),
+		})
+		// item 2: hidden
+		feedback = append(feedback, data.Feedback{
+			FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: strconv.Itoa(i), ItemId: "2"},
+			Timestamp:   time.Now(),
+		})
+	}
+	// item 1: liked twice an hour ago
+	for i := 0; i < 2; i++ {
+		feedback = append(feedback, data.Feedback{
+			FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: strconv.Itoa(i), ItemId: "1"},
+			Timestamp:   time.Now().Add(-time.Hour),
+		})
+	}
+	err = s.DataClient.BatchInsertFeedback(ctx, feedback, true, false, true)
+	s.NoError(err)
+
+	// load dataset
+	_, _, _, popularItems, trendingItems, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, nil, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	popular := make(map[string]float64)
+	for _, document := range popularItems.ToSlice() {
+		popular[document.Id] = document.Score
+	}
+	s.Equal(map[string]float64{"0": 7, "1": 2, "2": 0}, popular)
+	// the item liked recently is trending
+	trending := make(map[string]cache.Document)
+	for _, document := range trendingItems.ToSlice() {
+		trending[document.Id] = document
+	}
+	s.Len(trending, 2)
+	s.Greater(trending["1"].Score, trending["0"].Score)
+	s.ElementsMatch([]string{"", "a"}, trending["0"].Categories)
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_ItemLocations() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 3
+
+	// insert items and feedback
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "0", Timestamp: time.Now(), Labels: map[string]any{"location": "30,120"}},
+		{ItemId: "1", Timestamp: time.Now()},
+	})
+	s.NoError(err)
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+	}, true, false, true)
+	s.NoError(err)
+
+	// load dataset
+	dataset, _, latestItems, popularItems, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, nil, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	latitude, longitude, ok := dataset.ItemLocation(dataset.ItemIndex.ToNumber("0"))
+	s.True(ok)
+	s.Equal(30.0, latitude)
+	s.Equal(120.0, longitude)
+	_, _, ok = dataset.ItemLocation(dataset.ItemIndex.ToNumber("1"))
+	s.False(ok)
+	// locations are not labels of items
+	s.Zero(dataset.NumItemLabelUsed)
+	// documents are located
+	for _, aggregator := range []*cache.DocumentAggregator{latestItems, popularItems} {
+		locations := make(map[string]*cache.Location)
+		for _, document := range aggregator.ToSlice() {
+			locations[document.Id] = document.Location
+		}
+		s.Equal(map[string]*cache.Location{"0": {Latitude: 30, Longitude: 120}, "1": nil}, locations)
+	}
+}
+
+func (s *MasterTestSuite) TestLoadDataFromDatabase_HierarchicalCategories() {
+	ctx := context.Background()
+	// create config
+	s.Config = &config.Config{}
+	s.Config.Recommend.CacheSize = 3
+
+	// insert items and feedback
+	err := s.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "0", Timestamp: time.Now(), Categories: []string{"books/fiction/scifi"}},
+		{ItemId: "1", Timestamp: time.Now(), Categories: []string{"books/history"}},
+	})
+	s.NoError(err)
+	err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "0"}, Timestamp: time.Now()},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "like", UserId: "0", ItemId: "1"}, Timestamp: time.Now()},
+	}, true, false, true)
+	s.NoError(err)
+
+	// items belong to ancestors of their categories
+	dataset, _, latestItems, popularItems, _, _, err := s.LoadDataFromDatabase(s.DataClient, []string{"like"}, nil, 0, 0, NewOnlineEvaluator())
+	s.NoError(err)
+	s.Equal([]string{"books", "books/fiction", "books/fiction/scifi"}, dataset.ItemCategories[dataset.ItemIndex.ToNumber("0")])
+	s.ElementsMatch([]string{"books", "books/fiction", "books/fiction/scifi", "books/history"}, dataset.CategorySet.ToSlice())
+	for _, aggregator := range []*cache.DocumentAggregator{latestItems, popularItems} {
+		categories := make(map[string][]string)
+		for _, document := range aggregator.ToSlice() {
+			categories[document.Id] = document.Categories
+		}
+		s.Equal(map[string][]string{
+			"0": {"", "books", "books/fiction", "books/fiction/scifi"},
+			"1": {"", "books", "books/history"},
+		}, categories)
+	}
+}
+
+func TestTrendingScore(t *testing.T) {
+	// items without history are trending by the decayed count squared
+	assert.Equal(t, 4.0, trendingScore(2, 0, 24*time.Hour, 7*24*time.Hour))
+	// items receiving feedback at the baseline rate have velocities less than one
+	decayedCount := 1 / math.Ln2
+	steady := trendingScore(decayedCount, 7, 24*time.Hour, 7*24*time.Hour)
+	assert.InDelta(t, decayedCount*decayedCount/(decayedCount+1), steady, 1e-6)
+	// items receiving feedback twice as fast as the baseline rate are trending
+	assert.InDelta(t, 4*steady, trendingScore(2*decayedCount, 7, 24*time.Hour, 7*24*time.Hour), 1e-6)
+}
+
 func (s *MasterTestSuite) TestCheckItemNeighborCacheTimeout() {
 	s.Config = config.GetDefaultConfig()
 	ctx := context.Background()
@@ -0,0 +1,195 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package master
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/zhenghaoz/gorse/base/log"
+	"github.com/zhenghaoz/gorse/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// WebhookEventHeader is the header of the event name.
+	WebhookEventHeader = "X-Gorse-Event"
+	// WebhookSignatureHeader is the header of the HMAC-SHA256 signature of the payload.
+	WebhookSignatureHeader = "X-Gorse-Signature"
+)
+
+// webhookTimeout is the timeout of a webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// WebhookPayload is the JSON payload sent to webhooks.
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// ModelUpdatedEvent is sent after a new version of the ranking model or the click model is fitted.
+type ModelUpdatedEvent struct {
+	Model   string `json:"model"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Score   any    `json:"score"`
+}
+
+// TaskFailedEvent is sent after a task of the master fails.
+type TaskFailedEvent struct {
+	Task  string `json:"task"`
+	Error string `json:"error"`
+}
+
+// PositiveRateDroppedEvent is sent if the positive feedback rate of yesterday drops compared to the day before.
+type PositiveRateDroppedEvent struct {
+	FeedbackType string    `json:"feedback_type"`
+	Date         time.Time `json:"date"`
+	Rate         float64   `json:"rate"`
+	PreviousRate float64   `json:"previous_rate"`
+}
+
+// DataImportedEvent is sent after users, items or feedback are imported.
+type DataImportedEvent struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// SignWebhookPayload signs a payload by HMAC-SHA256 with the secret. The signature is in the form of "sha256=<hex>".
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook posts a payload to a webhook. It returns whether the delivery should be retried if it fails.
+func postWebhook(client *http.Client, webhook config.WebhookConfig, event string, payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, event)
+	if webhook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, payload))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	// retry server errors and rate limited deliveries
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, errors.Errorf("webhook %s responded %s", webhook.URL, resp.Status)
+}
+
+// deliverWebhook posts a payload to a webhook. Failed deliveries are retried up to retries times, and the backoff is
+// doubled after each retry.
+func deliverWebhook(webhook config.WebhookConfig, event string, payload []byte, retries int, backoff time.Duration) error {
+	client := &http.Client{Timeout: webhookTimeout}
+	for i := 0; ; i++ {
+		retry, err := postWebhook(client, webhook, event, payload)
+		if err == nil || !retry || i >= retries {
+			return err
+		}
+		log.Logger().Warn("failed to deliver webhook, retry later", zap.String("url", webhook.URL),
+			zap.String("event", event), zap.Duration("backoff", backoff), zap.Error(err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// notify sends an event to webhooks subscribing it in background.
+func (m *Master) notify(event string, data any) {
+	webhooks := m.Config.Master.Webhooks
+	if len(webhooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(WebhookPayload{Event: event, Timestamp: time.Now(), Data: data})
+	if err != nil {
+		log.Logger().Error("failed to marshal webhook payload", zap.String("event", event), zap.Error(err))
+		return
+	}
+	retries, backoff := m.Config.Master.WebhookRetries, m.Config.Master.WebhookBackoff
+	for _, webhook := range webhooks {
+		if webhook.Subscribe(event) {
+			go func(webhook config.WebhookConfig) {
+				if err := deliverWebhook(webhook, event, payload, retries, backoff); err != nil {
+					log.Logger().Error("failed to deliver webhook", zap.String("url", webhook.URL),
+						zap.String("event", event), zap.Error(err))
+				}
+			}(webhook)
+		}
+	}
+}
+
+// notifyPositiveRateDropped sends events if the positive feedback rate of yesterday drops by more than the threshold
+// compared to the day before. A drop is sent once for each feedback type and each day.
+func (m *Master) notifyPositiveRateDropped(evaluator *OnlineEvaluator) {
+	if evaluator.EvaluateDays < 3 {
+		return
+	}
+	if m.positiveRateDroppedDates == nil {
+		m.positiveRateDroppedDates = make(map[string]time.Time)
+	}
+	date := evaluator.TruncatedDateToday.Add(-24 * time.Hour)
+	for feedbackType, positiveFeedbacks := range evaluator.PositiveFeedbacks {
+		if m.positiveRateDroppedDates[feedbackType].Equal(date) {
+			continue
+		}
+		positiveFeedbackSets := evaluator.positiveFeedbackSets(positiveFeedbacks, false)
+		rate := evaluator.positiveFeedbackRate(1, positiveFeedbackSets, nil)
+		previousRate := evaluator.positiveFeedbackRate(2, positiveFeedbackSets, nil)
+		if previousRate > 0 && rate < previousRate*(1-m.Config.Master.PositiveRateDrop) {
+			log.Logger().Warn("positive feedback rate dropped", zap.String("feedback_type", feedbackType),
+				zap.Float64("rate", rate), zap.Float64("previous_rate", previousRate))
+			m.positiveRateDroppedDates[feedbackType] = date
+			m.notify(config.WebhookEventPositiveRateDropped, PositiveRateDroppedEvent{
+				FeedbackType: feedbackType,
+				Date:         date,
+				Rate:         rate,
+				PreviousRate: previousRate,
+			})
+		}
+	}
+}
+
+// notifyTaskFailed sends an event after a task fails.
+func (m *Master) notifyTaskFailed(name, err string) {
+	m.notify(config.WebhookEventTaskFailed, TaskFailedEvent{Task: name, Error: err})
+}
+
+// notifyModelUpdated sends an event after a new version of a model is fitted.
+func (m *Master) notifyModelUpdated(model, name string, version int64, score any) {
+	m.notify(config.WebhookEventModelUpdated, ModelUpdatedEvent{
+		Model:   model,
+		Name:    name,
+		Version: fmt.Sprintf("%x", version),
+		Score:   score,
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/config"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	assert.Equal(t, "sha256=8419ab361b37d61b696d008ef7549a18325132dae5da84c7424e8e1c590d0498",
+		SignWebhookPayload("secret", []byte(`{"event":"test"}`)))
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	var numRequests int32
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", r.Header.Get(WebhookEventHeader))
+		assert.Equal(t, SignWebhookPayload("secret", body), r.Header.Get(WebhookSignatureHeader))
+		switch r.URL.Path {
+		case "/bad_request":
+			atomic.AddInt32(&numRequests, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			// succeed at the third request
+			if atomic.AddInt32(&numRequests, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer webhookServer.Close()
+	webhook := config.WebhookConfig{URL: webhookServer.URL, Secret: "secret"}
+
+	// retry failed deliveries
+	err := deliverWebhook(webhook, "test", []byte(`{}`), 3, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&numRequests))
+	// retries are limited
+	atomic.StoreInt32(&numRequests, 0)
+	err = deliverWebhook(webhook, "test", []byte(`{}`), 1, time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&numRequests))
+	// client errors are not retried
+	atomic.StoreInt32(&numRequests, 0)
+	webhook.URL = webhookServer.URL + "/bad_request"
+	err = deliverWebhook(webhook, "test", []byte(`{}`), 3, time.Millisecond)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&numRequests))
+}
+
+func TestMaster_Notify(t *testing.T) {
+	s, _ := newMockServer(t)
+	defer s.Close(t)
+	payloads := make(chan []byte, 10)
+	webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		payloads <- body
+	}))
+	defer webhookServer.Close()
+	s.Config.Master.Webhooks = []config.WebhookConfig{{
+		URL:    webhookServer.URL,
+		Events: []string{config.WebhookEventDataImported, config.WebhookEventPositiveRateDropped},
+	}}
+
+	// unsubscribed events are not sent
+	s.notifyTaskFailed(TaskFitRankingModel, "error")
+	// data imported
+	s.notifyDataImported("users", 3)
+	var payload struct {
+		Event string
+		Data  DataImportedEvent
+	}
+	assert.NoError(t, json.Unmarshal(<-payloads, &payload))
+	assert.Equal(t, config.WebhookEventDataImported, payload.Event)
+	assert.Equal(t, DataImportedEvent{Type: "users", Count: 3}, payload.Data)
+
+	// positive feedback rate dropped from 1 to 0.5
+	evaluator := NewOnlineEvaluator()
+	yesterday := evaluator.TruncatedDateToday.Add(-24 * time.Hour).Add(time.Hour)
+	dayBefore := evaluator.TruncatedDateToday.Add(-48 * time.Hour).Add(time.Hour)
+	evaluator.Read(0, 0, dayBefore)
+	evaluator.Read(0, 1, dayBefore)
+	evaluator.Positive("star", 0, 0, 1, dayBefore)
+	evaluator.Positive("star", 0, 1, 1, dayBefore)
+	evaluator.Read(0, 2, yesterday)
+	evaluator.Read(0, 3, yesterday)
+	evaluator.Positive("star", 0, 2, 1, yesterday)
+	s.notifyPositiveRateDropped(evaluator)
+	var dropped struct {
+		Event string
+		Data  PositiveRateDroppedEvent
+	}
+	assert.NoError(t, json.Unmarshal(<-payloads, &dropped))
+	assert.Equal(t, config.WebhookEventPositiveRateDropped, dropped.Event)
+	assert.Equal(t, "star", dropped.Data.FeedbackType)
+	assert.Equal(t, 0.5, dropped.Data.Rate)
+	assert.Equal(t, 1.0, dropped.Data.PreviousRate)
+
+	// the drop is sent once a day
+	s.notifyPositiveRateDropped(evaluator)
+	select {
+	case <-payloads:
+		assert.Fail(t, "unexpected webhook")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// positive feedback rate not dropped
+	s.positiveRateDroppedDates = nil
+	s.Config.Master.PositiveRateDrop = 0.6
+	s.notifyPositiveRateDropped(evaluator)
+	select {
+	case <-payloads:
+		assert.Fail(t, "unexpected webhook")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bandit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/zhenghaoz/gorse/storage/cache"
+)
+
+const (
+	Random           = "random"
+	ThompsonSampling = "thompson_sampling"
+	UCB              = "ucb"
+)
+
+// Arm is the statistics of an arm. Trials are the number of users who read items of the arm and rewards are the
+// number of users who gave positive feedback to items of the arm.
+type Arm struct {
+	Trials  float64
+	Rewards float64
+}
+
+// Add merges the statistics of another arm.
+func (arm Arm) Add(other Arm) Arm {
+	return Arm{Trials: arm.Trials + other.Trials, Rewards: arm.Rewards + other.Rewards}
+}
+
+// Sample draws a reward rate from the Beta posterior of the arm with a uniform prior.
+func (arm Arm) Sample(rng *rand.Rand) float64 {
+	x := sampleGamma(rng, arm.Rewards+1)
+	y := sampleGamma(rng, math.Max(arm.Trials-arm.Rewards, 0)+1)
+	return x / (x + y)
+}
+
+// UpperConfidenceBound returns the UCB1 score of the arm. Arms without trials are always preferred.
+func (arm Arm) UpperConfidenceBound(totalTrials float64) float64 {
+	if arm.Trials <= 0 {
+		return math.Inf(1)
+	}
+	return arm.Rewards/arm.Trials + math.Sqrt(2*math.Log(math.Max(totalTrials, 1))/arm.Trials)
+}
+
+// Pull returns the statistics of the arm after it is chosen again before the reward is observed. The reward is
+// assumed to be the posterior mean, so the confidence bound of the arm narrows while the reward rate is kept.
+func (arm Arm) Pull() Arm {
+	return Arm{Trials: arm.Trials + 1, Rewards: arm.Rewards + (arm.Rewards+1)/(arm.Trials+2)}
+}
+
+// sampleGamma draws a sample from Gamma(shape, 1) by the Marsaglia-Tsang method.
+func sampleGamma(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		return sampleGamma(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// Choose returns the index of the arm chosen by the policy from named arms. Arms without statistics have no trials
+// and ties are broken randomly. The first arm is chosen by the random policy. It returns -1 if there are no arms.
+func Choose(policy string, rng *rand.Rand, arms map[string]Arm, names []string) int {
+	if len(names) == 0 {
+		return -1
+	}
+	var totalTrials float64
+	for _, name := range names {
+		totalTrials += arms[name].Trials
+	}
+	chosen, maxScore, ties := 0, math.Inf(-1), 0
+	for i, name := range names {
+		var score float64
+		switch policy {
+		case ThompsonSampling:
+			score = arms[name].Sample(rng)
+		case UCB:
+			score = arms[name].UpperConfidenceBound(totalTrials)
+		default:
+			return 0
+		}
+		if score > maxScore {
+			chosen, maxScore, ties = i, score, 1
+		} else if score == maxScore {
+			// choose one of tied arms uniformly
+			ties++
+			if rng.Intn(ties) == 0 {
+				chosen = i
+			}
+		}
+	}
+	return chosen
+}
+
+// State is the state of bandits learned from feedback by the master. It is stored in the cache store and loaded by
+// workers to explore items in offline recommendation.
+type State struct {
+	Sources   map[string]Arm // arms of exploration sources, e.g. popular, latest and trending
+	Items     map[string]Arm // arms of new items
+	Timestamp time.Time
+}
+
+// NewState creates an empty State.
+func NewState(timestamp time.Time) *State {
+	return &State{
+		Sources:   make(map[string]Arm),
+		Items:     make(map[string]Arm),
+		Timestamp: timestamp,
+	}
+}
+
+// Clone returns a copy of the state. Workers pull arms on their own copies.
+func (state *State) Clone() *State {
+	clone := NewState(state.Timestamp)
+	for name, arm := range state.Sources {
+		clone.Sources[name] = arm
+	}
+	for name, arm := range state.Items {
+		clone.Items[name] = arm
+	}
+	return clone
+}
+
+// LoadState loads the state of bandits from the cache store. An empty state is returned if no state has been saved.
+func LoadState(ctx context.Context, cacheClient cache.Database) (*State, error) {
+	value, err := cacheClient.Get(ctx, cache.BanditState).String()
+	if errors.Is(err, errors.NotFound) {
+		return NewState(time.Time{}), nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	state := NewState(time.Time{})
+	if err = json.Unmarshal([]byte(value), state); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return state, nil
+}
+
+// SaveState saves the state of bandits to the cache store.
+func SaveState(ctx context.Context, cacheClient cache.Database, state *State) error {
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return cacheClient.Set(ctx, cache.String(cache.BanditState, string(bytes)))
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bandit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/storage/cache"
+)
+
+func TestArm_Sample(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	arm := Arm{Trials: 100, Rewards: 30}
+	var sum float64
+	for i := 0; i < 10000; i++ {
+		sample := arm.Sample(rng)
+		assert.True(t, sample >= 0 && sample <= 1)
+		sum += sample
+	}
+	// the mean of Beta(31, 71)
+	assert.InDelta(t, 31.0/102.0, sum/10000, 0.01)
+}
+
+func TestArm_UpperConfidenceBound(t *testing.T) {
+	assert.True(t, math.IsInf(Arm{}.UpperConfidenceBound(10), 1))
+	assert.InDelta(t, 0.5+math.Sqrt(2*math.Log(100)/10), Arm{Trials: 10, Rewards: 5}.UpperConfidenceBound(100), 1e-9)
+	// arms with fewer trials have wider bounds
+	assert.Greater(t, Arm{Trials: 10, Rewards: 5}.UpperConfidenceBound(100), Arm{Trials: 90, Rewards: 45}.UpperConfidenceBound(100))
+}
+
+func TestChoose(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	arms := map[string]Arm{
+		"popular": {Trials: 1000, Rewards: 100},
+		"latest":  {Trials: 1000, Rewards: 500},
+	}
+	names := []string{"popular", "latest"}
+	assert.Equal(t, -1, Choose(ThompsonSampling, rng, arms, nil))
+	assert.Equal(t, 0, Choose(Random, rng, arms, names))
+	assert.Equal(t, 1, Choose(UCB, rng, arms, names))
+	counts := make([]int, len(names))
+	for i := 0; i < 1000; i++ {
+		counts[Choose(ThompsonSampling, rng, arms, names)]++
+	}
+	assert.Greater(t, counts[1], 990)
+	// arms without trials are explored
+	assert.Equal(t, 2, Choose(UCB, rng, arms, append(names, "trending")))
+	// ties are broken randomly
+	counts = make([]int, len(names))
+	for i := 0; i < 1000; i++ {
+		counts[Choose(UCB, rng, nil, names)]++
+	}
+	assert.InDelta(t, 500, counts[0], 100)
+}
+
+func TestArm_Pull(t *testing.T) {
+	arm := Arm{Trials: 8, Rewards: 4}.Pull()
+	assert.Equal(t, Arm{Trials: 9, Rewards: 4.5}, arm)
+	// pulled arms are not chosen by UCB repeatedly
+	rng := rand.New(rand.NewSource(0))
+	arms := map[string]Arm{"popular": {Trials: 10, Rewards: 5}, "latest": {Trials: 10, Rewards: 5}}
+	names := []string{"popular", "latest"}
+	counts := make([]int, len(names))
+	for i := 0; i < 100; i++ {
+		chosen := Choose(UCB, rng, arms, names)
+		arms[names[chosen]] = arms[names[chosen]].Pull()
+		counts[chosen]++
+	}
+	assert.InDelta(t, 50, counts[0], 1)
+}
+
+func TestState_Clone(t *testing.T) {
+	state := NewState(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	state.Sources["popular"] = Arm{Trials: 10, Rewards: 2}
+	state.Items["1"] = Arm{Trials: 3, Rewards: 1}
+	clone := state.Clone()
+	assert.Equal(t, state, clone)
+	clone.Sources["popular"] = clone.Sources["popular"].Pull()
+	clone.Items["2"] = Arm{}
+	assert.Equal(t, Arm{Trials: 10, Rewards: 2}, state.Sources["popular"])
+	assert.NotContains(t, state.Items, "2")
+}
+
+func TestState(t *testing.T) {
+	ctx := context.Background()
+	cacheClient, err := cache.Open(fmt.Sprintf("sqlite://%s/sqlite.db", t.TempDir()), "gorse_")
+	assert.NoError(t, err)
+	assert.NoError(t, cacheClient.Init())
+	defer cacheClient.Close()
+
+	// load empty state
+	state, err := LoadState(ctx, cacheClient)
+	assert.NoError(t, err)
+	assert.Equal(t, NewState(time.Time{}), state)
+	// save and load state
+	expected := NewState(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	expected.Sources["popular"] = Arm{Trials: 10, Rewards: 2}
+	expected.Items["1"] = Arm{Trials: 3, Rewards: 1}
+	err = SaveState(ctx, cacheClient, expected)
+	assert.NoError(t, err)
+	state, err = LoadState(ctx, cacheClient)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, state)
+}
@@ -40,6 +40,7 @@ type Dataset struct {
 	CtxValues   [][]float32
 	NormValues  base.Array[float32]
 	Target      base.Array[float32]
+	Weights     base.Array[float32] // sample weights, empty if all weights are 1
 
 	PositiveCount int
 	NegativeCount int
@@ -62,9 +63,18 @@ func (dataset *Dataset) Bytes() int {
 	bytes += uintptr(dataset.Items.Bytes())
 	bytes += uintptr(dataset.NormValues.Bytes())
 	bytes += uintptr(dataset.Target.Bytes())
+	bytes += uintptr(dataset.Weights.Bytes())
 	return int(bytes)
 }
 
+// Weight returns the weight of the i-th sample.
+func (dataset *Dataset) Weight(i int) float32 {
+	if dataset.Weights.Len() == 0 {
+		return 1
+	}
+	return dataset.Weights.Get(i)
+}
+
 // Count returns the number of samples.
 func (dataset *Dataset) Count() int {
 	if dataset.Users.Len() != dataset.Items.Len() {
@@ -82,6 +92,9 @@ func (dataset *Dataset) Count() int {
 	if dataset.CtxFeatures != nil && len(dataset.CtxFeatures) != dataset.Target.Len() {
 		panic("len(dataset.CtxFeatures) != len(dataset.Target)")
 	}
+	if dataset.Weights.Len() > 0 && dataset.Weights.Len() != dataset.Target.Len() {
+		panic("dataset.Weights.Len() != dataset.Target.Len()")
+	}
 	return dataset.Target.Len()
 }
 
@@ -228,6 +241,9 @@ func (dataset *Dataset) Split(ratio float32, seed int64) (*Dataset, *Dataset) {
 			}
 			testSet.NormValues.Append(dataset.NormValues.Get(i))
 			testSet.Target.Append(dataset.Target.Get(i))
+			if dataset.Weights.Len() > 0 {
+				testSet.Weights.Append(dataset.Weights.Get(i))
+			}
 			if dataset.Target.Get(i) > 0 {
 				testSet.PositiveCount++
 			} else {
@@ -245,6 +261,9 @@ func (dataset *Dataset) Split(ratio float32, seed int64) (*Dataset, *Dataset) {
 			}
 			trainSet.NormValues.Append(dataset.NormValues.Get(i))
 			trainSet.Target.Append(dataset.Target.Get(i))
+			if dataset.Weights.Len() > 0 {
+				trainSet.Weights.Append(dataset.Weights.Get(i))
+			}
 			if dataset.Target.Get(i) > 0 {
 				trainSet.PositiveCount++
 			} else {
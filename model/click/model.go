@@ -128,6 +128,7 @@ func (config *FitConfig) LoadDefaultIfNil() *FitConfig {
 type FactorizationMachine interface {
 	model.Model
 	Predict(userId, itemId string, userLabels, itemLabels []string) float32
+	PredictWithContext(userId, itemId string, userLabels, itemLabels, ctxLabels []string) float32
 	InternalPredict(x []int32, values []float32) float32
 	Fit(trainSet *Dataset, testSet *Dataset, config *FitConfig) Score
 	Marshal(w io.Writer) error
@@ -198,6 +199,12 @@ func (fm *FM) SetParams(params model.Params) {
 }
 
 func (fm *FM) Predict(userId, itemId string, userLabels, itemLabels []string) float32 {
+	return fm.PredictWithContext(userId, itemId, userLabels, itemLabels, nil)
+}
+
+// PredictWithContext predicts the score of an item for a user under context labels such as device or page.
+// Context labels unknown to the model are ignored.
+func (fm *FM) PredictWithContext(userId, itemId string, userLabels, itemLabels, ctxLabels []string) float32 {
 	var features []int32
 	var values []float32
 	// encode user
@@ -226,6 +233,13 @@ func (fm *FM) Predict(userId, itemId string, userLabels, itemLabels []string) fl
 			values = append(values, 1/norm)
 		}
 	}
+	// encode context labels
+	for _, ctxLabel := range ctxLabels {
+		if ctxLabelIndex := fm.Index.EncodeContextLabel(ctxLabel); ctxLabelIndex != base.NotId {
+			features = append(features, ctxLabelIndex)
+			values = append(values, 1)
+		}
+	}
 	return fm.InternalPredict(features, values)
 }
 
@@ -323,6 +337,7 @@ func (fm *FM) Fit(trainSet, testSet *Dataset, config *FitConfig) Score {
 				default:
 					log.Logger().Fatal("unknown task", zap.String("task", string(fm.Task)))
 				}
+				grad *= trainSet.Weight(i)
 				// \sum^n_{j=1}v_j,fx_j
 				floats.Zero(temp[workerId])
 				for it, j := range features {
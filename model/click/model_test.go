@@ -95,6 +95,8 @@ func TestFM_Regression_Criteo(t *testing.T) {
 	// test prediction
 	assert.Equal(t, m.InternalPredict([]int32{1, 2, 3, 4, 5, 6}, []float32{1, 1, 0.5, 0.5, 0.5, 0.5}),
 		m.Predict("1", "2", []string{"3", "4"}, []string{"5", "6"}))
+	assert.Equal(t, m.InternalPredict([]int32{1, 2, 3, 4, 5, 6, 7}, []float32{1, 1, 0.5, 0.5, 0.5, 0.5, 1}),
+		m.PredictWithContext("1", "2", []string{"3", "4"}, []string{"5", "6"}, []string{"7"}))
 
 	// test increment test
 	buf := bytes.NewBuffer(nil)
@@ -38,6 +38,8 @@ const (
 	Alpha       ParamName = "Alpha"       // weight for negative samples in ALS
 	Similarity  ParamName = "Similarity"
 	UseFeature  ParamName = "UseFeature"
+
+	HardNegativeRate ParamName = "HardNegativeRate" // probability of sampling hard negatives in BPR
 )
 
 // Params stores hyper-parameters for an model. It is a map between strings
@@ -49,6 +49,19 @@ type DataSet struct {
 	NumUserLabels    int32
 	NumItemLabelUsed int
 	NumUserLabelUsed int
+
+	// UserFeedbackWeights and ItemFeedbackWeights are aligned with UserFeedback and ItemFeedback. They are nil
+	// unless weighted feedback has been added.
+	UserFeedbackWeights [][]float32
+	ItemFeedbackWeights [][]float32
+
+	// HardNegatives are items with negative feedback from users, which are sampled as negatives by BPR. It is nil
+	// unless hard negatives have been added.
+	HardNegatives [][]int32
+
+	// ItemLocations are [latitude, longitude] of items located by the reserved location label. It is nil unless
+	// locations have been added, and elements of unlocated items are nil.
+	ItemLocations [][]float64
 }
 
 // NewMapIndexDataset creates a data set.
@@ -87,6 +100,10 @@ func (dataset *DataSet) Bytes() int {
 	bytes += reflect.TypeOf(dataset.UserFeedback).Elem().Size() * uintptr(len(dataset.UserFeedback)+len(dataset.ItemFeedback))
 	bytes += reflect.TypeOf(dataset.UserFeedback).Elem().Elem().Size() * uintptr(dataset.Count()*2)
 	bytes += encoding.MatrixBytes(dataset.Negatives)
+	bytes += encoding.MatrixBytes(dataset.UserFeedbackWeights)
+	bytes += encoding.MatrixBytes(dataset.ItemFeedbackWeights)
+	bytes += encoding.MatrixBytes(dataset.HardNegatives)
+	bytes += encoding.MatrixBytes(dataset.ItemLocations)
 
 	// ItemLabels + UserLabels
 	bytes += reflect.TypeOf(dataset.ItemLabels).Elem().Size() * uintptr(len(dataset.ItemLabels)+len(dataset.UserLabels))
@@ -114,6 +131,12 @@ func (dataset *DataSet) AddItem(itemId string) {
 }
 
 func (dataset *DataSet) AddFeedback(userId, itemId string, insertUserItem bool) {
+	dataset.AddWeightedFeedback(userId, itemId, 1, insertUserItem)
+}
+
+// AddWeightedFeedback adds feedback with a confidence weight. Weights are only stored once a weight other than 1
+// has been added.
+func (dataset *DataSet) AddWeightedFeedback(userId, itemId string, weight float32, insertUserItem bool) {
 	if insertUserItem {
 		dataset.UserIndex.Add(userId)
 	}
@@ -133,7 +156,55 @@ func (dataset *DataSet) AddFeedback(userId, itemId string, insertUserItem bool)
 			dataset.UserFeedback = append(dataset.UserFeedback, make([]int32, 0))
 		}
 		dataset.UserFeedback[userIndex] = append(dataset.UserFeedback[userIndex], itemIndex)
+		if dataset.UserFeedbackWeights != nil {
+			for int(itemIndex) >= len(dataset.ItemFeedbackWeights) {
+				dataset.ItemFeedbackWeights = append(dataset.ItemFeedbackWeights, make([]float32, 0))
+			}
+			dataset.ItemFeedbackWeights[itemIndex] = append(dataset.ItemFeedbackWeights[itemIndex], weight)
+			for int(userIndex) >= len(dataset.UserFeedbackWeights) {
+				dataset.UserFeedbackWeights = append(dataset.UserFeedbackWeights, make([]float32, 0))
+			}
+			dataset.UserFeedbackWeights[userIndex] = append(dataset.UserFeedbackWeights[userIndex], weight)
+		} else if weight != 1 {
+			// materialize weights once the first non-unit weight arrives
+			dataset.UserFeedbackWeights = createWeights(dataset.UserFeedback)
+			dataset.ItemFeedbackWeights = createWeights(dataset.ItemFeedback)
+			dataset.UserFeedbackWeights[userIndex][len(dataset.UserFeedbackWeights[userIndex])-1] = weight
+			dataset.ItemFeedbackWeights[itemIndex][len(dataset.ItemFeedbackWeights[itemIndex])-1] = weight
+		}
+	}
+}
+
+// UserFeedbackWeight returns the weight of the k-th feedback of a user.
+func (dataset *DataSet) UserFeedbackWeight(userIndex int32, k int) float32 {
+	if dataset.UserFeedbackWeights == nil {
+		return 1
 	}
+	return dataset.UserFeedbackWeights[userIndex][k]
+}
+
+// ItemFeedbackWeight returns the weight of the k-th feedback of an item.
+func (dataset *DataSet) ItemFeedbackWeight(itemIndex int32, k int) float32 {
+	if dataset.ItemFeedbackWeights == nil {
+		return 1
+	}
+	return dataset.ItemFeedbackWeights[itemIndex][k]
+}
+
+// IsWeighted returns true if any feedback has a weight other than 1.
+func (dataset *DataSet) IsWeighted() bool {
+	return dataset.UserFeedbackWeights != nil
+}
+
+func createWeights(feedback [][]int32) [][]float32 {
+	weights := make([][]float32, len(feedback))
+	for i := range feedback {
+		weights[i] = make([]float32, len(feedback[i]))
+		for j := range weights[i] {
+			weights[i][j] = 1
+		}
+	}
+	return weights
 }
 
 func (dataset *DataSet) SetNegatives(userId string, negatives []string) {
@@ -152,6 +223,45 @@ func (dataset *DataSet) SetNegatives(userId string, negatives []string) {
 	}
 }
 
+// AddHardNegative adds an item with negative feedback from a user. Unknown users or items are ignored.
+func (dataset *DataSet) AddHardNegative(userId, itemId string) {
+	userIndex := dataset.UserIndex.ToNumber(userId)
+	itemIndex := dataset.ItemIndex.ToNumber(itemId)
+	if userIndex != base.NotId && itemIndex != base.NotId {
+		for int(userIndex) >= len(dataset.HardNegatives) {
+			dataset.HardNegatives = append(dataset.HardNegatives, make([]int32, 0))
+		}
+		dataset.HardNegatives[userIndex] = append(dataset.HardNegatives[userIndex], itemIndex)
+	}
+}
+
+// UserHardNegatives returns items with negative feedback from a user.
+func (dataset *DataSet) UserHardNegatives(userIndex int32) []int32 {
+	if int(userIndex) >= len(dataset.HardNegatives) {
+		return nil
+	}
+	return dataset.HardNegatives[userIndex]
+}
+
+// SetItemLocation sets the location of an item.
+func (dataset *DataSet) SetItemLocation(itemId string, latitude, longitude float64) {
+	itemIndex := dataset.ItemIndex.ToNumber(itemId)
+	if itemIndex != base.NotId {
+		for int(itemIndex) >= len(dataset.ItemLocations) {
+			dataset.ItemLocations = append(dataset.ItemLocations, nil)
+		}
+		dataset.ItemLocations[itemIndex] = []float64{latitude, longitude}
+	}
+}
+
+// ItemLocation returns the location of an item. It returns false if the item is not located.
+func (dataset *DataSet) ItemLocation(itemIndex int32) (latitude, longitude float64, ok bool) {
+	if itemIndex < 0 || int(itemIndex) >= len(dataset.ItemLocations) || dataset.ItemLocations[itemIndex] == nil {
+		return 0, 0, false
+	}
+	return dataset.ItemLocations[itemIndex][0], dataset.ItemLocations[itemIndex][1], true
+}
+
 func (dataset *DataSet) Count() int {
 	if dataset.FeedbackUsers.Len() != dataset.FeedbackItems.Len() {
 		panic("dataset.FeedbackUsers.Len() != dataset.FeedbackItems.Len()")
@@ -199,30 +309,30 @@ func (dataset *DataSet) Split(numTestUsers int, seed int64) (*DataSet, *DataSet)
 	trainSet.NumUserLabels, testSet.NumUserLabels = dataset.NumUserLabels, dataset.NumUserLabels
 	trainSet.HiddenItems, testSet.HiddenItems = dataset.HiddenItems, dataset.HiddenItems
 	trainSet.ItemCategories, testSet.ItemCategories = dataset.ItemCategories, dataset.ItemCategories
+	trainSet.ItemLocations, testSet.ItemLocations = dataset.ItemLocations, dataset.ItemLocations
 	trainSet.CategorySet, testSet.CategorySet = dataset.CategorySet, dataset.CategorySet
 	trainSet.ItemLabels, testSet.ItemLabels = dataset.ItemLabels, dataset.ItemLabels
 	trainSet.UserLabels, testSet.UserLabels = dataset.UserLabels, dataset.UserLabels
+	trainSet.HardNegatives, testSet.HardNegatives = dataset.HardNegatives, dataset.HardNegatives
 	trainSet.NumItemLabelUsed, testSet.NumItemLabelUsed = dataset.NumItemLabelUsed, dataset.NumItemLabelUsed
 	trainSet.NumUserLabelUsed, testSet.NumUserLabelUsed = dataset.NumUserLabelUsed, dataset.NumUserLabelUsed
 	trainSet.UserIndex, testSet.UserIndex = dataset.UserIndex, dataset.UserIndex
 	trainSet.ItemIndex, testSet.ItemIndex = dataset.ItemIndex, dataset.ItemIndex
 	trainSet.UserFeedback, testSet.UserFeedback = createSliceOfSlice(dataset.UserCount()), createSliceOfSlice(dataset.UserCount())
 	trainSet.ItemFeedback, testSet.ItemFeedback = createSliceOfSlice(dataset.ItemCount()), createSliceOfSlice(dataset.ItemCount())
+	if dataset.IsWeighted() {
+		trainSet.UserFeedbackWeights, testSet.UserFeedbackWeights = make([][]float32, dataset.UserCount()), make([][]float32, dataset.UserCount())
+		trainSet.ItemFeedbackWeights, testSet.ItemFeedbackWeights = make([][]float32, dataset.ItemCount()), make([][]float32, dataset.ItemCount())
+	}
 	rng := base.NewRandomGenerator(seed)
 	if numTestUsers >= dataset.UserCount() || numTestUsers <= 0 {
 		for userIndex := int32(0); userIndex < int32(dataset.UserCount()); userIndex++ {
 			if len(dataset.UserFeedback[userIndex]) > 0 {
 				k := rng.Intn(len(dataset.UserFeedback[userIndex]))
-				testSet.FeedbackUsers.Append(userIndex)
-				testSet.FeedbackItems.Append(dataset.UserFeedback[userIndex][k])
-				testSet.UserFeedback[userIndex] = append(testSet.UserFeedback[userIndex], dataset.UserFeedback[userIndex][k])
-				testSet.ItemFeedback[dataset.UserFeedback[userIndex][k]] = append(testSet.ItemFeedback[dataset.UserFeedback[userIndex][k]], userIndex)
-				for i, itemIndex := range dataset.UserFeedback[userIndex] {
+				testSet.splitFeedback(dataset, userIndex, k)
+				for i := range dataset.UserFeedback[userIndex] {
 					if i != k {
-						trainSet.FeedbackUsers.Append(userIndex)
-						trainSet.FeedbackItems.Append(itemIndex)
-						trainSet.UserFeedback[userIndex] = append(trainSet.UserFeedback[userIndex], itemIndex)
-						trainSet.ItemFeedback[itemIndex] = append(trainSet.ItemFeedback[itemIndex], userIndex)
+						trainSet.splitFeedback(dataset, userIndex, i)
 					}
 				}
 			}
@@ -232,16 +342,10 @@ func (dataset *DataSet) Split(numTestUsers int, seed int64) (*DataSet, *DataSet)
 		for _, userIndex := range testUsers {
 			if len(dataset.UserFeedback[userIndex]) > 0 {
 				k := rng.Intn(len(dataset.UserFeedback[userIndex]))
-				testSet.FeedbackUsers.Append(userIndex)
-				testSet.FeedbackItems.Append(dataset.UserFeedback[userIndex][k])
-				testSet.UserFeedback[userIndex] = append(testSet.UserFeedback[userIndex], dataset.UserFeedback[userIndex][k])
-				testSet.ItemFeedback[dataset.UserFeedback[userIndex][k]] = append(testSet.ItemFeedback[dataset.UserFeedback[userIndex][k]], userIndex)
-				for i, itemIndex := range dataset.UserFeedback[userIndex] {
+				testSet.splitFeedback(dataset, userIndex, k)
+				for i := range dataset.UserFeedback[userIndex] {
 					if i != k {
-						trainSet.FeedbackUsers.Append(userIndex)
-						trainSet.FeedbackItems.Append(itemIndex)
-						trainSet.UserFeedback[userIndex] = append(trainSet.UserFeedback[userIndex], itemIndex)
-						trainSet.ItemFeedback[itemIndex] = append(trainSet.ItemFeedback[itemIndex], userIndex)
+						trainSet.splitFeedback(dataset, userIndex, i)
 					}
 				}
 			}
@@ -249,11 +353,8 @@ func (dataset *DataSet) Split(numTestUsers int, seed int64) (*DataSet, *DataSet)
 		testUserSet := mapset.NewSet(testUsers...)
 		for userIndex := int32(0); userIndex < int32(dataset.UserCount()); userIndex++ {
 			if !testUserSet.Contains(userIndex) {
-				for _, itemIndex := range dataset.UserFeedback[userIndex] {
-					trainSet.FeedbackUsers.Append(userIndex)
-					trainSet.FeedbackItems.Append(itemIndex)
-					trainSet.UserFeedback[userIndex] = append(trainSet.UserFeedback[userIndex], itemIndex)
-					trainSet.ItemFeedback[itemIndex] = append(trainSet.ItemFeedback[itemIndex], userIndex)
+				for i := range dataset.UserFeedback[userIndex] {
+					trainSet.splitFeedback(dataset, userIndex, i)
 				}
 			}
 		}
@@ -261,6 +362,20 @@ func (dataset *DataSet) Split(numTestUsers int, seed int64) (*DataSet, *DataSet)
 	return trainSet, testSet
 }
 
+// splitFeedback copies the k-th feedback of a user from the source dataset.
+func (dataset *DataSet) splitFeedback(source *DataSet, userIndex int32, k int) {
+	itemIndex := source.UserFeedback[userIndex][k]
+	dataset.FeedbackUsers.Append(userIndex)
+	dataset.FeedbackItems.Append(itemIndex)
+	dataset.UserFeedback[userIndex] = append(dataset.UserFeedback[userIndex], itemIndex)
+	dataset.ItemFeedback[itemIndex] = append(dataset.ItemFeedback[itemIndex], userIndex)
+	if dataset.UserFeedbackWeights != nil {
+		weight := source.UserFeedbackWeight(userIndex, k)
+		dataset.UserFeedbackWeights[userIndex] = append(dataset.UserFeedbackWeights[userIndex], weight)
+		dataset.ItemFeedbackWeights[itemIndex] = append(dataset.ItemFeedbackWeights[itemIndex], weight)
+	}
+}
+
 // GetIndex gets the i-th record by <user index, item index, rating>.
 func (dataset *DataSet) GetIndex(i int) (int32, int32) {
 	return dataset.FeedbackUsers.Get(i), dataset.FeedbackItems.Get(i)
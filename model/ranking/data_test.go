@@ -69,3 +69,73 @@ func TestDataSet_Split(t *testing.T) {
 	assert.Equal(t, numItems, test2.ItemCount())
 	assert.Equal(t, 2, test2.Count())
 }
+
+func TestDataSet_AddWeightedFeedback(t *testing.T) {
+	dataset := NewMapIndexDataset()
+	dataset.AddFeedback("0", "0", true)
+	assert.False(t, dataset.IsWeighted())
+	assert.Equal(t, float32(1), dataset.UserFeedbackWeight(0, 0))
+	dataset.AddWeightedFeedback("0", "1", 3, true)
+	dataset.AddWeightedFeedback("1", "1", 2, true)
+	assert.True(t, dataset.IsWeighted())
+	assert.Equal(t, []float32{1, 3}, dataset.UserFeedbackWeights[0])
+	assert.Equal(t, []float32{2}, dataset.UserFeedbackWeights[1])
+	assert.Equal(t, []float32{1}, dataset.ItemFeedbackWeights[0])
+	assert.Equal(t, []float32{3, 2}, dataset.ItemFeedbackWeights[1])
+	// split
+	train, test := dataset.Split(0, 0)
+	assert.Equal(t, dataset.Count(), train.Count()+test.Count())
+	for _, set := range []*DataSet{train, test} {
+		for userIndex, items := range set.UserFeedback {
+			for k, itemIndex := range items {
+				expected := float32(1)
+				if userIndex == 0 && itemIndex == 1 {
+					expected = 3
+				} else if userIndex == 1 {
+					expected = 2
+				}
+				assert.Equal(t, expected, set.UserFeedbackWeight(int32(userIndex), k))
+			}
+		}
+	}
+}
+
+func TestDataSet_AddHardNegative(t *testing.T) {
+	dataset := NewMapIndexDataset()
+	dataset.AddFeedback("0", "0", true)
+	dataset.AddFeedback("1", "1", true)
+	dataset.AddHardNegative("1", "0")
+	// unknown users and items are ignored
+	dataset.AddHardNegative("2", "0")
+	dataset.AddHardNegative("1", "2")
+	assert.Empty(t, dataset.UserHardNegatives(0))
+	assert.Equal(t, []int32{0}, dataset.UserHardNegatives(1))
+	assert.Empty(t, dataset.UserHardNegatives(2))
+	// hard negatives are shared by splits
+	train, test := dataset.Split(0, 0)
+	assert.Equal(t, []int32{0}, train.UserHardNegatives(1))
+	assert.Equal(t, []int32{0}, test.UserHardNegatives(1))
+}
+
+func TestDataSet_SetItemLocation(t *testing.T) {
+	dataset := NewMapIndexDataset()
+	dataset.AddItem("0")
+	dataset.AddItem("1")
+	dataset.SetItemLocation("0", 30, 120)
+	// unknown items are ignored
+	dataset.SetItemLocation("2", 30, 120)
+	latitude, longitude, ok := dataset.ItemLocation(0)
+	assert.True(t, ok)
+	assert.Equal(t, 30.0, latitude)
+	assert.Equal(t, 120.0, longitude)
+	_, _, ok = dataset.ItemLocation(1)
+	assert.False(t, ok)
+	_, _, ok = dataset.ItemLocation(2)
+	assert.False(t, ok)
+	// locations are shared by splits
+	train, test := dataset.Split(0, 0)
+	_, _, ok = train.ItemLocation(0)
+	assert.True(t, ok)
+	_, _, ok = test.ItemLocation(0)
+	assert.True(t, ok)
+}
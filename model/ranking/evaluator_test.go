@@ -92,6 +92,10 @@ func (m *mockMatrixFactorizationForEval) IsItemPredictable(_ int32) bool {
 	panic("implement me")
 }
 
+func (m *mockMatrixFactorizationForEval) FoldIn(_ []int32) []float32 {
+	panic("implement me")
+}
+
 func (m *mockMatrixFactorizationForEval) Marshal(_ io.Writer) error {
 	panic("implement me")
 }
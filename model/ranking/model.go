@@ -110,6 +110,9 @@ type MatrixFactorization interface {
 	IsUserPredictable(userIndex int32) bool
 	// IsItemPredictable returns false if item has no feedback and its embedding vector never be trained.
 	IsItemPredictable(itemIndex int32) bool
+	// FoldIn computes the latent factor of an unseen user from positive items while item factors are fixed.
+	// It returns nil if none of the items is predictable.
+	FoldIn(itemIndices []int32) []float32
 	// Marshal model into byte stream.
 	Marshal(w io.Writer) error
 	// Unmarshal model from byte stream.
@@ -322,6 +325,8 @@ type BPR struct {
 	reg        float32
 	initMean   float32
 	initStdDev float32
+	// hardNegativeRate is the probability of sampling negatives from hard negatives of a user.
+	hardNegativeRate float32
 }
 
 // NewBPR creates a BPR model.
@@ -355,6 +360,7 @@ func (bpr *BPR) SetParams(params model.Params) {
 	bpr.reg = bpr.Params.GetFloat32(model.Reg, 0.01)
 	bpr.initMean = bpr.Params.GetFloat32(model.InitMean, 0)
 	bpr.initStdDev = bpr.Params.GetFloat32(model.InitStdDev, 0.001)
+	bpr.hardNegativeRate = bpr.Params.GetFloat32(model.HardNegativeRate, 0.5)
 }
 
 func (bpr *BPR) GetParamsGrid(withSize bool) model.ParamsGrid {
@@ -392,6 +398,50 @@ func (bpr *BPR) InternalPredict(userIndex, itemIndex int32) float32 {
 	return ret
 }
 
+// FoldIn computes the latent factor of an unseen user by stochastic gradient descent on the BPR loss. The user factor
+// is initialized by the average of positive item factors and negative items are sampled from predictable items.
+func (bpr *BPR) FoldIn(itemIndices []int32) []float32 {
+	positiveItems := lo.Uniq(lo.Filter(itemIndices, func(itemIndex int32, _ int) bool {
+		return bpr.IsItemPredictable(itemIndex)
+	}))
+	if len(positiveItems) == 0 {
+		return nil
+	}
+	positiveSet := mapset.NewSet(positiveItems...)
+	userFactor := make([]float32, bpr.nFactors)
+	for _, itemIndex := range positiveItems {
+		floats.MulConstAddTo(bpr.ItemFactor[itemIndex], 1/float32(len(positiveItems)), userFactor)
+	}
+	if int(bpr.ItemIndex.Len()) <= len(positiveItems) {
+		return userFactor
+	}
+	rng := base.NewRandomGenerator(bpr.Params.GetInt64(model.RandomState, 0))
+	temp := make([]float32, bpr.nFactors)
+	for epoch := 0; epoch < bpr.nEpochs; epoch++ {
+		for range positiveItems {
+			posIndex := positiveItems[rng.Intn(len(positiveItems))]
+			negIndex := int32(-1)
+			for trial := 0; trial < 100 && negIndex == -1; trial++ {
+				candidate := rng.Int31n(bpr.ItemIndex.Len())
+				if !positiveSet.Contains(candidate) && bpr.IsItemPredictable(candidate) {
+					negIndex = candidate
+				}
+			}
+			if negIndex == -1 {
+				continue
+			}
+			diff := floats.Dot(userFactor, bpr.ItemFactor[posIndex]) - floats.Dot(userFactor, bpr.ItemFactor[negIndex])
+			grad := math32.Exp(-diff) / (1.0 + math32.Exp(-diff))
+			// Update user latent factor: h_{uij} * (q_i - q_j) - reg * p_u
+			floats.SubTo(bpr.ItemFactor[posIndex], bpr.ItemFactor[negIndex], temp)
+			floats.MulConst(temp, grad)
+			floats.MulConstAddTo(userFactor, -bpr.reg, temp)
+			floats.MulConstAddTo(temp, bpr.lr, userFactor)
+		}
+	}
+	return userFactor
+}
+
 // Fit the BPR model. Its task complexity is O(bpr.nEpochs).
 func (bpr *BPR) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 	config = config.LoadDefaultIfNil()
@@ -446,19 +496,24 @@ func (bpr *BPR) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 					break
 				}
 			}
-			posIndex := trainSet.UserFeedback[userIndex][rng[workerId].Intn(ratingCount)]
+			k := rng[workerId].Intn(ratingCount)
+			posIndex := trainSet.UserFeedback[userIndex][k]
+			weight := trainSet.UserFeedbackWeight(userIndex, k)
 			// Select a negative sample
 			negIndex := int32(-1)
-			for {
+			if hardNegatives := trainSet.UserHardNegatives(userIndex); len(hardNegatives) > 0 &&
+				rng[workerId].Float32() < bpr.hardNegativeRate {
+				negIndex = hardNegatives[rng[workerId].Intn(len(hardNegatives))]
+			}
+			for negIndex == -1 {
 				temp := rng[workerId].Int31n(int32(trainSet.ItemCount()))
 				if !userFeedback[userIndex].Contains(temp) {
 					negIndex = temp
-					break
 				}
 			}
 			diff := bpr.InternalPredict(userIndex, posIndex) - bpr.InternalPredict(userIndex, negIndex)
-			cost[workerId] += math32.Log(1 + math32.Exp(-diff))
-			grad := math32.Exp(-diff) / (1.0 + math32.Exp(-diff))
+			cost[workerId] += weight * math32.Log(1+math32.Exp(-diff))
+			grad := weight * math32.Exp(-diff) / (1.0 + math32.Exp(-diff))
 			// Pairwise update
 			copy(userFactor[workerId], bpr.UserFactor[userIndex])
 			copy(positiveItemFactor[workerId], bpr.ItemFactor[posIndex])
@@ -601,6 +656,8 @@ type CCD struct {
 	initMean   float32
 	initStdDev float32
 	weight     float32
+	// itemGram is the Gram matrix of item factors used to fold in users. It is computed once item factors are fixed.
+	itemGram [][]float32
 }
 
 // NewCCD creates a eALS model.
@@ -675,6 +732,7 @@ func (ccd *CCD) Clear() {
 	ccd.ItemIndex = nil
 	ccd.ItemFactor = nil
 	ccd.UserFactor = nil
+	ccd.itemGram = nil
 }
 
 func (ccd *CCD) Invalid() bool {
@@ -711,9 +769,69 @@ func (ccd *CCD) Init(trainSet *DataSet) {
 	// Initialize base
 	ccd.UserFactor = newUserFactor
 	ccd.ItemFactor = newItemFactor
+	ccd.itemGram = nil
 	ccd.BaseMatrixFactorization.Init(trainSet)
 }
 
+// computeItemGram computes the Gram matrix of factors of predictable items.
+func (ccd *CCD) computeItemGram() [][]float32 {
+	// S^q <- \sum^N_{itemIndex=1} c_i q_i q_i^T
+	s := base.NewMatrix32(ccd.nFactors, ccd.nFactors)
+	for itemIndex := int32(0); itemIndex < ccd.ItemIndex.Len(); itemIndex++ {
+		if ccd.IsItemPredictable(itemIndex) {
+			for i := 0; i < ccd.nFactors; i++ {
+				for j := 0; j < ccd.nFactors; j++ {
+					s[i][j] += ccd.ItemFactor[itemIndex][i] * ccd.ItemFactor[itemIndex][j]
+				}
+			}
+		}
+	}
+	return s
+}
+
+// FoldIn computes the latent factor of an unseen user by coordinate descent on the weighted least squares loss of
+// eALS, which is a least squares projection of positive items onto item factors.
+func (ccd *CCD) FoldIn(itemIndices []int32) []float32 {
+	positiveItems := lo.Uniq(lo.Filter(itemIndices, func(itemIndex int32, _ int) bool {
+		return ccd.IsItemPredictable(itemIndex)
+	}))
+	if len(positiveItems) == 0 {
+		return nil
+	}
+	s := ccd.itemGram
+	if s == nil {
+		s = ccd.computeItemGram()
+	}
+	userFactor := make([]float32, ccd.nFactors)
+	predictions := make([]float32, len(positiveItems))
+	res := make([]float32, len(positiveItems))
+	for ep := 0; ep < ccd.nEpochs; ep++ {
+		for f := 0; f < ccd.nFactors; f++ {
+			// \hat_{r}^f_{ui} <- \hat_{r}_{ui} - p_{uf]q_{if}
+			for k, i := range positiveItems {
+				res[k] = predictions[k] - userFactor[f]*ccd.ItemFactor[i][f]
+			}
+			// p_{uf} <-
+			a, b, c := float32(0), float32(0), float32(0)
+			for k, i := range positiveItems {
+				a += (1 - (1-ccd.weight)*res[k]) * ccd.ItemFactor[i][f]
+				c += (1 - ccd.weight) * ccd.ItemFactor[i][f] * ccd.ItemFactor[i][f]
+			}
+			for k := 0; k < ccd.nFactors; k++ {
+				if k != f {
+					b += ccd.weight * userFactor[k] * s[k][f]
+				}
+			}
+			userFactor[f] = (a - b) / (c + ccd.weight*s[f][f] + ccd.reg)
+			// \hat_{r}_{ui} <- \hat_{r}^f_{ui} - p_{uf]q_{if}
+			for k, i := range positiveItems {
+				predictions[k] = res[k] + userFactor[f]*ccd.ItemFactor[i][f]
+			}
+		}
+	}
+	return userFactor
+}
+
 // Fit the CCD model. Its task complexity is O(ccd.nEpochs).
 func (ccd *CCD) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 	config = config.LoadDefaultIfNil()
@@ -773,9 +891,11 @@ func (ccd *CCD) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 				}
 				// p_{uf} <-
 				a, b, c := float32(0), float32(0), float32(0)
-				for _, i := range userFeedback {
-					a += (1 - (1-ccd.weight)*userRes[workerId][i]) * ccd.ItemFactor[i][f]
-					c += (1 - ccd.weight) * ccd.ItemFactor[i][f] * ccd.ItemFactor[i][f]
+				for k, i := range userFeedback {
+					// confidence of observed feedback
+					w := trainSet.UserFeedbackWeight(int32(userIndex), k)
+					a += (w - (w-ccd.weight)*userRes[workerId][i]) * ccd.ItemFactor[i][f]
+					c += (w - ccd.weight) * ccd.ItemFactor[i][f] * ccd.ItemFactor[i][f]
 				}
 				for k := 0; k < ccd.nFactors; k++ {
 					if k != f {
@@ -814,9 +934,11 @@ func (ccd *CCD) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 				}
 				// q_{if} <-
 				a, b, c := float32(0), float32(0), float32(0)
-				for _, u := range itemFeedback {
-					a += (1 - (1-ccd.weight)*itemRes[workerId][u]) * ccd.UserFactor[u][f]
-					c += (1 - ccd.weight) * ccd.UserFactor[u][f] * ccd.UserFactor[u][f]
+				for k, u := range itemFeedback {
+					// confidence of observed feedback
+					w := trainSet.ItemFeedbackWeight(int32(itemIndex), k)
+					a += (w - (w-ccd.weight)*itemRes[workerId][u]) * ccd.UserFactor[u][f]
+					c += (w - ccd.weight) * ccd.UserFactor[u][f] * ccd.UserFactor[u][f]
 				}
 				for k := 0; k < ccd.nFactors; k++ {
 					if k != f {
@@ -850,6 +972,7 @@ func (ccd *CCD) Fit(trainSet, valSet *DataSet, config *FitConfig) Score {
 	// restore best snapshot
 	ccd.UserFactor = snapshots.BestWeights[0].([][]float32)
 	ccd.ItemFactor = snapshots.BestWeights[1].([][]float32)
+	ccd.itemGram = ccd.computeItemGram()
 	log.Logger().Info("fit ccd complete",
 		zap.Float32(fmt.Sprintf("NDCG@%v", config.TopK), snapshots.BestScore.NDCG),
 		zap.Float32(fmt.Sprintf("Precision@%v", config.TopK), snapshots.BestScore.Precision),
@@ -898,5 +1021,6 @@ func (ccd *CCD) Unmarshal(r io.Reader) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	ccd.itemGram = ccd.computeItemGram()
 	return nil
 }
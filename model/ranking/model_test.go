@@ -35,6 +35,20 @@ func newFitConfig(numEpoch int) *FitConfig {
 	return cfg
 }
 
+// assertFoldIn checks that positive items of a user rank higher than other items for the folded-in user factor.
+func assertFoldIn(t *testing.T, m MatrixFactorization, trainSet *DataSet) {
+	assert.Nil(t, m.FoldIn(nil))
+	userFactor := m.FoldIn(trainSet.UserFeedback[1])
+	var positiveScore, totalScore float32
+	for _, itemIndex := range trainSet.UserFeedback[1] {
+		positiveScore += floats.Dot(userFactor, m.GetItemFactor(itemIndex))
+	}
+	for itemIndex := int32(0); itemIndex < trainSet.ItemIndex.Len(); itemIndex++ {
+		totalScore += floats.Dot(userFactor, m.GetItemFactor(itemIndex))
+	}
+	assert.Greater(t, positiveScore/float32(len(trainSet.UserFeedback[1])), totalScore/float32(trainSet.ItemIndex.Len()))
+}
+
 // He, Xiangnan, et al. "Neural collaborative filtering." Proceedings
 // of the 26th international conference on world wide web. 2017.
 
@@ -64,6 +78,9 @@ func TestBPR_MovieLens(t *testing.T) {
 	assert.False(t, m.IsUserPredictable(math.MaxInt32))
 	assert.False(t, m.IsItemPredictable(math.MaxInt32))
 
+	// test fold-in
+	assertFoldIn(t, m, trainSet)
+
 	// test encode/decode model and increment training
 	buf := bytes.NewBuffer(nil)
 	err = MarshalModel(buf, m)
@@ -119,6 +136,10 @@ func TestCCD_MovieLens(t *testing.T) {
 	assert.Equal(t, m.Predict("1", "1"), m.InternalPredict(1, 1))
 	assert.Equal(t, m.InternalPredict(1, 1), floats.Dot(m.GetUserFactor(1), m.GetItemFactor(1)))
 
+	// test fold-in
+	assert.Equal(t, m.computeItemGram(), m.itemGram)
+	assertFoldIn(t, m, trainSet)
+
 	// test encode/decode model and increment training
 	buf := bytes.NewBuffer(nil)
 	err = MarshalModel(buf, m)
@@ -126,6 +147,7 @@ func TestCCD_MovieLens(t *testing.T) {
 	tmp, err := UnmarshalModel(buf)
 	assert.NoError(t, err)
 	m = tmp.(*CCD)
+	assert.Equal(t, m.computeItemGram(), m.itemGram)
 	m.nEpochs = 1
 	fitConfig = newFitConfig(1)
 	scoreInc := m.Fit(trainSet, testSet, fitConfig)
@@ -54,6 +54,10 @@ func (m *mockMatrixFactorizationForSearch) IsItemPredictable(_ int32) bool {
 	panic("implement me")
 }
 
+func (m *mockMatrixFactorizationForSearch) FoldIn(_ []int32) []float32 {
+	panic("implement me")
+}
+
 func (m *mockMatrixFactorizationForSearch) Marshal(_ io.Writer) error {
 	panic("implement me")
 }
@@ -19,7 +19,7 @@ import (
 	"time"
 )
 
-//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative protocol.proto
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative protocol.proto gorse.proto
 
 func DecodeTask(in *PushTaskInfoRequest) *task.Task {
 	return &task.Task{
@@ -0,0 +1,309 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/storage/cache"
+)
+
+const (
+	ScopeRecommend = "recommend" // read recommendations
+	ScopeFeedback  = "feedback"  // insert feedback
+	ScopeAdmin     = "admin"     // access all APIs
+)
+
+// AdminAttribute is the request attribute marking requests authorized as admin by other filters, e.g. the login
+// filter of the dashboard.
+const AdminAttribute = "admin"
+
+// apiKeyRefreshInterval is the interval to reload API keys from the cache store.
+const apiKeyRefreshInterval = 10 * time.Second
+
+// APIKey is a named API key with scopes and a rate limit. Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	Name   string
+	Hash   string `json:",omitempty"`
+	Scopes []string
+	// RateLimit is the number of requests per second allowed by a server node. There is no limit if it is zero.
+	RateLimit float64
+	// Burst is the maximal number of requests in a burst. It is one if it is zero.
+	Burst   int
+	Revoked bool
+}
+
+// Validate checks if the API key is valid.
+func (key *APIKey) Validate() error {
+	if key.Name == "" {
+		return errors.NotValidf("empty API key name")
+	}
+	if len(key.Scopes) == 0 {
+		return errors.NotValidf("API key %s without scopes", key.Name)
+	}
+	for _, scope := range key.Scopes {
+		if scope != ScopeRecommend && scope != ScopeFeedback && scope != ScopeAdmin {
+			return errors.NotValidf("API key scope %s", scope)
+		}
+	}
+	if key.RateLimit < 0 || key.Burst < 0 {
+		return errors.NotValidf("API key %s with rate limit %v and burst %d", key.Name, key.RateLimit, key.Burst)
+	}
+	return nil
+}
+
+// HasScope checks if the API key grants the scope. The admin scope grants all scopes.
+func (key *APIKey) HasScope(scope string) bool {
+	return lo.Contains(key.Scopes, ScopeAdmin) || lo.Contains(key.Scopes, scope)
+}
+
+// GenerateAPIKey generates a random API key.
+func GenerateAPIKey() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", errors.Trace(err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// HashAPIKey returns the SHA-256 hash of an API key.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// LoadAPIKeys loads API keys from the cache store.
+func LoadAPIKeys(ctx context.Context, cacheClient cache.Database) ([]APIKey, error) {
+	value, err := cacheClient.Get(ctx, cache.APIKeys).String()
+	if errors.Is(err, errors.NotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var keys []APIKey
+	if err = json.Unmarshal([]byte(value), &keys); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return keys, nil
+}
+
+// SaveAPIKeys saves API keys to the cache store.
+func SaveAPIKeys(ctx context.Context, cacheClient cache.Database, keys []APIKey) error {
+	bytes, err := json.Marshal(keys)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return cacheClient.Set(ctx, cache.String(cache.APIKeys, string(bytes)))
+}
+
+// tokenBucket limits the rate of requests. Tokens are refilled at the rate up to the burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(lo.Max([]int{burst, 1})),
+		tokens: float64(lo.Max([]int{burst, 1})),
+		last:   now,
+	}
+}
+
+// allow takes a token from the bucket if there is any.
+func (bucket *tokenBucket) allow(now time.Time) bool {
+	if now.After(bucket.last) {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+		bucket.last = now
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// apiKeyStore caches API keys loaded from the cache store and rate limits requests of API keys on this node.
+type apiKeyStore struct {
+	mutex    sync.Mutex
+	keys     map[string]APIKey
+	buckets  map[string]*tokenBucket
+	loadTime time.Time
+}
+
+// invalidate forces API keys to be reloaded from the cache store.
+func (store *apiKeyStore) invalidate() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.loadTime = time.Time{}
+}
+
+// get finds an API key by its hash. API keys are reloaded from the cache store if they are out of date. It also
+// returns the number of API keys.
+func (store *apiKeyStore) get(ctx context.Context, cacheClient cache.Database, hash string) (*APIKey, int, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(store.loadTime) > apiKeyRefreshInterval {
+		keys, err := LoadAPIKeys(ctx, cacheClient)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+		buckets := make(map[string]*tokenBucket)
+		store.keys = make(map[string]APIKey, len(keys))
+		for _, key := range keys {
+			store.keys[key.Hash] = key
+			// keep buckets of API keys whose rate limits are unchanged
+			if bucket, exist := store.buckets[key.Hash]; exist && bucket.rate == key.RateLimit && bucket.burst == float64(lo.Max([]int{key.Burst, 1})) {
+				buckets[key.Hash] = bucket
+			} else if key.RateLimit > 0 {
+				buckets[key.Hash] = newTokenBucket(key.RateLimit, key.Burst, now)
+			}
+		}
+		store.buckets = buckets
+		store.loadTime = now
+	}
+	if key, exist := store.keys[hash]; exist && !key.Revoked {
+		return &key, len(store.keys), nil
+	}
+	return nil, len(store.keys), nil
+}
+
+// allow takes a token from the bucket of an API key. Requests of API keys without rate limits are always allowed.
+func (store *apiKeyStore) allow(hash string) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if bucket, exist := store.buckets[hash]; exist {
+		return bucket.allow(time.Now())
+	}
+	return true
+}
+
+// maxSubjectBuckets is the number of buckets of JWT subjects that triggers pruning of idle buckets.
+const maxSubjectBuckets = 10000
+
+// subjectLimiter rate limits requests of JWT subjects on this node.
+type subjectLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// allow takes a token from the bucket of a subject. Requests are always allowed if the rate is zero.
+func (limiter *subjectLimiter) allow(subject string, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	now := time.Now()
+	bucket, exist := limiter.buckets[subject]
+	if !exist || bucket.rate != rate || bucket.burst != float64(lo.Max([]int{burst, 1})) {
+		if limiter.buckets == nil {
+			limiter.buckets = make(map[string]*tokenBucket)
+		} else if len(limiter.buckets) >= maxSubjectBuckets {
+			// buckets refilled to the burst are the same as new ones
+			for name, b := range limiter.buckets {
+				if b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst {
+					delete(limiter.buckets, name)
+				}
+			}
+		}
+		bucket = newTokenBucket(rate, burst, now)
+		limiter.buckets[subject] = bucket
+	}
+	return bucket.allow(now)
+}
+
+// authenticate checks the API key or the bearer token of a request to an API requiring the scope. It returns the name
+// of the API key or the subject of the JWT. The API key in the configuration is an admin key named "default". All
+// requests are allowed if neither the API key in the configuration, API keys in the cache store nor JWT
+// authentication exist.
+func (s *RestServer) authenticate(ctx context.Context, apikey, token, scope string) (string, error) {
+	if token != "" && s.Config.JWT.Enabled() {
+		return s.authenticateJWT(token, scope)
+	}
+	if s.Config.Server.APIKey != "" && apikey == s.Config.Server.APIKey {
+		APIKeyRequestsTotalVec.WithLabelValues("default", "allowed").Inc()
+		return "default", nil
+	}
+	hash := HashAPIKey(apikey)
+	key, numKeys, err := s.apiKeys.get(ctx, s.CacheClient, hash)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if key == nil {
+		if numKeys == 0 && s.Config.Server.APIKey == "" && !s.Config.JWT.Enabled() {
+			return "", nil
+		}
+		return "", errors.Unauthorizedf("invalid API key")
+	}
+	if !key.HasScope(scope) {
+		APIKeyRequestsTotalVec.WithLabelValues(key.Name, "forbidden").Inc()
+		return key.Name, errors.Forbiddenf("API key %s without scope %s", key.Name, scope)
+	}
+	if !s.apiKeys.allow(hash) {
+		APIKeyRequestsTotalVec.WithLabelValues(key.Name, "rate_limited").Inc()
+		return key.Name, errors.QuotaLimitExceededf("API key %s", key.Name)
+	}
+	APIKeyRequestsTotalVec.WithLabelValues(key.Name, "allowed").Inc()
+	return key.Name, nil
+}
+
+// restAPIScope returns the scope required by a REST-ful API.
+func restAPIScope(method, routePath string) string {
+	switch {
+	case strings.HasPrefix(routePath, "/api/recommend"),
+		strings.HasPrefix(routePath, "/api/session/recommend"),
+		strings.HasPrefix(routePath, "/api/intermediate/recommend"),
+		strings.HasPrefix(routePath, "/api/explain/recommend"),
+		strings.HasPrefix(routePath, "/api/popular"),
+		strings.HasPrefix(routePath, "/api/latest"),
+		strings.HasPrefix(routePath, "/api/trending"),
+		strings.Contains(routePath, "/neighbors/"):
+		return ScopeRecommend
+	case routePath == "/api/feedback" && (method == http.MethodPost || method == http.MethodPut):
+		return ScopeFeedback
+	default:
+		return ScopeAdmin
+	}
+}
+
+// grpcAPIScope returns the scope required by a gRPC API.
+func grpcAPIScope(fullMethod string) string {
+	switch fullMethod[strings.LastIndex(fullMethod, "/")+1:] {
+	case "GetRecommend", "SessionRecommend", "GetItemNeighbors", "GetUserNeighbors", "GetPopular", "GetLatest", "GetTrending":
+		return ScopeRecommend
+	case "InsertFeedback":
+		return ScopeFeedback
+	default:
+		return ScopeAdmin
+	}
+}
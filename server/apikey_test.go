@@ -0,0 +1,180 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKey_Validate(t *testing.T) {
+	assert.NoError(t, (&APIKey{Name: "a", Scopes: []string{ScopeRecommend, ScopeFeedback}}).Validate())
+	assert.NoError(t, (&APIKey{Name: "a", Scopes: []string{ScopeAdmin}, RateLimit: 10, Burst: 20}).Validate())
+	assert.Error(t, (&APIKey{Scopes: []string{ScopeAdmin}}).Validate())
+	assert.Error(t, (&APIKey{Name: "a"}).Validate())
+	assert.Error(t, (&APIKey{Name: "a", Scopes: []string{"unknown"}}).Validate())
+	assert.Error(t, (&APIKey{Name: "a", Scopes: []string{ScopeAdmin}, RateLimit: -1}).Validate())
+}
+
+func TestAPIKey_HasScope(t *testing.T) {
+	key := APIKey{Scopes: []string{ScopeRecommend}}
+	assert.True(t, key.HasScope(ScopeRecommend))
+	assert.False(t, key.HasScope(ScopeFeedback))
+	key = APIKey{Scopes: []string{ScopeAdmin}}
+	assert.True(t, key.HasScope(ScopeRecommend))
+	assert.True(t, key.HasScope(ScopeFeedback))
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, 3, now)
+	assert.True(t, bucket.allow(now))
+	assert.True(t, bucket.allow(now))
+	assert.True(t, bucket.allow(now))
+	assert.False(t, bucket.allow(now))
+	// refill a token in 500ms
+	assert.True(t, bucket.allow(now.Add(500*time.Millisecond)))
+	assert.False(t, bucket.allow(now.Add(500*time.Millisecond)))
+	// tokens are limited by the burst
+	assert.True(t, bucket.allow(now.Add(time.Hour)))
+	assert.True(t, bucket.allow(now.Add(time.Hour)))
+	assert.True(t, bucket.allow(now.Add(time.Hour)))
+	assert.False(t, bucket.allow(now.Add(time.Hour)))
+}
+
+func TestSubjectLimiter(t *testing.T) {
+	var limiter subjectLimiter
+	// no limit
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.allow("alice", 0, 0))
+	}
+	// subjects are limited separately
+	assert.True(t, limiter.allow("alice", 0.001, 2))
+	assert.True(t, limiter.allow("alice", 0.001, 2))
+	assert.False(t, limiter.allow("alice", 0.001, 2))
+	assert.True(t, limiter.allow("bob", 0.001, 2))
+	// buckets are reset if the rate limit changes
+	assert.True(t, limiter.allow("alice", 0.001, 1))
+	assert.False(t, limiter.allow("alice", 0.001, 1))
+}
+
+func TestAPIScope(t *testing.T) {
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodGet, "/api/recommend/{user-id}"))
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodPost, "/api/session/recommend"))
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodGet, "/api/explain/recommend/{user-id}/{category}"))
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodGet, "/api/item/{item-id}/neighbors/{category}"))
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodGet, "/api/trending/{category}"))
+	assert.Equal(t, ScopeRecommend, restAPIScope(http.MethodGet, "/api/popular/{category}"))
+	assert.Equal(t, ScopeFeedback, restAPIScope(http.MethodPost, "/api/feedback"))
+	assert.Equal(t, ScopeFeedback, restAPIScope(http.MethodPut, "/api/feedback"))
+	assert.Equal(t, ScopeAdmin, restAPIScope(http.MethodGet, "/api/feedback"))
+	assert.Equal(t, ScopeAdmin, restAPIScope(http.MethodPost, "/api/item"))
+	assert.Equal(t, ScopeRecommend, grpcAPIScope("/protocol.Gorse/GetRecommend"))
+	assert.Equal(t, ScopeRecommend, grpcAPIScope("/protocol.Gorse/GetTrending"))
+	assert.Equal(t, ScopeFeedback, grpcAPIScope("/protocol.Gorse/InsertFeedback"))
+	assert.Equal(t, ScopeAdmin, grpcAPIScope("/protocol.Gorse/DeleteItem"))
+}
+
+func (suite *ServerTestSuite) TestAPIKeys() {
+	ctx := context.Background()
+	t := suite.T()
+	err := SaveAPIKeys(ctx, suite.CacheClient, []APIKey{
+		{Name: "recommend", Hash: HashAPIKey("recommend_key"), Scopes: []string{ScopeRecommend}},
+		{Name: "feedback", Hash: HashAPIKey("feedback_key"), Scopes: []string{ScopeFeedback}, RateLimit: 0.001, Burst: 1},
+		{Name: "revoked", Hash: HashAPIKey("revoked_key"), Scopes: []string{ScopeAdmin}, Revoked: true},
+	})
+	suite.NoError(err)
+	suite.apiKeys.invalidate()
+	defer suite.apiKeys.invalidate()
+
+	// keys with scopes
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", "recommend_key").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", "recommend_key").
+		JSON([]Feedback{}).
+		Expect(t).
+		Status(http.StatusForbidden).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("X-API-Key", "recommend_key").
+		Expect(t).
+		Status(http.StatusForbidden).
+		End()
+	// rate limited key
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", "feedback_key").
+		JSON([]Feedback{}).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", "feedback_key").
+		JSON([]Feedback{}).
+		Expect(t).
+		Status(http.StatusTooManyRequests).
+		End()
+	// revoked and unknown keys
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("X-API-Key", "revoked_key").
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", "unknown_key").
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		End()
+	// the API key in the configuration is an admin key
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	// all requests are allowed without API keys
+	suite.Config.Server.APIKey = ""
+	suite.NoError(SaveAPIKeys(ctx, suite.CacheClient, nil))
+	suite.apiKeys.invalidate()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+}
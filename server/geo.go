@@ -0,0 +1,163 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/juju/errors"
+	"github.com/zhenghaoz/gorse/protocol"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+)
+
+// GeoFilter restricts items to those located within the radius (in kilometers) of a location.
+type GeoFilter struct {
+	Location cache.Location
+	Radius   float64
+}
+
+// ParseGeoFilter parses the geo filter from query parameters "lat", "lon" and "radius". It returns nil if none of
+// them is given.
+func ParseGeoFilter(request *restful.Request) (*GeoFilter, error) {
+	latitude := request.QueryParameter("lat")
+	longitude := request.QueryParameter("lon")
+	radius := request.QueryParameter("radius")
+	if latitude == "" && longitude == "" && radius == "" {
+		return nil, nil
+	}
+	if latitude == "" || longitude == "" || radius == "" {
+		return nil, errors.NotValidf("lat, lon and radius should be given together, but lat = %q, lon = %q, radius = %q",
+			latitude, longitude, radius)
+	}
+	var (
+		filter GeoFilter
+		err    error
+	)
+	if filter.Location.Latitude, err = strconv.ParseFloat(latitude, 64); err != nil || math.Abs(filter.Location.Latitude) > 90 {
+		return nil, errors.NotValidf("lat %s", latitude)
+	}
+	if filter.Location.Longitude, err = strconv.ParseFloat(longitude, 64); err != nil || math.Abs(filter.Location.Longitude) > 180 {
+		return nil, errors.NotValidf("lon %s", longitude)
+	}
+	if filter.Radius, err = strconv.ParseFloat(radius, 64); err != nil || filter.Radius <= 0 {
+		return nil, errors.NotValidf("radius %s", radius)
+	}
+	return &filter, nil
+}
+
+// fromProtoGeoFilter converts a geo filter of the gRPC API. It returns nil if the geo filter is empty.
+func fromProtoGeoFilter(geo *protocol.GeoFilter) (*GeoFilter, error) {
+	if geo == nil {
+		return nil, nil
+	}
+	if math.Abs(geo.GetLatitude()) > 90 {
+		return nil, errors.NotValidf("lat %v", geo.GetLatitude())
+	}
+	if math.Abs(geo.GetLongitude()) > 180 {
+		return nil, errors.NotValidf("lon %v", geo.GetLongitude())
+	}
+	if geo.GetRadius() <= 0 {
+		return nil, errors.NotValidf("radius %v", geo.GetRadius())
+	}
+	return &GeoFilter{
+		Location: cache.Location{Latitude: geo.GetLatitude(), Longitude: geo.GetLongitude()},
+		Radius:   geo.GetRadius(),
+	}, nil
+}
+
+// itemLocation returns the location of an item from the reserved location label, or nil if the item is not located.
+func itemLocation(labels any) *cache.Location {
+	if latitude, longitude, ok := data.ParseLocation(labels); ok {
+		return &cache.Location{Latitude: latitude, Longitude: longitude}
+	}
+	return nil
+}
+
+// contains checks if an item is located within the radius.
+func (filter *GeoFilter) contains(item *data.Item) bool {
+	location := itemLocation(item.Labels)
+	return location != nil && location.Distance(filter.Location) <= filter.Radius
+}
+
+// distanceDecay returns the decay of the score of an item at the distance (in kilometers). The score is halved every
+// half distance and not decayed if the half distance is zero.
+func (s *RestServer) distanceDecay(distance float64) float64 {
+	if s.Config.Recommend.Geo.HalfDistance <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, distance/s.Config.Recommend.Geo.HalfDistance)
+}
+
+// decayDocuments decays scores of documents by distances to the location and sorts documents by decayed scores.
+func (s *RestServer) decayDocuments(documents []cache.Document, location cache.Location) {
+	for i := range documents {
+		if documents[i].Location != nil {
+			documents[i].Score *= s.distanceDecay(documents[i].Location.Distance(location))
+		}
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].Score > documents[j].Score
+	})
+}
+
+// searchItems searches items in a collection for recommendation. Only items within the radius are returned if the
+// request is located, and their distances are recorded.
+func (s *RestServer) searchItems(ctx *recommendContext, collection, subset string) ([]cache.Document, error) {
+	if ctx.geo == nil {
+		return s.CacheClient.SearchDocuments(ctx.context, collection, subset, []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+	}
+	documents, err := s.CacheClient.SearchNearbyDocuments(ctx.context, collection, subset, []string{ctx.category},
+		ctx.geo.Location, ctx.geo.Radius, 0, s.Config.Recommend.CacheSize)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, document := range documents {
+		ctx.distances[document.Id] = document.Location.Distance(ctx.geo.Location)
+	}
+	return documents, nil
+}
+
+// locateItem checks if an item is within the radius of the request. The distance of the item is recorded.
+func (ctx *recommendContext) locateItem(item *data.Item) bool {
+	if ctx.geo == nil {
+		return true
+	}
+	location := itemLocation(item.Labels)
+	if location == nil {
+		return false
+	}
+	distance := location.Distance(ctx.geo.Location)
+	if distance > ctx.geo.Radius {
+		return false
+	}
+	ctx.distances[item.ItemId] = distance
+	return true
+}
+
+// decayDistance re-ranks recommended items by decaying the relevance of items by distances to the location of the
+// request. The relevance of an item is decided by its position in recommendations.
+func (s *RestServer) decayDistance(ctx *recommendContext) {
+	scores := make(map[string]float64, len(ctx.results))
+	for i, itemId := range ctx.results {
+		scores[itemId] = s.distanceDecay(ctx.distances[itemId]) / float64(i+1)
+	}
+	sort.SliceStable(ctx.results, func(i, j int) bool {
+		return scores[ctx.results[i]] > scores[ctx.results[j]]
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/steinfletcher/apitest"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+)
+
+var (
+	hangzhou = cache.Location{Latitude: 30.27, Longitude: 120.15}
+	shanghai = cache.Location{Latitude: 31.23, Longitude: 121.47}
+	beijing  = cache.Location{Latitude: 39.90, Longitude: 116.40}
+)
+
+func (suite *ServerTestSuite) TestNearbyItems() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Geo.HalfDistance = 100
+	documents := []cache.Document{
+		{Id: "0", Score: 100, Categories: []string{""}, Location: &shanghai},
+		{Id: "1", Score: 99, Categories: []string{""}, Location: &beijing},
+		{Id: "2", Score: 98, Categories: []string{""}},
+		{Id: "3", Score: 97, Categories: []string{""}, Location: &hangzhou},
+	}
+	err := suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", documents)
+	suite.NoError(err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", documents)
+	suite.NoError(err)
+
+	// items out of the radius are removed and scores are decayed by distances
+	decayed := documents[0]
+	decayed.Score *= suite.distanceDecay(shanghai.Distance(hangzhou))
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15", "radius": "200"}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{documents[3], decayed})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15", "radius": "200", "offset": "1"}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{decayed})).
+		End()
+	// latest items are not decayed
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/latest").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15", "radius": "200"}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{documents[0], documents[3]})).
+		End()
+	// invalid parameters
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15"}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "91", "lon": "120.15", "radius": "200"}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithLocation() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Geo.HalfDistance = 100
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"latest"}
+	// insert items
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/items").
+		Header("X-API-Key", apiKey).
+		JSON([]Item{
+			{ItemId: "0", Labels: map[string]any{data.LocationLabel: "31.23,121.47"}, Timestamp: "2023-01-04"},
+			{ItemId: "1", Labels: map[string]any{data.LocationLabel: "39.90,116.40"}, Timestamp: "2023-01-03"},
+			{ItemId: "2", Timestamp: "2023-01-02"},
+			{ItemId: "3", Labels: map[string]any{data.LocationLabel: "30.27,120.15"}, Timestamp: "2023-01-01"},
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/items").
+		Header("X-API-Key", apiKey).
+		JSON([]Item{{ItemId: "4", Labels: map[string]any{data.LocationLabel: "invalid"}}}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+
+	// the nearest item is ranked first
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15", "radius": "200"}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"3", "0"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"0", "1", "2", "3"})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestSessionRecommendWithLocation() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Geo.HalfDistance = 100
+	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"a"}
+	err := suite.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, "9", []cache.Document{
+		{Id: "0", Score: 100, Categories: []string{""}, Location: &shanghai},
+		{Id: "1", Score: 99, Categories: []string{""}, Location: &beijing},
+		{Id: "2", Score: 98, Categories: []string{""}},
+		{Id: "3", Score: 97, Categories: []string{""}, Location: &hangzhou},
+	})
+	suite.NoError(err)
+
+	// items out of the radius are removed and scores are decayed by distances
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/session/recommend").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15", "radius": "200"}).
+		JSON([]Feedback{{FeedbackKey: data.FeedbackKey{FeedbackType: "a", UserId: "0", ItemId: "9"}, Timestamp: "2023-01-01"}}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{
+			{Id: "3", Score: 97},
+			{Id: "0", Score: 100 * suite.distanceDecay(shanghai.Distance(hangzhou))},
+		})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/session/recommend").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"lat": "30.27", "lon": "120.15"}).
+		JSON([]Feedback{{FeedbackKey: data.FeedbackKey{FeedbackType: "a", UserId: "0", ItemId: "9"}, Timestamp: "2023-01-01"}}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+}
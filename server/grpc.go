@@ -0,0 +1,355 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/base/log"
+	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/protocol"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// StartGrpcServer starts the gRPC API server.
+func (s *RestServer) StartGrpcServer() {
+	log.Logger().Info("start grpc server",
+		zap.String("host", s.HttpHost),
+		zap.Int("port", s.GrpcPort))
+	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.HttpHost, s.GrpcPort))
+	if err != nil {
+		log.Logger().Fatal("failed to listen", zap.Error(err))
+	}
+	s.GrpcServer = grpc.NewServer(grpc.MaxSendMsgSize(math.MaxInt), grpc.UnaryInterceptor(s.UnaryInterceptor))
+	protocol.RegisterGorseServer(s.GrpcServer, &grpcServer{RestServer: s})
+	if err = s.GrpcServer.Serve(lis); err != nil {
+		log.Logger().Fatal("failed to start grpc server", zap.Error(err))
+	}
+}
+
+// UnaryInterceptor authenticates gRPC requests by the API key in metadata, then logs requests and records
+// request latencies as the filters of REST-ful APIs.
+func (s *RestServer) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var apikey, token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			apikey = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = bearerToken(values[0])
+		}
+	}
+	if name, err := s.authenticate(ctx, apikey, token, grpcAPIScope(info.FullMethod)); err != nil {
+		log.Logger().Error("unauthorized", zap.String("method", info.FullMethod),
+			zap.String("api_key_name", name), zap.Error(err))
+		switch {
+		case errors.Is(err, errors.Unauthorized):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, errors.Forbidden):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, errors.QuotaLimitExceeded):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	startTime := time.Now()
+	resp, err := handler(ctx, req)
+	responseTime := time.Since(startTime)
+	if err == nil {
+		RestAPIRequestSecondsVec.WithLabelValues(fmt.Sprintf("GRPC %s", info.FullMethod)).
+			Observe(responseTime.Seconds())
+	}
+	if !s.DisableLog {
+		log.Logger().Info(fmt.Sprintf("GRPC %s", info.FullMethod),
+			zap.String("status_code", status.Code(err).String()),
+			zap.Duration("response_time", responseTime))
+	}
+	return resp, err
+}
+
+// grpcServer implements the gRPC API by the handlers of the REST-ful API server.
+type grpcServer struct {
+	protocol.UnimplementedGorseServer
+	*RestServer
+}
+
+// grpcError converts an error to a gRPC status error.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, errors.NotValid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errors.NotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errors.AlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		log.Logger().Error("internal server error", zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// parsePage returns the default number of items if n is zero.
+func (s *grpcServer) parsePage(n, offset int32) (int, int, error) {
+	if n < 0 || offset < 0 {
+		return 0, 0, errors.NotValidf("n = %d, offset = %d", n, offset)
+	}
+	if n == 0 {
+		return s.Config.Server.DefaultN, int(offset), nil
+	}
+	return int(n), int(offset), nil
+}
+
+func (s *grpcServer) GetRecommend(ctx context.Context, in *protocol.RecommendRequest) (*protocol.RecommendResponse, error) {
+	n, offset, err := s.parsePage(in.GetN(), in.GetOffset())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	var writeBackDelay time.Duration
+	if in.GetWriteBackDelay() != "" {
+		if writeBackDelay, err = time.ParseDuration(in.GetWriteBackDelay()); err != nil {
+			return nil, grpcError(errors.NewNotValid(err, "invalid write back delay"))
+		}
+	}
+	geo, err := fromProtoGeoFilter(in.GetGeo())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	options, err := s.newRecommendOptions(in.GetScene(), in.GetRecommenders(), in.ExcludeRead)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	results, err := s.onlineRecommend(ctx, log.Logger(), in.GetUserId(), in.GetCategory(), n, offset,
+		in.GetContext(), geo, options, nil, in.GetWriteBackType(), writeBackDelay)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.RecommendResponse{
+		ItemIds: results,
+		Variants: lo.Map(s.Config.Recommend.AssignVariants(in.GetUserId()), func(variant config.Variant, _ int) string {
+			return variant.String()
+		}),
+	}, nil
+}
+
+func (s *grpcServer) SessionRecommend(ctx context.Context, in *protocol.SessionRecommendRequest) (*protocol.DocumentsResponse, error) {
+	n, offset, err := s.parsePage(in.GetN(), in.GetOffset())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	feedback := make([]data.Feedback, len(in.GetFeedback()))
+	for i, f := range in.GetFeedback() {
+		if feedback[i], err = fromProtoFeedback(f).ToDataFeedback(); err != nil {
+			return nil, grpcError(errors.NewNotValid(err, "invalid timestamp"))
+		}
+	}
+	geo, err := fromProtoGeoFilter(in.GetGeo())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	documents, err := s.recommendSession(ctx, feedback, in.GetCategory(), geo, n, offset)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoDocuments(documents), nil
+}
+
+func (s *grpcServer) GetItemNeighbors(ctx context.Context, in *protocol.NeighborsRequest) (*protocol.DocumentsResponse, error) {
+	return s.listDocuments(ctx, cache.ItemNeighbors, in.GetId(), in.GetCategory(), true, "", in.GetN(), in.GetOffset(), nil)
+}
+
+func (s *grpcServer) GetUserNeighbors(ctx context.Context, in *protocol.NeighborsRequest) (*protocol.DocumentsResponse, error) {
+	return s.listDocuments(ctx, cache.UserNeighbors, in.GetId(), "", false, "", in.GetN(), in.GetOffset(), nil)
+}
+
+func (s *grpcServer) GetPopular(ctx context.Context, in *protocol.NonPersonalizedRequest) (*protocol.DocumentsResponse, error) {
+	return s.listDocuments(ctx, cache.PopularItems, "", in.GetCategory(), true, in.GetUserId(), in.GetN(), in.GetOffset(), in.GetGeo())
+}
+
+func (s *grpcServer) GetLatest(ctx context.Context, in *protocol.NonPersonalizedRequest) (*protocol.DocumentsResponse, error) {
+	return s.listDocuments(ctx, cache.LatestItems, "", in.GetCategory(), true, in.GetUserId(), in.GetN(), in.GetOffset(), in.GetGeo())
+}
+
+func (s *grpcServer) GetTrending(ctx context.Context, in *protocol.NonPersonalizedRequest) (*protocol.DocumentsResponse, error) {
+	return s.listDocuments(ctx, cache.TrendingItems, "", in.GetCategory(), true, in.GetUserId(), in.GetN(), in.GetOffset(), in.GetGeo())
+}
+
+func (s *grpcServer) listDocuments(ctx context.Context, collection, subset, category string, isItem bool, userId string, n, offset int32, in *protocol.GeoFilter) (*protocol.DocumentsResponse, error) {
+	numDocuments, begin, err := s.parsePage(n, offset)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	geo, err := fromProtoGeoFilter(in)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	documents, err := s.getDocuments(ctx, collection, subset, category, isItem, userId, numDocuments, begin, geo)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoDocuments(documents), nil
+}
+
+func (s *grpcServer) InsertFeedback(ctx context.Context, in *protocol.InsertFeedbackRequest) (*protocol.RowAffected, error) {
+	feedback := lo.Map(in.GetFeedback(), func(f *protocol.Feedback, _ int) Feedback {
+		return fromProtoFeedback(f)
+	})
+	result, replayed, err := s.insertFeedbackIdempotently(ctx, log.Logger(), in.GetIdempotencyKey(), feedback,
+		in.GetOverwrite(), in.GetPartial())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if replayed {
+		if err = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(IdempotentReplayedHeader), "true")); err != nil {
+			log.Logger().Error("failed to set header", zap.Error(err))
+		}
+	}
+	return &protocol.RowAffected{
+		RowAffected: int64(result.RowAffected),
+		Errors: lo.Map(result.Errors, func(rowError RowError, _ int) *protocol.RowError {
+			return &protocol.RowError{Index: int32(rowError.Index), Error: rowError.Error}
+		}),
+	}, nil
+}
+
+func (s *grpcServer) InsertUser(ctx context.Context, in *protocol.User) (*protocol.RowAffected, error) {
+	user := data.User{
+		UserId:    in.GetUserId(),
+		Subscribe: in.GetSubscribe(),
+		Comment:   in.GetComment(),
+	}
+	if err := unmarshalLabels(in.GetLabels(), &user.Labels); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.saveUser(ctx, user); err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.RowAffected{RowAffected: 1}, nil
+}
+
+func (s *grpcServer) GetUser(ctx context.Context, in *protocol.UserRequest) (*protocol.User, error) {
+	user, err := s.DataClient.GetUser(ctx, in.GetUserId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	labels, err := json.Marshal(user.Labels)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.User{
+		UserId:    user.UserId,
+		Labels:    string(labels),
+		Subscribe: user.Subscribe,
+		Comment:   user.Comment,
+	}, nil
+}
+
+func (s *grpcServer) DeleteUser(ctx context.Context, in *protocol.UserRequest) (*protocol.RowAffected, error) {
+	if err := s.DataClient.DeleteUser(ctx, in.GetUserId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.RowAffected{RowAffected: 1}, nil
+}
+
+func (s *grpcServer) InsertItem(ctx context.Context, in *protocol.Item) (*protocol.RowAffected, error) {
+	item := Item{
+		ItemId:     in.GetItemId(),
+		IsHidden:   in.GetIsHidden(),
+		Categories: in.GetCategories(),
+		Timestamp:  in.GetTimestamp(),
+		Comment:    in.GetComment(),
+	}
+	if err := unmarshalLabels(in.GetLabels(), &item.Labels); err != nil {
+		return nil, grpcError(err)
+	}
+	count, err := s.batchInsertItems(ctx, log.Logger(), []Item{item})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.RowAffected{RowAffected: int64(count)}, nil
+}
+
+func (s *grpcServer) GetItem(ctx context.Context, in *protocol.ItemRequest) (*protocol.Item, error) {
+	item, err := s.DataClient.GetItem(ctx, in.GetItemId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	labels, err := json.Marshal(item.Labels)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.Item{
+		ItemId:     item.ItemId,
+		IsHidden:   item.IsHidden,
+		Categories: item.Categories,
+		Timestamp:  item.Timestamp.Format(time.RFC3339Nano),
+		Labels:     string(labels),
+		Comment:    item.Comment,
+	}, nil
+}
+
+func (s *grpcServer) DeleteItem(ctx context.Context, in *protocol.ItemRequest) (*protocol.RowAffected, error) {
+	if err := s.removeItem(ctx, in.GetItemId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &protocol.RowAffected{RowAffected: 1}, nil
+}
+
+// unmarshalLabels decodes labels encoded in JSON. Labels are left empty if the JSON is empty.
+func unmarshalLabels(labels string, v *any) error {
+	if labels == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(labels), v); err != nil {
+		return errors.NewNotValid(err, "invalid labels")
+	}
+	return nil
+}
+
+func fromProtoFeedback(f *protocol.Feedback) Feedback {
+	return Feedback{
+		FeedbackKey: data.FeedbackKey{
+			FeedbackType: f.GetFeedbackType(),
+			UserId:       f.GetUserId(),
+			ItemId:       f.GetItemId(),
+		},
+		Timestamp: f.GetTimestamp(),
+		Comment:   f.GetComment(),
+		Value:     f.GetValue(),
+	}
+}
+
+func toProtoDocuments(documents []cache.Document) *protocol.DocumentsResponse {
+	return &protocol.DocumentsResponse{
+		Documents: lo.Map(documents, func(document cache.Document, _ int) *protocol.Document {
+			return &protocol.Document{Id: document.Id, Score: document.Score}
+		}),
+	}
+}
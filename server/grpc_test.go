@@ -0,0 +1,258 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/protocol"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (suite *ServerTestSuite) newGrpcClient() (protocol.GorseClient, func()) {
+	listen, err := net.Listen("tcp", "localhost:0")
+	suite.NoError(err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(suite.UnaryInterceptor))
+	protocol.RegisterGorseServer(server, &grpcServer{RestServer: &suite.RestServer})
+	go func() {
+		_ = server.Serve(listen)
+	}()
+	conn, err := grpc.Dial(listen.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	suite.NoError(err)
+	return protocol.NewGorseClient(conn), func() {
+		suite.NoError(conn.Close())
+		server.Stop()
+	}
+}
+
+func (suite *ServerTestSuite) TestGrpc() {
+	client, stop := suite.newGrpcClient()
+	defer stop()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey)
+
+	// unauthorized
+	_, err := client.GetUser(context.Background(), &protocol.UserRequest{UserId: "0"})
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+
+	// users
+	resp, err := client.InsertUser(ctx, &protocol.User{UserId: "0", Labels: `["a","b"]`, Comment: "comment"})
+	suite.NoError(err)
+	suite.Equal(int64(1), resp.GetRowAffected())
+	user, err := client.GetUser(ctx, &protocol.UserRequest{UserId: "0"})
+	suite.NoError(err)
+	suite.Equal("0", user.GetUserId())
+	suite.JSONEq(`["a","b"]`, user.GetLabels())
+	suite.Equal("comment", user.GetComment())
+	_, err = client.InsertUser(ctx, &protocol.User{UserId: "1", Labels: `{`})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+	_, err = client.DeleteUser(ctx, &protocol.UserRequest{UserId: "0"})
+	suite.NoError(err)
+	_, err = client.GetUser(ctx, &protocol.UserRequest{UserId: "0"})
+	suite.Equal(codes.NotFound, status.Code(err))
+
+	// items
+	resp, err = client.InsertItem(ctx, &protocol.Item{ItemId: "0", Categories: []string{"a"}, Timestamp: "2023-01-01T00:00:00Z", Labels: `["a"]`})
+	suite.NoError(err)
+	suite.Equal(int64(1), resp.GetRowAffected())
+	item, err := client.GetItem(ctx, &protocol.ItemRequest{ItemId: "0"})
+	suite.NoError(err)
+	suite.Equal([]string{"a"}, item.GetCategories())
+	suite.Equal("2023-01-01T00:00:00Z", item.GetTimestamp())
+	suite.JSONEq(`["a"]`, item.GetLabels())
+	_, err = client.InsertItem(ctx, &protocol.Item{ItemId: "1", Timestamp: "invalid"})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+	_, err = client.DeleteItem(ctx, &protocol.ItemRequest{ItemId: "0"})
+	suite.NoError(err)
+	_, err = client.GetItem(ctx, &protocol.ItemRequest{ItemId: "0"})
+	suite.Equal(codes.NotFound, status.Code(err))
+
+	// feedback
+	resp, err = client.InsertFeedback(ctx, &protocol.InsertFeedbackRequest{Feedback: []*protocol.Feedback{
+		{FeedbackType: "click", UserId: "0", ItemId: "1"},
+		{FeedbackType: "click", UserId: "0", ItemId: "3"},
+	}})
+	suite.NoError(err)
+	suite.Equal(int64(2), resp.GetRowAffected())
+	_, err = client.InsertFeedback(ctx, &protocol.InsertFeedbackRequest{Feedback: []*protocol.Feedback{
+		{FeedbackType: "click", UserId: "0", ItemId: "1", Timestamp: "invalid"},
+	}})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	// non-personalized recommendation
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", []cache.Document{
+		{Id: "1", Score: 100, Categories: []string{""}},
+		{Id: "2", Score: 99, Categories: []string{""}},
+		{Id: "3", Score: 98, Categories: []string{""}},
+		{Id: "4", Score: 97, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	popular, err := client.GetPopular(ctx, &protocol.NonPersonalizedRequest{N: 2, Offset: 1})
+	suite.NoError(err)
+	suite.Equal([]string{"2", "3"}, lo.Map(popular.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+	popular, err = client.GetPopular(ctx, &protocol.NonPersonalizedRequest{UserId: "0"})
+	suite.NoError(err)
+	suite.Equal([]string{"2", "4"}, lo.Map(popular.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+	_, err = client.GetPopular(ctx, &protocol.NonPersonalizedRequest{N: -1})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+	err = suite.CacheClient.AddDocuments(ctx, cache.TrendingItems, "", []cache.Document{
+		{Id: "3", Score: 100, Categories: []string{""}},
+		{Id: "5", Score: 99, Categories: []string{"a"}},
+	})
+	suite.NoError(err)
+	trending, err := client.GetTrending(ctx, &protocol.NonPersonalizedRequest{})
+	suite.NoError(err)
+	suite.Equal([]string{"3"}, lo.Map(trending.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+	trending, err = client.GetTrending(ctx, &protocol.NonPersonalizedRequest{Category: "a"})
+	suite.NoError(err)
+	suite.Equal([]string{"5"}, lo.Map(trending.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+
+	// located recommendation
+	err = suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{
+		{Id: "6", Score: 100, Categories: []string{""}, Location: &hangzhou},
+		{Id: "7", Score: 99, Categories: []string{""}, Location: &beijing},
+	})
+	suite.NoError(err)
+	latest, err := client.GetLatest(ctx, &protocol.NonPersonalizedRequest{Geo: &protocol.GeoFilter{Latitude: 30.27, Longitude: 120.15, Radius: 200}})
+	suite.NoError(err)
+	suite.Equal([]string{"6"}, lo.Map(latest.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+	_, err = client.GetLatest(ctx, &protocol.NonPersonalizedRequest{Geo: &protocol.GeoFilter{Latitude: 91, Longitude: 120.15, Radius: 200}})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+
+	// personalized recommendation
+	err = suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "1", Score: 100, Categories: []string{""}},
+		{Id: "2", Score: 99, Categories: []string{""}},
+		{Id: "3", Score: 98, Categories: []string{""}},
+		{Id: "4", Score: 97, Categories: []string{""}},
+		{Id: "5", Score: 96, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	recommend, err := client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", N: 2})
+	suite.NoError(err)
+	suite.Equal([]string{"2", "4"}, recommend.GetItemIds())
+	recommend, err = client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", N: 2, Offset: 1})
+	suite.NoError(err)
+	suite.Equal([]string{"4", "5"}, recommend.GetItemIds())
+
+	// session recommendation
+	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"a"}
+	err = suite.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, "1", []cache.Document{
+		{Id: "2", Score: 100000, Categories: []string{""}},
+		{Id: "9", Score: 1, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	session, err := client.SessionRecommend(ctx, &protocol.SessionRecommendRequest{Feedback: []*protocol.Feedback{
+		{FeedbackType: "a", UserId: "0", ItemId: "1"},
+	}})
+	suite.NoError(err)
+	suite.Equal([]string{"2", "9"}, lo.Map(session.GetDocuments(), func(document *protocol.Document, _ int) string {
+		return document.GetId()
+	}))
+	neighbors, err := client.GetItemNeighbors(ctx, &protocol.NeighborsRequest{Id: "1", N: 1})
+	suite.NoError(err)
+	suite.Len(neighbors.GetDocuments(), 1)
+	suite.Equal("2", neighbors.GetDocuments()[0].GetId())
+	suite.Equal(100000.0, neighbors.GetDocuments()[0].GetScore())
+}
+
+func (suite *ServerTestSuite) TestGrpcRecommendOptions() {
+	client, stop := suite.newGrpcClient()
+	defer stop()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey)
+	suite.Config.Recommend.Scenes = map[string]config.SceneConfig{
+		"detail": {Recommenders: []string{"offline:1", "popular"}, ExcludeRead: lo.ToPtr(false)},
+	}
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "0", Score: 100, Categories: []string{""}},
+		{Id: "1", Score: 99, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", []cache.Document{
+		{Id: "2", Score: 10, Categories: []string{""}},
+		{Id: "3", Score: 9, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{{
+		FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "0"},
+		Timestamp:   time.Now().Add(-time.Hour),
+	}}, true, true, true)
+	suite.NoError(err)
+
+	recommend, err := client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", N: 4, Scene: "detail"})
+	suite.NoError(err)
+	suite.Equal([]string{"0", "2", "3"}, recommend.GetItemIds())
+	recommend, err = client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", N: 4, Scene: "detail", ExcludeRead: lo.ToPtr(true)})
+	suite.NoError(err)
+	suite.Equal([]string{"1", "2", "3"}, recommend.GetItemIds())
+	recommend, err = client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", N: 4, Recommenders: []string{"popular", "offline"}})
+	suite.NoError(err)
+	suite.Equal([]string{"2", "3", "1"}, recommend.GetItemIds())
+	_, err = client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", Scene: "unknown"})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+	_, err = client.GetRecommend(ctx, &protocol.RecommendRequest{UserId: "0", Recommenders: []string{"unknown"}})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (suite *ServerTestSuite) TestGrpcInsertFeedback() {
+	client, stop := suite.newGrpcClient()
+	defer stop()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey)
+	feedback := []*protocol.Feedback{
+		{FeedbackType: "click", UserId: "0", ItemId: "0"},
+		{FeedbackType: "click", UserId: "0", ItemId: "1", Timestamp: "invalid"},
+	}
+
+	// valid feedback is inserted in the partial mode
+	var header metadata.MD
+	resp, err := client.InsertFeedback(ctx, &protocol.InsertFeedbackRequest{Feedback: feedback, Partial: true, IdempotencyKey: "request-0"}, grpc.Header(&header))
+	suite.NoError(err)
+	suite.Equal(int64(1), resp.GetRowAffected())
+	suite.Len(resp.GetErrors(), 1)
+	suite.Equal(int32(1), resp.GetErrors()[0].GetIndex())
+	suite.Empty(header.Get(IdempotentReplayedHeader))
+	// the result is replayed for the same idempotency key
+	_, err = suite.DataClient.DeleteUserItemFeedback(ctx, "0", "0")
+	suite.NoError(err)
+	resp, err = client.InsertFeedback(ctx, &protocol.InsertFeedbackRequest{Feedback: feedback, Partial: true, IdempotencyKey: "request-0"}, grpc.Header(&header))
+	suite.NoError(err)
+	suite.Equal(int64(1), resp.GetRowAffected())
+	suite.Equal([]string{"true"}, header.Get(IdempotentReplayedHeader))
+	userFeedback, err := suite.DataClient.GetUserFeedback(ctx, "0", nil)
+	suite.NoError(err)
+	suite.Empty(userFeedback)
+	// the key can't be reused for another request
+	_, err = client.InsertFeedback(ctx, &protocol.InsertFeedbackRequest{Feedback: feedback[:1], IdempotencyKey: "request-0"})
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/zhenghaoz/gorse/storage/cache"
+)
+
+const (
+	// IdempotencyKeyHeader is the header of idempotency keys.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set if the response is the remembered result of a previous request.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength is the max length of idempotency keys.
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyRecord is the result of a request with an idempotency key. The key is reserved by an in-flight record
+// before the request is processed.
+type IdempotencyRecord struct {
+	RequestHash string
+	InFlight    bool
+	Result      Success
+	Timestamp   time.Time
+}
+
+// Expired checks if the record is out of the time-to-live.
+func (record *IdempotencyRecord) Expired(ttl time.Duration) bool {
+	return time.Since(record.Timestamp) > ttl
+}
+
+// hashRequest returns the SHA-256 hash of a request body.
+func hashRequest(body any) (string, error) {
+	bytes, err := json.Marshal(body)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	hash := sha256.Sum256(bytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// LoadIdempotencyRecord loads the result of a request with an idempotency key from the cache store. Records out of
+// the time-to-live are not found and corrupted records are not valid.
+func LoadIdempotencyRecord(ctx context.Context, cacheClient cache.Database, key string, ttl time.Duration) (*IdempotencyRecord, error) {
+	value, err := cacheClient.Get(ctx, cache.Key(cache.IdempotencyKeys, key)).String()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return parseIdempotencyRecord(key, value, ttl)
+}
+
+// parseIdempotencyRecord parses an idempotency record stored in the cache store.
+func parseIdempotencyRecord(key, value string, ttl time.Duration) (*IdempotencyRecord, error) {
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return nil, errors.NewNotValid(err, fmt.Sprintf("idempotency record %s", key))
+	}
+	if record.Expired(ttl) {
+		return nil, errors.NotFoundf("idempotency key %s", key)
+	}
+	return &record, nil
+}
+
+// SaveIdempotencyRecord saves the result of a request with an idempotency key to the cache store.
+func SaveIdempotencyRecord(ctx context.Context, cacheClient cache.Database, key string, record IdempotencyRecord) error {
+	bytes, err := json.Marshal(record)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return cacheClient.Set(ctx, cache.String(cache.Key(cache.IdempotencyKeys, key), string(bytes)))
+}
+
+// ReserveIdempotencyKey saves an in-flight record to the cache store if the idempotency key is absent. It returns
+// true if the key is reserved.
+func ReserveIdempotencyKey(ctx context.Context, cacheClient cache.Database, key, requestHash string) (bool, error) {
+	bytes, err := json.Marshal(IdempotencyRecord{RequestHash: requestHash, InFlight: true, Timestamp: time.Now()})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return cacheClient.SetNX(ctx, cache.String(cache.Key(cache.IdempotencyKeys, key), string(bytes)))
+}
+
+// DeleteIdempotencyRecord deletes the record of an idempotency key from the cache store.
+func DeleteIdempotencyRecord(ctx context.Context, cacheClient cache.Database, key string) error {
+	return cacheClient.Delete(ctx, cache.Key(cache.IdempotencyKeys, key))
+}
+
+// reserveIdempotentRequest reserves an idempotency key for a request. It returns the remembered result of a previous
+// request with the same key if the key has been used. Expired or corrupted records are taken over only if they are
+// unchanged since being read. Reusing a key for a different request is not valid and a key reserved by a request in
+// flight already exists.
+func (s *RestServer) reserveIdempotentRequest(ctx context.Context, key, requestHash string) (*Success, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errors.NotValidf("idempotency key longer than %d", maxIdempotencyKeyLength)
+	}
+	for i := 0; i < 2; i++ {
+		reserved, err := ReserveIdempotencyKey(ctx, s.CacheClient, key, requestHash)
+		if err != nil {
+			return nil, errors.Trace(err)
+		} else if reserved {
+			return nil, nil
+		}
+		value, err := s.CacheClient.Get(ctx, cache.Key(cache.IdempotencyKeys, key)).String()
+		if errors.Is(err, errors.NotFound) {
+			// the record has been deleted since the reservation failed
+			continue
+		} else if err != nil {
+			return nil, errors.Trace(err)
+		}
+		record, err := parseIdempotencyRecord(key, value, s.Config.Server.IdempotencyKeyTTL)
+		if errors.Is(err, errors.NotFound) || errors.Is(err, errors.NotValid) {
+			// take over the expired or corrupted record unless it has been replaced by another request
+			if _, err = s.CacheClient.CompareAndDelete(ctx, cache.Key(cache.IdempotencyKeys, key), value); err != nil {
+				return nil, errors.Trace(err)
+			}
+			continue
+		} else if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if record.RequestHash != requestHash {
+			return nil, errors.NotValidf("idempotency key %s reused for a different request", key)
+		}
+		if record.InFlight {
+			return nil, errors.AlreadyExistsf("request with idempotency key %s in flight", key)
+		}
+		return &record.Result, nil
+	}
+	return nil, errors.AlreadyExistsf("request with idempotency key %s in flight", key)
+}
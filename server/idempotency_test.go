@@ -0,0 +1,207 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/juju/errors"
+	"github.com/steinfletcher/apitest"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+)
+
+func (suite *ServerTestSuite) TestIdempotentFeedback() {
+	ctx := context.Background()
+	t := suite.T()
+	feedback := []Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "0"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "1"}},
+	}
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-0").
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(Success{RowAffected: 2})).
+		End()
+	// the result is replayed without insertion
+	_, err := suite.DataClient.DeleteUserItemFeedback(ctx, "0", "0")
+	suite.NoError(err)
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-0").
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Header(IdempotentReplayedHeader, "true").
+		Body(suite.marshal(Success{RowAffected: 2})).
+		End()
+	userFeedback, err := suite.DataClient.GetUserFeedback(ctx, "0", nil)
+	suite.NoError(err)
+	suite.Len(userFeedback, 1)
+	// the key can't be reused for another request
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-0").
+		JSON(feedback[:1]).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, strings.Repeat("a", maxIdempotencyKeyLength+1)).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	// expired results are not replayed
+	suite.Config.Server.IdempotencyKeyTTL = time.Nanosecond
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-0").
+		JSON(feedback[:1]).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(Success{RowAffected: 1})).
+		End()
+	_, err = LoadIdempotencyRecord(ctx, suite.CacheClient, "request-0", time.Nanosecond)
+	suite.True(errors.Is(err, errors.NotFound))
+}
+
+func (suite *ServerTestSuite) TestPartialFeedback() {
+	ctx := context.Background()
+	t := suite.T()
+	feedback := []Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "0"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "1"}, Timestamp: "invalid"},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "2"}, Timestamp: "2023-01-01"},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "", ItemId: "3"}},
+	}
+	// one invalid feedback rejects the whole batch
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	// valid feedback is inserted in the partial mode
+	_, err := dateparse.ParseAny("invalid")
+	suite.Error(err)
+	rowErrors := []RowError{
+		{Index: 1, Error: errors.NewNotValid(err, "invalid timestamp").Error()},
+		{Index: 3, Error: errors.NotValidf("feedback without feedback type, user id or item id").Error()},
+	}
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"partial": "true"}).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(Success{RowAffected: 2, Errors: rowErrors})).
+		End()
+	userFeedback, err := suite.DataClient.GetUserFeedback(ctx, "0", nil)
+	suite.NoError(err)
+	suite.Len(userFeedback, 2)
+	// the partial mode must be a boolean
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{"partial": "yes"}).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+}
+
+func (suite *ServerTestSuite) TestIdempotentFeedbackInFlight() {
+	ctx := context.Background()
+	t := suite.T()
+	feedback := []Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "0"}},
+	}
+	requestHash, err := hashRequest([]any{feedback, false, false})
+	suite.NoError(err)
+	// the key reserved by a request in flight conflicts
+	reserved, err := ReserveIdempotencyKey(ctx, suite.CacheClient, "request-0", requestHash)
+	suite.NoError(err)
+	suite.True(reserved)
+	reserved, err = ReserveIdempotencyKey(ctx, suite.CacheClient, "request-0", requestHash)
+	suite.NoError(err)
+	suite.False(reserved)
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-0").
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusConflict).
+		End()
+	userFeedback, err := suite.DataClient.GetUserFeedback(ctx, "0", nil)
+	suite.NoError(err)
+	suite.Empty(userFeedback)
+	// the corrupted record is taken over
+	err = suite.CacheClient.Set(ctx, cache.String(cache.Key(cache.IdempotencyKeys, "request-1"), "corrupted"))
+	suite.NoError(err)
+	_, err = LoadIdempotencyRecord(ctx, suite.CacheClient, "request-1", suite.Config.Server.IdempotencyKeyTTL)
+	suite.True(errors.Is(err, errors.NotValid))
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-1").
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(Success{RowAffected: 1})).
+		End()
+	record, err := LoadIdempotencyRecord(ctx, suite.CacheClient, "request-1", suite.Config.Server.IdempotencyKeyTTL)
+	suite.NoError(err)
+	suite.False(record.InFlight)
+	suite.Equal(Success{RowAffected: 1}, record.Result)
+	// the key is released if the request fails
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		Header(IdempotencyKeyHeader, "request-2").
+		JSON([]Feedback{{FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "1"}, Timestamp: "invalid"}}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	_, err = LoadIdempotencyRecord(ctx, suite.CacheClient, "request-2", suite.Config.Server.IdempotencyKeyTTL)
+	suite.True(errors.Is(err, errors.NotFound))
+}
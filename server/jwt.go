@@ -0,0 +1,335 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/base/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// jwksRefreshInterval is the interval to reload the JSON web key set.
+	jwksRefreshInterval = 10 * time.Minute
+	// jwksMinRefreshInterval is the minimal interval to reload the JSON web key set for unknown key IDs.
+	jwksMinRefreshInterval = time.Minute
+	// jwtLeeway tolerates the clock skew between the issuer and servers in validating time claims of JWTs.
+	jwtLeeway = 30 * time.Second
+)
+
+// JWTClaims are claims in the payload of a JWT.
+type JWTClaims map[string]any
+
+// Subject returns the subject ("sub") of the JWT.
+func (claims JWTClaims) Subject() string {
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// Roles returns roles in a claim. The claim could be a string or an array of strings, and nested claims are separated
+// by dots, e.g. "realm_access.roles".
+func (claims JWTClaims) Roles(claim string) []string {
+	var value any = map[string]any(claims)
+	for _, name := range strings.Split(claim, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		var roles []string
+		for _, role := range value {
+			if role, ok := role.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
+
+// jwtAlgorithms are algorithms of JWT signatures by public keys.
+var jwtAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// parseJWKS parses public keys for signatures in a JSON web key set. Keys are indexed by key IDs.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.Trace(err)
+	}
+	// keys for encryption are ignored
+	jwks.Keys = lo.Filter(jwks.Keys, func(key json.RawMessage, _ int) bool {
+		var params struct {
+			Use string `json:"use"`
+		}
+		return json.Unmarshal(key, &params) == nil && (params.Use == "" || params.Use == "sig")
+	})
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	parsed, err := keyfunc.NewJSON(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// only RSA and ECDSA public keys are supported
+	keys := make(map[string]crypto.PublicKey)
+	for kid, key := range parsed.ReadOnlyKeys() {
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey:
+			keys[kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// loadJWKS loads a JSON web key set from a URL or a file.
+func loadJWKS(source string) (map[string]crypto.PublicKey, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("failed to load JWKS from %s: %s", source, resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return parseJWKS(data)
+}
+
+// jwksStore caches the JSON web key set loaded from a URL or a file. The last loaded keys are kept if reloading
+// fails, and reloading is retried with exponential backoff.
+type jwksStore struct {
+	mutex    sync.Mutex
+	source   string
+	keys     map[string]crypto.PublicKey
+	loadTime time.Time
+	loadErr  error
+	failures int
+	failTime time.Time
+	loading  chan struct{} // closed once the loading in progress is finished
+}
+
+// backoff returns the interval to retry loading after failures.
+func (store *jwksStore) backoff() time.Duration {
+	if store.failures > 6 {
+		return jwksMinRefreshInterval
+	}
+	return lo.Min([]time.Duration{time.Second << (store.failures - 1), jwksMinRefreshInterval})
+}
+
+// stale checks if the JSON web key set should be reloaded.
+func (store *jwksStore) stale(now time.Time, kid string) bool {
+	if store.failures > 0 && now.Sub(store.failTime) < store.backoff() {
+		return false
+	}
+	_, exist := store.keys[kid]
+	return store.keys == nil || now.Sub(store.loadTime) > jwksRefreshInterval ||
+		(!exist && now.Sub(store.loadTime) > jwksMinRefreshInterval)
+}
+
+// lookup finds a public key by its ID. The only key is returned if the key ID is empty.
+func (store *jwksStore) lookup(kid string) (crypto.PublicKey, error) {
+	if key, exist := store.keys[kid]; exist {
+		return key, nil
+	}
+	if kid == "" && len(store.keys) == 1 {
+		return lo.Values(store.keys)[0], nil
+	}
+	if store.keys == nil && store.loadErr != nil {
+		return nil, errors.Trace(store.loadErr)
+	}
+	return nil, errors.Unauthorizedf("unknown JWT key %s", kid)
+}
+
+// get finds a public key by its ID. The JSON web key set is reloaded without holding the lock if it is out of date
+// or the key ID is unknown. Only requests arriving before any keys are loaded wait for the loading.
+func (store *jwksStore) get(source, kid string) (crypto.PublicKey, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if source != store.source {
+		store.source = source
+		store.keys = nil
+		store.loadErr = nil
+		store.failures = 0
+	}
+	for store.stale(time.Now(), kid) {
+		if store.loading != nil {
+			if store.keys != nil {
+				// use current keys during reloading
+				break
+			}
+			loading := store.loading
+			store.mutex.Unlock()
+			<-loading
+			store.mutex.Lock()
+			continue
+		}
+		loading := make(chan struct{})
+		store.loading = loading
+		store.mutex.Unlock()
+		keys, err := loadJWKS(source)
+		store.mutex.Lock()
+		store.loading = nil
+		close(loading)
+		if source != store.source {
+			return nil, errors.Unauthorizedf("JWKS source changed during loading")
+		}
+		if err != nil {
+			log.Logger().Error("failed to load JWKS", zap.String("source", source), zap.Error(err))
+			store.loadErr = err
+			store.failures++
+			store.failTime = time.Now()
+		} else {
+			store.keys = keys
+			store.loadErr = nil
+			store.failures = 0
+			store.loadTime = time.Now()
+		}
+		break
+	}
+	return store.lookup(kid)
+}
+
+// verifyJWT verifies the signature, the expiration time, the issuer and the audience of a JWT, and returns its
+// claims. JWTs without expiration time are rejected. The issuer and the audience are not checked if they are empty.
+func verifyJWT(token string, getKey func(kid string) (crypto.PublicKey, error), issuer, audience string, now time.Time) (JWTClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(jwtAlgorithms),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+	var keyErr error
+	parsed, err := jwt.NewParser(options...).Parse(token, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		var key crypto.PublicKey
+		key, keyErr = getKey(kid)
+		return key, keyErr
+	})
+	if keyErr != nil {
+		return nil, errors.Trace(keyErr)
+	} else if err != nil {
+		return nil, errors.NewUnauthorized(err, "invalid JWT")
+	}
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.Unauthorizedf("malformed JWT payload")
+	}
+	return JWTClaims(claims), nil
+}
+
+// VerifyJWT verifies a JWT by the JSON web key set in the configuration and returns its claims.
+func (s *RestServer) VerifyJWT(token string) (JWTClaims, error) {
+	jwtConfig := s.Config.JWT
+	if !jwtConfig.Enabled() {
+		return nil, errors.Unauthorizedf("JWT authentication disabled")
+	}
+	return verifyJWT(token, func(kid string) (crypto.PublicKey, error) {
+		return s.jwks.get(jwtConfig.JWKS, kid)
+	}, jwtConfig.Issuer, jwtConfig.Audience, time.Now())
+}
+
+// HasDashboardRole checks if roles in the claims of a JWT grant access to the dashboard.
+func (s *RestServer) HasDashboardRole(claims JWTClaims) bool {
+	return hasAnyRole(claims.Roles(s.Config.JWT.RolesClaim), s.Config.JWT.DashboardRoles)
+}
+
+func hasAnyRole(roles, granted []string) bool {
+	return lo.ContainsBy(roles, func(role string) bool {
+		return lo.Contains(granted, role)
+	})
+}
+
+// jwtScopes maps roles in the claims of a JWT to scopes.
+func (s *RestServer) jwtScopes(claims JWTClaims) []string {
+	roles := claims.Roles(s.Config.JWT.RolesClaim)
+	var scopes []string
+	if hasAnyRole(roles, s.Config.JWT.RecommendRoles) {
+		scopes = append(scopes, ScopeRecommend)
+	}
+	if hasAnyRole(roles, s.Config.JWT.FeedbackRoles) {
+		scopes = append(scopes, ScopeFeedback)
+	}
+	if hasAnyRole(roles, s.Config.JWT.AdminRoles) {
+		scopes = append(scopes, ScopeAdmin)
+	}
+	return scopes
+}
+
+// authenticateJWT checks the JWT of a request to an API requiring the scope. It returns the subject of the JWT.
+func (s *RestServer) authenticateJWT(token, scope string) (string, error) {
+	claims, err := s.VerifyJWT(token)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	key := APIKey{Name: claims.Subject(), Scopes: s.jwtScopes(claims)}
+	if !key.HasScope(scope) {
+		APIKeyRequestsTotalVec.WithLabelValues("jwt", "forbidden").Inc()
+		return key.Name, errors.Forbiddenf("JWT of %s without scope %s", key.Name, scope)
+	}
+	if !s.subjects.allow(key.Name, s.Config.JWT.RateLimit, s.Config.JWT.Burst) {
+		APIKeyRequestsTotalVec.WithLabelValues("jwt", "rate_limited").Inc()
+		return key.Name, errors.QuotaLimitExceededf("JWT of %s", key.Name)
+	}
+	APIKeyRequestsTotalVec.WithLabelValues("jwt", "allowed").Inc()
+	return key.Name, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(authorization string) string {
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
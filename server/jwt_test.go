@@ -0,0 +1,322 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/juju/errors"
+	"github.com/steinfletcher/apitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/protocol"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// jwksFixture writes public keys to a JSON web key set file.
+func jwksFixture(t *testing.T, keys map[string]crypto.Signer) string {
+	var jwks []map[string]string
+	for kid, key := range keys {
+		switch publicKey := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			jwks = append(jwks, map[string]string{
+				"kty": "EC",
+				"kid": kid,
+				"crv": publicKey.Curve.Params().Name,
+				"x":   base64.RawURLEncoding.EncodeToString(publicKey.X.Bytes()),
+				"y":   base64.RawURLEncoding.EncodeToString(publicKey.Y.Bytes()),
+			})
+		}
+	}
+	// keys for encryption and unsupported keys are ignored
+	jwks = append(jwks, map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"})
+	jwks = append(jwks, map[string]string{"kty": "oct", "kid": "oct", "k": "c2VjcmV0"})
+	data, err := json.Marshal(map[string]any{"keys": jwks})
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+// signJWT signs claims by a RSA or ECDSA private key.
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), jwt.MapClaims(claims))
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func TestJWTClaims_Roles(t *testing.T) {
+	var claims JWTClaims
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"sub": "alice",
+		"roles": ["a", "b", 1],
+		"scope": "c d",
+		"realm_access": {"roles": ["e"]}
+	}`), &claims))
+	assert.Equal(t, "alice", claims.Subject())
+	assert.Equal(t, []string{"a", "b"}, claims.Roles("roles"))
+	assert.Equal(t, []string{"c", "d"}, claims.Roles("scope"))
+	assert.Equal(t, []string{"e"}, claims.Roles("realm_access.roles"))
+	assert.Empty(t, claims.Roles("realm_access.groups"))
+	assert.Empty(t, claims.Roles("sub.roles"))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	keys, err := loadJWKS(jwksFixture(t, map[string]crypto.Signer{"rsa": rsaKey, "ecdsa": ecdsaKey}))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	getKey := func(kid string) (crypto.PublicKey, error) {
+		if key, exist := keys[kid]; exist {
+			return key, nil
+		}
+		return nil, errors.Unauthorizedf("unknown JWT key %s", kid)
+	}
+	now := time.Now()
+	claims := map[string]any{"sub": "alice", "iss": "https://issuer", "aud": []string{"gorse", "other"}, "exp": now.Add(time.Hour).Unix()}
+
+	// valid signatures
+	for _, token := range []string{
+		signJWT(t, "RS256", "rsa", rsaKey, claims),
+		signJWT(t, "RS512", "rsa", rsaKey, claims),
+		signJWT(t, "PS256", "rsa", rsaKey, claims),
+		signJWT(t, "ES256", "ecdsa", ecdsaKey, claims),
+	} {
+		verified, err := verifyJWT(token, getKey, "https://issuer", "gorse", now)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", verified.Subject())
+	}
+	// invalid signatures
+	token := signJWT(t, "RS256", "rsa", rsaKey, claims)
+	_, err = verifyJWT(token[:len(token)-4]+"AAAA", getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(signJWT(t, "RS256", "ecdsa", rsaKey, claims), getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	es256 := strings.Split(signJWT(t, "ES256", "ecdsa", ecdsaKey, claims), ".")
+	es384 := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES384","kid":"ecdsa"}`)) + "." + es256[1] + "." + es256[2]
+	_, err = verifyJWT(es384, getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(signJWT(t, "RS256", "unknown", rsaKey, claims), getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + parts[1] + "."
+	_, err = verifyJWT(none, getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT("invalid", getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	// invalid claims
+	_, err = verifyJWT(token, getKey, "", "", now.Add(2*time.Hour))
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(token, getKey, "", "", now.Add(time.Hour+jwtLeeway/2))
+	assert.NoError(t, err)
+	_, err = verifyJWT(signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"sub": "alice"}), getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(signJWT(t, "RS256", "rsa", rsaKey, map[string]any{"nbf": now.Add(time.Hour).Unix(), "exp": now.Add(2 * time.Hour).Unix()}), getKey, "", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(token, getKey, "https://other", "", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = verifyJWT(token, getKey, "", "unknown", now)
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+}
+
+func TestJWKSStore(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(jwksFixture(t, map[string]crypto.Signer{"rsa": rsaKey}))
+	assert.NoError(t, err)
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer jwksServer.Close()
+
+	var store jwksStore
+	key, err := store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+	// the only key is used if the key ID is empty
+	key, err = store.get(jwksServer.URL, "")
+	assert.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+	_, err = store.get(jwksServer.URL, "unknown")
+	assert.True(t, errors.Is(err, errors.Unauthorized))
+	_, err = store.get(jwksServer.URL+"/404", "rsa")
+	assert.Error(t, err)
+}
+
+func TestJWKSStore_Failure(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(jwksFixture(t, map[string]crypto.Signer{"rsa": rsaKey}))
+	assert.NoError(t, err)
+	var requests int
+	var unavailable bool
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer jwksServer.Close()
+
+	var store jwksStore
+	_, err = store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+	// the last loaded keys are kept if reloading fails
+	unavailable = true
+	store.loadTime = time.Now().Add(-2 * jwksRefreshInterval)
+	key, err := store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, store.failures)
+	// reloading is not retried until the backoff elapses
+	_, err = store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+	store.failTime = time.Now().Add(-store.backoff())
+	_, err = store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 2, store.failures)
+	assert.Equal(t, 2*time.Second, store.backoff())
+	// failures are reset after reloading succeeds
+	unavailable = false
+	store.failTime = time.Now().Add(-store.backoff())
+	_, err = store.get(jwksServer.URL, "rsa")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, requests)
+	assert.Zero(t, store.failures)
+}
+
+func (suite *ServerTestSuite) TestJWT() {
+	t := suite.T()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.NoError(err)
+	suite.Config.JWT.JWKS = jwksFixture(t, map[string]crypto.Signer{"rsa": rsaKey})
+	suite.Config.JWT.Issuer = "https://issuer"
+	suite.Config.JWT.RolesClaim = "realm_access.roles"
+	suite.Config.JWT.RecommendRoles = []string{"reader"}
+	suite.Config.JWT.AdminRoles = []string{"operator"}
+	newToken := func(roles ...string) string {
+		return signJWT(t, "RS256", "rsa", rsaKey, map[string]any{
+			"sub":          "alice",
+			"iss":          "https://issuer",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]any{"roles": roles},
+		})
+	}
+
+	// roles are mapped to scopes
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("Authorization", "Bearer "+newToken("reader")).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("Authorization", "Bearer "+newToken("reader")).
+		Expect(t).
+		Status(http.StatusForbidden).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("Authorization", "Bearer "+newToken("operator")).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	// invalid tokens
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("Authorization", "Bearer invalid").
+		Expect(t).
+		Status(http.StatusUnauthorized).
+		End()
+	// API keys still work
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/users").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+
+	// gRPC APIs
+	client, stop := suite.newGrpcClient()
+	defer stop()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+newToken("reader"))
+	_, err = client.GetPopular(ctx, &protocol.NonPersonalizedRequest{})
+	suite.NoError(err)
+	_, err = client.GetUser(ctx, &protocol.UserRequest{UserId: "0"})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+
+	// requests are rate limited by subjects
+	suite.Config.JWT.RateLimit = 0.001
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("Authorization", "Bearer "+newToken("reader")).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular").
+		Header("Authorization", "Bearer "+newToken("reader")).
+		Expect(t).
+		Status(http.StatusTooManyRequests).
+		End()
+}
@@ -25,4 +25,9 @@ var (
 		Subsystem: "server",
 		Name:      "rest_api_request_seconds",
 	}, []string{"api"})
+	APIKeyRequestsTotalVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gorse",
+		Subsystem: "server",
+		Name:      "api_key_requests_total",
+	}, []string{"api_key", "result"})
 )
@@ -18,9 +18,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/araddon/dateparse"
@@ -32,13 +36,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/samber/lo"
 	"github.com/thoas/go-funk"
+	"github.com/zhenghaoz/gorse/base"
 	"github.com/zhenghaoz/gorse/base/heap"
 	"github.com/zhenghaoz/gorse/base/log"
+	"github.com/zhenghaoz/gorse/base/parallel"
+	"github.com/zhenghaoz/gorse/base/search"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model/click"
+	"github.com/zhenghaoz/gorse/model/ranking"
 	"github.com/zhenghaoz/gorse/storage/cache"
 	"github.com/zhenghaoz/gorse/storage/data"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/emicklei/go-restful/otelrestful"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/protobuf/proto"
 	"modernc.org/mathutil"
 )
@@ -53,16 +63,58 @@ const (
 	DetractedAPITag      = "deprecated"
 )
 
+const batchSize = 10000
+
+// maxBatchRecommendUsers is the max number of users in a request of batch recommendation.
+const maxBatchRecommendUsers = 100
+
 // RestServer implements a REST-ful API server.
 type RestServer struct {
 	*config.Settings
 
 	HttpHost string
 	HttpPort int
+	GrpcPort int
 
 	DisableLog bool
 	WebService *restful.WebService
 	HttpServer *http.Server
+	GrpcServer *grpc.Server
+
+	// apiKeys caches API keys and rate limits requests of API keys.
+	apiKeys apiKeyStore
+	// jwks caches the JSON web key set to verify JWTs.
+	jwks jwksStore
+	// subjects rate limits requests of JWT subjects.
+	subjects subjectLimiter
+	// rules caches business rules applied to recommendations.
+	rules ruleStore
+	// rankingIndex computes collaborative and session recommendations on the fly if the ranking model is synced.
+	rankingIndex *rankingIndex
+	// modelMutex protects models and the ranking index replaced by synchronization.
+	modelMutex sync.RWMutex
+}
+
+// syncedClickModel returns the click model synced from the master.
+func (s *RestServer) syncedClickModel() click.FactorizationMachine {
+	s.modelMutex.RLock()
+	defer s.modelMutex.RUnlock()
+	return s.ClickModel
+}
+
+// SetClickModel replaces the click model used to re-rank recommendations. The master of gorse-in-one replaces the
+// click model of the embedded server after fitting.
+func (s *RestServer) SetClickModel(clickModel click.FactorizationMachine) {
+	s.modelMutex.Lock()
+	defer s.modelMutex.Unlock()
+	s.ClickModel = clickModel
+}
+
+// syncedRankingIndex returns the ranking index built from the ranking model synced from the master.
+func (s *RestServer) syncedRankingIndex() *rankingIndex {
+	s.modelMutex.RLock()
+	defer s.modelMutex.RUnlock()
+	return s.rankingIndex
 }
 
 // StartHttpServer starts the REST-ful API server.
@@ -83,7 +135,7 @@ func (s *RestServer) StartHttpServer(container *restful.Container) {
 
 	// Add container filter to enable CORS
 	cors := restful.CrossOriginResourceSharing{
-		AllowedHeaders: []string{"Content-Type", "Accept", "X-API-Key"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "X-API-Key", "Authorization"},
 		AllowedDomains: s.Config.Master.HttpCorsDomains,
 		AllowedMethods: s.Config.Master.HttpCorsMethods,
 		CookiesAllowed: false,
@@ -126,19 +178,29 @@ func (s *RestServer) AuthFilter(req *restful.Request, resp *restful.Response, ch
 		chain.ProcessFilter(req, resp)
 		return
 	}
-	if s.Config.Server.APIKey == "" {
+	if isAdmin, _ := req.Attribute(AdminAttribute).(bool); isAdmin {
+		// Requests from the dashboard have been authorized.
 		chain.ProcessFilter(req, resp)
 		return
 	}
 	apikey := req.HeaderParameter("X-API-Key")
-	if apikey == s.Config.Server.APIKey {
+	token := bearerToken(req.HeaderParameter("Authorization"))
+	name, err := s.authenticate(req.Request.Context(), apikey, token, restAPIScope(req.Request.Method, req.SelectedRoutePath()))
+	if err == nil {
 		chain.ProcessFilter(req, resp)
 		return
 	}
-	log.ResponseLogger(resp).Error("unauthorized",
-		zap.String("api_key", s.Config.Server.APIKey),
-		zap.String("X-API-Key", apikey))
-	if err := resp.WriteError(http.StatusUnauthorized, fmt.Errorf("unauthorized")); err != nil {
+	log.ResponseLogger(resp).Error("unauthorized", zap.String("api_key_name", name), zap.Error(err))
+	httpStatus := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errors.Unauthorized):
+		httpStatus = http.StatusUnauthorized
+	case errors.Is(err, errors.Forbidden):
+		httpStatus = http.StatusForbidden
+	case errors.Is(err, errors.QuotaLimitExceeded):
+		httpStatus = http.StatusTooManyRequests
+	}
+	if err = resp.WriteError(httpStatus, err); err != nil {
 		log.ResponseLogger(resp).Error("failed to write error", zap.Error(err))
 	}
 }
@@ -280,7 +342,7 @@ func (s *RestServer) CreateWebService() {
 		Returns(http.StatusOK, "OK", Success{}).
 		Writes(Success{}))
 	// Insert category
-	ws.Route(ws.PUT("/item/{item-id}/category/{category}").To(s.insertItemCategory).
+	ws.Route(ws.PUT("/item/{item-id}/category/{category:*}").To(s.insertItemCategory).
 		Doc("Insert a category for a item.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{ItemsAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
@@ -289,7 +351,7 @@ func (s *RestServer) CreateWebService() {
 		Returns(http.StatusOK, "OK", Success{}).
 		Writes(Success{}))
 	// Delete category
-	ws.Route(ws.DELETE("/item/{item-id}/category/{category}").To(s.deleteItemCategory).
+	ws.Route(ws.DELETE("/item/{item-id}/category/{category:*}").To(s.deleteItemCategory).
 		Doc("Delete a category from a item.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{ItemsAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
@@ -302,6 +364,8 @@ func (s *RestServer) CreateWebService() {
 		Doc("Insert feedbacks. Ignore insertion if feedback exists.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{FeedbackAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.HeaderParameter(IdempotencyKeyHeader, "Key to remember the result of the request").DataType("string")).
+		Param(ws.QueryParameter("partial", "Insert valid feedback and return errors of invalid feedback").DataType("boolean")).
 		Reads([]data.Feedback{}).
 		Returns(http.StatusOK, "OK", Success{}).
 		Writes(Success{}))
@@ -309,6 +373,8 @@ func (s *RestServer) CreateWebService() {
 		Doc("Insert feedbacks. Existed feedback will be overwritten.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{FeedbackAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.HeaderParameter(IdempotencyKeyHeader, "Key to remember the result of the request").DataType("string")).
+		Param(ws.QueryParameter("partial", "Insert valid feedback and return errors of invalid feedback").DataType("boolean")).
 		Reads([]data.Feedback{}).
 		Returns(http.StatusOK, "OK", Success{}).
 		Writes(Success{}))
@@ -407,7 +473,7 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
-	ws.Route(ws.GET("/intermediate/recommend/{user-id}/{category}").To(s.getCollaborative).
+	ws.Route(ws.GET("/intermediate/recommend/{user-id}/{category:*}").To(s.getCollaborative).
 		Doc("Get the collaborative filtering recommendation for a user").
 		Metadata(restfulspec.KeyOpenAPITags, []string{DetractedAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
@@ -425,16 +491,48 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned recommendations").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned recommendations").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
-	ws.Route(ws.GET("/popular/{category}").To(s.getPopular).
+	ws.Route(ws.GET("/popular/{category:*}").To(s.getPopular).
 		Doc("Get popular items in category.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.PathParameter("category", "Category of returned items.").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
+		Returns(http.StatusOK, "OK", []cache.Document{}).
+		Writes([]cache.Document{}))
+	// Get trending items
+	ws.Route(ws.GET("/trending").To(s.getTrending).
+		Doc("Get trending items.").
+		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
+		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
+		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
+		Returns(http.StatusOK, "OK", []cache.Document{}).
+		Writes([]cache.Document{}))
+	ws.Route(ws.GET("/trending/{category:*}").To(s.getTrending).
+		Doc("Get trending items in category.").
+		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
+		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.PathParameter("category", "Category of returned items.").DataType("string")).
+		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
+		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
@@ -445,16 +543,22 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
-	ws.Route(ws.GET("/latest/{category}").To(s.getLatest).
+	ws.Route(ws.GET("/latest/{category:*}").To(s.getLatest).
 		Doc("Get the latest items in category.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.PathParameter("category", "Category of returned items.").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Param(ws.QueryParameter("user-id", "Remove read items of a user").DataType("string")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
@@ -466,9 +570,12 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.PathParameter("item-id", "ID of the item to get neighbors").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
-	ws.Route(ws.GET("/item/{item-id}/neighbors/{category}").To(s.getItemNeighbors).
+	ws.Route(ws.GET("/item/{item-id}/neighbors/{category:*}").To(s.getItemNeighbors).
 		Doc("Get neighbors of a item in category.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
@@ -476,6 +583,9 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.PathParameter("category", "Category of returned items").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
 	ws.Route(ws.GET("/user/{user-id}/neighbors/").To(s.getUserNeighbors).
@@ -494,11 +604,51 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.PathParameter("user-id", "ID of the user to get recommendation").DataType("string")).
 		Param(ws.QueryParameter("write-back-type", "Type of write back feedback").DataType("string")).
 		Param(ws.QueryParameter("write-back-delay", "Timestamp delay of write back feedback (format 0h0m0s)").DataType("string")).
+		Param(ws.QueryParameter("context", "Context labels of the request, e.g. device.mobile").DataType("string").AllowMultiple(true)).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("scene", "Scene of the recommender chain defined in the configuration").DataType("string")).
+		Param(ws.QueryParameter("recommenders", "Comma-separated recommender chain, e.g. offline:10,popular").DataType("string")).
+		Param(ws.QueryParameter("exclude-read", "Exclude items with feedback from the user").DataType("boolean")).
 		Returns(http.StatusOK, "OK", []string{}).
 		Writes([]string{}))
-	ws.Route(ws.GET("/recommend/{user-id}/{category}").To(s.getRecommend).
+	ws.Route(ws.GET("/explain/recommend/{user-id}").To(s.explainRecommend).
+		Doc("Explain recommendation for user.").
+		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
+		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.PathParameter("user-id", "ID of the user to get recommendation").DataType("string")).
+		Param(ws.QueryParameter("context", "Context labels of the request, e.g. device.mobile").DataType("string").AllowMultiple(true)).
+		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
+		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("scene", "Scene of the recommender chain defined in the configuration").DataType("string")).
+		Param(ws.QueryParameter("recommenders", "Comma-separated recommender chain, e.g. offline:10,popular").DataType("string")).
+		Param(ws.QueryParameter("exclude-read", "Exclude items with feedback from the user").DataType("boolean")).
+		Returns(http.StatusOK, "OK", []Explanation{}).
+		Writes([]Explanation{}))
+	ws.Route(ws.GET("/explain/recommend/{user-id}/{category:*}").To(s.explainRecommend).
+		Doc("Explain recommendation for user.").
+		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
+		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.PathParameter("user-id", "ID of the user to get recommendation").DataType("string")).
+		Param(ws.PathParameter("category", "Category of the returned items").DataType("string")).
+		Param(ws.QueryParameter("context", "Context labels of the request, e.g. device.mobile").DataType("string").AllowMultiple(true)).
+		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
+		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("scene", "Scene of the recommender chain defined in the configuration").DataType("string")).
+		Param(ws.QueryParameter("recommenders", "Comma-separated recommender chain, e.g. offline:10,popular").DataType("string")).
+		Param(ws.QueryParameter("exclude-read", "Exclude items with feedback from the user").DataType("boolean")).
+		Returns(http.StatusOK, "OK", []Explanation{}).
+		Writes([]Explanation{}))
+	ws.Route(ws.GET("/recommend/{user-id}/{category:*}").To(s.getRecommend).
 		Doc("Get recommendation for user.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
@@ -506,26 +656,55 @@ func (s *RestServer) CreateWebService() {
 		Param(ws.PathParameter("category", "Category of the returned items").DataType("string")).
 		Param(ws.QueryParameter("write-back-type", "Type of write back feedback").DataType("string")).
 		Param(ws.QueryParameter("write-back-delay", "Timestamp delay of write back feedback (format 0h0m0s)").DataType("string")).
+		Param(ws.QueryParameter("context", "Context labels of the request, e.g. device.mobile").DataType("string").AllowMultiple(true)).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("scene", "Scene of the recommender chain defined in the configuration").DataType("string")).
+		Param(ws.QueryParameter("recommenders", "Comma-separated recommender chain, e.g. offline:10,popular").DataType("string")).
+		Param(ws.QueryParameter("exclude-read", "Exclude items with feedback from the user").DataType("boolean")).
 		Returns(http.StatusOK, "OK", []string{}).
 		Writes([]string{}))
+	ws.Route(ws.POST("/recommend").To(s.batchRecommend).
+		Doc("Get recommendation for users in batch.").
+		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
+		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
+		Param(ws.QueryParameter("write-back-type", "Type of write back feedback").DataType("string")).
+		Param(ws.QueryParameter("write-back-delay", "Timestamp delay of write back feedback (format 0h0m0s)").DataType("string")).
+		Param(ws.QueryParameter("context", "Context labels of the request, e.g. device.mobile").DataType("string").AllowMultiple(true)).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
+		Param(ws.QueryParameter("scene", "Scene of the recommender chain defined in the configuration").DataType("string")).
+		Param(ws.QueryParameter("recommenders", "Comma-separated recommender chain, e.g. offline:10,popular").DataType("string")).
+		Param(ws.QueryParameter("exclude-read", "Exclude items with feedback from the user").DataType("boolean")).
+		Reads(BatchRecommendRequest{}).
+		Returns(http.StatusOK, "OK", map[string]BatchRecommendResult{}).
+		Writes(map[string]BatchRecommendResult{}))
 	ws.Route(ws.POST("/session/recommend").To(s.sessionRecommend).
 		Doc("Get recommendation for session.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Reads([]Feedback{}).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
-	ws.Route(ws.POST("/session/recommend/{category}").To(s.sessionRecommend).
+	ws.Route(ws.POST("/session/recommend/{category:*}").To(s.sessionRecommend).
 		Doc("Get recommendation for session.").
 		Metadata(restfulspec.KeyOpenAPITags, []string{RecommendationAPITag}).
 		Param(ws.HeaderParameter("X-API-Key", "API key").DataType("string")).
 		Param(ws.PathParameter("category", "Category of the returned items").DataType("string")).
 		Param(ws.QueryParameter("n", "Number of returned items").DataType("integer")).
 		Param(ws.QueryParameter("offset", "Offset of returned items").DataType("integer")).
+		Param(ws.QueryParameter("lat", "Latitude of the location").DataType("number")).
+		Param(ws.QueryParameter("lon", "Longitude of the location").DataType("number")).
+		Param(ws.QueryParameter("radius", "Radius in kilometers around the location").DataType("number")).
 		Reads([]Feedback{}).
 		Returns(http.StatusOK, "OK", []cache.Document{}).
 		Writes([]cache.Document{}))
@@ -579,22 +758,70 @@ func (s *RestServer) searchDocuments(collection, subset, category string, isItem
 		return
 	}
 	userId = request.QueryParameter("user-id")
+	var geo *GeoFilter
+	if isItem {
+		if geo, err = ParseGeoFilter(request); err != nil {
+			BadRequest(response, err)
+			return
+		}
+	}
 
-	// Get the sorted list
-	items, err := s.CacheClient.SearchDocuments(ctx, collection, subset, []string{category}, offset, offset+n)
+	items, err := s.getDocuments(ctx, collection, subset, category, isItem, userId, n, offset, geo)
 	if err != nil {
 		InternalServerError(response, err)
 		return
 	}
+	Ok(response, items)
+}
+
+// getDocuments gets sorted documents from the cache store. Items read by the user are removed if the user id is not
+// empty. Business rules are applied if documents are items. If the geo filter is not nil, only items within the radius
+// are returned and their scores are decayed by distances.
+func (s *RestServer) getDocuments(ctx context.Context, collection, subset, category string, isItem bool, userId string, n, offset int, geo *GeoFilter) ([]cache.Document, error) {
+	// Business rules are applied to items from the top of the list since items might be pinned to any slot.
+	var (
+		rules []Rule
+		err   error
+	)
+	if isItem {
+		if rules, err = s.rules.get(ctx, s.CacheClient); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	begin, end := offset, offset+n
+	if len(rules) > 0 {
+		begin = 0
+	}
+
+	// Get the sorted list
+	var items []cache.Document
+	if geo != nil {
+		// All items within the radius are loaded since they are re-ranked by distances.
+		items, err = s.CacheClient.SearchNearbyDocuments(ctx, collection, subset, []string{category}, geo.Location, geo.Radius, 0, -1)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		// Latest items are ranked by timestamps, so that they are not decayed.
+		if collection != cache.LatestItems {
+			s.decayDocuments(items, geo.Location)
+		}
+		if len(rules) == 0 {
+			items = items[mathutil.Min(offset, len(items)):]
+		}
+	} else {
+		items, err = s.CacheClient.SearchDocuments(ctx, collection, subset, []string{category}, begin, end)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 
 	// Remove read items
+	readItems := mapset.NewSet[string]()
 	if userId != "" {
 		feedback, err := s.DataClient.GetUserFeedback(ctx, userId, s.Config.Now())
 		if err != nil {
-			InternalServerError(response, err)
-			return
+			return nil, errors.Trace(err)
 		}
-		readItems := mapset.NewSet[string]()
 		for _, f := range feedback {
 			readItems.Add(f.ItemId)
 		}
@@ -607,11 +834,33 @@ func (s *RestServer) searchDocuments(collection, subset, category string, isItem
 		items = prunedItems
 	}
 
-	// Send result
+	// Apply business rules
+	if len(rules) > 0 {
+		itemIds, err := s.applyRules(ctx, rules, userId, cache.ConvertDocumentsToValues(items), func(item *data.Item) bool {
+			return (category == "" || funk.ContainsString(data.ExpandCategories(item.Categories), category)) &&
+				!readItems.Contains(item.ItemId) && (geo == nil || geo.contains(item))
+		})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		documents := make(map[string]cache.Document, len(items))
+		for _, item := range items {
+			documents[item.Id] = item
+		}
+		items = lo.Map(itemIds, func(itemId string, _ int) cache.Document {
+			if document, exist := documents[itemId]; exist {
+				return document
+			}
+			// pinned items are not in the list
+			return cache.Document{Id: itemId}
+		})
+		items = items[mathutil.Min(offset, len(items)):]
+	}
+
 	if n > 0 && len(items) > n {
 		items = items[:n]
 	}
-	Ok(response, items)
+	return items, nil
 }
 
 func (s *RestServer) getPopular(request *restful.Request, response *restful.Response) {
@@ -620,6 +869,12 @@ func (s *RestServer) getPopular(request *restful.Request, response *restful.Resp
 	s.searchDocuments(cache.PopularItems, "", category, true, request, response)
 }
 
+func (s *RestServer) getTrending(request *restful.Request, response *restful.Response) {
+	category := request.PathParameter("category")
+	log.ResponseLogger(response).Debug("get category trending items in category", zap.String("category", category))
+	s.searchDocuments(cache.TrendingItems, "", category, true, request, response)
+}
+
 func (s *RestServer) getLatest(request *restful.Request, response *restful.Response) {
 	category := request.PathParameter("category")
 	log.ResponseLogger(response).Debug("get category latest items in category", zap.String("category", category))
@@ -685,18 +940,60 @@ func (s *RestServer) getCollaborative(request *restful.Request, response *restfu
 // 2. If there are historical interactions of the users, return similar items.
 // 3. Otherwise, return fallback recommendation (popular/latest).
 func (s *RestServer) Recommend(ctx context.Context, response *restful.Response, userId, category string, n int, recommenders ...Recommender) ([]string, error) {
-	initStart := time.Now()
-
 	// create context
 	recommendCtx, err := s.createRecommendContext(ctx, userId, category, n)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	return s.recommend(recommendCtx, log.ResponseLogger(response), recommenders...)
+}
 
+func (s *RestServer) recommend(recommendCtx *recommendContext, logger *zap.Logger, recommenders ...Recommender) ([]string, error) {
 	// execute recommenders
 	for _, recommender := range recommenders {
-		err = recommender(recommendCtx)
-		if err != nil {
+		if err := recommender(recommendCtx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	n := recommendCtx.n
+
+	// decay items by distances to the location of the request
+	if recommendCtx.geo != nil {
+		s.decayDistance(recommendCtx)
+	}
+
+	// rank recommendations by the click model under request context
+	if clickModel := s.syncedClickModel(); len(recommendCtx.contextLabels) > 0 &&
+		s.Config.Recommend.GetEnableClickThroughPrediction(recommendCtx.variants) && clickModel != nil && !clickModel.Invalid() {
+		if err := s.rankByClickModel(recommendCtx, clickModel); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	// decay items similar to negative items
+	if len(recommendCtx.negativeItems) > 0 {
+		if err := s.decayNegativeNeighbors(recommendCtx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	// diversify recommendations
+	if s.Config.Recommend.Diversity.EnableDiversity {
+		if err := s.diversify(recommendCtx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	// apply business rules
+	rules, err := s.rules.get(recommendCtx.context, s.CacheClient)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(rules) > 0 {
+		if recommendCtx.results, err = s.applyRules(recommendCtx.context, rules, recommendCtx.userId, recommendCtx.results, func(item *data.Item) bool {
+			return (recommendCtx.category == "" || funk.ContainsString(data.ExpandCategories(item.Categories), recommendCtx.category)) &&
+				!recommendCtx.excludeSet.Contains(item.ItemId) && recommendCtx.locateItem(item)
+		}); err != nil {
 			return nil, errors.Trace(err)
 		}
 	}
@@ -705,14 +1002,15 @@ func (s *RestServer) Recommend(ctx context.Context, response *restful.Response,
 	if len(recommendCtx.results) > n {
 		recommendCtx.results = recommendCtx.results[:n]
 	}
-	totalTime := time.Since(initStart)
-	log.ResponseLogger(response).Info("complete recommendation",
+	totalTime := time.Since(recommendCtx.startTime)
+	logger.Info("complete recommendation",
 		zap.Int("num_from_final", recommendCtx.numFromOffline),
 		zap.Int("num_from_collaborative", recommendCtx.numFromCollaborative),
 		zap.Int("num_from_item_based", recommendCtx.numFromItemBased),
 		zap.Int("num_from_user_based", recommendCtx.numFromUserBased),
 		zap.Int("num_from_latest", recommendCtx.numFromLatest),
 		zap.Int("num_from_poplar", recommendCtx.numFromPopular),
+		zap.Int("num_from_trending", recommendCtx.numFromTrending),
 		zap.Duration("total_time", totalTime),
 		zap.Duration("load_final_recommend_time", recommendCtx.loadOfflineRecTime),
 		zap.Duration("load_col_recommend_time", recommendCtx.loadColRecTime),
@@ -720,10 +1018,182 @@ func (s *RestServer) Recommend(ctx context.Context, response *restful.Response,
 		zap.Duration("item_based_recommend_time", recommendCtx.itemBasedTime),
 		zap.Duration("user_based_recommend_time", recommendCtx.userBasedTime),
 		zap.Duration("load_latest_time", recommendCtx.loadLatestTime),
-		zap.Duration("load_popular_time", recommendCtx.loadPopularTime))
+		zap.Duration("load_popular_time", recommendCtx.loadPopularTime),
+		zap.Duration("load_trending_time", recommendCtx.loadTrendingTime))
 	return recommendCtx.results, nil
 }
 
+// rankByClickModel re-ranks recommended items by click-through rates predicted by the click model under
+// context labels of the request. Items not found in the data store are moved to the end.
+func (s *RestServer) rankByClickModel(ctx *recommendContext, clickModel click.FactorizationMachine) error {
+	var userLabels []string
+	user, err := s.DataClient.GetUser(ctx.context, ctx.userId)
+	if err != nil && !errors.Is(err, errors.NotFound) {
+		return errors.Trace(err)
+	} else if err == nil {
+		userLabels = data.FlattenLabels(user.Labels)
+	}
+	items, err := s.DataClient.BatchGetItems(ctx.context, ctx.results)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	scores := make(map[string]float64, len(items))
+	for _, item := range items {
+		scores[item.ItemId] = float64(clickModel.PredictWithContext(ctx.userId, item.ItemId, userLabels, data.FlattenLabels(item.Labels), ctx.contextLabels))
+	}
+	sort.SliceStable(ctx.results, func(i, j int) bool {
+		scoreI, existI := scores[ctx.results[i]]
+		scoreJ, existJ := scores[ctx.results[j]]
+		return existI && (!existJ || scoreI > scoreJ)
+	})
+	for itemId, score := range scores {
+		ctx.scores[itemId] = score
+		ctx.sources[itemId] = "click_model"
+		if explanation, exist := ctx.explanations[itemId]; exist {
+			explanation.ClickThroughRate = lo.ToPtr(score)
+			ctx.explanations[itemId] = explanation
+		}
+	}
+	return nil
+}
+
+// maxDecayedNegativeItems is the max number of the most recent negative items whose neighbors are decayed.
+const maxDecayedNegativeItems = 10
+
+// decayNegativeNeighbors re-ranks recommended items by decaying the relevance of items which are neighbors of
+// negative items or share categories with them. Only the most recent negative items are considered. The relevance
+// of an item is decided by its position in recommendations.
+func (s *RestServer) decayNegativeNeighbors(ctx *recommendContext) error {
+	scores := make(map[string]float64, len(ctx.results))
+	for i, itemId := range ctx.results {
+		scores[itemId] = 1 / float64(i+1)
+	}
+	decay := s.Config.Recommend.DataSource.NegativeFeedbackDecay
+	recentNegativeItems := ctx.negativeItems
+	if len(recentNegativeItems) > maxDecayedNegativeItems {
+		recentNegativeItems = recentNegativeItems[:maxDecayedNegativeItems]
+	}
+	// decay neighbors of negative items
+	itemNeighbors := make([][]cache.Document, len(recentNegativeItems))
+	err := parallel.Parallel(len(recentNegativeItems), len(recentNegativeItems), func(_, jobId int) (err error) {
+		itemNeighbors[jobId], err = s.CacheClient.SearchDocuments(ctx.context, cache.ItemNeighbors, recentNegativeItems[jobId], []string{""}, 0, s.Config.Recommend.CacheSize)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	neighbors := mapset.NewSet[string]()
+	for _, documents := range itemNeighbors {
+		for _, neighbor := range documents {
+			neighbors.Add(neighbor.Id)
+		}
+	}
+	for _, itemId := range neighbors.ToSlice() {
+		if _, exist := scores[itemId]; exist {
+			scores[itemId] *= decay
+		}
+	}
+	// decay items in categories of negative items
+	negativeItems, err := s.DataClient.BatchGetItems(ctx.context, recentNegativeItems)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	categories := mapset.NewSet[string]()
+	for _, item := range negativeItems {
+		categories.Append(item.Categories...)
+	}
+	if categories.Cardinality() > 0 {
+		items, err := s.DataClient.BatchGetItems(ctx.context, ctx.results)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, item := range items {
+			if lo.ContainsBy(item.Categories, func(category string) bool { return categories.Contains(category) }) {
+				scores[item.ItemId] *= decay
+			}
+		}
+	}
+	sort.SliceStable(ctx.results, func(i, j int) bool {
+		return scores[ctx.results[i]] > scores[ctx.results[j]]
+	})
+	return nil
+}
+
+// diversify re-ranks recommended items by maximal marginal relevance. The relevance of an item is decided by its
+// score normalized among consecutive items from the same recommender, and items from preceding recommenders are more
+// relevant.
+func (s *RestServer) diversify(ctx *recommendContext) error {
+	itemIds := ctx.results
+	scores := ctx.relevance()
+	var (
+		similarity         func(candidate, selected int) float64
+		neighborSimilarity *cache.NeighborSimilarity
+	)
+	switch s.Config.Recommend.Diversity.Similarity {
+	case "neighbors":
+		neighborSimilarity = cache.NewNeighborSimilarity(ctx.context, s.CacheClient, s.Config.Recommend.CacheSize)
+		similarity = func(candidate, selected int) float64 {
+			return neighborSimilarity.Similarity(itemIds[candidate], itemIds[selected])
+		}
+	default:
+		items, err := s.DataClient.BatchGetItems(ctx.context, itemIds)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		labels := make(map[string]mapset.Set[string], len(items))
+		for _, item := range items {
+			labels[item.ItemId] = mapset.NewSet[string](data.FlattenLabels(item.Labels)...)
+			labels[item.ItemId].Append(item.Categories...)
+		}
+		similarity = func(candidate, selected int) float64 {
+			candidateLabels, exist := labels[itemIds[candidate]]
+			if !exist {
+				return 0
+			}
+			selectedLabels, exist := labels[itemIds[selected]]
+			if !exist {
+				return 0
+			}
+			return base.Jaccard(candidateLabels, selectedLabels)
+		}
+	}
+	selected := base.MMR(scores, ctx.n, s.Config.Recommend.Diversity.Lambda, similarity)
+	if neighborSimilarity != nil && neighborSimilarity.Err() != nil {
+		return errors.Trace(neighborSimilarity.Err())
+	}
+	ctx.results = lo.Map(selected, func(i, _ int) string {
+		return itemIds[i]
+	})
+	return nil
+}
+
+// relevance returns the relevance of recommended items. Consecutive items from the same recommender form a group, in
+// which scores are min-max normalized to [0, 1]. The relevance of items in the i-th group is reduced by i, so that
+// items from preceding recommenders are more relevant.
+func (ctx *recommendContext) relevance() []float64 {
+	relevance := make([]float64, len(ctx.results))
+	for begin, group := 0, 0; begin < len(ctx.results); group++ {
+		end := begin + 1
+		for end < len(ctx.results) && ctx.sources[ctx.results[end]] == ctx.sources[ctx.results[begin]] {
+			end++
+		}
+		minScore, maxScore := math.Inf(1), math.Inf(-1)
+		for _, itemId := range ctx.results[begin:end] {
+			minScore = math.Min(minScore, ctx.scores[itemId])
+			maxScore = math.Max(maxScore, ctx.scores[itemId])
+		}
+		for i := begin; i < end; i++ {
+			if maxScore > minScore {
+				relevance[i] = (ctx.scores[ctx.results[i]]-minScore)/(maxScore-minScore) - float64(group)
+			} else {
+				relevance[i] = 1 - float64(group)
+			}
+		}
+		begin = end
+	}
+	return relevance
+}
+
 type recommendContext struct {
 	context      context.Context
 	userId       string
@@ -732,10 +1202,32 @@ type recommendContext struct {
 	n            int
 	results      []string
 	excludeSet   mapset.Set[string]
+	startTime    time.Time
+
+	// fallbackItems are non-personalized recommendations shared by all users in a batch. They are loaded once they
+	// are used by any user in the batch.
+	fallbackItems map[string][]cache.Document
+	// explanations are collected only if the recommendation is being explained.
+	explanations map[string]Explanation
+	// scores and sources are scores of recommended items and recommenders giving the scores.
+	scores  map[string]float64
+	sources map[string]string
+	// variants are experiment variants assigned to the user.
+	variants []config.Variant
+	// contextLabels are context labels of the request, e.g. device.mobile.
+	contextLabels []string
+	// negativeItems are items with negative feedback from the user, sorted from the most recent.
+	negativeItems []string
+	// geo restricts items within the radius of the location of the request, and distances of items are recorded.
+	geo       *GeoFilter
+	distances map[string]float64
+	// offlineScene is the scene of the request if it has independent offline recommendation.
+	offlineScene string
 
 	numPrevStage         int
 	numFromLatest        int
 	numFromPopular       int
+	numFromTrending      int
 	numFromUserBased     int
 	numFromItemBased     int
 	numFromCollaborative int
@@ -748,36 +1240,81 @@ type recommendContext struct {
 	userBasedTime      time.Duration
 	loadLatestTime     time.Duration
 	loadPopularTime    time.Duration
+	loadTrendingTime   time.Duration
 }
 
 func (s *RestServer) createRecommendContext(ctx context.Context, userId, category string, n int) (*recommendContext, error) {
+	startTime := time.Now()
 	// pull historical feedback
 	userFeedback, err := s.DataClient.GetUserFeedback(ctx, userId, s.Config.Now())
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	excludeSet := mapset.NewSet[string]()
+	var negativeFeedback []data.Feedback
 	for _, item := range userFeedback {
-		if !s.Config.Recommend.Replacement.EnableReplacement {
+		if funk.ContainsString(s.Config.Recommend.DataSource.NegativeFeedbackTypes, item.FeedbackType) {
+			// items with negative feedback are never recommended again
+			excludeSet.Add(item.ItemId)
+			negativeFeedback = append(negativeFeedback, item)
+		} else if !s.Config.Recommend.Replacement.EnableReplacement {
 			excludeSet.Add(item.ItemId)
 		}
 	}
+	// negative items are sorted from the most recent
+	sort.SliceStable(negativeFeedback, func(i, j int) bool {
+		return negativeFeedback[i].Timestamp.After(negativeFeedback[j].Timestamp)
+	})
+	negativeItems := lo.Uniq(lo.Map(negativeFeedback, func(feedback data.Feedback, _ int) string {
+		return feedback.ItemId
+	}))
 	return &recommendContext{
-		userId:       userId,
-		category:     category,
-		n:            n,
-		excludeSet:   excludeSet,
-		userFeedback: userFeedback,
-		context:      ctx,
+		userId:        userId,
+		category:      category,
+		n:             n,
+		excludeSet:    excludeSet,
+		userFeedback:  userFeedback,
+		context:       ctx,
+		startTime:     startTime,
+		variants:      s.Config.Recommend.AssignVariants(userId),
+		negativeItems: negativeItems,
+		distances:     make(map[string]float64),
+		scores:        make(map[string]float64),
+		sources:       make(map[string]string),
 	}, nil
 }
 
+// Explanation explains which recommender produced a recommended item.
+type Explanation struct {
+	ItemId      string
+	Recommender string
+	Score       float64
+	// ClickThroughRate is predicted by the click model for offline recommendation if click-through prediction is enabled.
+	ClickThroughRate *float64 `json:",omitempty"`
+	// Source and Reason are recorded by the worker for offline recommendation.
+	Source string `json:",omitempty"`
+	Reason string `json:",omitempty"`
+	// Items are historical items and similarities contributed to item-based recommendation.
+	Items []cache.Document `json:",omitempty"`
+	// Users are neighbor users and similarities contributed to user-based recommendation.
+	Users []cache.Document `json:",omitempty"`
+}
+
+// explain records the explanation of a recommended item if the recommendation is being explained.
+func (ctx *recommendContext) explain(explanation Explanation) {
+	ctx.scores[explanation.ItemId] = explanation.Score
+	ctx.sources[explanation.ItemId] = explanation.Recommender
+	if ctx.explanations != nil {
+		ctx.explanations[explanation.ItemId] = explanation
+	}
+}
+
 type Recommender func(ctx *recommendContext) error
 
 func (s *RestServer) RecommendOffline(ctx *recommendContext) error {
 	if len(ctx.results) < ctx.n {
 		start := time.Now()
-		recommendation, err := s.CacheClient.SearchDocuments(ctx.context, cache.OfflineRecommend, ctx.userId, []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+		recommendation, err := s.searchItems(ctx, cache.OfflineRecommend, cache.Key(ctx.userId, ctx.offlineScene))
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -785,6 +1322,11 @@ func (s *RestServer) RecommendOffline(ctx *recommendContext) error {
 			if !ctx.excludeSet.Contains(item.Id) {
 				ctx.results = append(ctx.results, item.Id)
 				ctx.excludeSet.Add(item.Id)
+				explanation := Explanation{ItemId: item.Id, Recommender: "offline", Score: item.Score, Source: item.Source, Reason: item.Reason}
+				if s.Config.Recommend.GetEnableClickThroughPrediction(ctx.variants) {
+					explanation.ClickThroughRate = lo.ToPtr(item.Score)
+				}
+				ctx.explain(explanation)
 			}
 		}
 		ctx.loadOfflineRecTime = time.Since(start)
@@ -797,14 +1339,23 @@ func (s *RestServer) RecommendOffline(ctx *recommendContext) error {
 func (s *RestServer) RecommendCollaborative(ctx *recommendContext) error {
 	if len(ctx.results) < ctx.n {
 		start := time.Now()
-		collaborativeRecommendation, err := s.CacheClient.SearchDocuments(ctx.context, cache.CollaborativeRecommend, ctx.userId, []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+		collaborativeRecommendation, err := s.searchItems(ctx, cache.CollaborativeRecommend, ctx.userId)
 		if err != nil {
 			return errors.Trace(err)
 		}
+		// compute recommendations on the fly if the cache is missing or stale, except for located requests since items
+		// in the vector index are not located
+		if rankingIndex := s.syncedRankingIndex(); rankingIndex != nil && ctx.geo == nil && (len(collaborativeRecommendation) == 0 ||
+			collaborativeRecommendation[0].Timestamp.Before(time.Now().Add(-s.Config.Recommend.CacheExpire))) {
+			if recommendation, exist := rankingIndex.recommend(ctx.userId, ctx.category, s.Config.Recommend.CacheSize+ctx.excludeSet.Cardinality()); exist {
+				collaborativeRecommendation = recommendation
+			}
+		}
 		for _, item := range collaborativeRecommendation {
 			if !ctx.excludeSet.Contains(item.Id) {
 				ctx.results = append(ctx.results, item.Id)
 				ctx.excludeSet.Add(item.Id)
+				ctx.explain(Explanation{ItemId: item.Id, Recommender: "collaborative", Score: item.Score})
 			}
 		}
 		ctx.loadColRecTime = time.Since(start)
@@ -814,10 +1365,89 @@ func (s *RestServer) RecommendCollaborative(ctx *recommendContext) error {
 	return nil
 }
 
+// rankingIndex is the vector index of item factors in a ranking model.
+type rankingIndex struct {
+	model ranking.MatrixFactorization
+	index search.VectorIndex
+}
+
+// buildRankingIndex builds the vector index of item factors. Categories of items are loaded from the data store and
+// hidden items are excluded from search results.
+func (s *RestServer) buildRankingIndex(ctx context.Context, model ranking.MatrixFactorization) (*rankingIndex, error) {
+	items := make(map[string][]string)
+	itemChan, errChan := s.DataClient.GetItemStream(ctx, batchSize, nil)
+	for batchItems := range itemChan {
+		for _, item := range batchItems {
+			if !item.IsHidden {
+				items[item.ItemId] = data.ExpandCategories(item.Categories)
+			}
+		}
+	}
+	if err := <-errChan; err != nil {
+		return nil, errors.Trace(err)
+	}
+	itemIndex := model.GetItemIndex()
+	vectors := make([]search.Vector, itemIndex.Len())
+	for i := int32(0); i < itemIndex.Len(); i++ {
+		if categories, exist := items[itemIndex.ToName(i)]; exist && model.IsItemPredictable(i) {
+			vectors[i] = search.NewDenseVector(model.GetItemFactor(i), categories, false)
+		} else {
+			vectors[i] = search.NewDenseVector(model.GetItemFactor(i), nil, true)
+		}
+	}
+	var index search.VectorIndex
+	if s.Config.Recommend.Collaborative.EnableIndex {
+		builder := search.NewHNSWBuilder(vectors, s.Config.Recommend.CacheSize, runtime.NumCPU())
+		index, _ = builder.Build(s.Config.Recommend.Collaborative.IndexRecall, s.Config.Recommend.Collaborative.IndexFitEpoch, false, nil)
+	} else {
+		index = search.NewBruteforce(vectors)
+	}
+	return &rankingIndex{model: model, index: index}, nil
+}
+
+// recommend searches top n items for a user in a category. It returns false if the user is unknown to the model.
+func (index *rankingIndex) recommend(userId, category string, n int) ([]cache.Document, bool) {
+	userIndex := index.model.GetUserIndex().ToNumber(userId)
+	if userIndex == base.NotId || !index.model.IsUserPredictable(userIndex) {
+		return nil, false
+	}
+	return index.search(index.model.GetUserFactor(userIndex), category, n), true
+}
+
+// foldIn searches top n items in a category for an anonymous user with positive items. It returns false if none of
+// the items is known to the model.
+func (index *rankingIndex) foldIn(itemIds []string, category string, n int) ([]cache.Document, bool) {
+	var itemIndices []int32
+	for _, itemId := range itemIds {
+		if itemIndex := index.model.GetItemIndex().ToNumber(itemId); itemIndex != base.NotId {
+			itemIndices = append(itemIndices, itemIndex)
+		}
+	}
+	userFactor := index.model.FoldIn(itemIndices)
+	if userFactor == nil {
+		return nil, false
+	}
+	return index.search(userFactor, category, n), true
+}
+
+// search top n items in a category for a user factor.
+func (index *rankingIndex) search(userFactor []float32, category string, n int) []cache.Document {
+	values, scores := index.index.MultiSearch(search.NewDenseVector(userFactor, nil, false), []string{category}, n, false)
+	documents := make([]cache.Document, len(values[category]))
+	for i, value := range values[category] {
+		documents[i] = cache.Document{
+			Id:    index.model.GetItemIndex().ToName(value),
+			Score: -float64(scores[category][i]),
+		}
+	}
+	return documents
+}
+
 func (s *RestServer) RecommendUserBased(ctx *recommendContext) error {
 	if len(ctx.results) < ctx.n {
 		start := time.Now()
 		candidates := make(map[string]float64)
+		contributors := make(map[string][]cache.Document)
 		// load similar users
 		similarUsers, err := s.CacheClient.SearchDocuments(ctx.context, cache.UserNeighbors, ctx.userId, []string{""}, 0, s.Config.Recommend.CacheSize)
 		if err != nil {
@@ -836,8 +1466,11 @@ func (s *RestServer) RecommendUserBased(ctx *recommendContext) error {
 					if err != nil {
 						return errors.Trace(err)
 					}
-					if ctx.category == "" || funk.ContainsString(item.Categories, ctx.category) {
+					if (ctx.category == "" || funk.ContainsString(data.ExpandCategories(item.Categories), ctx.category)) && ctx.locateItem(&item) {
 						candidates[feedback.ItemId] += user.Score
+						if ctx.explanations != nil {
+							contributors[feedback.ItemId] = append(contributors[feedback.ItemId], cache.Document{Id: user.Id, Score: user.Score})
+						}
 					}
 				}
 			}
@@ -848,9 +1481,12 @@ func (s *RestServer) RecommendUserBased(ctx *recommendContext) error {
 		for id, score := range candidates {
 			filter.Push(id, score)
 		}
-		ids, _ := filter.PopAll()
+		ids, scores := filter.PopAll()
 		ctx.results = append(ctx.results, ids...)
 		ctx.excludeSet.Append(ids...)
+		for i, id := range ids {
+			ctx.explain(Explanation{ItemId: id, Recommender: "user_based", Score: scores[i], Users: contributors[id]})
+		}
 		ctx.userBasedTime = time.Since(start)
 		ctx.numFromUserBased = len(ctx.results) - ctx.numPrevStage
 		ctx.numPrevStage = len(ctx.results)
@@ -874,9 +1510,10 @@ func (s *RestServer) RecommendItemBased(ctx *recommendContext) error {
 		}
 		// collect candidates
 		candidates := make(map[string]float64)
+		contributors := make(map[string][]cache.Document)
 		for _, feedback := range userFeedback {
 			// load similar items
-			similarItems, err := s.CacheClient.SearchDocuments(ctx.context, cache.ItemNeighbors, feedback.ItemId, []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+			similarItems, err := s.searchItems(ctx, cache.ItemNeighbors, feedback.ItemId)
 			if err != nil {
 				return errors.Trace(err)
 			}
@@ -884,6 +1521,9 @@ func (s *RestServer) RecommendItemBased(ctx *recommendContext) error {
 			for _, item := range similarItems {
 				if !ctx.excludeSet.Contains(item.Id) {
 					candidates[item.Id] += item.Score
+					if ctx.explanations != nil {
+						contributors[item.Id] = append(contributors[item.Id], cache.Document{Id: feedback.ItemId, Score: item.Score})
+					}
 				}
 			}
 		}
@@ -893,9 +1533,12 @@ func (s *RestServer) RecommendItemBased(ctx *recommendContext) error {
 		for id, score := range candidates {
 			filter.Push(id, score)
 		}
-		ids, _ := filter.PopAll()
+		ids, scores := filter.PopAll()
 		ctx.results = append(ctx.results, ids...)
 		ctx.excludeSet.Append(ids...)
+		for i, id := range ids {
+			ctx.explain(Explanation{ItemId: id, Recommender: "item_based", Score: scores[i], Items: contributors[id]})
+		}
 		ctx.itemBasedTime = time.Since(start)
 		ctx.numFromItemBased = len(ctx.results) - ctx.numPrevStage
 		ctx.numPrevStage = len(ctx.results)
@@ -906,7 +1549,7 @@ func (s *RestServer) RecommendItemBased(ctx *recommendContext) error {
 func (s *RestServer) RecommendLatest(ctx *recommendContext) error {
 	if len(ctx.results) < ctx.n {
 		start := time.Now()
-		items, err := s.CacheClient.SearchDocuments(ctx.context, cache.LatestItems, "", []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+		items, err := s.searchFallbackItems(ctx, cache.LatestItems)
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -914,6 +1557,7 @@ func (s *RestServer) RecommendLatest(ctx *recommendContext) error {
 			if !ctx.excludeSet.Contains(item.Id) {
 				ctx.results = append(ctx.results, item.Id)
 				ctx.excludeSet.Add(item.Id)
+				ctx.explain(Explanation{ItemId: item.Id, Recommender: "latest", Score: item.Score})
 			}
 		}
 		ctx.loadLatestTime = time.Since(start)
@@ -926,7 +1570,7 @@ func (s *RestServer) RecommendLatest(ctx *recommendContext) error {
 func (s *RestServer) RecommendPopular(ctx *recommendContext) error {
 	if len(ctx.results) < ctx.n {
 		start := time.Now()
-		items, err := s.CacheClient.SearchDocuments(ctx.context, cache.PopularItems, "", []string{ctx.category}, 0, s.Config.Recommend.CacheSize)
+		items, err := s.searchFallbackItems(ctx, cache.PopularItems)
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -934,6 +1578,7 @@ func (s *RestServer) RecommendPopular(ctx *recommendContext) error {
 			if !ctx.excludeSet.Contains(item.Id) {
 				ctx.results = append(ctx.results, item.Id)
 				ctx.excludeSet.Add(item.Id)
+				ctx.explain(Explanation{ItemId: item.Id, Recommender: "popular", Score: item.Score})
 			}
 		}
 		ctx.loadPopularTime = time.Since(start)
@@ -943,54 +1588,256 @@ func (s *RestServer) RecommendPopular(ctx *recommendContext) error {
 	return nil
 }
 
-func (s *RestServer) getRecommend(request *restful.Request, response *restful.Response) {
-	ctx := context.Background()
-	if request != nil && request.Request != nil {
-		ctx = request.Request.Context()
+func (s *RestServer) RecommendTrending(ctx *recommendContext) error {
+	if len(ctx.results) < ctx.n {
+		start := time.Now()
+		items, err := s.searchFallbackItems(ctx, cache.TrendingItems)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, item := range items {
+			if !ctx.excludeSet.Contains(item.Id) {
+				ctx.results = append(ctx.results, item.Id)
+				ctx.excludeSet.Add(item.Id)
+				ctx.explain(Explanation{ItemId: item.Id, Recommender: "trending", Score: item.Score})
+			}
+		}
+		ctx.loadTrendingTime = time.Since(start)
+		ctx.numFromTrending = len(ctx.results) - ctx.numPrevStage
+		ctx.numPrevStage = len(ctx.results)
 	}
-	// parse arguments
-	userId := request.PathParameter("user-id")
-	n, err := ParseInt(request, "n", s.Config.Server.DefaultN)
-	if err != nil {
-		BadRequest(response, err)
-		return
+	return nil
+}
+
+// searchFallbackItems loads non-personalized recommendations. Items loaded for the batch are reused if exist.
+func (s *RestServer) searchFallbackItems(ctx *recommendContext, collection string) ([]cache.Document, error) {
+	if ctx.fallbackItems == nil {
+		return s.searchItems(ctx, collection, "")
 	}
-	category := request.PathParameter("category")
-	offset, err := ParseInt(request, "offset", 0)
-	if err != nil {
-		BadRequest(response, err)
-		return
+	if items, exist := ctx.fallbackItems[collection]; exist {
+		if ctx.geo != nil {
+			for _, item := range items {
+				ctx.distances[item.Id] = item.Location.Distance(ctx.geo.Location)
+			}
+		}
+		return items, nil
 	}
-	writeBackFeedback := request.QueryParameter("write-back-type")
-	writeBackDelay, err := ParseDuration(request, "write-back-delay")
+	items, err := s.searchItems(ctx, collection, "")
 	if err != nil {
-		BadRequest(response, err)
-		return
+		return nil, errors.Trace(err)
 	}
-	// online recommendation
-	recommenders := []Recommender{s.RecommendOffline}
-	for _, recommender := range s.Config.Recommend.Online.FallbackRecommend {
-		switch recommender {
+	ctx.fallbackItems[collection] = items
+	return items, nil
+}
+
+// onlineRecommenders creates the recommender chain from stages. The offline recommender followed by fallback
+// recommenders is used if stages are nil.
+func (s *RestServer) onlineRecommenders(variants []config.Variant, stages []string) ([]Recommender, error) {
+	if stages == nil {
+		stages = append([]string{"offline"}, s.Config.Recommend.GetFallbackRecommend(variants)...)
+	}
+	recommenders := make([]Recommender, 0, len(stages))
+	for _, stage := range stages {
+		name, limit, err := config.ParseRecommenderStage(stage)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		var recommender Recommender
+		switch name {
+		case "offline":
+			recommender = s.RecommendOffline
 		case "collaborative":
-			recommenders = append(recommenders, s.RecommendCollaborative)
+			recommender = s.RecommendCollaborative
 		case "item_based":
-			recommenders = append(recommenders, s.RecommendItemBased)
+			recommender = s.RecommendItemBased
 		case "user_based":
-			recommenders = append(recommenders, s.RecommendUserBased)
+			recommender = s.RecommendUserBased
 		case "latest":
-			recommenders = append(recommenders, s.RecommendLatest)
+			recommender = s.RecommendLatest
 		case "popular":
-			recommenders = append(recommenders, s.RecommendPopular)
+			recommender = s.RecommendPopular
+		case "trending":
+			recommender = s.RecommendTrending
 		default:
-			InternalServerError(response, fmt.Errorf("unknown fallback recommendation method `%s`", recommender))
-			return
+			return nil, errors.NotValidf("recommender `%s`", name)
 		}
+		if limit > 0 {
+			recommender = limitRecommender(recommender, limit)
+		}
+		recommenders = append(recommenders, recommender)
 	}
-	results, err := s.Recommend(ctx, response, userId, category, offset+n, recommenders...)
-	if err != nil {
+	return recommenders, nil
+}
+
+// limitRecommender limits the number of items added by a recommender. Items beyond the limit are left to following
+// recommenders.
+func limitRecommender(recommender Recommender, limit int) Recommender {
+	return func(ctx *recommendContext) error {
+		n, end := ctx.n, len(ctx.results)+limit
+		ctx.n = mathutil.Min(n, end)
+		err := recommender(ctx)
+		ctx.n = n
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if len(ctx.results) > end {
+			for _, itemId := range ctx.results[end:] {
+				ctx.excludeSet.Remove(itemId)
+			}
+			ctx.results = ctx.results[:end]
+			ctx.numPrevStage = end
+		}
+		return nil
+	}
+}
+
+// RecommendOptions are options of online recommendation for a request. Unset options follow the configuration.
+type RecommendOptions struct {
+	// Scene is the name of the scene of the request.
+	Scene string
+	// Recommenders are stages of the recommender chain in the format of "name" or "name:limit".
+	Recommenders []string
+	// ExcludeRead decides whether items with feedback from the user are excluded.
+	ExcludeRead *bool
+}
+
+// parseRecommendOptions parses options of online recommendation from query parameters "scene", "recommenders" and
+// "exclude-read".
+func (s *RestServer) parseRecommendOptions(request *restful.Request) (RecommendOptions, error) {
+	var recommenders []string
+	if param := request.QueryParameter("recommenders"); param != "" {
+		recommenders = strings.Split(param, ",")
+	}
+	var excludeRead *bool
+	if param := request.QueryParameter("exclude-read"); param != "" {
+		value, err := strconv.ParseBool(param)
+		if err != nil {
+			return RecommendOptions{}, errors.NewNotValid(err, "invalid exclude-read")
+		}
+		excludeRead = &value
+	}
+	return s.newRecommendOptions(request.QueryParameter("scene"), recommenders, excludeRead)
+}
+
+// newRecommendOptions creates options of online recommendation. The recommender chain and excludeRead override
+// options of the scene if they are set.
+func (s *RestServer) newRecommendOptions(scene string, recommenders []string, excludeRead *bool) (RecommendOptions, error) {
+	var options RecommendOptions
+	if scene != "" {
+		sceneConfig, exist := s.Config.Recommend.Scenes[scene]
+		if !exist {
+			return RecommendOptions{}, errors.NotValidf("scene `%s`", scene)
+		}
+		options.Scene = scene
+		options.Recommenders = sceneConfig.Recommenders
+		options.ExcludeRead = sceneConfig.ExcludeRead
+	}
+	if len(recommenders) > 0 {
+		options.Recommenders = recommenders
+	}
+	if excludeRead != nil {
+		options.ExcludeRead = excludeRead
+	}
+	// validate the recommender chain
+	if options.Recommenders != nil {
+		if _, err := s.onlineRecommenders(nil, options.Recommenders); err != nil {
+			return RecommendOptions{}, errors.Trace(err)
+		}
+	}
+	return options, nil
+}
+
+// applyOptions applies options of online recommendation to the context. The offline recommendation of the scene is
+// used if the scene has independent offline recommendation.
+func (s *RestServer) applyOptions(ctx *recommendContext, options RecommendOptions) {
+	if options.ExcludeRead != nil {
+		ctx.excludeRead(*options.ExcludeRead)
+	}
+	if scene, exist := s.Config.Recommend.Scenes[options.Scene]; exist && scene.Offline != nil {
+		ctx.offlineScene = options.Scene
+		ctx.variants = append([]config.Variant{scene.Offline.Variant(options.Scene)}, ctx.variants...)
+	}
+}
+
+// excludeRead decides whether items with feedback from the user are excluded. Items with negative feedback are always
+// excluded.
+func (ctx *recommendContext) excludeRead(exclude bool) {
+	ctx.excludeSet = mapset.NewSet[string](ctx.negativeItems...)
+	if exclude {
+		for _, feedback := range ctx.userFeedback {
+			ctx.excludeSet.Add(feedback.ItemId)
+		}
+	}
+}
+
+func (s *RestServer) getRecommend(request *restful.Request, response *restful.Response) {
+	ctx := context.Background()
+	if request != nil && request.Request != nil {
+		ctx = request.Request.Context()
+	}
+	// parse arguments
+	userId := request.PathParameter("user-id")
+	n, err := ParseInt(request, "n", s.Config.Server.DefaultN)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	category := request.PathParameter("category")
+	offset, err := ParseInt(request, "offset", 0)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	writeBackFeedback := request.QueryParameter("write-back-type")
+	writeBackDelay, err := ParseDuration(request, "write-back-delay")
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	geo, err := ParseGeoFilter(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	options, err := s.parseRecommendOptions(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	// online recommendation
+	writeVariants(response, s.Config.Recommend.AssignVariants(userId))
+	results, err := s.onlineRecommend(ctx, log.ResponseLogger(response), userId, category, n, offset,
+		request.Request.URL.Query()["context"], geo, options, nil, writeBackFeedback, writeBackDelay)
+	if err != nil {
 		InternalServerError(response, err)
 		return
 	}
+	// Send result
+	Ok(response, results)
+}
+
+// onlineRecommend recommends items to a user by online recommenders. Non-personalized recommendations are shared by
+// fallbackItems if it is not nil. Recommended items are written back as feedback with the write back type if it is
+// not empty.
+func (s *RestServer) onlineRecommend(ctx context.Context, logger *zap.Logger, userId, category string, n, offset int,
+	contextLabels []string, geo *GeoFilter, options RecommendOptions, fallbackItems map[string][]cache.Document,
+	writeBackFeedback string, writeBackDelay time.Duration) ([]string, error) {
+	recommenders, err := s.onlineRecommenders(s.Config.Recommend.AssignVariants(userId), options.Recommenders)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	recommendCtx, err := s.createRecommendContext(ctx, userId, category, offset+n)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s.applyOptions(recommendCtx, options)
+	recommendCtx.contextLabels = contextLabels
+	recommendCtx.geo = geo
+	recommendCtx.fallbackItems = fallbackItems
+	results, err := s.recommend(recommendCtx, logger, recommenders...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	results = results[mathutil.Min(offset, len(results)):]
 	// write back
 	if writeBackFeedback != "" {
@@ -1007,12 +1854,150 @@ func (s *RestServer) getRecommend(request *restful.Request, response *restful.Re
 			}
 			err = s.DataClient.BatchInsertFeedback(ctx, []data.Feedback{feedback}, false, false, false)
 			if err != nil {
-				InternalServerError(response, err)
-				return
+				return nil, errors.Trace(err)
 			}
 		}
 	}
+	return results, nil
+}
+
+func (s *RestServer) explainRecommend(request *restful.Request, response *restful.Response) {
+	ctx := context.Background()
+	if request != nil && request.Request != nil {
+		ctx = request.Request.Context()
+	}
+	// parse arguments
+	userId := request.PathParameter("user-id")
+	category := request.PathParameter("category")
+	n, err := ParseInt(request, "n", s.Config.Server.DefaultN)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	offset, err := ParseInt(request, "offset", 0)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	geo, err := ParseGeoFilter(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	options, err := s.parseRecommendOptions(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	// online recommendation with explanations
+	variants := s.Config.Recommend.AssignVariants(userId)
+	writeVariants(response, variants)
+	recommenders, err := s.onlineRecommenders(variants, options.Recommenders)
+	if err != nil {
+		InternalServerError(response, err)
+		return
+	}
+	recommendCtx, err := s.createRecommendContext(ctx, userId, category, offset+n)
+	if err != nil {
+		InternalServerError(response, err)
+		return
+	}
+	s.applyOptions(recommendCtx, options)
+	recommendCtx.explanations = make(map[string]Explanation)
+	recommendCtx.contextLabels = request.Request.URL.Query()["context"]
+	recommendCtx.geo = geo
+	results, err := s.recommend(recommendCtx, log.ResponseLogger(response), recommenders...)
+	if err != nil {
+		InternalServerError(response, err)
+		return
+	}
+	results = results[mathutil.Min(offset, len(results)):]
 	// Send result
+	Ok(response, lo.Map(results, func(itemId string, _ int) Explanation {
+		return recommendCtx.explanations[itemId]
+	}))
+}
+
+// BatchRecommendRequest is the request of recommendation for multiple users.
+type BatchRecommendRequest struct {
+	UserIds  []string
+	Category string
+	N        int
+	Offset   int
+}
+
+// BatchRecommendResult is the recommendation for a user in batch recommendation. Error is set if the recommendation
+// for this user failed.
+type BatchRecommendResult struct {
+	Items []string
+	Error string `json:",omitempty"`
+	// Variants are experiment variants assigned to the user, e.g. "experiment=variant".
+	Variants []string `json:",omitempty"`
+}
+
+// writeVariants writes experiment variants assigned to a user to the response header.
+func writeVariants(response *restful.Response, variants []config.Variant) {
+	for _, variant := range variants {
+		response.AddHeader("X-Experiment", variant.String())
+	}
+}
+
+func (s *RestServer) batchRecommend(request *restful.Request, response *restful.Response) {
+	ctx := context.Background()
+	if request != nil && request.Request != nil {
+		ctx = request.Request.Context()
+	}
+	// parse arguments
+	var batchRequest BatchRecommendRequest
+	if err := request.ReadEntity(&batchRequest); err != nil {
+		BadRequest(response, err)
+		return
+	}
+	if batchRequest.N == 0 {
+		batchRequest.N = s.Config.Server.DefaultN
+	}
+	if batchRequest.N < 0 || batchRequest.Offset < 0 {
+		BadRequest(response, errors.NotValidf("n = %d, offset = %d", batchRequest.N, batchRequest.Offset))
+		return
+	}
+	if len(batchRequest.UserIds) > maxBatchRecommendUsers {
+		BadRequest(response, errors.NotValidf("%d users exceed the limit %d", len(batchRequest.UserIds), maxBatchRecommendUsers))
+		return
+	}
+	writeBackFeedback := request.QueryParameter("write-back-type")
+	writeBackDelay, err := ParseDuration(request, "write-back-delay")
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	geo, err := ParseGeoFilter(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	options, err := s.parseRecommendOptions(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
+	// recommend for each user, non-personalized recommendations are loaded once for all users
+	fallbackItems := make(map[string][]cache.Document)
+	results := make(map[string]BatchRecommendResult, len(batchRequest.UserIds))
+	for _, userId := range batchRequest.UserIds {
+		items, err := s.onlineRecommend(ctx, log.ResponseLogger(response), userId, batchRequest.Category, batchRequest.N, batchRequest.Offset,
+			request.Request.URL.Query()["context"], geo, options, fallbackItems, writeBackFeedback, writeBackDelay)
+		if err != nil {
+			log.ResponseLogger(response).Error("failed to recommend", zap.String("user_id", userId), zap.Error(err))
+			results[userId] = BatchRecommendResult{Error: err.Error()}
+			continue
+		}
+		results[userId] = BatchRecommendResult{
+			Items: items,
+			Variants: lo.Map(s.Config.Recommend.AssignVariants(userId), func(variant config.Variant, _ int) string {
+				return variant.String()
+			}),
+		}
+	}
 	Ok(response, results)
 }
 
@@ -1038,6 +2023,11 @@ func (s *RestServer) sessionRecommend(request *restful.Request, response *restfu
 		BadRequest(response, err)
 		return
 	}
+	geo, err := ParseGeoFilter(request)
+	if err != nil {
+		BadRequest(response, err)
+		return
+	}
 
 	// pre-process feedback
 	dataFeedback := make([]data.Feedback, len(feedbacks))
@@ -1049,6 +2039,18 @@ func (s *RestServer) sessionRecommend(request *restful.Request, response *restfu
 			return
 		}
 	}
+	result, err := s.recommendSession(ctx, dataFeedback, category, geo, n, offset)
+	if err != nil {
+		InternalServerError(response, err)
+		return
+	}
+	// Send result
+	Ok(response, result)
+}
+
+// recommendSession recommends items to an anonymous user by feedback in the session. If the geo filter is not nil,
+// only items within the radius are recommended and their scores are decayed by distances.
+func (s *RestServer) recommendSession(ctx context.Context, dataFeedback []data.Feedback, category string, geo *GeoFilter, n, offset int) ([]cache.Document, error) {
 	data.SortFeedbacks(dataFeedback)
 
 	// item-based recommendation
@@ -1060,15 +2062,42 @@ func (s *RestServer) sessionRecommend(request *restful.Request, response *restfu
 			userFeedback = append(userFeedback, feedback)
 		}
 	}
+	// fold-in recommendation, except for located requests since items in the vector index are not located
+	if s.Config.Recommend.Online.SessionRecommend == "fold_in" && geo == nil {
+		if rankingIndex := s.syncedRankingIndex(); rankingIndex != nil {
+			itemIds := lo.Map(userFeedback, func(feedback data.Feedback, _ int) string {
+				return feedback.ItemId
+			})
+			if result, exist := rankingIndex.foldIn(itemIds, category, n+offset+excludeSet.Cardinality()); exist {
+				result = lo.Filter(result, func(document cache.Document, _ int) bool {
+					return !excludeSet.Contains(document.Id)
+				})
+				result = result[lo.Min([]int{len(result), offset}):]
+				result = result[:lo.Min([]int{len(result), n})]
+				return result, nil
+			}
+		}
+	}
 	// collect candidates
 	candidates := make(map[string]float64)
 	usedFeedbackCount := 0
 	for _, feedback := range userFeedback {
 		// load similar items
-		similarItems, err := s.CacheClient.SearchDocuments(ctx, cache.ItemNeighbors, feedback.ItemId, []string{category}, 0, s.Config.Recommend.CacheSize)
+		var (
+			similarItems []cache.Document
+			err          error
+		)
+		if geo == nil {
+			similarItems, err = s.CacheClient.SearchDocuments(ctx, cache.ItemNeighbors, feedback.ItemId, []string{category}, 0, s.Config.Recommend.CacheSize)
+		} else {
+			similarItems, err = s.CacheClient.SearchNearbyDocuments(ctx, cache.ItemNeighbors, feedback.ItemId, []string{category},
+				geo.Location, geo.Radius, 0, s.Config.Recommend.CacheSize)
+			for i := range similarItems {
+				similarItems[i].Score *= s.distanceDecay(similarItems[i].Location.Distance(geo.Location))
+			}
+		}
 		if err != nil {
-			BadRequest(response, err)
-			return
+			return nil, errors.Trace(err)
 		}
 		// add unseen items
 		//similarItems = s.FilterOutHiddenScores(response, similarItems, "")
@@ -1103,13 +2132,19 @@ func (s *RestServer) sessionRecommend(request *restful.Request, response *restfu
 		result = nil
 	}
 	result = result[:lo.Min([]int{len(result), n})]
-	// Send result
-	Ok(response, result)
+	return result, nil
 }
 
 // Success is the returned data structure for data insert operations.
 type Success struct {
 	RowAffected int
+	Errors      []RowError `json:",omitempty"`
+}
+
+// RowError is the error of a row rejected in a batch insertion.
+type RowError struct {
+	Index int
+	Error string
 }
 
 func (s *RestServer) insertUser(request *restful.Request, response *restful.Response) {
@@ -1123,21 +2158,28 @@ func (s *RestServer) insertUser(request *restful.Request, response *restful.Resp
 		BadRequest(response, err)
 		return
 	}
-	// validate labels
-	if err := data.ValidateLabels(temp.Labels); err != nil {
-		BadRequest(response, err)
+	if err := s.saveUser(ctx, temp); err != nil {
+		if errors.Is(err, errors.NotValid) {
+			BadRequest(response, err)
+		} else {
+			InternalServerError(response, err)
+		}
 		return
 	}
-	if err := s.DataClient.BatchInsertUsers(ctx, []data.User{temp}); err != nil {
-		InternalServerError(response, err)
-		return
+	Ok(response, Success{RowAffected: 1})
+}
+
+// saveUser inserts a user and updates the modify timestamp of the user.
+func (s *RestServer) saveUser(ctx context.Context, user data.User) error {
+	// validate labels
+	if err := data.ValidateLabels(user.Labels); err != nil {
+		return errors.NewNotValid(err, "invalid labels")
 	}
-	// insert modify timestamp
-	if err := s.CacheClient.Set(ctx, cache.Time(cache.Key(cache.LastModifyUserTime, temp.UserId), time.Now())); err != nil {
-		InternalServerError(response, err)
-		return
+	if err := s.DataClient.BatchInsertUsers(ctx, []data.User{user}); err != nil {
+		return errors.Trace(err)
 	}
-	Ok(response, Success{RowAffected: 1})
+	// insert modify timestamp
+	return s.CacheClient.Set(ctx, cache.Time(cache.Key(cache.LastModifyUserTime, user.UserId), time.Now()))
 }
 
 func (s *RestServer) modifyUser(request *restful.Request, response *restful.Response) {
@@ -1305,7 +2347,8 @@ type Item struct {
 	Comment    string
 }
 
-func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Response, temp []Item) {
+// batchInsertItems inserts items and updates caches of items. It returns the number of inserted items.
+func (s *RestServer) batchInsertItems(ctx context.Context, logger *zap.Logger, temp []Item) (int, error) {
 	var (
 		count int
 		items = make([]data.Item, 0, len(temp))
@@ -1318,14 +2361,19 @@ func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Res
 		insertItemsTime      time.Duration
 		insertCacheTime      time.Duration
 	)
+	// validate labels
+	for _, item := range temp {
+		if err := data.ValidateLabels(item.Labels); err != nil {
+			return 0, errors.NewNotValid(err, "invalid labels")
+		}
+	}
 	// load existed items
 	start := time.Now()
 	existedItems, err := s.DataClient.BatchGetItems(ctx, lo.Map(temp, func(t Item, i int) string {
 		return t.ItemId
 	}))
 	if err != nil {
-		InternalServerError(response, err)
-		return
+		return 0, errors.Trace(err)
 	}
 	existedItemsSet := make(map[string]data.Item)
 	for _, item := range existedItems {
@@ -1340,8 +2388,7 @@ func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Res
 		var err error
 		if item.Timestamp != "" {
 			if timestamp, err = dateparse.ParseAny(item.Timestamp); err != nil {
-				BadRequest(response, err)
-				return
+				return 0, errors.NewNotValid(err, "invalid timestamp")
 			}
 		}
 		items = append(items, data.Item{
@@ -1358,17 +2405,16 @@ func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Res
 			Score:      float64(timestamp.Unix()),
 			Categories: withWildCard(item.Categories),
 			Timestamp:  time.Now(),
+			Location:   itemLocation(item.Labels),
 		}}); err != nil {
-			InternalServerError(response, err)
-			return
+			return 0, errors.Trace(err)
 		}
 		// update items cache
 		if err = s.CacheClient.UpdateDocuments(ctx, cache.ItemCache, item.ItemId, cache.DocumentPatch{
 			Categories: withWildCard(item.Categories),
 			IsHidden:   &item.IsHidden,
 		}); err != nil {
-			InternalServerError(response, err)
-			return
+			return 0, errors.Trace(err)
 		}
 		count++
 	}
@@ -1377,8 +2423,7 @@ func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Res
 	// insert items
 	start = time.Now()
 	if err = s.DataClient.BatchInsertItems(ctx, items); err != nil {
-		InternalServerError(response, err)
-		return
+		return 0, errors.Trace(err)
 	}
 	insertItemsTime = time.Since(start)
 
@@ -1388,25 +2433,23 @@ func (s *RestServer) batchInsertItems(ctx context.Context, response *restful.Res
 	values := make([]cache.Value, len(items))
 	for i, item := range items {
 		values[i] = cache.Time(cache.Key(cache.LastModifyItemTime, item.ItemId), time.Now())
-		categories.Append(item.Categories...)
+		categories.Append(data.ExpandCategories(item.Categories)...)
 	}
 	if err = s.CacheClient.Set(ctx, values...); err != nil {
-		InternalServerError(response, err)
-		return
+		return 0, errors.Trace(err)
 	}
 	// insert categories
 	if err = s.CacheClient.AddSet(ctx, cache.ItemCategories, categories.ToSlice()...); err != nil {
-		InternalServerError(response, err)
-		return
+		return 0, errors.Trace(err)
 	}
 
 	insertCacheTime = time.Since(start)
-	log.ResponseLogger(response).Info("batch insert items",
+	logger.Info("batch insert items",
 		zap.Duration("load_existed_items_time", loadExistedItemsTime),
 		zap.Duration("parse_timestamp_time", parseTimesatmpTime),
 		zap.Duration("insert_items_time", insertItemsTime),
 		zap.Duration("insert_cache_time", insertCacheTime))
-	Ok(response, Success{RowAffected: count})
+	return count, nil
 }
 
 func (s *RestServer) insertItems(request *restful.Request, response *restful.Response) {
@@ -1419,15 +2462,8 @@ func (s *RestServer) insertItems(request *restful.Request, response *restful.Res
 		BadRequest(response, err)
 		return
 	}
-	// validate labels
-	for _, user := range items {
-		if err := data.ValidateLabels(user.Labels); err != nil {
-			BadRequest(response, err)
-			return
-		}
-	}
 	// Insert items
-	s.batchInsertItems(ctx, response, items)
+	s.writeInsertItems(ctx, response, items)
 }
 
 func (s *RestServer) insertItem(request *restful.Request, response *restful.Response) {
@@ -1436,17 +2472,24 @@ func (s *RestServer) insertItem(request *restful.Request, response *restful.Resp
 		ctx = request.Request.Context()
 	}
 	var item Item
-	var err error
-	if err = request.ReadEntity(&item); err != nil {
+	if err := request.ReadEntity(&item); err != nil {
 		BadRequest(response, err)
 		return
 	}
-	// validate labels
-	if err := data.ValidateLabels(item.Labels); err != nil {
-		BadRequest(response, err)
+	s.writeInsertItems(ctx, response, []Item{item})
+}
+
+func (s *RestServer) writeInsertItems(ctx context.Context, response *restful.Response, items []Item) {
+	count, err := s.batchInsertItems(ctx, log.ResponseLogger(response), items)
+	if err != nil {
+		if errors.Is(err, errors.NotValid) {
+			BadRequest(response, err)
+		} else {
+			InternalServerError(response, err)
+		}
 		return
 	}
-	s.batchInsertItems(ctx, response, []Item{item})
+	Ok(response, Success{RowAffected: count})
 }
 
 func (s *RestServer) modifyItem(request *restful.Request, response *restful.Response) {
@@ -1549,19 +2592,23 @@ func (s *RestServer) deleteItem(request *restful.Request, response *restful.Resp
 		ctx = request.Request.Context()
 	}
 	itemId := request.PathParameter("item-id")
-	// delete item from database
-	if err := s.DataClient.DeleteItem(ctx, itemId); err != nil {
-		InternalServerError(response, err)
-		return
-	}
-	// delete item from cache
-	if err := s.CacheClient.DeleteDocuments(ctx, cache.ItemCache, cache.DocumentCondition{Id: &itemId}); err != nil {
+	if err := s.removeItem(ctx, itemId); err != nil {
 		InternalServerError(response, err)
 		return
 	}
 	Ok(response, Success{RowAffected: 1})
 }
 
+// removeItem deletes an item from the data store and the cache store.
+func (s *RestServer) removeItem(ctx context.Context, itemId string) error {
+	// delete item from database
+	if err := s.DataClient.DeleteItem(ctx, itemId); err != nil {
+		return errors.Trace(err)
+	}
+	// delete item from cache
+	return s.CacheClient.DeleteDocuments(ctx, cache.ItemCache, cache.DocumentCondition{Id: &itemId})
+}
+
 func (s *RestServer) insertItemCategory(request *restful.Request, response *restful.Response) {
 	ctx := context.Background()
 	if request != nil && request.Request != nil {
@@ -1631,12 +2678,17 @@ type Feedback struct {
 	data.FeedbackKey
 	Timestamp string
 	Comment   string
+	Value     float64
+	// ContextLabels are labels of the context where the feedback happened, e.g. device.mobile.
+	ContextLabels []string
 }
 
 func (f Feedback) ToDataFeedback() (data.Feedback, error) {
 	var feedback data.Feedback
 	feedback.FeedbackKey = f.FeedbackKey
 	feedback.Comment = f.Comment
+	feedback.Value = f.Value
+	feedback.ContextLabels = f.ContextLabels
 	if f.Timestamp != "" {
 		var err error
 		feedback.Timestamp, err = dateparse.ParseAny(f.Timestamp)
@@ -1653,48 +2705,127 @@ func (s *RestServer) insertFeedback(overwrite bool) func(request *restful.Reques
 		if request != nil && request.Request != nil {
 			ctx = request.Request.Context()
 		}
+		var partial bool
+		if partialParam := request.QueryParameter("partial"); partialParam != "" {
+			var err error
+			if partial, err = strconv.ParseBool(partialParam); err != nil {
+				BadRequest(response, err)
+				return
+			}
+		}
 		// add ratings
 		var feedbackLiterTime []Feedback
 		if err := request.ReadEntity(&feedbackLiterTime); err != nil {
 			BadRequest(response, err)
 			return
 		}
-		// parse datetime
-		var err error
-		feedback := make([]data.Feedback, len(feedbackLiterTime))
-		users := mapset.NewSet[string]()
-		items := mapset.NewSet[string]()
-		for i := range feedback {
-			users.Add(feedbackLiterTime[i].UserId)
-			items.Add(feedbackLiterTime[i].ItemId)
-			feedback[i], err = feedbackLiterTime[i].ToDataFeedback()
-			if err != nil {
+		result, replayed, err := s.insertFeedbackIdempotently(ctx, log.ResponseLogger(response),
+			request.HeaderParameter(IdempotencyKeyHeader), feedbackLiterTime, overwrite, partial)
+		if err != nil {
+			if errors.Is(err, errors.NotValid) {
 				BadRequest(response, err)
-				return
+			} else if errors.Is(err, errors.AlreadyExists) {
+				Error(response, http.StatusConflict, err)
+			} else {
+				InternalServerError(response, err)
 			}
+			return
 		}
-		// insert feedback to data store
-		err = s.DataClient.BatchInsertFeedback(ctx, feedback,
-			s.Config.Server.AutoInsertUser,
-			s.Config.Server.AutoInsertItem, overwrite)
+		if replayed {
+			response.Header().Set(IdempotentReplayedHeader, "true")
+		}
+		Ok(response, result)
+	}
+}
+
+// insertFeedbackIdempotently inserts feedback. The result of a request with an idempotency key is remembered and
+// replayed for the retries of the request. It returns whether the result is replayed.
+func (s *RestServer) insertFeedbackIdempotently(ctx context.Context, logger *zap.Logger, idempotencyKey string, feedback []Feedback, overwrite, partial bool) (*Success, bool, error) {
+	// reserve the idempotency key or replay the result of the request with the same key
+	var requestHash string
+	if idempotencyKey != "" {
+		var err error
+		if requestHash, err = hashRequest([]any{feedback, overwrite, partial}); err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		result, err := s.reserveIdempotentRequest(ctx, idempotencyKey, requestHash)
 		if err != nil {
-			InternalServerError(response, err)
-			return
+			return nil, false, errors.Trace(err)
+		}
+		if result != nil {
+			return result, true, nil
 		}
-		values := make([]cache.Value, 0, users.Cardinality()+items.Cardinality())
-		for _, userId := range users.ToSlice() {
-			values = append(values, cache.Time(cache.Key(cache.LastModifyUserTime, userId), time.Now()))
+	}
+	count, rowErrors, err := s.batchInsertFeedback(ctx, logger, feedback, overwrite, partial)
+	if err != nil {
+		if idempotencyKey != "" {
+			// release the idempotency key since nothing is inserted
+			if err := DeleteIdempotencyRecord(ctx, s.CacheClient, idempotencyKey); err != nil {
+				logger.Error("failed to release idempotency key", zap.String("key", idempotencyKey), zap.Error(err))
+			}
 		}
-		for _, itemId := range items.ToSlice() {
-			values = append(values, cache.Time(cache.Key(cache.LastModifyItemTime, itemId), time.Now()))
+		return nil, false, errors.Trace(err)
+	}
+	result := Success{RowAffected: count, Errors: rowErrors}
+	if idempotencyKey != "" {
+		record := IdempotencyRecord{RequestHash: requestHash, Result: result, Timestamp: time.Now()}
+		if err = SaveIdempotencyRecord(ctx, s.CacheClient, idempotencyKey, record); err != nil {
+			// feedback has been inserted, so the result is returned even if it can't be remembered
+			logger.Error("failed to save idempotency record", zap.String("key", idempotencyKey), zap.Error(err))
+			if err = DeleteIdempotencyRecord(ctx, s.CacheClient, idempotencyKey); err != nil {
+				logger.Error("failed to release idempotency key", zap.String("key", idempotencyKey), zap.Error(err))
+			}
 		}
-		if err = s.CacheClient.Set(ctx, values...); err != nil {
-			InternalServerError(response, err)
-			return
+	}
+	return &result, false, nil
+}
+
+// batchInsertFeedback inserts feedback and updates modify timestamps of users and items. It returns the number of
+// inserted feedback. Invalid feedback rejects the whole batch, or it is skipped and reported in row errors in the
+// partial mode.
+func (s *RestServer) batchInsertFeedback(ctx context.Context, logger *zap.Logger, feedbackLiterTime []Feedback, overwrite, partial bool) (int, []RowError, error) {
+	// parse datetime and validate feedback
+	var rowErrors []RowError
+	feedback := make([]data.Feedback, 0, len(feedbackLiterTime))
+	users := mapset.NewSet[string]()
+	items := mapset.NewSet[string]()
+	for i := range feedbackLiterTime {
+		f, err := feedbackLiterTime[i].ToDataFeedback()
+		if err != nil {
+			err = errors.NewNotValid(err, "invalid timestamp")
+		} else if f.FeedbackType == "" || f.UserId == "" || f.ItemId == "" {
+			err = errors.NotValidf("feedback without feedback type, user id or item id")
 		}
-		log.ResponseLogger(response).Info("Insert feedback successfully", zap.Int("num_feedback", len(feedback)))
-		Ok(response, Success{RowAffected: len(feedback)})
+		if err != nil {
+			if !partial {
+				return 0, nil, err
+			}
+			rowErrors = append(rowErrors, RowError{Index: i, Error: err.Error()})
+			continue
+		}
+		users.Add(f.UserId)
+		items.Add(f.ItemId)
+		feedback = append(feedback, f)
+	}
+	// insert feedback to data store
+	err := s.DataClient.BatchInsertFeedback(ctx, feedback,
+		s.Config.Server.AutoInsertUser,
+		s.Config.Server.AutoInsertItem, overwrite)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	values := make([]cache.Value, 0, users.Cardinality()+items.Cardinality())
+	for _, userId := range users.ToSlice() {
+		values = append(values, cache.Time(cache.Key(cache.LastModifyUserTime, userId), time.Now()))
+	}
+	for _, itemId := range items.ToSlice() {
+		values = append(values, cache.Time(cache.Key(cache.LastModifyItemTime, itemId), time.Now()))
+	}
+	if err = s.CacheClient.Set(ctx, values...); err != nil {
+		return 0, nil, errors.Trace(err)
 	}
+	logger.Info("Insert feedback successfully", zap.Int("num_feedback", len(feedback)), zap.Int("num_errors", len(rowErrors)))
+	return len(feedback), rowErrors, nil
 }
 
 // FeedbackIterator is the iterator for feedback.
@@ -1914,9 +3045,7 @@ func Text(response *restful.Response, content string) {
 	}
 }
 
+// withWildCard returns categories with their ancestors and the wildcard category.
 func withWildCard(categories []string) []string {
-	result := make([]string, len(categories), len(categories)+1)
-	copy(result, categories)
-	result = append(result, "")
-	return result
+	return append(data.ExpandCategories(categories), "")
 }
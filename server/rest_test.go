@@ -22,12 +22,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/bits-and-blooms/bitset"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/samber/lo"
 	"github.com/steinfletcher/apitest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"github.com/zhenghaoz/gorse/base"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model"
+	"github.com/zhenghaoz/gorse/model/click"
+	"github.com/zhenghaoz/gorse/model/ranking"
 	"github.com/zhenghaoz/gorse/storage/cache"
 	"github.com/zhenghaoz/gorse/storage/data"
 	"google.golang.org/protobuf/proto"
@@ -751,7 +756,7 @@ func (suite *ServerTestSuite) TestFeedback() {
 		Header("X-API-Key", apiKey).
 		Expect(t).
 		Status(http.StatusOK).
-		Body(`[{"FeedbackType":"click", "UserId": "2", "ItemId": "4", "Timestamp":"0001-01-01T00:00:00Z","Comment":""}]`).
+		Body(`[{"FeedbackType":"click", "UserId": "2", "ItemId": "4", "Timestamp":"0001-01-01T00:00:00Z","Comment":"","Value":0}]`).
 		End()
 	apitest.New().
 		Handler(suite.handler).
@@ -759,7 +764,7 @@ func (suite *ServerTestSuite) TestFeedback() {
 		Header("X-API-Key", apiKey).
 		Expect(t).
 		Status(http.StatusOK).
-		Body(`[{"FeedbackType":"click", "UserId": "2", "ItemId": "4", "Timestamp":"0001-01-01T00:00:00Z","Comment":""}]`).
+		Body(`[{"FeedbackType":"click", "UserId": "2", "ItemId": "4", "Timestamp":"0001-01-01T00:00:00Z","Comment":"","Value":0}]`).
 		End()
 	// test overwrite
 	apitest.New().
@@ -769,6 +774,7 @@ func (suite *ServerTestSuite) TestFeedback() {
 		JSON([]data.Feedback{{
 			FeedbackKey: data.FeedbackKey{FeedbackType: "click", UserId: "0", ItemId: "0"},
 			Comment:     "override",
+			Value:       2.5,
 		}}).
 		Expect(t).
 		Status(http.StatusOK).
@@ -778,6 +784,7 @@ func (suite *ServerTestSuite) TestFeedback() {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(ret))
 	assert.Equal(t, "override", ret[0].Comment)
+	assert.Equal(t, 2.5, ret[0].Value)
 	// test not overwrite
 	apitest.New().
 		Handler(suite.handler).
@@ -826,6 +833,8 @@ func (suite *ServerTestSuite) TestNonPersonalizedRecommend() {
 		{"Latest Items in Category", cache.LatestItems, "", "0", "/api/latest/0"},
 		{"Popular Items", cache.PopularItems, "", "", "/api/popular/"},
 		{"Popular Items in Category", cache.PopularItems, "", "0", "/api/popular/0"},
+		{"Trending Items", cache.TrendingItems, "", "", "/api/trending/"},
+		{"Trending Items in Category", cache.TrendingItems, "", "0", "/api/trending/0"},
 		{"Offline Recommend", cache.OfflineRecommend, "0", "", "/api/intermediate/recommend/0"},
 		{"Offline Recommend in Category", cache.OfflineRecommend, "0", "0", "/api/intermediate/recommend/0/0"},
 	}
@@ -1140,6 +1149,126 @@ func (suite *ServerTestSuite) TestGetRecommends() {
 		End()
 }
 
+func (suite *ServerTestSuite) TestGetRecommendsWithHierarchicalCategories() {
+	t := suite.T()
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"latest"}
+	// insert items
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/items").
+		Header("X-API-Key", apiKey).
+		JSON([]Item{
+			{ItemId: "0", Categories: []string{"books/fiction/scifi"}, Timestamp: "2023-01-04"},
+			{ItemId: "1", Categories: []string{"books/history"}, Timestamp: "2023-01-03"},
+			{ItemId: "2", Categories: []string{"music"}, Timestamp: "2023-01-02"},
+			{ItemId: "3", Categories: []string{"books/fiction"}, Timestamp: "2023-01-01"},
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	// items are recommended in ancestors of their categories
+	for category, expected := range map[string][]string{
+		"books":               {"0", "1", "3"},
+		"books/fiction":       {"0", "3"},
+		"books/fiction/scifi": {"0"},
+		"music":               {"2"},
+	} {
+		apitest.New().
+			Handler(suite.handler).
+			Get("/api/recommend/0/"+category).
+			Header("X-API-Key", apiKey).
+			Expect(t).
+			Status(http.StatusOK).
+			Body(suite.marshal(expected)).
+			End()
+	}
+	apitest.New().
+		Handler(suite.handler).
+		Put("/api/item/2/category/music/jazz").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		End()
+	item, err := suite.DataClient.GetItem(context.Background(), "2")
+	suite.NoError(err)
+	suite.Equal([]string{"music", "music/jazz"}, item.Categories)
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithScenes() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Scenes = map[string]config.SceneConfig{
+		"homepage": {Recommenders: []string{"popular"}},
+		"detail":   {Recommenders: []string{"offline:1", "popular"}, ExcludeRead: lo.ToPtr(false)},
+		"video":    {Offline: &config.OfflineSceneConfig{}},
+	}
+	// insert recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "0", Score: 100, Categories: []string{""}},
+		{Id: "1", Score: 99, Categories: []string{""}},
+		{Id: "2", Score: 98, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, cache.Key("0", "video"), []cache.Document{
+		{Id: "5", Score: 100, Categories: []string{""}},
+		{Id: "6", Score: 99, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", []cache.Document{
+		{Id: "2", Score: 10, Categories: []string{""}},
+		{Id: "3", Score: 9, Categories: []string{""}},
+		{Id: "4", Score: 8, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	// insert read feedback
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{{
+		FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "0", ItemId: "0"},
+		Timestamp:   time.Now().Add(-time.Hour),
+	}}, true, true, true)
+	suite.NoError(err)
+
+	for _, testCase := range []struct {
+		params   map[string]string
+		expected []string
+	}{
+		{map[string]string{}, []string{"1", "2"}},
+		{map[string]string{"recommenders": "offline:1,popular"}, []string{"1", "2", "3", "4"}},
+		{map[string]string{"recommenders": "popular,offline"}, []string{"2", "3", "4", "1"}},
+		{map[string]string{"scene": "homepage"}, []string{"2", "3", "4"}},
+		{map[string]string{"scene": "detail"}, []string{"0", "2", "3", "4"}},
+		{map[string]string{"scene": "detail", "exclude-read": "true"}, []string{"1", "2", "3", "4"}},
+		{map[string]string{"scene": "video"}, []string{"5", "6"}},
+		{map[string]string{"scene": "video", "recommenders": "offline,popular"}, []string{"5", "6", "2", "3"}},
+	} {
+		testCase.params["n"] = "4"
+		apitest.New().
+			Handler(suite.handler).
+			Get("/api/recommend/0").
+			Header("X-API-Key", apiKey).
+			QueryParams(testCase.params).
+			Expect(t).
+			Status(http.StatusOK).
+			Body(suite.marshal(testCase.expected)).
+			End()
+	}
+	// invalid options
+	for _, params := range []map[string]string{
+		{"scene": "unknown"},
+		{"recommenders": "unknown"},
+		{"recommenders": "offline:0"},
+		{"exclude-read": "maybe"},
+	} {
+		apitest.New().
+			Handler(suite.handler).
+			Get("/api/recommend/0").
+			Header("X-API-Key", apiKey).
+			QueryParams(params).
+			Expect(t).
+			Status(http.StatusBadRequest).
+			End()
+	}
+}
+
 func (suite *ServerTestSuite) TestGetRecommendsWithReplacement() {
 	ctx := context.Background()
 	t := suite.T()
@@ -1194,6 +1323,555 @@ func (suite *ServerTestSuite) TestGetRecommendsWithReplacement() {
 		End()
 }
 
+func (suite *ServerTestSuite) TestGetRecommendsWithNegativeFeedback() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Replacement.EnableReplacement = true
+	suite.Config.Recommend.DataSource.NegativeFeedbackTypes = []string{"dislike"}
+	// insert recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "0", Score: 100, Categories: []string{""}},
+		{Id: "1", Score: 99, Categories: []string{""}},
+		{Id: "2", Score: 98, Categories: []string{""}},
+		{Id: "3", Score: 97, Categories: []string{""}},
+		{Id: "4", Score: 96, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert item neighbors
+	err = suite.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, "0", []cache.Document{
+		{Id: "1", Score: 1, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert items
+	err = suite.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "0", Categories: []string{"a"}},
+		{ItemId: "1"},
+		{ItemId: "2", Categories: []string{"a"}},
+		{ItemId: "3", Categories: []string{"b"}},
+		{ItemId: "4"},
+	})
+	assert.NoError(t, err)
+	// insert negative feedback
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/feedback").
+		Header("X-API-Key", apiKey).
+		JSON([]data.Feedback{{FeedbackKey: data.FeedbackKey{FeedbackType: "dislike", UserId: "0", ItemId: "0"}}}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(`{"RowAffected": 1}`).
+		End()
+	// the negative item is excluded even if replacement is enabled, and its neighbors and categories are decayed
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "4",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1", "3", "2", "4"})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithDiversity() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Diversity.EnableDiversity = true
+	suite.Config.Recommend.Diversity.Lambda = 0.5
+	// insert recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "1", Score: 100, Categories: []string{""}},
+		{Id: "2", Score: 99, Categories: []string{""}},
+		{Id: "3", Score: 98, Categories: []string{""}},
+		{Id: "4", Score: 97, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert items
+	err = suite.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "1", Labels: []any{"a"}},
+		{ItemId: "2", Labels: []any{"a"}},
+		{ItemId: "3", Labels: []any{"b"}},
+		{ItemId: "4", Labels: []any{"b"}},
+	})
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1", "3", "2"})).
+		End()
+	// relevance is decided by scores
+	suite.Config.Recommend.Diversity.Lambda = 0.7
+	err = suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "1", []cache.Document{
+		{Id: "1", Score: 100, Categories: []string{""}},
+		{Id: "2", Score: 99, Categories: []string{""}},
+		{Id: "3", Score: 10, Categories: []string{""}},
+		{Id: "4", Score: 9, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/1").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1", "2", "3"})).
+		End()
+}
+
+func TestRecommendContext_Relevance(t *testing.T) {
+	ctx := &recommendContext{
+		results: []string{"1", "2", "3", "4", "5"},
+		scores:  map[string]float64{"1": 10, "2": 6, "3": 0, "4": 100, "5": 100},
+		sources: map[string]string{"1": "offline", "2": "offline", "3": "offline", "4": "popular", "5": "popular"},
+	}
+	assert.Equal(t, []float64{1, 0.6, 0, 0, 0}, ctx.relevance())
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithExperiments() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"latest"}
+	suite.Config.Recommend.Experiments = []config.ExperimentConfig{{
+		Name:     "fallback",
+		Variants: []config.VariantConfig{{Name: "popular", Weight: 1, FallbackRecommend: []string{"popular"}}},
+	}}
+	// insert latest and popular items
+	err := suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{{Id: "1", Score: 1, Categories: []string{""}}})
+	assert.NoError(t, err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", []cache.Document{{Id: "2", Score: 1, Categories: []string{""}}})
+	assert.NoError(t, err)
+	// variants are written to the header and fallback recommenders follow variants
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Header("X-Experiment", "fallback=popular").
+		Body(suite.marshal([]string{"2"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		JSON(BatchRecommendRequest{UserIds: []string{"0"}}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(map[string]BatchRecommendResult{
+			"0": {Items: []string{"2"}, Variants: []string{"fallback=popular"}},
+		})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithContext() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Offline.EnableClickThroughPrediction = true
+	// train a click model preferring items with larger ids on desktop and items with smaller ids on mobile
+	builder := click.NewUnifiedMapIndexBuilder()
+	builder.AddUser("0")
+	for _, itemId := range []string{"1", "2", "3"} {
+		builder.AddItem(itemId)
+	}
+	builder.AddCtxLabel("device.desktop")
+	builder.AddCtxLabel("device.mobile")
+	dataset := &click.Dataset{
+		Index:        builder.Build(),
+		UserFeatures: [][]int32{nil},
+		ItemFeatures: [][]int32{nil, nil, nil},
+	}
+	for _, sample := range []struct {
+		itemIndex int32
+		ctxLabel  string
+		target    float32
+	}{
+		{2, "device.desktop", 1}, {1, "device.desktop", 1}, {1, "device.desktop", -1}, {0, "device.desktop", -1},
+		{0, "device.mobile", 1}, {1, "device.mobile", 1}, {1, "device.mobile", -1}, {2, "device.mobile", -1},
+	} {
+		dataset.Users.Append(0)
+		dataset.Items.Append(sample.itemIndex)
+		dataset.NormValues.Append(1)
+		dataset.Target.Append(sample.target)
+		dataset.CtxFeatures = append(dataset.CtxFeatures, []int32{dataset.Index.EncodeContextLabel(sample.ctxLabel)})
+		dataset.CtxValues = append(dataset.CtxValues, []float32{1})
+	}
+	fm := click.NewFM(click.FMClassification, model.Params{model.NEpochs: 100, model.Lr: 0.1})
+	fm.Fit(dataset, dataset, nil)
+	suite.ClickModel = fm
+	defer func() {
+		suite.ClickModel = nil
+	}()
+	// insert recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "2", Score: 100, Categories: []string{""}},
+		{Id: "3", Score: 99, Categories: []string{""}},
+		{Id: "1", Score: 98, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert items
+	err = suite.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "1"}, {ItemId: "2"}, {ItemId: "3"}})
+	assert.NoError(t, err)
+	// recommendations are not re-ranked without context
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"2", "3", "1"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Query("context", "device.desktop").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"3", "2", "1"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Query("context", "device.mobile").
+		Query("context", "page.home").
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1", "2", "3"})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithRankingIndex() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"collaborative"}
+	// insert items
+	err := suite.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "1", Categories: []string{"a"}},
+		{ItemId: "2"},
+		{ItemId: "3", Categories: []string{"a"}},
+		{ItemId: "4", IsHidden: true},
+	})
+	assert.NoError(t, err)
+	// create ranking model
+	bpr := ranking.NewBPR(nil)
+	bpr.UserIndex = base.NewMapIndex()
+	bpr.UserIndex.Add("0")
+	bpr.ItemIndex = base.NewMapIndex()
+	for _, itemId := range []string{"1", "2", "3", "4"} {
+		bpr.ItemIndex.Add(itemId)
+	}
+	bpr.UserPredictable = bitset.New(1).Set(0)
+	bpr.ItemPredictable = bitset.New(4).Set(0).Set(1).Set(2).Set(3)
+	bpr.UserFactor = [][]float32{{1}}
+	bpr.ItemFactor = [][]float32{{1}, {2}, {3}, {4}}
+	suite.rankingIndex, err = suite.buildRankingIndex(ctx, bpr)
+	assert.NoError(t, err)
+	defer func() {
+		suite.rankingIndex = nil
+	}()
+	// compute recommendations if the cache is missing
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"3", "2", "1"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0/a").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"3", "1"})).
+		End()
+	// compute recommendations if the cache is stale
+	err = suite.CacheClient.AddDocuments(ctx, cache.CollaborativeRecommend, "0", []cache.Document{
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: time.Now().Add(-suite.Config.Recommend.CacheExpire - time.Hour)},
+	})
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"3", "2", "1"})).
+		End()
+	// use cached recommendations if the cache is fresh
+	err = suite.CacheClient.AddDocuments(ctx, cache.CollaborativeRecommend, "0", []cache.Document{
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: time.Now()},
+	})
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1"})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestGetRecommendsWithRules() {
+	ctx := context.Background()
+	t := suite.T()
+	// insert recommendation
+	documents := []cache.Document{
+		{Id: "1", Score: 100, Categories: []string{""}},
+		{Id: "2", Score: 99, Categories: []string{""}},
+		{Id: "3", Score: 98, Categories: []string{""}},
+		{Id: "4", Score: 97, Categories: []string{""}},
+	}
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", documents)
+	assert.NoError(t, err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "1", documents)
+	assert.NoError(t, err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", documents)
+	assert.NoError(t, err)
+	// insert users and items
+	err = suite.DataClient.BatchInsertUsers(ctx, []data.User{{UserId: "0", Labels: []any{"vip"}}, {UserId: "1"}})
+	assert.NoError(t, err)
+	err = suite.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "1"}, {ItemId: "2", Labels: []any{"promo"}}, {ItemId: "3"}, {ItemId: "4"}, {ItemId: "9"},
+	})
+	assert.NoError(t, err)
+	// insert rules
+	err = SaveRules(ctx, suite.CacheClient, []Rule{
+		{Name: "sponsored", Action: RuleActionPin, ItemIds: []string{"9"}, Position: 2},
+		{Name: "promo", Action: RuleActionBoost, Labels: []string{"promo"}},
+		{Name: "vip", Action: RuleActionBlock, ItemIds: []string{"1"}, UserLabels: []string{"vip"}},
+	})
+	assert.NoError(t, err)
+	suite.rules.invalidate()
+	defer suite.rules.invalidate()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"2", "9", "3"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/1").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n":      "3",
+			"offset": "1",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"9", "1", "3"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular/").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{documents[1], {Id: "9"}, documents[0]})).
+		End()
+	// items read by the user are not pinned
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "read", UserId: "2", ItemId: "9"}},
+	}, true, false, true)
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular/").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n":       "3",
+			"user-id": "2",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{documents[1], documents[0], documents[2]})).
+		End()
+	// items out of the category are not pinned
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/popular/a").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]cache.Document{})).
+		End()
+}
+
+func (suite *ServerTestSuite) TestBatchRecommend() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"latest"}
+	// insert offline recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{
+		{Id: "1", Score: 99, Categories: []string{""}},
+		{Id: "2", Score: 98, Categories: []string{""}},
+		{Id: "3", Score: 97, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert latest
+	err = suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{
+		{Id: "4", Score: 96, Categories: []string{""}},
+		{Id: "5", Score: 95, Categories: []string{""}},
+		{Id: "6", Score: 94, Categories: []string{""}},
+	})
+	assert.NoError(t, err)
+	// insert feedback
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "a", UserId: "1", ItemId: "4"}},
+	}, true, true, true)
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		JSON(BatchRecommendRequest{UserIds: []string{"0", "1"}, N: 2, Offset: 1}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(map[string]BatchRecommendResult{
+			"0": {Items: []string{"2", "3"}},
+			"1": {Items: []string{"6"}},
+		})).
+		End()
+	// write back recommendation
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"write-back-type":  "read",
+			"write-back-delay": "10m",
+			"recommenders":     "latest",
+		}).
+		JSON(BatchRecommendRequest{UserIds: []string{"2"}, N: 1}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal(map[string]BatchRecommendResult{
+			"2": {Items: []string{"4"}},
+		})).
+		End()
+	feedback, err := suite.DataClient.GetUserFeedback(ctx, "2", lo.ToPtr(time.Now().Add(time.Hour)), "read")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4"}, lo.Map(feedback, func(feedback data.Feedback, _ int) string {
+		return feedback.ItemId
+	}))
+	// invalid arguments
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		JSON(BatchRecommendRequest{UserIds: []string{"0"}, N: -1}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		Query("recommenders", "unknown").
+		JSON(BatchRecommendRequest{UserIds: []string{"0"}}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/recommend").
+		Header("X-API-Key", apiKey).
+		JSON(BatchRecommendRequest{UserIds: make([]string, maxBatchRecommendUsers+1)}).
+		Expect(t).
+		Status(http.StatusBadRequest).
+		End()
+}
+
+func (suite *ServerTestSuite) TestExplainRecommend() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"a"}
+	suite.Config.Recommend.Offline.EnableClickThroughPrediction = true
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"item_based", "latest"}
+	// insert offline recommendation
+	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{{Id: "2", Score: 0.9, Categories: []string{""}, Source: "item_based", Reason: "1"}})
+	assert.NoError(t, err)
+	// insert similar items
+	err = suite.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, "1", []cache.Document{{Id: "3", Score: 5, Categories: []string{""}}})
+	assert.NoError(t, err)
+	// insert latest
+	err = suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{
+		{Id: "4", Score: 1, Categories: []string{""}},
+		{Id: "5", Score: 2, Categories: []string{"c"}},
+	})
+	assert.NoError(t, err)
+	// insert feedback
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "a", UserId: "0", ItemId: "1"}},
+	}, true, true, true)
+	assert.NoError(t, err)
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/explain/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "3",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]Explanation{
+			{ItemId: "2", Recommender: "offline", Score: 0.9, ClickThroughRate: proto.Float64(0.9), Source: "item_based", Reason: "1"},
+			{ItemId: "3", Recommender: "item_based", Score: 5, Items: []cache.Document{{Id: "1", Score: 5}}},
+			{ItemId: "4", Recommender: "latest", Score: 1},
+		})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/explain/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n":      "1",
+			"offset": "2",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]Explanation{{ItemId: "4", Recommender: "latest", Score: 1}})).
+		End()
+	// explain recommendation in a category
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/explain/recommend/0/c").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "1",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]Explanation{{ItemId: "5", Recommender: "latest", Score: 2}})).
+		End()
+}
+
 func (suite *ServerTestSuite) TestServerGetRecommendsFallbackItemBasedSimilar() {
 	ctx := context.Background()
 	t := suite.T()
@@ -1405,6 +2083,19 @@ func (suite *ServerTestSuite) TestGetRecommendsFallbackPreCached() {
 		{Id: "111", Score: 89, Categories: []string{"*"}},
 		{Id: "112", Score: 88, Categories: []string{"*"}}})
 	assert.NoError(t, err)
+	// insert trending
+	err = suite.CacheClient.AddDocuments(ctx, cache.TrendingItems, "", []cache.Document{
+		{Id: "17", Score: 87, Categories: []string{""}},
+		{Id: "18", Score: 86, Categories: []string{""}},
+		{Id: "19", Score: 85, Categories: []string{""}},
+		{Id: "20", Score: 84, Categories: []string{""}}})
+	assert.NoError(t, err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.TrendingItems, "", []cache.Document{
+		{Id: "117", Score: 87, Categories: []string{"*"}},
+		{Id: "118", Score: 86, Categories: []string{"*"}},
+		{Id: "119", Score: 85, Categories: []string{"*"}},
+		{Id: "120", Score: 84, Categories: []string{"*"}}})
+	assert.NoError(t, err)
 	// insert collaborative filtering
 	err = suite.CacheClient.AddDocuments(ctx, cache.CollaborativeRecommend, "0", []cache.Document{
 		{Id: "13", Score: 79, Categories: []string{""}},
@@ -1466,6 +2157,30 @@ func (suite *ServerTestSuite) TestGetRecommendsFallbackPreCached() {
 		Status(http.StatusOK).
 		Body(suite.marshal([]string{"101", "102", "103", "104", "105", "106", "107", "108"})).
 		End()
+	// test trending fallback
+	suite.Config.Recommend.Online.FallbackRecommend = []string{"trending"}
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "8",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"1", "2", "3", "4", "17", "18", "19", "20"})).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Get("/api/recommend/0/*").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n": "8",
+		}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body(suite.marshal([]string{"101", "102", "103", "104", "117", "118", "119", "120"})).
+		End()
 	// test collaborative filtering
 	suite.Config.Recommend.Online.FallbackRecommend = []string{"collaborative"}
 	apitest.New().
@@ -1605,6 +2320,84 @@ func (suite *ServerTestSuite) TestSessionRecommend() {
 		End()
 }
 
+func (suite *ServerTestSuite) TestSessionRecommendWithFoldIn() {
+	ctx := context.Background()
+	t := suite.T()
+	suite.Config.Recommend.Online.SessionRecommend = "fold_in"
+	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"a"}
+	// insert items
+	err := suite.DataClient.BatchInsertItems(ctx, []data.Item{
+		{ItemId: "1"},
+		{ItemId: "2"},
+		{ItemId: "3", Categories: []string{"*"}},
+		{ItemId: "4"},
+		{ItemId: "5", IsHidden: true},
+	})
+	assert.NoError(t, err)
+	// create ranking model
+	ccd := ranking.NewCCD(model.Params{model.NFactors: 2, model.NEpochs: 5})
+	ccd.UserIndex = base.NewMapIndex()
+	ccd.UserPredictable = bitset.New(0)
+	ccd.UserFactor = [][]float32{}
+	ccd.ItemIndex = base.NewMapIndex()
+	for _, itemId := range []string{"1", "2", "3", "4", "5"} {
+		ccd.ItemIndex.Add(itemId)
+	}
+	ccd.ItemPredictable = bitset.New(5).Set(0).Set(1).Set(2).Set(3).Set(4)
+	ccd.ItemFactor = [][]float32{{1, 0}, {0, 1}, {0.9, 0}, {0.5, 0}, {1, 0}}
+	suite.rankingIndex, err = suite.buildRankingIndex(ctx, ccd)
+	assert.NoError(t, err)
+	defer func() {
+		suite.rankingIndex = nil
+	}()
+	assertItems := func(expected ...string) func(*http.Response, *http.Request) error {
+		return func(response *http.Response, _ *http.Request) error {
+			var documents []cache.Document
+			if err := json.NewDecoder(response.Body).Decode(&documents); err != nil {
+				return err
+			}
+			assert.Equal(t, expected, lo.Map(documents, func(document cache.Document, _ int) string {
+				return document.Id
+			}))
+			return nil
+		}
+	}
+	feedback := []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "a", UserId: "0", ItemId: "1"}, Timestamp: time.Date(2010, 1, 1, 1, 1, 1, 1, time.UTC)},
+	}
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/session/recommend").
+		Header("X-API-Key", apiKey).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(assertItems("3", "4", "2")).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/session/recommend").
+		Header("X-API-Key", apiKey).
+		QueryParams(map[string]string{
+			"n":      "1",
+			"offset": "1",
+		}).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(assertItems("4")).
+		End()
+	apitest.New().
+		Handler(suite.handler).
+		Post("/api/session/recommend/*").
+		Header("X-API-Key", apiKey).
+		JSON(feedback).
+		Expect(t).
+		Status(http.StatusOK).
+		Assert(assertItems("3")).
+		End()
+}
+
 func (suite *ServerTestSuite) TestVisibility() {
 	ctx := context.Background()
 	t := suite.T()
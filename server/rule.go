@@ -0,0 +1,272 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/storage/cache"
+	"github.com/zhenghaoz/gorse/storage/data"
+)
+
+const (
+	RuleActionPin   = "pin"   // pin items to a slot
+	RuleActionBoost = "boost" // move items ahead of other items
+	RuleActionBury  = "bury"  // move items behind other items
+	RuleActionBlock = "block" // remove items
+)
+
+// ruleRefreshInterval is the maximum age of business rules cached by a node.
+const ruleRefreshInterval = 10 * time.Second
+
+// Rule is a business rule applied to recommended items.
+type Rule struct {
+	Name   string
+	Action string
+	// ItemIds are items matched by the rule.
+	ItemIds []string
+	// Labels match items with any of these labels. Labels are flattened, e.g. "brand.nike".
+	Labels []string
+	// UserLabels restrict the rule to users with any of these labels. The rule applies to all users if empty.
+	UserLabels []string
+	// Position is the slot (starting from 1) of the first pinned item.
+	Position int
+}
+
+// Validate checks if the rule is valid.
+func (rule *Rule) Validate() error {
+	if rule.Name == "" {
+		return errors.NotValidf("empty rule name")
+	}
+	switch rule.Action {
+	case RuleActionPin:
+		if len(rule.ItemIds) == 0 {
+			return errors.NotValidf("pin rule %s without items", rule.Name)
+		}
+		if rule.Position <= 0 {
+			return errors.NotValidf("pin rule %s with position %d", rule.Name, rule.Position)
+		}
+	case RuleActionBoost, RuleActionBury, RuleActionBlock:
+		if len(rule.ItemIds) == 0 && len(rule.Labels) == 0 {
+			return errors.NotValidf("%s rule %s without items or labels", rule.Action, rule.Name)
+		}
+	default:
+		return errors.NotValidf("rule action %s", rule.Action)
+	}
+	return nil
+}
+
+func (rule *Rule) matchUser(userLabels mapset.Set[string]) bool {
+	return len(rule.UserLabels) == 0 ||
+		lo.ContainsBy(rule.UserLabels, func(label string) bool {
+			return userLabels.Contains(label)
+		})
+}
+
+func (rule *Rule) matchItem(itemId string, itemLabels mapset.Set[string]) bool {
+	return lo.Contains(rule.ItemIds, itemId) ||
+		lo.ContainsBy(rule.Labels, func(label string) bool {
+			return itemLabels.Contains(label)
+		})
+}
+
+// LoadRules loads business rules from the cache store.
+func LoadRules(ctx context.Context, cacheClient cache.Database) ([]Rule, error) {
+	value, err := cacheClient.Get(ctx, cache.BusinessRules).String()
+	if errors.Is(err, errors.NotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var rules []Rule
+	if err = json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rules, nil
+}
+
+// SaveRules saves business rules to the cache store.
+func SaveRules(ctx context.Context, cacheClient cache.Database, rules []Rule) error {
+	bytes, err := json.Marshal(rules)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return cacheClient.Set(ctx, cache.String(cache.BusinessRules, string(bytes)))
+}
+
+// ruleStore caches business rules loaded from the cache store.
+type ruleStore struct {
+	mutex    sync.Mutex
+	rules    []Rule
+	loadTime time.Time
+}
+
+// invalidate forces business rules to be reloaded from the cache store.
+func (store *ruleStore) invalidate() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.loadTime = time.Time{}
+}
+
+// load reloads business rules from the cache store.
+func (store *ruleStore) load(ctx context.Context, cacheClient cache.Database) ([]Rule, error) {
+	rules, err := LoadRules(ctx, cacheClient)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.rules = rules
+	store.loadTime = time.Now()
+	return rules, nil
+}
+
+// get returns business rules. Business rules are reloaded from the cache store if they are out of date.
+func (store *ruleStore) get(ctx context.Context, cacheClient cache.Database) ([]Rule, error) {
+	store.mutex.Lock()
+	rules, loadTime := store.rules, store.loadTime
+	store.mutex.Unlock()
+	if time.Since(loadTime) <= ruleRefreshInterval {
+		return rules, nil
+	}
+	return store.load(ctx, cacheClient)
+}
+
+// InvalidateRules forces business rules to be reloaded from the cache store by the next request.
+func (s *RestServer) InvalidateRules() {
+	s.rules.invalidate()
+}
+
+// applyRules applies business rules to items recommended to a user. The user is anonymous if the user id is empty.
+// Pinned items not in recommended items should be accepted by the filter, which is the same filter for recommended
+// items, e.g. categories, excluded items and locations.
+func (s *RestServer) applyRules(ctx context.Context, rules []Rule, userId string, itemIds []string, filter func(item *data.Item) bool) ([]string, error) {
+	// filter rules by user labels
+	userLabels := mapset.NewSet[string]()
+	if userId != "" && lo.ContainsBy(rules, func(rule Rule) bool { return len(rule.UserLabels) > 0 }) {
+		user, err := s.DataClient.GetUser(ctx, userId)
+		if err != nil && !errors.Is(err, errors.NotFound) {
+			return nil, errors.Trace(err)
+		} else if err == nil {
+			userLabels.Append(data.FlattenLabels(user.Labels)...)
+		}
+	}
+	rules = lo.Filter(rules, func(rule Rule, _ int) bool {
+		return rule.matchUser(userLabels)
+	})
+	if len(rules) == 0 {
+		return itemIds, nil
+	}
+	// load pinned items, and load items if labels are matched
+	candidates := mapset.NewSet[string]()
+	for _, rule := range rules {
+		if rule.Action == RuleActionPin {
+			candidates.Append(rule.ItemIds...)
+		} else if len(rule.Labels) > 0 {
+			candidates.Append(itemIds...)
+		}
+	}
+	var items []data.Item
+	if candidates.Cardinality() > 0 {
+		var err error
+		if items, err = s.DataClient.BatchGetItems(ctx, candidates.ToSlice()); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return rankByRules(rules, itemIds, items, filter), nil
+}
+
+// rankByRules blocks, boosts, buries and pins items by rules. Items are loaded if they are pinned or labels are
+// matched. Pinned items must exist and not be hidden, and pinned items not in recommended items should be accepted by
+// the filter.
+func rankByRules(rules []Rule, itemIds []string, items []data.Item, filter func(item *data.Item) bool) []string {
+	recommended := mapset.NewSet(itemIds...)
+	itemLabels := make(map[string]mapset.Set[string], len(items))
+	pinnable := mapset.NewSet[string]()
+	for i, item := range items {
+		if !item.IsHidden {
+			itemLabels[item.ItemId] = mapset.NewSet(data.FlattenLabels(item.Labels)...)
+			if recommended.Contains(item.ItemId) || filter == nil || filter(&items[i]) {
+				pinnable.Add(item.ItemId)
+			}
+		}
+	}
+	labelsOf := func(itemId string) mapset.Set[string] {
+		if labels, exist := itemLabels[itemId]; exist {
+			return labels
+		}
+		return mapset.NewSet[string]()
+	}
+	matchAny := func(action, itemId string) bool {
+		return lo.ContainsBy(rules, func(rule Rule) bool {
+			return rule.Action == action && rule.matchItem(itemId, labelsOf(itemId))
+		})
+	}
+	// collect pinned items
+	type pinnedItem struct {
+		itemId   string
+		position int
+	}
+	var pinnedItems []pinnedItem
+	pinned := mapset.NewSet[string]()
+	for _, rule := range rules {
+		if rule.Action == RuleActionPin {
+			position := rule.Position
+			for _, itemId := range rule.ItemIds {
+				if pinnable.Contains(itemId) && !pinned.Contains(itemId) && !matchAny(RuleActionBlock, itemId) {
+					pinned.Add(itemId)
+					pinnedItems = append(pinnedItems, pinnedItem{itemId: itemId, position: position})
+					position++
+				}
+			}
+		}
+	}
+	sort.SliceStable(pinnedItems, func(i, j int) bool {
+		return pinnedItems[i].position < pinnedItems[j].position
+	})
+	// block, boost and bury items
+	var boosted, normal, buried []string
+	for _, itemId := range itemIds {
+		if pinned.Contains(itemId) || matchAny(RuleActionBlock, itemId) {
+			continue
+		} else if matchAny(RuleActionBoost, itemId) {
+			boosted = append(boosted, itemId)
+		} else if matchAny(RuleActionBury, itemId) {
+			buried = append(buried, itemId)
+		} else {
+			normal = append(normal, itemId)
+		}
+	}
+	result := make([]string, 0, len(itemIds)+len(pinnedItems))
+	result = append(result, boosted...)
+	result = append(result, normal...)
+	result = append(result, buried...)
+	// pin items
+	for _, item := range pinnedItems {
+		position := item.position - 1
+		if position > len(result) {
+			position = len(result)
+		}
+		result = append(result[:position], append([]string{item.itemId}, result[position:]...)...)
+	}
+	return result
+}
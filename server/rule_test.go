@@ -0,0 +1,53 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zhenghaoz/gorse/storage/data"
+)
+
+func TestRule_Validate(t *testing.T) {
+	assert.NoError(t, (&Rule{Name: "a", Action: RuleActionPin, ItemIds: []string{"1"}, Position: 1}).Validate())
+	assert.NoError(t, (&Rule{Name: "a", Action: RuleActionBoost, Labels: []string{"promo"}}).Validate())
+	assert.Error(t, (&Rule{Action: RuleActionBlock, ItemIds: []string{"1"}}).Validate())
+	assert.Error(t, (&Rule{Name: "a", Action: "unknown", ItemIds: []string{"1"}}).Validate())
+	assert.Error(t, (&Rule{Name: "a", Action: RuleActionPin, ItemIds: []string{"1"}}).Validate())
+	assert.Error(t, (&Rule{Name: "a", Action: RuleActionPin, Position: 1}).Validate())
+	assert.Error(t, (&Rule{Name: "a", Action: RuleActionBury}).Validate())
+}
+
+func TestRankByRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "block", Action: RuleActionBlock, ItemIds: []string{"3", "8"}},
+		{Name: "boost", Action: RuleActionBoost, Labels: []string{"promo"}},
+		{Name: "bury", Action: RuleActionBury, ItemIds: []string{"2"}},
+		{Name: "pin", Action: RuleActionPin, ItemIds: []string{"8", "9", "10", "11"}, Position: 2},
+	}
+	items := []data.Item{
+		{ItemId: "1"}, {ItemId: "2"}, {ItemId: "3"}, {ItemId: "4", Labels: []any{"promo"}}, {ItemId: "5"},
+		{ItemId: "8"}, {ItemId: "9"}, {ItemId: "11", IsHidden: true},
+	}
+	// blocked, hidden and nonexistent items are not pinned
+	assert.Equal(t, []string{"4", "9", "1", "5", "2"}, rankByRules(rules, []string{"1", "2", "3", "4", "5"}, items, nil))
+	// items are pinned to the end if the slot is out of range
+	assert.Equal(t, []string{"4", "9"}, rankByRules(rules, []string{"4"}, items, nil))
+	// items rejected by the filter are not pinned unless they are recommended
+	reject := func(item *data.Item) bool { return false }
+	assert.Equal(t, []string{"4", "1", "5", "2"}, rankByRules(rules, []string{"1", "2", "3", "4", "5"}, items, reject))
+	assert.Equal(t, []string{"4", "9", "1", "5", "2"}, rankByRules(rules, []string{"1", "2", "3", "4", "5", "9"}, items, reject))
+}
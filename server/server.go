@@ -18,12 +18,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
 	"time"
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/juju/errors"
 	"github.com/zhenghaoz/gorse/base"
+	"github.com/zhenghaoz/gorse/base/encoding"
 	"github.com/zhenghaoz/gorse/base/log"
 	"github.com/zhenghaoz/gorse/cmd/version"
 	"github.com/zhenghaoz/gorse/config"
@@ -54,7 +56,7 @@ type Server struct {
 }
 
 // NewServer creates a server node.
-func NewServer(masterHost string, masterPort int, serverHost string, serverPort int, cacheFile string) *Server {
+func NewServer(masterHost string, masterPort int, serverHost string, serverPort, grpcPort int, cacheFile string) *Server {
 	s := &Server{
 		masterHost: masterHost,
 		masterPort: masterPort,
@@ -63,6 +65,7 @@ func NewServer(masterHost string, masterPort int, serverHost string, serverPort
 			Settings:   config.NewSettings(),
 			HttpHost:   serverHost,
 			HttpPort:   serverPort,
+			GrpcPort:   grpcPort,
 			WebService: new(restful.WebService),
 		},
 	}
@@ -105,6 +108,9 @@ func (s *Server) Serve() {
 	s.masterClient = protocol.NewMasterClient(conn)
 
 	go s.Sync()
+	if s.GrpcPort > 0 {
+		go s.StartGrpcServer()
+	}
 	container := restful.NewContainer()
 	s.StartHttpServer(container)
 }
@@ -114,6 +120,9 @@ func (s *Server) Shutdown() {
 	if err != nil {
 		log.Logger().Fatal("failed to shutdown http server", zap.Error(err))
 	}
+	if s.GrpcServer != nil {
+		s.GrpcServer.GracefulStop()
+	}
 }
 
 // Sync this server to the master.
@@ -165,6 +174,70 @@ func (s *Server) Sync() {
 			s.cachePrefix = s.Config.Database.CacheTablePrefix
 		}
 
+		// load business rules
+		if _, err = s.rules.load(context.Background(), s.CacheClient); err != nil {
+			log.Logger().Error("failed to load business rules", zap.Error(err))
+		}
+
+		// pull click model
+		if meta.ClickModelVersion != s.ClickModelVersion {
+			log.Logger().Info("start pull click model",
+				zap.String("old_version", encoding.Hex(s.ClickModelVersion)),
+				zap.String("new_version", encoding.Hex(meta.ClickModelVersion)))
+			if clickModelReceiver, err := s.masterClient.GetClickModel(context.Background(),
+				&protocol.VersionInfo{Version: meta.ClickModelVersion},
+				grpc.MaxCallRecvMsgSize(math.MaxInt)); err != nil {
+				log.Logger().Error("failed to pull click model", zap.Error(err))
+			} else if clickModel, err := protocol.UnmarshalClickModel(clickModelReceiver); err != nil {
+				log.Logger().Error("failed to unmarshal click model", zap.Error(err))
+			} else {
+				s.SetClickModel(clickModel)
+				s.ClickModelVersion = meta.ClickModelVersion
+				log.Logger().Info("synced click model",
+					zap.String("version", encoding.Hex(s.ClickModelVersion)))
+			}
+		}
+
+		// pull ranking model
+		if s.Config.Server.EnableRankingModel && meta.RankingModelVersion != s.RankingModelVersion {
+			log.Logger().Info("start pull ranking model",
+				zap.String("old_version", encoding.Hex(s.RankingModelVersion)),
+				zap.String("new_version", encoding.Hex(meta.RankingModelVersion)))
+			if rankingModelReceiver, err := s.masterClient.GetRankingModel(context.Background(),
+				&protocol.VersionInfo{Version: meta.RankingModelVersion},
+				grpc.MaxCallRecvMsgSize(math.MaxInt)); err != nil {
+				log.Logger().Error("failed to pull ranking model", zap.Error(err))
+			} else if rankingModel, err := protocol.UnmarshalRankingModel(rankingModelReceiver); err != nil {
+				log.Logger().Error("failed to unmarshal ranking model", zap.Error(err))
+			} else if rankingModel.Invalid() {
+				s.modelMutex.Lock()
+				s.RankingModel = rankingModel
+				s.rankingIndex = nil
+				s.modelMutex.Unlock()
+				s.RankingModelVersion = meta.RankingModelVersion
+			} else {
+				startTime := time.Now()
+				if rankingIndex, err := s.buildRankingIndex(context.Background(), rankingModel); err != nil {
+					log.Logger().Error("failed to build ranking index", zap.Error(err))
+				} else {
+					s.modelMutex.Lock()
+					s.RankingModel = rankingModel
+					s.rankingIndex = rankingIndex
+					s.modelMutex.Unlock()
+					s.RankingModelVersion = meta.RankingModelVersion
+					log.Logger().Info("synced ranking model",
+						zap.String("version", encoding.Hex(s.RankingModelVersion)),
+						zap.Duration("build_index_time", time.Since(startTime)))
+				}
+			}
+		} else if !s.Config.Server.EnableRankingModel && s.syncedRankingIndex() != nil {
+			s.modelMutex.Lock()
+			s.RankingModel = nil
+			s.rankingIndex = nil
+			s.modelMutex.Unlock()
+			s.RankingModelVersion = 0
+		}
+
 		// create trace provider
 		if !s.traceConfig.Equal(s.Config.Tracing) {
 			log.Logger().Info("create trace provider", zap.Any("tracing_config", s.Config.Tracing))
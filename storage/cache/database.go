@@ -16,6 +16,7 @@ package cache
 
 import (
 	"context"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -80,6 +81,11 @@ const (
 	//  Categorized popular items - latest_items/{category}
 	PopularItems = "popular_items"
 
+	// TrendingItems is sorted set of trending items. The format of key:
+	//  Global trending items      - trending_items
+	//  Categorized trending items - trending_items/{category}
+	TrendingItems = "trending_items"
+
 	// LatestItems is sorted set of the latest items. The format of key:
 	//  Global latest items      - latest_items
 	//  Categorized the latest items - latest_items/{category}
@@ -89,6 +95,27 @@ const (
 	//	Global item categories - item_categories
 	ItemCategories = "item_categories"
 
+	// BusinessRules is the list of business rules applied to recommendations. The format of key:
+	//	Global business rules - business_rules
+	BusinessRules = "business_rules"
+
+	// APIKeys is the list of API keys. The format of key:
+	//	Global API keys - api_keys
+	APIKeys = "api_keys"
+
+	// IdempotencyKeys is the results of requests with idempotency keys. The format of key:
+	//	Result of a request - idempotency_keys/{key}
+	IdempotencyKeys = "idempotency_keys"
+
+	// BanditState is the state of bandits choosing exploration sources and new items. The format of key:
+	//	Global bandit state - bandit_state
+	BanditState = "bandit_state"
+
+	// ExploredItems is sorted set of items explored in offline recommendation for each user. The source of
+	// exploration is the source of a document. The format of key:
+	//	Explored items - explored_items/{user_id}
+	ExploredItems = "explored_items"
+
 	LastModifyItemTime          = "last_modify_item_time"           // the latest timestamp that a user related data was modified
 	LastModifyUserTime          = "last_modify_user_time"           // the latest timestamp that an item related data was modified
 	LastUpdateUserRecommendTime = "last_update_user_recommend_time" // the latest timestamp that a user's recommendation was updated
@@ -112,9 +139,11 @@ const (
 	UserNeighborIndexRecall    = "user_neighbor_index_recall"
 	ItemNeighborIndexRecall    = "item_neighbor_index_recall"
 	MatchingIndexRecall        = "matching_index_recall"
+
+	LastUpdateTrendingItemsTime = "last_update_trending_items_time" // the latest timestamp that trending items were updated
 )
 
-var ItemCache = []string{PopularItems, LatestItems, ItemNeighbors, OfflineRecommend}
+var ItemCache = []string{PopularItems, TrendingItems, LatestItems, ItemNeighbors, OfflineRecommend}
 
 var (
 	ErrObjectNotExist = errors.NotFoundf("object")
@@ -187,6 +216,33 @@ type Document struct {
 	IsHidden   bool      `json:"-"`
 	Categories []string  `json:"-" gorm:"type:text;serializer:json"`
 	Timestamp  time.Time `json:"-"`
+	Source     string    `json:",omitempty"` // the recommender produced this document
+	Reason     string    `json:",omitempty"` // the optional reason payload, such as the item or user leading to this document
+	// Location is the optional coordinates of the item.
+	Location *Location `json:"-" gorm:"-" bson:"-"`
+}
+
+// earthRadius is the mean radius of the earth in kilometers.
+const earthRadius = 6371.0
+
+// Location is the coordinates of an item in degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Distance returns the great-circle distance in kilometers between two locations by the haversine formula.
+func (l Location) Distance(other Location) float64 {
+	lat1, lat2 := l.Latitude*math.Pi/180, other.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - l.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Within checks if a document is located within the radius (in kilometers) of a location.
+func (document *Document) Within(location Location, radius float64) bool {
+	return document.Location != nil && document.Location.Distance(location) <= radius
 }
 
 func SortDocuments(documents []Document) {
@@ -219,19 +275,38 @@ func NewDocumentAggregator(timestamp time.Time) *DocumentAggregator {
 }
 
 func (aggregator *DocumentAggregator) Add(category string, values []string, scores []float64) {
+	documents := make([]Document, len(values))
 	for i, value := range values {
-		if _, ok := aggregator.Documents[value]; !ok {
-			aggregator.Documents[value] = &Document{
-				Id:         value,
-				Score:      scores[i],
+		documents[i] = Document{Id: value, Score: scores[i]}
+	}
+	aggregator.AddDocuments(category, documents)
+}
+
+// Locate sets locations of documents. The function returns nil if a document is not located.
+func (aggregator *DocumentAggregator) Locate(location func(id string) *Location) {
+	for id, document := range aggregator.Documents {
+		document.Location = location(id)
+	}
+}
+
+// AddDocuments adds documents to a category. The source and reason of documents are kept.
+func (aggregator *DocumentAggregator) AddDocuments(category string, documents []Document) {
+	for _, document := range documents {
+		if _, ok := aggregator.Documents[document.Id]; !ok {
+			aggregator.Documents[document.Id] = &Document{
+				Id:         document.Id,
+				Score:      document.Score,
 				Categories: []string{category},
 				Timestamp:  aggregator.Timestamp,
+				Source:     document.Source,
+				Reason:     document.Reason,
+				Location:   document.Location,
 			}
 		} else {
-			if aggregator.Documents[value].Score != scores[i] {
+			if aggregator.Documents[document.Id].Score != document.Score {
 				panic("score should be the same")
 			}
-			aggregator.Documents[value].Categories = append(aggregator.Documents[value].Categories, category)
+			aggregator.Documents[document.Id].Categories = append(aggregator.Documents[document.Id].Categories, category)
 		}
 	}
 }
@@ -245,6 +320,51 @@ func (aggregator *DocumentAggregator) ToSlice() []Document {
 	return documents
 }
 
+// NeighborSimilarity measures similarities between items by item neighbors in the cache store. Neighbors of an item
+// are loaded once, and similarities are divided by the maximal similarity among them.
+type NeighborSimilarity struct {
+	ctx       context.Context
+	database  Database
+	n         int
+	neighbors map[string]map[string]float64
+	err       error
+}
+
+// NewNeighborSimilarity creates a NeighborSimilarity loading at most n neighbors of each item.
+func NewNeighborSimilarity(ctx context.Context, database Database, n int) *NeighborSimilarity {
+	return &NeighborSimilarity{
+		ctx:       ctx,
+		database:  database,
+		n:         n,
+		neighbors: make(map[string]map[string]float64),
+	}
+}
+
+// Similarity returns the similarity between a candidate and a selected item in [0, 1]. It is zero if the candidate is
+// not a neighbor of the selected item.
+func (similarity *NeighborSimilarity) Similarity(candidate, selected string) float64 {
+	neighbors, exist := similarity.neighbors[selected]
+	if !exist {
+		documents, err := similarity.database.SearchDocuments(similarity.ctx, ItemNeighbors, selected, []string{""}, 0, similarity.n)
+		if err != nil && similarity.err == nil {
+			similarity.err = errors.Trace(err)
+		}
+		neighbors = make(map[string]float64, len(documents))
+		if len(documents) > 0 && documents[0].Score > 0 {
+			for _, document := range documents {
+				neighbors[document.Id] = document.Score / documents[0].Score
+			}
+		}
+		similarity.neighbors[selected] = neighbors
+	}
+	return neighbors[candidate]
+}
+
+// Err returns the first error while loading neighbors.
+func (similarity *NeighborSimilarity) Err() error {
+	return similarity.err
+}
+
 type DocumentCondition struct {
 	Subset *string
 	Id     *string
@@ -279,8 +399,12 @@ type Database interface {
 	Purge() error
 
 	Set(ctx context.Context, values ...Value) error
+	// SetNX sets a value if the name does not exist. It returns true if the value is set.
+	SetNX(ctx context.Context, value Value) (bool, error)
 	Get(ctx context.Context, name string) *ReturnValue
 	Delete(ctx context.Context, name string) error
+	// CompareAndDelete deletes a value if it equals the given value. It returns true if the value is deleted.
+	CompareAndDelete(ctx context.Context, name, value string) (bool, error)
 
 	GetSet(ctx context.Context, key string) ([]string, error)
 	SetSet(ctx context.Context, key string, members ...string) error
@@ -293,6 +417,9 @@ type Database interface {
 
 	AddDocuments(ctx context.Context, collection, subset string, documents []Document) error
 	SearchDocuments(ctx context.Context, collection, subset string, query []string, begin, end int) ([]Document, error)
+	// SearchNearbyDocuments searches documents like SearchDocuments, but only documents located within the radius (in
+	// kilometers) of the location are returned.
+	SearchNearbyDocuments(ctx context.Context, collection, subset string, query []string, location Location, radius float64, begin, end int) ([]Document, error)
 	DeleteDocuments(ctx context.Context, collection []string, condition DocumentCondition) error
 	UpdateDocuments(ctx context.Context, collection []string, id string, patch DocumentPatch) error
 
@@ -409,6 +536,12 @@ func Open(path, tablePrefix string) (Database, error) {
 			return nil, errors.Trace(err)
 		}
 		return database, nil
+	} else if strings.HasPrefix(path, storage.MemoryPrefix) {
+		database, err := newMemoryDatabase(path[len(storage.MemoryPrefix):])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return database, nil
 	}
 	return nil, errors.Errorf("Unknown database: %s", path)
 }
@@ -96,6 +96,28 @@ func (suite *baseTestSuite) TestMeta() {
 	// test set duplicate
 	err = suite.Database.Set(ctx, String("100", "1"), String("100", "2"))
 	suite.NoError(err)
+	// test set if not exists
+	ok, err := suite.Database.SetNX(ctx, String(Key("meta", "nx"), "1"))
+	suite.NoError(err)
+	suite.True(ok)
+	ok, err = suite.Database.SetNX(ctx, String(Key("meta", "nx"), "2"))
+	suite.NoError(err)
+	suite.False(ok)
+	value, err = suite.Database.Get(ctx, Key("meta", "nx")).String()
+	suite.NoError(err)
+	suite.Equal("1", value)
+	// test compare and delete
+	ok, err = suite.Database.CompareAndDelete(ctx, Key("meta", "nx"), "2")
+	suite.NoError(err)
+	suite.False(ok)
+	ok, err = suite.Database.CompareAndDelete(ctx, Key("meta", "nx"), "1")
+	suite.NoError(err)
+	suite.True(ok)
+	_, err = suite.Database.Get(ctx, Key("meta", "nx")).String()
+	suite.ErrorIs(err, errors.NotFound)
+	ok, err = suite.Database.CompareAndDelete(ctx, Key("meta", "nx"), "1")
+	suite.NoError(err)
+	suite.False(ok)
 }
 
 func (suite *baseTestSuite) TestSet() {
@@ -436,6 +458,88 @@ func (suite *baseTestSuite) TestSubsetDocument() {
 	suite.Equal("2", documents[0].Id)
 }
 
+func (suite *baseTestSuite) TestDocumentSource() {
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	err := suite.AddDocuments(ctx, "a", "", []Document{
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Source: "item_based", Reason: "10"},
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts},
+	})
+	suite.NoError(err)
+	documents, err := suite.SearchDocuments(ctx, "a", "", []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]Document{
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts},
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Source: "item_based", Reason: "10"},
+	}, documents)
+
+	// overwrite source
+	err = suite.AddDocuments(ctx, "a", "", []Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Source: "latest"}})
+	suite.NoError(err)
+	documents, err = suite.SearchDocuments(ctx, "a", "", []string{""}, 1, 2)
+	suite.NoError(err)
+	suite.Equal([]Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Source: "latest"}}, documents)
+}
+
+func (suite *baseTestSuite) TestNearbyDocument() {
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	hangzhou := &Location{Latitude: 30.2741, Longitude: 120.1551}
+	shanghai := &Location{Latitude: 31.2304, Longitude: 121.4737}
+	beijing := &Location{Latitude: 39.9042, Longitude: 116.4074}
+	err := suite.AddDocuments(ctx, "a", "", []Document{
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: hangzhou},
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts, Location: shanghai},
+		{Id: "3", Score: 3, Categories: []string{""}, Timestamp: ts, Location: beijing},
+		{Id: "4", Score: 4, Categories: []string{""}, Timestamp: ts},
+	})
+	suite.NoError(err)
+	documents, err := suite.SearchDocuments(ctx, "a", "", []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]Document{
+		{Id: "4", Score: 4, Categories: []string{""}, Timestamp: ts},
+		{Id: "3", Score: 3, Categories: []string{""}, Timestamp: ts, Location: beijing},
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts, Location: shanghai},
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: hangzhou},
+	}, documents)
+
+	// search nearby documents
+	documents, err = suite.SearchNearbyDocuments(ctx, "a", "", []string{""}, *hangzhou, 200, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]Document{
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts, Location: shanghai},
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: hangzhou},
+	}, documents)
+	documents, err = suite.SearchNearbyDocuments(ctx, "a", "", []string{""}, *hangzhou, 200, 1, 2)
+	suite.NoError(err)
+	suite.Equal([]Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: hangzhou}}, documents)
+	documents, err = suite.SearchNearbyDocuments(ctx, "a", "", []string{""}, *hangzhou, 100, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: hangzhou}}, documents)
+
+	// remove location
+	err = suite.AddDocuments(ctx, "a", "", []Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts}})
+	suite.NoError(err)
+	documents, err = suite.SearchNearbyDocuments(ctx, "a", "", []string{""}, *hangzhou, 100, 0, -1)
+	suite.NoError(err)
+	suite.Empty(documents)
+}
+
+func (suite *baseTestSuite) TestNeighborSimilarity() {
+	ctx := context.Background()
+	err := suite.AddDocuments(ctx, ItemNeighbors, "1", []Document{
+		{Id: "2", Score: 4, Categories: []string{""}},
+		{Id: "3", Score: 2, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	similarity := NewNeighborSimilarity(ctx, suite.Database, 10)
+	suite.Equal(1.0, similarity.Similarity("2", "1"))
+	suite.Equal(0.5, similarity.Similarity("3", "1"))
+	suite.Zero(similarity.Similarity("4", "1"))
+	suite.Zero(similarity.Similarity("1", "2"))
+	suite.NoError(similarity.Err())
+}
+
 func (suite *baseTestSuite) TestTimeSeries() {
 	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 	ctx := context.Background()
@@ -462,3 +566,44 @@ func TestKey(t *testing.T) {
 	assert.Equal(t, "a", Key("a", ""))
 	assert.Equal(t, "a/b", Key("a", "b"))
 }
+
+func TestDocumentAggregator(t *testing.T) {
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator := NewDocumentAggregator(ts)
+	aggregator.AddDocuments("", []Document{{Id: "1", Score: 1, Source: "popular"}, {Id: "2", Score: 2}})
+	aggregator.Add("a", []string{"1"}, []float64{1})
+	documents := aggregator.ToSlice()
+	SortDocuments(documents)
+	assert.Equal(t, []Document{
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts},
+		{Id: "1", Score: 1, Categories: []string{"", "a"}, Timestamp: ts, Source: "popular"},
+	}, documents)
+}
+
+func TestDocumentAggregator_Locate(t *testing.T) {
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator := NewDocumentAggregator(ts)
+	aggregator.Add("", []string{"1", "2"}, []float64{1, 2})
+	aggregator.Locate(func(id string) *Location {
+		if id == "1" {
+			return &Location{Latitude: 30, Longitude: 120}
+		}
+		return nil
+	})
+	documents := aggregator.ToSlice()
+	SortDocuments(documents)
+	assert.Equal(t, []Document{
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: ts},
+		{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts, Location: &Location{Latitude: 30, Longitude: 120}},
+	}, documents)
+}
+
+func TestLocation_Distance(t *testing.T) {
+	hangzhou := Location{Latitude: 30.2741, Longitude: 120.1551}
+	shanghai := Location{Latitude: 31.2304, Longitude: 121.4737}
+	assert.Zero(t, hangzhou.Distance(hangzhou))
+	assert.InDelta(t, 165, hangzhou.Distance(shanghai), 1)
+	assert.InDelta(t, hangzhou.Distance(shanghai), shanghai.Distance(hangzhou), 1e-9)
+	// the distance across the antimeridian
+	assert.InDelta(t, 111, Location{Longitude: 179.5}.Distance(Location{Longitude: -179.5}), 1)
+}
@@ -0,0 +1,595 @@
+// Copyright 2022 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/base/log"
+	"github.com/zhenghaoz/gorse/storage"
+	"go.uber.org/zap"
+)
+
+// MemoryDatabase is the cache storage in memory. It is designed for demos, tests and small deployments running in a
+// single process. If a path is given, data is written to a snapshot file periodically and reloaded at startup.
+type MemoryDatabase struct {
+	path     string
+	interval time.Duration
+	dirty    bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.RWMutex
+
+	values    map[string]string
+	sets      map[string]map[string]struct{}
+	queues    map[string][]string
+	documents map[string]map[string]map[string]Document // collection -> subset -> id -> document
+	points    map[string]map[int64]float64              // name -> unix nano -> value
+}
+
+type memoryDocument struct {
+	Collection string
+	Subset     string
+	Id         string
+	Score      float64
+	IsHidden   bool
+	Categories []string
+	Timestamp  time.Time
+	Source     string
+	Reason     string
+	Location   *Location
+}
+
+type memorySnapshot struct {
+	Values    map[string]string
+	Sets      map[string][]string
+	Queues    map[string][]string
+	Documents []memoryDocument
+	Points    []TimeSeriesPoint
+}
+
+// newMemoryDatabase creates a memory database from a DSN in the format of "path?snapshot_interval=1m". The snapshot
+// is disabled if the path is empty.
+func newMemoryDatabase(dsn string) (*MemoryDatabase, error) {
+	path, interval, err := storage.ParseMemoryDSN(dsn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	database := &MemoryDatabase{
+		path:     path,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	database.reset()
+	if database.path == "" {
+		return database, nil
+	}
+	// load snapshot
+	if err = database.load(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	database.wg.Add(1)
+	go func() {
+		defer database.wg.Done()
+		ticker := time.NewTicker(database.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-database.done:
+				return
+			case <-ticker.C:
+				if err := database.Snapshot(); err != nil {
+					log.Logger().Error("failed to write snapshot", zap.String("path", database.path), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return database, nil
+}
+
+func (db *MemoryDatabase) reset() {
+	db.values = make(map[string]string)
+	db.sets = make(map[string]map[string]struct{})
+	db.queues = make(map[string][]string)
+	db.documents = make(map[string]map[string]map[string]Document)
+	db.points = make(map[string]map[int64]float64)
+}
+
+// load data from the snapshot file.
+func (db *MemoryDatabase) load() error {
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	var snapshot memorySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return errors.Trace(err)
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for name, value := range snapshot.Values {
+		db.values[name] = value
+	}
+	for name, members := range snapshot.Sets {
+		db.addSet(name, members)
+	}
+	for name, queue := range snapshot.Queues {
+		db.queues[name] = queue
+	}
+	for _, document := range snapshot.Documents {
+		db.putDocument(document.Collection, document.Subset, Document{
+			Id:         document.Id,
+			Score:      document.Score,
+			IsHidden:   document.IsHidden,
+			Categories: document.Categories,
+			Timestamp:  document.Timestamp,
+			Source:     document.Source,
+			Reason:     document.Reason,
+			Location:   document.Location,
+		})
+	}
+	db.addTimeSeriesPoints(snapshot.Points)
+	return nil
+}
+
+// Snapshot writes data to the snapshot file if there are modifications since the last snapshot.
+func (db *MemoryDatabase) Snapshot() error {
+	if db.path == "" {
+		return nil
+	}
+	db.mutex.Lock()
+	if !db.dirty {
+		db.mutex.Unlock()
+		return nil
+	}
+	snapshot := memorySnapshot{
+		Values: db.values,
+		Sets:   make(map[string][]string, len(db.sets)),
+		Queues: db.queues,
+	}
+	for name := range db.sets {
+		snapshot.Sets[name] = db.getSet(name)
+	}
+	for collection, subsets := range db.documents {
+		for subset, documents := range subsets {
+			for _, document := range documents {
+				snapshot.Documents = append(snapshot.Documents, memoryDocument{
+					Collection: collection,
+					Subset:     subset,
+					Id:         document.Id,
+					Score:      document.Score,
+					IsHidden:   document.IsHidden,
+					Categories: document.Categories,
+					Timestamp:  document.Timestamp,
+					Source:     document.Source,
+					Reason:     document.Reason,
+					Location:   document.Location,
+				})
+			}
+		}
+	}
+	for name, points := range db.points {
+		for timestamp, value := range points {
+			snapshot.Points = append(snapshot.Points, TimeSeriesPoint{
+				Name:      name,
+				Timestamp: time.Unix(0, timestamp).UTC(),
+				Value:     value,
+			})
+		}
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		db.mutex.Unlock()
+		return errors.Trace(err)
+	}
+	db.dirty = false
+	db.mutex.Unlock()
+	// write to a temporary file and rename it to keep the snapshot complete
+	temp := db.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err == nil {
+		err = os.Rename(temp, db.path)
+	}
+	if err != nil {
+		db.mutex.Lock()
+		db.dirty = true
+		db.mutex.Unlock()
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// Close stops the snapshot loop and writes the last snapshot.
+func (db *MemoryDatabase) Close() error {
+	select {
+	case <-db.done:
+		return nil
+	default:
+		close(db.done)
+	}
+	db.wg.Wait()
+	return db.Snapshot()
+}
+
+func (db *MemoryDatabase) Ping() error {
+	return nil
+}
+
+func (db *MemoryDatabase) Init() error {
+	return nil
+}
+
+func (db *MemoryDatabase) Scan(work func(string) error) error {
+	db.mutex.RLock()
+	keys := make([]string, 0, len(db.values)+len(db.sets))
+	for name := range db.values {
+		keys = append(keys, name)
+	}
+	for name := range db.sets {
+		keys = append(keys, name)
+	}
+	db.mutex.RUnlock()
+	for _, key := range keys {
+		if err := work(key); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDatabase) Purge() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.reset()
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) Set(_ context.Context, values ...Value) error {
+	if len(values) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, value := range values {
+		db.values[value.name] = value.value
+	}
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) SetNX(_ context.Context, value Value) (bool, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if _, exist := db.values[value.name]; exist {
+		return false, nil
+	}
+	db.values[value.name] = value.value
+	db.dirty = true
+	return true, nil
+}
+
+func (db *MemoryDatabase) Get(_ context.Context, name string) *ReturnValue {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	if value, exist := db.values[name]; exist {
+		return &ReturnValue{value: value}
+	}
+	return &ReturnValue{err: errors.Annotate(ErrObjectNotExist, name)}
+}
+
+func (db *MemoryDatabase) Delete(_ context.Context, name string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	delete(db.values, name)
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) CompareAndDelete(_ context.Context, name, value string) (bool, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if current, exist := db.values[name]; !exist || current != value {
+		return false, nil
+	}
+	delete(db.values, name)
+	db.dirty = true
+	return true, nil
+}
+
+func (db *MemoryDatabase) GetSet(_ context.Context, key string) ([]string, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return db.getSet(key), nil
+}
+
+func (db *MemoryDatabase) SetSet(_ context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	delete(db.sets, key)
+	db.addSet(key, members)
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) AddSet(_ context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.addSet(key, members)
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) RemSet(_ context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	if set, exist := db.sets[key]; exist {
+		for _, member := range members {
+			delete(set, member)
+		}
+		if len(set) == 0 {
+			delete(db.sets, key)
+		}
+	}
+	db.dirty = true
+	return nil
+}
+
+// Push appends a message to a queue. An existed message is moved to the tail of the queue.
+func (db *MemoryDatabase) Push(_ context.Context, name, value string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	queue := lo.Filter(db.queues[name], func(message string, _ int) bool {
+		return message != value
+	})
+	db.queues[name] = append(queue, value)
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) Pop(_ context.Context, name string) (string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	queue := db.queues[name]
+	if len(queue) == 0 {
+		return "", io.EOF
+	}
+	if len(queue) == 1 {
+		delete(db.queues, name)
+	} else {
+		db.queues[name] = queue[1:]
+	}
+	db.dirty = true
+	return queue[0], nil
+}
+
+func (db *MemoryDatabase) Remain(_ context.Context, name string) (int64, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return int64(len(db.queues[name])), nil
+}
+
+func (db *MemoryDatabase) AddDocuments(_ context.Context, collection, subset string, documents []Document) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, document := range documents {
+		db.putDocument(collection, subset, document)
+	}
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) SearchDocuments(_ context.Context, collection, subset string, query []string, begin, end int) ([]Document, error) {
+	return db.searchDocuments(collection, subset, query, begin, end, func(Document) bool { return true })
+}
+
+func (db *MemoryDatabase) SearchNearbyDocuments(_ context.Context, collection, subset string, query []string, location Location, radius float64, begin, end int) ([]Document, error) {
+	return db.searchDocuments(collection, subset, query, begin, end, func(document Document) bool {
+		return document.Within(location, radius)
+	})
+}
+
+func (db *MemoryDatabase) searchDocuments(collection, subset string, query []string, begin, end int, filter func(Document) bool) ([]Document, error) {
+	if len(query) == 0 {
+		return nil, nil
+	}
+	db.mutex.RLock()
+	documents := make([]Document, 0, 10)
+	for _, document := range db.documents[collection][subset] {
+		if !document.IsHidden && lo.Every(document.Categories, query) && filter(document) {
+			documents = append(documents, Document{
+				Id:         document.Id,
+				Score:      document.Score,
+				Categories: append([]string(nil), document.Categories...),
+				Timestamp:  document.Timestamp,
+				Source:     document.Source,
+				Reason:     document.Reason,
+				Location:   document.Location,
+			})
+		}
+	}
+	db.mutex.RUnlock()
+	sort.Slice(documents, func(i, j int) bool {
+		if documents[i].Score != documents[j].Score {
+			return documents[i].Score > documents[j].Score
+		}
+		return documents[i].Id < documents[j].Id
+	})
+	if begin >= len(documents) {
+		return documents[:0], nil
+	}
+	if end == -1 || end > len(documents) {
+		end = len(documents)
+	}
+	return documents[begin:end], nil
+}
+
+func (db *MemoryDatabase) UpdateDocuments(_ context.Context, collections []string, id string, patch DocumentPatch) error {
+	if len(collections) == 0 {
+		return nil
+	}
+	if patch.Score == nil && patch.IsHidden == nil && patch.Categories == nil {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, collection := range collections {
+		for _, documents := range db.documents[collection] {
+			document, exist := documents[id]
+			if !exist {
+				continue
+			}
+			if patch.Score != nil {
+				document.Score = *patch.Score
+			}
+			if patch.IsHidden != nil {
+				document.IsHidden = *patch.IsHidden
+			}
+			if patch.Categories != nil {
+				document.Categories = append([]string(nil), patch.Categories...)
+			}
+			documents[id] = document
+		}
+	}
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) DeleteDocuments(_ context.Context, collections []string, condition DocumentCondition) error {
+	if err := condition.Check(); err != nil {
+		return errors.Trace(err)
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, collection := range collections {
+		for subset, documents := range db.documents[collection] {
+			if condition.Subset != nil && *condition.Subset != subset {
+				continue
+			}
+			for id, document := range documents {
+				if condition.Id != nil && *condition.Id != id {
+					continue
+				}
+				if condition.Before != nil && !document.Timestamp.Before(*condition.Before) {
+					continue
+				}
+				delete(documents, id)
+			}
+		}
+	}
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) AddTimeSeriesPoints(_ context.Context, points []TimeSeriesPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.addTimeSeriesPoints(points)
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) GetTimeSeriesPoints(_ context.Context, name string, begin, end time.Time) ([]TimeSeriesPoint, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	var points []TimeSeriesPoint
+	for timestamp, value := range db.points[name] {
+		if timestamp >= begin.UnixNano() && timestamp < end.UnixNano() {
+			points = append(points, TimeSeriesPoint{
+				Name:      name,
+				Timestamp: time.Unix(0, timestamp).UTC(),
+				Value:     value,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+	return points, nil
+}
+
+func (db *MemoryDatabase) getSet(key string) []string {
+	set, exist := db.sets[key]
+	if !exist {
+		return nil
+	}
+	members := lo.Keys(set)
+	sort.Strings(members)
+	return members
+}
+
+func (db *MemoryDatabase) addSet(key string, members []string) {
+	set, exist := db.sets[key]
+	if !exist {
+		set = make(map[string]struct{}, len(members))
+		db.sets[key] = set
+	}
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+}
+
+func (db *MemoryDatabase) putDocument(collection, subset string, document Document) {
+	subsets, exist := db.documents[collection]
+	if !exist {
+		subsets = make(map[string]map[string]Document)
+		db.documents[collection] = subsets
+	}
+	documents, exist := subsets[subset]
+	if !exist {
+		documents = make(map[string]Document)
+		subsets[subset] = documents
+	}
+	document.Categories = append([]string(nil), document.Categories...)
+	document.Timestamp = document.Timestamp.Round(0).UTC()
+	if document.Location != nil {
+		location := *document.Location
+		document.Location = &location
+	}
+	documents[document.Id] = document
+}
+
+func (db *MemoryDatabase) addTimeSeriesPoints(points []TimeSeriesPoint) {
+	for _, point := range points {
+		series, exist := db.points[point.Name]
+		if !exist {
+			series = make(map[int64]float64)
+			db.points[point.Name] = series
+		}
+		series[point.Timestamp.UnixNano()] = point.Value
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MemoryTestSuite struct {
+	baseTestSuite
+}
+
+func (suite *MemoryTestSuite) SetupSuite() {
+	var err error
+	// create database
+	path := fmt.Sprintf("memory://%s/cache.json", suite.T().TempDir())
+	suite.Database, err = Open(path, "")
+	suite.NoError(err)
+	// create schema
+	err = suite.Database.Init()
+	suite.NoError(err)
+}
+
+func (suite *MemoryTestSuite) TearDownSuite() {
+	suite.NoError(suite.Database.Close())
+}
+
+func TestMemory(t *testing.T) {
+	suite.Run(t, new(MemoryTestSuite))
+}
+
+func TestMemoryDatabase_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	path := fmt.Sprintf("memory://%s/cache.json?snapshot_interval=1h", t.TempDir())
+	ts := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// insert data and close
+	database, err := Open(path, "")
+	assert.NoError(t, err)
+	assert.NoError(t, database.Set(ctx, String("key", "value")))
+	assert.NoError(t, database.AddSet(ctx, "set", "a", "b"))
+	assert.NoError(t, database.Push(ctx, "queue", "1"))
+	assert.NoError(t, database.AddDocuments(ctx, "a", "", []Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts}}))
+	assert.NoError(t, database.AddTimeSeriesPoints(ctx, []TimeSeriesPoint{{Name: "a", Value: 1, Timestamp: ts}}))
+	assert.NoError(t, database.Close())
+
+	// reopen from snapshot
+	database, err = Open(path, "")
+	assert.NoError(t, err)
+	value, err := database.Get(ctx, "key").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	members, err := database.GetSet(ctx, "set")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, members)
+	message, err := database.Pop(ctx, "queue")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", message)
+	documents, err := database.SearchDocuments(ctx, "a", "", []string{""}, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []Document{{Id: "1", Score: 1, Categories: []string{""}, Timestamp: ts}}, documents)
+	points, err := database.GetTimeSeriesPoints(ctx, "a", ts, ts.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, []TimeSeriesPoint{{Name: "a", Value: 1, Timestamp: ts}}, points)
+	assert.NoError(t, database.Close())
+}
@@ -205,6 +205,17 @@ func (m MongoDB) Set(ctx context.Context, values ...Value) error {
 	return errors.Trace(err)
 }
 
+func (m MongoDB) SetNX(ctx context.Context, value Value) (bool, error) {
+	c := m.client.Database(m.dbName).Collection(m.ValuesTable())
+	_, err := c.InsertOne(ctx, bson.M{"_id": value.name, "value": value.value})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Trace(err)
+	}
+	return true, nil
+}
+
 func (m MongoDB) Get(ctx context.Context, name string) *ReturnValue {
 	c := m.client.Database(m.dbName).Collection(m.ValuesTable())
 	r := c.FindOne(ctx, bson.M{"_id": bson.M{"$eq": name}})
@@ -226,6 +237,15 @@ func (m MongoDB) Delete(ctx context.Context, name string) error {
 	return errors.Trace(err)
 }
 
+func (m MongoDB) CompareAndDelete(ctx context.Context, name, value string) (bool, error) {
+	c := m.client.Database(m.dbName).Collection(m.ValuesTable())
+	r, err := c.DeleteOne(ctx, bson.M{"_id": bson.M{"$eq": name}, "value": bson.M{"$eq": value}})
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return r.DeletedCount > 0, nil
+}
+
 func (m MongoDB) GetSet(ctx context.Context, name string) ([]string, error) {
 	c := m.client.Database(m.dbName).Collection(m.SetsTable())
 	r, err := c.Find(ctx, bson.M{"name": name})
@@ -331,6 +351,9 @@ func (m MongoDB) AddDocuments(ctx context.Context, collection, subset string, do
 				"is_hidden":  document.IsHidden,
 				"categories": document.Categories,
 				"timestamp":  document.Timestamp,
+				"source":     document.Source,
+				"reason":     document.Reason,
+				"location":   encodeMongoLocation(document.Location),
 			}}))
 	}
 	_, err := m.client.Database(m.dbName).Collection(m.DocumentTable()).BulkWrite(ctx, models)
@@ -338,6 +361,19 @@ func (m MongoDB) AddDocuments(ctx context.Context, collection, subset string, do
 }
 
 func (m MongoDB) SearchDocuments(ctx context.Context, collection, subset string, query []string, begin, end int) ([]Document, error) {
+	return m.searchDocuments(ctx, collection, subset, query, bson.M{}, begin, end)
+}
+
+func (m MongoDB) SearchNearbyDocuments(ctx context.Context, collection, subset string, query []string, location Location, radius float64, begin, end int) ([]Document, error) {
+	// the radius of a sphere is in radians
+	return m.searchDocuments(ctx, collection, subset, query, bson.M{
+		"location": bson.M{"$geoWithin": bson.M{"$centerSphere": bson.A{
+			bson.A{location.Longitude, location.Latitude}, radius / earthRadius,
+		}}},
+	}, begin, end)
+}
+
+func (m MongoDB) searchDocuments(ctx context.Context, collection, subset string, query []string, filter bson.M, begin, end int) ([]Document, error) {
 	if len(query) == 0 {
 		return nil, nil
 	}
@@ -345,22 +381,25 @@ func (m MongoDB) SearchDocuments(ctx context.Context, collection, subset string,
 	if end != -1 {
 		opt.SetLimit(int64(end - begin))
 	}
-	cur, err := m.client.Database(m.dbName).Collection(m.DocumentTable()).Find(ctx, bson.M{
-		"collection": collection,
-		"subset":     subset,
-		"is_hidden":  false,
-		"categories": bson.M{"$all": query},
-	}, opt)
+	filter["collection"] = collection
+	filter["subset"] = subset
+	filter["is_hidden"] = false
+	filter["categories"] = bson.M{"$all": query}
+	cur, err := m.client.Database(m.dbName).Collection(m.DocumentTable()).Find(ctx, filter, opt)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 	documents := make([]Document, 0)
 	for cur.Next(ctx) {
-		var document Document
+		var document struct {
+			Document `bson:",inline"`
+			Location []float64 `bson:"location"`
+		}
 		if err = cur.Decode(&document); err != nil {
 			return nil, errors.Trace(err)
 		}
-		documents = append(documents, document)
+		document.Document.Location = decodeMongoLocation(document.Location)
+		documents = append(documents, document.Document)
 	}
 	return documents, nil
 }
@@ -442,3 +481,18 @@ func (m MongoDB) GetTimeSeriesPoints(ctx context.Context, name string, begin, en
 	}
 	return points, nil
 }
+
+// encodeMongoLocation encodes a location as a legacy coordinate pair of [longitude, latitude].
+func encodeMongoLocation(location *Location) []float64 {
+	if location == nil {
+		return nil
+	}
+	return []float64{location.Longitude, location.Latitude}
+}
+
+func decodeMongoLocation(location []float64) *Location {
+	if len(location) != 2 {
+		return nil
+	}
+	return &Location{Latitude: location[1], Longitude: location[0]}
+}
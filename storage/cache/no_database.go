@@ -48,6 +48,16 @@ func (NoDatabase) Set(_ context.Context, _ ...Value) error {
 	return ErrNoDatabase
 }
 
+// SetNX method of NoDatabase returns ErrNoDatabase.
+func (NoDatabase) SetNX(_ context.Context, _ Value) (bool, error) {
+	return false, ErrNoDatabase
+}
+
+// CompareAndDelete method of NoDatabase returns ErrNoDatabase.
+func (NoDatabase) CompareAndDelete(_ context.Context, _, _ string) (bool, error) {
+	return false, ErrNoDatabase
+}
+
 // Get method of NoDatabase returns ErrNoDatabase.
 func (NoDatabase) Get(_ context.Context, _ string) *ReturnValue {
 	return &ReturnValue{err: ErrNoDatabase}
@@ -98,6 +108,10 @@ func (NoDatabase) SearchDocuments(_ context.Context, _, _ string, _ []string, _,
 	return nil, ErrNoDatabase
 }
 
+func (NoDatabase) SearchNearbyDocuments(_ context.Context, _, _ string, _ []string, _ Location, _ float64, _, _ int) ([]Document, error) {
+	return nil, ErrNoDatabase
+}
+
 func (NoDatabase) UpdateDocuments(_ context.Context, _ []string, _ string, _ DocumentPatch) error {
 	return ErrNoDatabase
 }
@@ -37,6 +37,10 @@ func TestNoDatabase(t *testing.T) {
 	assert.ErrorIs(t, err, ErrNoDatabase)
 	err = database.Set(ctx)
 	assert.ErrorIs(t, err, ErrNoDatabase)
+	_, err = database.SetNX(ctx, String("", ""))
+	assert.ErrorIs(t, err, ErrNoDatabase)
+	_, err = database.CompareAndDelete(ctx, "", "")
+	assert.ErrorIs(t, err, ErrNoDatabase)
 	_, err = database.Get(ctx, Key("", "")).String()
 	assert.ErrorIs(t, err, ErrNoDatabase)
 	_, err = database.Get(ctx, Key("", "")).Integer()
@@ -66,6 +70,8 @@ func TestNoDatabase(t *testing.T) {
 	assert.ErrorIs(t, err, ErrNoDatabase)
 	_, err = database.SearchDocuments(ctx, "", "", nil, 0, 0)
 	assert.ErrorIs(t, err, ErrNoDatabase)
+	_, err = database.SearchNearbyDocuments(ctx, "", "", nil, Location{}, 0, 0, 0)
+	assert.ErrorIs(t, err, ErrNoDatabase)
 	err = database.UpdateDocuments(ctx, nil, "", DocumentPatch{})
 	assert.ErrorIs(t, err, ErrNoDatabase)
 	err = database.DeleteDocuments(ctx, nil, DocumentCondition{})
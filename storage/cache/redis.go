@@ -64,11 +64,18 @@ func (r *Redis) Init() error {
 			"score", "NUMERIC", "SORTABLE",
 			"is_hidden", "NUMERIC",
 			"categories", "TAG", "SEPARATOR", ";",
-			"timestamp", "NUMERIC", "SORTABLE").
+			"timestamp", "NUMERIC", "SORTABLE",
+			"location", "GEO").
 			Result()
 		if err != nil {
 			return errors.Trace(err)
 		}
+	} else {
+		// add the location field to the index created by old versions
+		_, err = r.client.Do(context.TODO(), "FT.ALTER", r.DocumentTable(), "SCHEMA", "ADD", "location", "GEO").Result()
+		if err != nil && !strings.Contains(err.Error(), "Duplicate field") {
+			return errors.Trace(err)
+		}
 	}
 	if !lo.Contains(indices, r.PointsTable()) {
 		_, err = r.client.Do(context.TODO(), "FT.CREATE", r.PointsTable(),
@@ -148,6 +155,12 @@ func (r *Redis) Set(ctx context.Context, values ...Value) error {
 	return errors.Trace(err)
 }
 
+// SetNX sets a value in Redis if the name does not exist.
+func (r *Redis) SetNX(ctx context.Context, value Value) (bool, error) {
+	ok, err := r.client.SetNX(ctx, r.Key(value.name), value.value, 0).Result()
+	return ok, errors.Trace(err)
+}
+
 // Get returns a value from Redis.
 func (r *Redis) Get(ctx context.Context, key string) *ReturnValue {
 	val, err := r.client.Get(ctx, r.Key(key)).Result()
@@ -165,6 +178,23 @@ func (r *Redis) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, r.Key(key)).Err()
 }
 
+// compareAndDeleteScript deletes a key if its value equals the argument.
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// CompareAndDelete deletes a value from Redis if it equals the given value.
+func (r *Redis) CompareAndDelete(ctx context.Context, name, value string) (bool, error) {
+	deleted, err := compareAndDeleteScript.Run(ctx, r.client, []string{r.Key(name)}, value).Int()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return deleted > 0, nil
+}
+
 // GetSet returns members of a set from Redis.
 func (r *Redis) GetSet(ctx context.Context, key string) ([]string, error) {
 	return r.client.SMembers(ctx, r.Key(key)).Result()
@@ -244,13 +274,32 @@ func (r *Redis) AddDocuments(ctx context.Context, collection, subset string, doc
 			"score", document.Score,
 			"is_hidden", document.IsHidden,
 			"categories", encodeCategories(document.Categories),
-			"timestamp", document.Timestamp.UnixMicro())
+			"timestamp", document.Timestamp.UnixMicro(),
+			"source", document.Source,
+			"reason", document.Reason)
+		if document.Location != nil {
+			p.HSet(ctx, r.documentKey(collection, subset, document.Id), "location", encodeLocation(*document.Location))
+		} else {
+			p.HDel(ctx, r.documentKey(collection, subset, document.Id), "location")
+		}
 	}
 	_, err := p.Exec(ctx)
 	return errors.Trace(err)
 }
 
 func (r *Redis) SearchDocuments(ctx context.Context, collection, subset string, query []string, begin, end int) ([]Document, error) {
+	return r.searchDocuments(ctx, collection, subset, query, "", begin, end)
+}
+
+func (r *Redis) SearchNearbyDocuments(ctx context.Context, collection, subset string, query []string, location Location, radius float64, begin, end int) ([]Document, error) {
+	filter := fmt.Sprintf(" @location:[%s %s %s km]",
+		strconv.FormatFloat(location.Longitude, 'f', -1, 64),
+		strconv.FormatFloat(location.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(radius, 'f', -1, 64))
+	return r.searchDocuments(ctx, collection, subset, query, filter, begin, end)
+}
+
+func (r *Redis) searchDocuments(ctx context.Context, collection, subset string, query []string, filter string, begin, end int) ([]Document, error) {
 	if len(query) == 0 {
 		return nil, nil
 	}
@@ -262,6 +311,7 @@ func (r *Redis) SearchDocuments(ctx context.Context, collection, subset string,
 	for _, q := range query {
 		builder.WriteString(fmt.Sprintf(" @categories:{ %s }", encdodeCategory(q)))
 	}
+	builder.WriteString(filter)
 	args := []any{"FT.SEARCH", r.DocumentTable(), builder.String(), "SORTBY", "score", "DESC", "LIMIT", begin}
 	if end == -1 {
 		args = append(args, 10000)
@@ -425,6 +475,14 @@ func parseSearchDocumentsResult(result any) (count int64, keys []string, documen
 			return 0, nil, nil, errors.Trace(err)
 		}
 		document.Timestamp = time.UnixMicro(timestampMicros).In(time.UTC)
+		// source and reason are missing in documents written by old versions
+		document.Source, _ = fields["source"].(string)
+		document.Reason, _ = fields["reason"].(string)
+		if location, ok := fields["location"].(string); ok {
+			if document.Location, err = decodeLocation(location); err != nil {
+				return 0, nil, nil, errors.Trace(err)
+			}
+		}
 		documents = append(documents, document)
 	}
 	return
@@ -544,3 +602,26 @@ func decodeCategories(s string) ([]string, error) {
 	}
 	return categories, nil
 }
+
+// encodeLocation encodes a location in the format of "longitude,latitude" required by GEO fields.
+func encodeLocation(location Location) string {
+	return strconv.FormatFloat(location.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Latitude, 'f', -1, 64)
+}
+
+func decodeLocation(s string) (*Location, error) {
+	longitude, latitude, found := strings.Cut(s, ",")
+	if !found {
+		return nil, errors.NotValidf("location %s", s)
+	}
+	var (
+		location Location
+		err      error
+	)
+	if location.Longitude, err = strconv.ParseFloat(longitude, 64); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if location.Latitude, err = strconv.ParseFloat(latitude, 64); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &location, nil
+}
@@ -114,6 +114,10 @@ type PostgresDocument struct {
 	Categories pq.StringArray `gorm:"type:text[]"`
 	Score      float64
 	Timestamp  time.Time
+	Source     string
+	Reason     string
+	Latitude   *float64
+	Longitude  *float64
 }
 
 type SQLDocument struct {
@@ -124,6 +128,10 @@ type SQLDocument struct {
 	Categories []string `gorm:"type:text;serializer:json"`
 	Score      float64
 	Timestamp  time.Time
+	Source     string
+	Reason     string
+	Latitude   *float64
+	Longitude  *float64
 }
 
 type SQLDatabase struct {
@@ -233,6 +241,17 @@ func (db *SQLDatabase) Set(ctx context.Context, values ...Value) error {
 	return errors.Trace(err)
 }
 
+func (db *SQLDatabase) SetNX(ctx context.Context, value Value) (bool, error) {
+	result := db.gormDB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&SQLValue{Name: value.name, Value: value.value})
+	if result.Error != nil {
+		return false, errors.Trace(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (db *SQLDatabase) Get(ctx context.Context, name string) *ReturnValue {
 	rs, err := db.gormDB.WithContext(ctx).Table(db.ValuesTable()).Where("name = ?", name).Select("value").Rows()
 	if err != nil {
@@ -255,6 +274,14 @@ func (db *SQLDatabase) Delete(ctx context.Context, name string) error {
 	return errors.Trace(err)
 }
 
+func (db *SQLDatabase) CompareAndDelete(ctx context.Context, name, value string) (bool, error) {
+	result := db.gormDB.WithContext(ctx).Where("value = ?", value).Delete(&SQLValue{Name: name})
+	if result.Error != nil {
+		return false, errors.Trace(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (db *SQLDatabase) GetSet(ctx context.Context, key string) ([]string, error) {
 	rs, err := db.gormDB.WithContext(ctx).Table(db.SetsTable()).Select("member").Where("name = ?", key).Rows()
 	if err != nil {
@@ -357,6 +384,7 @@ func (db *SQLDatabase) AddDocuments(ctx context.Context, collection, subset stri
 	switch db.driver {
 	case Postgres:
 		rows = lo.Map(documents, func(document Document, _ int) PostgresDocument {
+			latitude, longitude := encodeSQLLocation(document.Location)
 			return PostgresDocument{
 				Collection: collection,
 				Subset:     subset,
@@ -365,10 +393,15 @@ func (db *SQLDatabase) AddDocuments(ctx context.Context, collection, subset stri
 				IsHidden:   document.IsHidden,
 				Categories: document.Categories,
 				Timestamp:  document.Timestamp,
+				Source:     document.Source,
+				Reason:     document.Reason,
+				Latitude:   latitude,
+				Longitude:  longitude,
 			}
 		})
 	case SQLite, MySQL:
 		rows = lo.Map(documents, func(document Document, _ int) SQLDocument {
+			latitude, longitude := encodeSQLLocation(document.Location)
 			return SQLDocument{
 				Collection: collection,
 				Subset:     subset,
@@ -377,21 +410,35 @@ func (db *SQLDatabase) AddDocuments(ctx context.Context, collection, subset stri
 				IsHidden:   document.IsHidden,
 				Categories: document.Categories,
 				Timestamp:  document.Timestamp,
+				Source:     document.Source,
+				Reason:     document.Reason,
+				Latitude:   latitude,
+				Longitude:  longitude,
 			}
 		})
 	}
 	db.gormDB.WithContext(ctx).Table(db.DocumentTable()).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "collection"}, {Name: "subset"}, {Name: "id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"score", "categories", "timestamp"}),
+		DoUpdates: clause.AssignmentColumns([]string{"score", "categories", "timestamp", "source", "reason", "latitude", "longitude"}),
 	}).Create(rows)
 	return nil
 }
 
 func (db *SQLDatabase) SearchDocuments(ctx context.Context, collection, subset string, query []string, begin, end int) ([]Document, error) {
+	return db.searchDocuments(ctx, collection, subset, query, nil, 0, begin, end)
+}
+
+func (db *SQLDatabase) SearchNearbyDocuments(ctx context.Context, collection, subset string, query []string, location Location, radius float64, begin, end int) ([]Document, error) {
+	return db.searchDocuments(ctx, collection, subset, query, &location, radius, begin, end)
+}
+
+// searchDocuments searches documents. If the location is not nil, documents are filtered by latitudes in the database
+// and then filtered by distances, so that the pagination is applied after filtering.
+func (db *SQLDatabase) searchDocuments(ctx context.Context, collection, subset string, query []string, location *Location, radius float64, begin, end int) ([]Document, error) {
 	if len(query) == 0 {
 		return nil, nil
 	}
-	tx := db.gormDB.WithContext(ctx).Model(&PostgresDocument{}).Select("id, score, categories, timestamp")
+	tx := db.gormDB.WithContext(ctx).Model(&PostgresDocument{}).Select("id, score, categories, timestamp, source, reason, latitude, longitude")
 	switch db.driver {
 	case Postgres:
 		tx = tx.Where("collection = ? and subset = ? and is_hidden = false and categories @> ?", collection, subset, pq.StringArray(query))
@@ -402,11 +449,18 @@ func (db *SQLDatabase) SearchDocuments(ctx context.Context, collection, subset s
 		}
 		tx = tx.Where("collection = ? and subset = ? and is_hidden = false and JSON_CONTAINS(categories,?)", collection, subset, string(q))
 	}
-	tx = tx.Order("score desc").Offset(begin)
-	if end != -1 {
-		tx = tx.Limit(end - begin)
-	} else {
-		tx = tx.Limit(math.MaxInt64)
+	if location != nil {
+		deltaLatitude := radius / earthRadius * 180 / math.Pi
+		tx = tx.Where("latitude between ? and ?", location.Latitude-deltaLatitude, location.Latitude+deltaLatitude)
+	}
+	tx = tx.Order("score desc")
+	if location == nil {
+		tx = tx.Offset(begin)
+		if end != -1 {
+			tx = tx.Limit(end - begin)
+		} else {
+			tx = tx.Limit(math.MaxInt64)
+		}
 	}
 	rows, err := tx.Rows()
 	if err != nil {
@@ -414,27 +468,50 @@ func (db *SQLDatabase) SearchDocuments(ctx context.Context, collection, subset s
 	}
 	documents := make([]Document, 0, 10)
 	for rows.Next() {
+		var document Document
 		switch db.driver {
 		case Postgres:
-			var document PostgresDocument
-			if err = rows.Scan(&document.Id, &document.Score, &document.Categories, &document.Timestamp); err != nil {
+			var row PostgresDocument
+			if err = rows.Scan(&row.Id, &row.Score, &row.Categories, &row.Timestamp, &row.Source, &row.Reason, &row.Latitude, &row.Longitude); err != nil {
 				return nil, errors.Trace(err)
 			}
-			documents = append(documents, Document{
-				Id:         document.Id,
-				Score:      document.Score,
-				Categories: document.Categories,
-				Timestamp:  document.Timestamp,
-			})
+			document = Document{
+				Id:         row.Id,
+				Score:      row.Score,
+				Categories: row.Categories,
+				Timestamp:  row.Timestamp,
+				Source:     row.Source,
+				Reason:     row.Reason,
+				Location:   decodeSQLLocation(row.Latitude, row.Longitude),
+			}
 		case SQLite, MySQL:
-			var document Document
-			if err = db.gormDB.ScanRows(rows, &document); err != nil {
+			var row SQLDocument
+			if err = db.gormDB.ScanRows(rows, &row); err != nil {
 				return nil, errors.Trace(err)
 			}
-			document.Timestamp = document.Timestamp.In(time.UTC)
+			document = Document{
+				Id:         row.Id,
+				Score:      row.Score,
+				Categories: row.Categories,
+				Timestamp:  row.Timestamp.In(time.UTC),
+				Source:     row.Source,
+				Reason:     row.Reason,
+				Location:   decodeSQLLocation(row.Latitude, row.Longitude),
+			}
+		}
+		if location == nil || document.Within(*location, radius) {
 			documents = append(documents, document)
 		}
 	}
+	if location != nil {
+		if begin >= len(documents) {
+			return documents[:0], nil
+		}
+		if end == -1 || end > len(documents) {
+			end = len(documents)
+		}
+		documents = documents[begin:end]
+	}
 	return documents, nil
 }
 
@@ -509,3 +586,17 @@ func (db *SQLDatabase) GetTimeSeriesPoints(ctx context.Context, name string, beg
 	}
 	return points, nil
 }
+
+func encodeSQLLocation(location *Location) (latitude, longitude *float64) {
+	if location == nil {
+		return nil, nil
+	}
+	return &location.Latitude, &location.Longitude
+}
+
+func decodeSQLLocation(latitude, longitude *float64) *Location {
+	if latitude == nil || longitude == nil {
+		return nil
+	}
+	return &Location{Latitude: *latitude, Longitude: *longitude}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,7 +47,21 @@ var (
 	ErrNoDatabase   = errors.NotAssignedf("database")
 )
 
+// LocationLabel is the reserved label of item coordinates in the format of "latitude,longitude".
+const LocationLabel = "location"
+
 func ValidateLabels(o any) error {
+	if labels, ok := o.(map[string]any); ok {
+		if _, exist := labels[LocationLabel]; exist {
+			if _, _, ok = ParseLocation(labels); !ok {
+				return errors.Errorf("location must be in the format of \"latitude,longitude\"")
+			}
+		}
+	}
+	return validateLabels(o)
+}
+
+func validateLabels(o any) error {
 	if o == nil {
 		return nil
 	}
@@ -65,7 +80,7 @@ func ValidateLabels(o any) error {
 		return nil
 	case map[string]any:
 		for _, val := range labels {
-			if err := ValidateLabels(val); err != nil {
+			if err := validateLabels(val); err != nil {
 				return err
 			}
 		}
@@ -77,8 +92,46 @@ func ValidateLabels(o any) error {
 	}
 }
 
+// ParseLocation parses coordinates of an item from the reserved location label. It returns false if the label is
+// missing or invalid.
+func ParseLocation(o any) (latitude, longitude float64, ok bool) {
+	labels, ok := o.(map[string]any)
+	if !ok {
+		return 0, 0, false
+	}
+	location, ok := labels[LocationLabel].(string)
+	if !ok {
+		return 0, 0, false
+	}
+	latitudeString, longitudeString, ok := strings.Cut(location, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(latitudeString), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, false
+	}
+	longitude, err = strconv.ParseFloat(strings.TrimSpace(longitudeString), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, false
+	}
+	return latitude, longitude, true
+}
+
+// FlattenLabels flattens labels into strings, e.g. {"city": "wenzhou"} is flattened into "city.wenzhou". The reserved
+// location label is excluded since coordinates are not features of items.
 func FlattenLabels(o any) []string {
 	labels := make([]string, 0)
+	if m, ok := o.(map[string]any); ok {
+		if _, exist := m[LocationLabel]; exist {
+			for key, val := range m {
+				if key != LocationLabel {
+					labels = flattenLabels(labels, key+".", val)
+				}
+			}
+			return labels
+		}
+	}
 	return flattenLabels(labels, "", o)
 }
 
@@ -117,6 +170,24 @@ type Item struct {
 	Comment    string    `mapsstructure:"comment"`
 }
 
+// CategorySeparator separates levels of hierarchical categories, e.g. "books/fiction/scifi".
+const CategorySeparator = "/"
+
+// ExpandCategories returns categories with all their ancestors, e.g. "books/fiction" is expanded to "books" and
+// "books/fiction". Ancestors are placed before descendants and duplicate categories are removed.
+func ExpandCategories(categories []string) []string {
+	result := make([]string, 0, len(categories))
+	for _, category := range categories {
+		for i := range category {
+			if strings.HasPrefix(category[i:], CategorySeparator) && i > 0 {
+				result = append(result, category[:i])
+			}
+		}
+		result = append(result, category)
+	}
+	return lo.Uniq(result)
+}
+
 // ItemPatch is the modification on an item.
 type ItemPatch struct {
 	IsHidden   *bool
@@ -153,6 +224,10 @@ type Feedback struct {
 	FeedbackKey `gorm:"embedded" mapstructure:",squash"`
 	Timestamp   time.Time `gorm:"column:time_stamp" mapsstructure:"timestamp"`
 	Comment     string    `gorm:"column:comment" mapsstructure:"comment"`
+	Value       float64   `gorm:"column:value" mapstructure:"value"`
+
+	// ContextLabels are labels of the context where the feedback happened, e.g. device.mobile.
+	ContextLabels []string `gorm:"column:context_labels;serializer:json" mapstructure:"context_labels"`
 }
 
 // SortFeedbacks sorts feedback from latest to oldest.
@@ -298,6 +373,12 @@ func Open(path, tablePrefix string) (Database, error) {
 			return nil, errors.Trace(err)
 		}
 		return database, nil
+	} else if strings.HasPrefix(path, storage.MemoryPrefix) {
+		database, err := newMemoryDatabase(path[len(storage.MemoryPrefix):])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return database, nil
 	}
 	return nil, errors.Errorf("Unknown database: %s", path)
 }
@@ -218,11 +218,11 @@ func (suite *baseTestSuite) TestFeedback() {
 	// insert feedbacks
 	timestamp := time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC)
 	feedback := []Feedback{
-		{FeedbackKey{positiveFeedbackType, "0", "8"}, timestamp, "comment"},
-		{FeedbackKey{positiveFeedbackType, "1", "6"}, timestamp, "comment"},
-		{FeedbackKey{positiveFeedbackType, "2", "4"}, timestamp, "comment"},
-		{FeedbackKey{positiveFeedbackType, "3", "2"}, timestamp, "comment"},
-		{FeedbackKey{positiveFeedbackType, "4", "0"}, timestamp, "comment"},
+		{FeedbackKey{positiveFeedbackType, "0", "8"}, timestamp, "comment", 1},
+		{FeedbackKey{positiveFeedbackType, "1", "6"}, timestamp, "comment", 2},
+		{FeedbackKey{positiveFeedbackType, "2", "4"}, timestamp, "comment", 3},
+		{FeedbackKey{positiveFeedbackType, "3", "2"}, timestamp, "comment", 4},
+		{FeedbackKey{positiveFeedbackType, "4", "0"}, timestamp, "comment", 5},
 	}
 	err = suite.Database.BatchInsertFeedback(ctx, feedback, true, true, true)
 	suite.NoError(err)
@@ -233,11 +233,11 @@ func (suite *baseTestSuite) TestFeedback() {
 	suite.NoError(err)
 	// future feedback
 	futureFeedback := []Feedback{
-		{FeedbackKey{duplicateFeedbackType, "0", "0"}, time.Now().Add(time.Hour), "comment"},
-		{FeedbackKey{duplicateFeedbackType, "1", "2"}, time.Now().Add(time.Hour), "comment"},
-		{FeedbackKey{duplicateFeedbackType, "2", "4"}, time.Now().Add(time.Hour), "comment"},
-		{FeedbackKey{duplicateFeedbackType, "3", "6"}, time.Now().Add(time.Hour), "comment"},
-		{FeedbackKey{duplicateFeedbackType, "4", "8"}, time.Now().Add(time.Hour), "comment"},
+		{FeedbackKey{duplicateFeedbackType, "0", "0"}, time.Now().Add(time.Hour), "comment", 0},
+		{FeedbackKey{duplicateFeedbackType, "1", "2"}, time.Now().Add(time.Hour), "comment", 0},
+		{FeedbackKey{duplicateFeedbackType, "2", "4"}, time.Now().Add(time.Hour), "comment", 0},
+		{FeedbackKey{duplicateFeedbackType, "3", "6"}, time.Now().Add(time.Hour), "comment", 0},
+		{FeedbackKey{duplicateFeedbackType, "4", "8"}, time.Now().Add(time.Hour), "comment", 0},
 	}
 	err = suite.Database.BatchInsertFeedback(ctx, futureFeedback, true, true, true)
 	suite.NoError(err)
@@ -309,22 +309,26 @@ func (suite *baseTestSuite) TestFeedback() {
 	err = suite.Database.BatchInsertFeedback(ctx, []Feedback{{
 		FeedbackKey: FeedbackKey{positiveFeedbackType, "0", "8"},
 		Comment:     "override",
+		Value:       10,
 	}}, true, true, true)
 	suite.NoError(err)
 	ret, err = suite.Database.GetUserFeedback(ctx, "0", lo.ToPtr(time.Now()), positiveFeedbackType)
 	suite.NoError(err)
 	suite.Equal(1, len(ret))
 	suite.Equal("override", ret[0].Comment)
+	suite.Equal(10.0, ret[0].Value)
 	// test not overwrite
 	err = suite.Database.BatchInsertFeedback(ctx, []Feedback{{
 		FeedbackKey: FeedbackKey{positiveFeedbackType, "0", "8"},
 		Comment:     "not_override",
+		Value:       20,
 	}}, true, true, false)
 	suite.NoError(err)
 	ret, err = suite.Database.GetUserFeedback(ctx, "0", lo.ToPtr(time.Now()), positiveFeedbackType)
 	suite.NoError(err)
 	suite.Equal(1, len(ret))
 	suite.Equal("override", ret[0].Comment)
+	suite.Equal(10.0, ret[0].Value)
 
 	// insert no feedback
 	err = suite.Database.BatchInsertFeedback(ctx, nil, true, true, true)
@@ -477,11 +481,11 @@ func (suite *baseTestSuite) TestDeleteUser() {
 	ctx := context.Background()
 	// Insert ret
 	feedback := []Feedback{
-		{FeedbackKey{positiveFeedbackType, "a", "0"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "a", "2"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "a", "4"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "a", "6"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "a", "8"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
+		{FeedbackKey{positiveFeedbackType, "a", "0"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "a", "2"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "a", "4"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "a", "6"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "a", "8"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
 	}
 	err := suite.Database.BatchInsertFeedback(ctx, feedback, true, true, true)
 	suite.NoError(err)
@@ -502,11 +506,11 @@ func (suite *baseTestSuite) TestDeleteItem() {
 	ctx := context.Background()
 	// Insert ret
 	feedbacks := []Feedback{
-		{FeedbackKey{positiveFeedbackType, "0", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "1", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "2", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "3", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{positiveFeedbackType, "4", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
+		{FeedbackKey{positiveFeedbackType, "0", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "1", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "2", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "3", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{positiveFeedbackType, "4", "b"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
 	}
 	err := suite.Database.BatchInsertFeedback(ctx, feedbacks, true, true, true)
 	suite.NoError(err)
@@ -526,11 +530,11 @@ func (suite *baseTestSuite) TestDeleteItem() {
 func (suite *baseTestSuite) TestDeleteFeedback() {
 	ctx := context.Background()
 	feedbacks := []Feedback{
-		{FeedbackKey{"type1", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type2", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type3", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type1", "2", "4"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type1", "1", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
+		{FeedbackKey{"type1", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type2", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type3", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type1", "2", "4"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type1", "1", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
 	}
 	err := suite.Database.BatchInsertFeedback(ctx, feedbacks, true, true, true)
 	suite.NoError(err)
@@ -602,11 +606,11 @@ func (suite *baseTestSuite) TestTimeLimit() {
 
 	// insert feedback
 	feedbacks := []Feedback{
-		{FeedbackKey{"type1", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type2", "2", "3"}, time.Date(1997, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type3", "2", "3"}, time.Date(1998, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type1", "2", "4"}, time.Date(1999, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
-		{FeedbackKey{"type1", "1", "3"}, time.Date(2000, 3, 15, 0, 0, 0, 0, time.UTC), "comment"},
+		{FeedbackKey{"type1", "2", "3"}, time.Date(1996, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type2", "2", "3"}, time.Date(1997, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type3", "2", "3"}, time.Date(1998, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type1", "2", "4"}, time.Date(1999, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
+		{FeedbackKey{"type1", "1", "3"}, time.Date(2000, 3, 15, 0, 0, 0, 0, time.UTC), "comment", 0},
 	}
 	err = suite.Database.BatchInsertFeedback(ctx, feedbacks, true, true, true)
 	suite.NoError(err)
@@ -686,6 +690,13 @@ func (suite *baseTestSuite) TestTimezone() {
 		item, err = suite.Database.GetItem(ctx, "200")
 		suite.NoError(err)
 		suite.Equal(now.Truncate(time.Millisecond).In(time.UTC), item.Timestamp)
+	case *MemoryDatabase:
+		item, err := suite.Database.GetItem(ctx, "100")
+		suite.NoError(err)
+		suite.Equal(now.In(time.UTC), item.Timestamp)
+		item, err = suite.Database.GetItem(ctx, "200")
+		suite.NoError(err)
+		suite.Equal(now.In(time.UTC), item.Timestamp)
 	default:
 		suite.T().Skipf("unknown database: %v", reflect.TypeOf(suite.Database))
 	}
@@ -754,6 +765,34 @@ func TestValidateLabels(t *testing.T) {
 	assert.Error(t, ValidateLabels(map[string]any{"price": 100, "tags": []any{"1", "2", "3"}}))
 	assert.Error(t, ValidateLabels(map[string]any{"city": "wenzhou", "tags": []any{1, 2, 3}}))
 	assert.Error(t, ValidateLabels(map[string]any{"city": "wenzhou", "tags": []any{"1", "2", "1"}}))
+
+	// reserved location label
+	assert.NoError(t, ValidateLabels(map[string]any{"location": "28.0,120.7"}))
+	assert.NoError(t, ValidateLabels(map[string]any{"address": map[string]any{"location": "downtown"}}))
+	assert.Error(t, ValidateLabels(map[string]any{"location": "downtown"}))
+	assert.Error(t, ValidateLabels(map[string]any{"location": "91,120.7"}))
+}
+
+func TestParseLocation(t *testing.T) {
+	latitude, longitude, ok := ParseLocation(map[string]any{"location": "28.0, -120.7"})
+	assert.True(t, ok)
+	assert.Equal(t, 28.0, latitude)
+	assert.Equal(t, -120.7, longitude)
+	_, _, ok = ParseLocation(nil)
+	assert.False(t, ok)
+	_, _, ok = ParseLocation(map[string]any{"city": "wenzhou"})
+	assert.False(t, ok)
+	_, _, ok = ParseLocation(map[string]any{"location": "28.0"})
+	assert.False(t, ok)
+	_, _, ok = ParseLocation(map[string]any{"location": "28.0,181"})
+	assert.False(t, ok)
+}
+
+func TestExpandCategories(t *testing.T) {
+	assert.Equal(t, []string{"books", "books/fiction", "books/fiction/scifi", "books/history", "music"},
+		ExpandCategories([]string{"books/fiction/scifi", "books/history", "music", "books"}))
+	assert.Equal(t, []string{"/books"}, ExpandCategories([]string{"/books"}))
+	assert.Empty(t, ExpandCategories(nil))
 }
 
 func TestFlattenLabels(t *testing.T) {
@@ -767,4 +806,9 @@ func TestFlattenLabels(t *testing.T) {
 	assert.ElementsMatch(t, []string{"city.wenzhou", "tags.1", "tags.2", "tags.3"}, labels)
 	labels = FlattenLabels(map[string]any{"address": map[string]any{"province": "zhejiang", "city": "wenzhou"}})
 	assert.ElementsMatch(t, []string{"address.province.zhejiang", "address.city.wenzhou"}, labels)
+	// the reserved location label is excluded
+	labels = FlattenLabels(map[string]any{"city": "wenzhou", LocationLabel: "28.0,120.7"})
+	assert.ElementsMatch(t, []string{"city.wenzhou"}, labels)
+	labels = FlattenLabels(map[string]any{"address": map[string]any{"location": "downtown"}})
+	assert.ElementsMatch(t, []string{"address.location.downtown"}, labels)
 }
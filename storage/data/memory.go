@@ -0,0 +1,674 @@
+// Copyright 2023 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/samber/lo"
+	"github.com/zhenghaoz/gorse/base/log"
+	"github.com/zhenghaoz/gorse/storage"
+	"go.uber.org/zap"
+)
+
+// MemoryDatabase is the data storage in memory. It is designed for demos, tests and small deployments running in a
+// single process. If a path is given, data is written to a snapshot file periodically and reloaded at startup.
+type MemoryDatabase struct {
+	path     string
+	interval time.Duration
+	dirty    bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.RWMutex
+
+	users        map[string]User
+	items        map[string]Item
+	feedback     map[FeedbackKey]Feedback
+	userFeedback map[string]map[FeedbackKey]struct{}
+	itemFeedback map[string]map[FeedbackKey]struct{}
+}
+
+type memorySnapshot struct {
+	Users    []User
+	Items    []Item
+	Feedback []Feedback
+}
+
+// newMemoryDatabase creates a memory database from a DSN in the format of "path?snapshot_interval=1m". The snapshot
+// is disabled if the path is empty.
+func newMemoryDatabase(dsn string) (*MemoryDatabase, error) {
+	path, interval, err := storage.ParseMemoryDSN(dsn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	database := &MemoryDatabase{
+		path:     path,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	database.reset()
+	if database.path == "" {
+		return database, nil
+	}
+	// load snapshot
+	if err = database.load(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	database.wg.Add(1)
+	go func() {
+		defer database.wg.Done()
+		ticker := time.NewTicker(database.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-database.done:
+				return
+			case <-ticker.C:
+				if err := database.Snapshot(); err != nil {
+					log.Logger().Error("failed to write snapshot", zap.String("path", database.path), zap.Error(err))
+				}
+			}
+		}
+	}()
+	return database, nil
+}
+
+func (db *MemoryDatabase) reset() {
+	db.users = make(map[string]User)
+	db.items = make(map[string]Item)
+	db.feedback = make(map[FeedbackKey]Feedback)
+	db.userFeedback = make(map[string]map[FeedbackKey]struct{})
+	db.itemFeedback = make(map[string]map[FeedbackKey]struct{})
+}
+
+// load data from the snapshot file.
+func (db *MemoryDatabase) load() error {
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	var snapshot memorySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return errors.Trace(err)
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, user := range snapshot.Users {
+		db.users[user.UserId] = user
+	}
+	for _, item := range snapshot.Items {
+		db.items[item.ItemId] = item
+	}
+	for _, feedback := range snapshot.Feedback {
+		db.putFeedback(feedback)
+	}
+	return nil
+}
+
+// Snapshot writes data to the snapshot file if there are modifications since the last snapshot.
+func (db *MemoryDatabase) Snapshot() error {
+	if db.path == "" {
+		return nil
+	}
+	db.mutex.Lock()
+	if !db.dirty {
+		db.mutex.Unlock()
+		return nil
+	}
+	snapshot := memorySnapshot{
+		Users:    make([]User, 0, len(db.users)),
+		Items:    make([]Item, 0, len(db.items)),
+		Feedback: make([]Feedback, 0, len(db.feedback)),
+	}
+	for _, user := range db.users {
+		snapshot.Users = append(snapshot.Users, user)
+	}
+	for _, item := range db.items {
+		snapshot.Items = append(snapshot.Items, item)
+	}
+	for _, feedback := range db.feedback {
+		snapshot.Feedback = append(snapshot.Feedback, feedback)
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		db.mutex.Unlock()
+		return errors.Trace(err)
+	}
+	db.dirty = false
+	db.mutex.Unlock()
+	// write to a temporary file and rename it to keep the snapshot complete
+	temp := db.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err == nil {
+		err = os.Rename(temp, db.path)
+	}
+	if err != nil {
+		db.mutex.Lock()
+		db.dirty = true
+		db.mutex.Unlock()
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func (db *MemoryDatabase) Init() error {
+	return nil
+}
+
+func (db *MemoryDatabase) Ping() error {
+	return nil
+}
+
+// Close stops the snapshot loop and writes the last snapshot.
+func (db *MemoryDatabase) Close() error {
+	select {
+	case <-db.done:
+		return nil
+	default:
+		close(db.done)
+	}
+	db.wg.Wait()
+	return db.Snapshot()
+}
+
+func (db *MemoryDatabase) Purge() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.reset()
+	db.dirty = true
+	return nil
+}
+
+// BatchInsertItems inserts items into memory.
+func (db *MemoryDatabase) BatchInsertItems(_ context.Context, items []Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+	rows := make([]Item, len(items))
+	for i, item := range items {
+		labels, err := normalizeLabels(item.Labels)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		item.Labels = labels
+		item.Categories = cloneStrings(item.Categories)
+		item.Timestamp = normalizeTime(item.Timestamp)
+		rows[i] = item
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, item := range rows {
+		db.items[item.ItemId] = item
+	}
+	db.dirty = true
+	return nil
+}
+
+func (db *MemoryDatabase) BatchGetItems(_ context.Context, itemIds []string) ([]Item, error) {
+	if len(itemIds) == 0 {
+		return nil, nil
+	}
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	items := make([]Item, 0, len(itemIds))
+	for _, itemId := range itemIds {
+		if item, exist := db.items[itemId]; exist {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ItemId < items[j].ItemId
+	})
+	return items, nil
+}
+
+// DeleteItem deletes an item and its feedback from memory.
+func (db *MemoryDatabase) DeleteItem(_ context.Context, itemId string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	delete(db.items, itemId)
+	for key := range db.itemFeedback[itemId] {
+		db.deleteFeedback(key)
+	}
+	db.dirty = true
+	return nil
+}
+
+// GetItem returns an item from memory.
+func (db *MemoryDatabase) GetItem(_ context.Context, itemId string) (Item, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	item, exist := db.items[itemId]
+	if !exist {
+		return Item{}, errors.Annotate(ErrItemNotExist, itemId)
+	}
+	return item, nil
+}
+
+// ModifyItem modifies an item in memory.
+func (db *MemoryDatabase) ModifyItem(_ context.Context, itemId string, patch ItemPatch) error {
+	labels, err := normalizeLabels(patch.Labels)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	item, exist := db.items[itemId]
+	if !exist {
+		return nil
+	}
+	if patch.IsHidden != nil {
+		item.IsHidden = *patch.IsHidden
+	}
+	if patch.Categories != nil {
+		item.Categories = cloneStrings(patch.Categories)
+	}
+	if patch.Comment != nil {
+		item.Comment = *patch.Comment
+	}
+	if patch.Labels != nil {
+		item.Labels = labels
+	}
+	if patch.Timestamp != nil {
+		item.Timestamp = normalizeTime(*patch.Timestamp)
+	}
+	db.items[itemId] = item
+	db.dirty = true
+	return nil
+}
+
+// GetItems returns items from memory.
+func (db *MemoryDatabase) GetItems(_ context.Context, cursor string, n int, timeLimit *time.Time) (string, []Item, error) {
+	buf, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	cursorItem := string(buf)
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	itemIds := make([]string, 0, len(db.items))
+	for itemId, item := range db.items {
+		if itemId >= cursorItem && (timeLimit == nil || !item.Timestamp.Before(*timeLimit)) {
+			itemIds = append(itemIds, itemId)
+		}
+	}
+	sort.Strings(itemIds)
+	items := make([]Item, 0, n)
+	for _, itemId := range itemIds {
+		if len(items) == n {
+			return base64.StdEncoding.EncodeToString([]byte(itemId)), items, nil
+		}
+		items = append(items, db.items[itemId])
+	}
+	return "", items, nil
+}
+
+// GetItemFeedback returns feedback of an item from memory.
+func (db *MemoryDatabase) GetItemFeedback(_ context.Context, itemId string, feedbackTypes ...string) ([]Feedback, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	now := time.Now()
+	return filterFeedback(db.feedback, db.itemFeedback[itemId], nil, &now, feedbackTypes), nil
+}
+
+// BatchInsertUsers inserts users into memory.
+func (db *MemoryDatabase) BatchInsertUsers(_ context.Context, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	rows := make([]User, len(users))
+	for i, user := range users {
+		labels, err := normalizeLabels(user.Labels)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		user.Labels = labels
+		user.Subscribe = cloneStrings(user.Subscribe)
+		rows[i] = user
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	for _, user := range rows {
+		db.users[user.UserId] = user
+	}
+	db.dirty = true
+	return nil
+}
+
+// DeleteUser deletes a user and its feedback from memory.
+func (db *MemoryDatabase) DeleteUser(_ context.Context, userId string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	delete(db.users, userId)
+	for key := range db.userFeedback[userId] {
+		db.deleteFeedback(key)
+	}
+	db.dirty = true
+	return nil
+}
+
+// GetUser returns a user from memory.
+func (db *MemoryDatabase) GetUser(_ context.Context, userId string) (User, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	user, exist := db.users[userId]
+	if !exist {
+		return User{}, errors.Annotate(ErrUserNotExist, userId)
+	}
+	return user, nil
+}
+
+// ModifyUser modifies a user in memory.
+func (db *MemoryDatabase) ModifyUser(_ context.Context, userId string, patch UserPatch) error {
+	labels, err := normalizeLabels(patch.Labels)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	user, exist := db.users[userId]
+	if !exist {
+		return nil
+	}
+	if patch.Labels != nil {
+		user.Labels = labels
+	}
+	if patch.Comment != nil {
+		user.Comment = *patch.Comment
+	}
+	if patch.Subscribe != nil {
+		user.Subscribe = cloneStrings(patch.Subscribe)
+	}
+	db.users[userId] = user
+	db.dirty = true
+	return nil
+}
+
+// GetUsers returns users from memory.
+func (db *MemoryDatabase) GetUsers(_ context.Context, cursor string, n int) (string, []User, error) {
+	buf, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	cursorUser := string(buf)
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	userIds := make([]string, 0, len(db.users))
+	for userId := range db.users {
+		if userId >= cursorUser {
+			userIds = append(userIds, userId)
+		}
+	}
+	sort.Strings(userIds)
+	users := make([]User, 0, n)
+	for _, userId := range userIds {
+		if len(users) == n {
+			return base64.StdEncoding.EncodeToString([]byte(userId)), users, nil
+		}
+		users = append(users, db.users[userId])
+	}
+	return "", users, nil
+}
+
+// GetUserFeedback returns feedback of a user from memory.
+func (db *MemoryDatabase) GetUserFeedback(_ context.Context, userId string, endTime *time.Time, feedbackTypes ...string) ([]Feedback, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	return filterFeedback(db.feedback, db.userFeedback[userId], nil, endTime, feedbackTypes), nil
+}
+
+// GetUserItemFeedback returns feedback between a user and an item from memory.
+func (db *MemoryDatabase) GetUserItemFeedback(_ context.Context, userId, itemId string, feedbackTypes ...string) ([]Feedback, error) {
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	feedback := filterFeedback(db.feedback, db.userFeedback[userId], nil, nil, feedbackTypes)
+	result := make([]Feedback, 0, len(feedback))
+	for _, f := range feedback {
+		if f.ItemId == itemId {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// DeleteUserItemFeedback deletes feedback between a user and an item from memory.
+func (db *MemoryDatabase) DeleteUserItemFeedback(_ context.Context, userId, itemId string, feedbackTypes ...string) (int, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	feedback := filterFeedback(db.feedback, db.userFeedback[userId], nil, nil, feedbackTypes)
+	count := 0
+	for _, f := range feedback {
+		if f.ItemId == itemId {
+			db.deleteFeedback(f.FeedbackKey)
+			count++
+		}
+	}
+	db.dirty = true
+	return count, nil
+}
+
+// BatchInsertFeedback inserts feedback into memory.
+func (db *MemoryDatabase) BatchInsertFeedback(_ context.Context, feedback []Feedback, insertUser, insertItem, overwrite bool) error {
+	if len(feedback) == 0 {
+		return nil
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	memo := make(map[FeedbackKey]struct{}, len(feedback))
+	for _, f := range feedback {
+		// skip duplicate feedback in the same batch
+		if _, exist := memo[f.FeedbackKey]; exist {
+			continue
+		}
+		memo[f.FeedbackKey] = struct{}{}
+		// insert or check user
+		if _, exist := db.users[f.UserId]; !exist {
+			if !insertUser {
+				continue
+			}
+			db.users[f.UserId] = User{UserId: f.UserId}
+		}
+		// insert or check item
+		if _, exist := db.items[f.ItemId]; !exist {
+			if !insertItem {
+				continue
+			}
+			db.items[f.ItemId] = Item{ItemId: f.ItemId}
+		}
+		// insert feedback
+		if _, exist := db.feedback[f.FeedbackKey]; exist && !overwrite {
+			continue
+		}
+		f.Timestamp = normalizeTime(f.Timestamp)
+		db.putFeedback(f)
+	}
+	db.dirty = true
+	return nil
+}
+
+// GetFeedback returns feedback from memory.
+func (db *MemoryDatabase) GetFeedback(_ context.Context, cursor string, n int, beginTime, endTime *time.Time, feedbackTypes ...string) (string, []Feedback, error) {
+	buf, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+	var cursorKey *FeedbackKey
+	if len(buf) > 0 {
+		if cursorKey, err = feedbackKeyFromString(string(buf)); err != nil {
+			return "", nil, errors.Trace(err)
+		}
+	}
+	db.mutex.RLock()
+	defer db.mutex.RUnlock()
+	feedback := filterFeedback(db.feedback, db.feedback, beginTime, endTime, feedbackTypes)
+	if cursorKey != nil {
+		begin := sort.Search(len(feedback), func(i int) bool {
+			return !lessFeedbackKey(feedback[i].FeedbackKey, *cursorKey)
+		})
+		feedback = feedback[begin:]
+	}
+	if len(feedback) > n {
+		nextCursor, err := feedback[n].toString()
+		if err != nil {
+			return "", nil, errors.Trace(err)
+		}
+		return base64.StdEncoding.EncodeToString([]byte(nextCursor)), feedback[:n], nil
+	}
+	return "", feedback, nil
+}
+
+// GetUserStream reads users from memory by stream.
+func (db *MemoryDatabase) GetUserStream(_ context.Context, batchSize int) (chan []User, chan error) {
+	db.mutex.RLock()
+	users := make([]User, 0, len(db.users))
+	for _, user := range db.users {
+		users = append(users, user)
+	}
+	db.mutex.RUnlock()
+	return streamBatches(users, batchSize)
+}
+
+// GetItemStream reads items from memory by stream.
+func (db *MemoryDatabase) GetItemStream(_ context.Context, batchSize int, timeLimit *time.Time) (chan []Item, chan error) {
+	db.mutex.RLock()
+	items := make([]Item, 0, len(db.items))
+	for _, item := range db.items {
+		if timeLimit == nil || !item.Timestamp.Before(*timeLimit) {
+			items = append(items, item)
+		}
+	}
+	db.mutex.RUnlock()
+	return streamBatches(items, batchSize)
+}
+
+// GetFeedbackStream reads feedback from memory by stream.
+func (db *MemoryDatabase) GetFeedbackStream(_ context.Context, batchSize int, beginTime, endTime *time.Time, feedbackTypes ...string) (chan []Feedback, chan error) {
+	db.mutex.RLock()
+	feedback := filterFeedback(db.feedback, db.feedback, beginTime, endTime, feedbackTypes)
+	db.mutex.RUnlock()
+	return streamBatches(feedback, batchSize)
+}
+
+func (db *MemoryDatabase) putFeedback(feedback Feedback) {
+	key := feedback.FeedbackKey
+	db.feedback[key] = feedback
+	if db.userFeedback[key.UserId] == nil {
+		db.userFeedback[key.UserId] = make(map[FeedbackKey]struct{})
+	}
+	db.userFeedback[key.UserId][key] = struct{}{}
+	if db.itemFeedback[key.ItemId] == nil {
+		db.itemFeedback[key.ItemId] = make(map[FeedbackKey]struct{})
+	}
+	db.itemFeedback[key.ItemId][key] = struct{}{}
+}
+
+func (db *MemoryDatabase) deleteFeedback(key FeedbackKey) {
+	delete(db.feedback, key)
+	delete(db.userFeedback[key.UserId], key)
+	if len(db.userFeedback[key.UserId]) == 0 {
+		delete(db.userFeedback, key.UserId)
+	}
+	delete(db.itemFeedback[key.ItemId], key)
+	if len(db.itemFeedback[key.ItemId]) == 0 {
+		delete(db.itemFeedback, key.ItemId)
+	}
+}
+
+// filterFeedback returns feedback of given keys sorted by feedback keys.
+func filterFeedback[T any](feedback map[FeedbackKey]Feedback, keys map[FeedbackKey]T, beginTime, endTime *time.Time, feedbackTypes []string) []Feedback {
+	result := make([]Feedback, 0, len(keys))
+	for key := range keys {
+		if len(feedbackTypes) > 0 && !lo.Contains(feedbackTypes, key.FeedbackType) {
+			continue
+		}
+		f := feedback[key]
+		if beginTime != nil && f.Timestamp.Before(*beginTime) {
+			continue
+		}
+		if endTime != nil && f.Timestamp.After(*endTime) {
+			continue
+		}
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lessFeedbackKey(result[i].FeedbackKey, result[j].FeedbackKey)
+	})
+	return result
+}
+
+func lessFeedbackKey(a, b FeedbackKey) bool {
+	if a.FeedbackType != b.FeedbackType {
+		return a.FeedbackType < b.FeedbackType
+	}
+	if a.UserId != b.UserId {
+		return a.UserId < b.UserId
+	}
+	return a.ItemId < b.ItemId
+}
+
+func streamBatches[T any](rows []T, batchSize int) (chan []T, chan error) {
+	rowChan := make(chan []T, bufSize)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(rowChan)
+		defer close(errChan)
+		for begin := 0; begin < len(rows); begin += batchSize {
+			end := begin + batchSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+			rowChan <- rows[begin:end]
+		}
+		errChan <- nil
+	}()
+	return rowChan, errChan
+}
+
+// normalizeLabels converts labels to the same types as labels decoded from JSON.
+func normalizeLabels(labels any) (any, error) {
+	if labels == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var normalized any
+	if err = json.Unmarshal(data, &normalized); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return normalized, nil
+}
+
+// normalizeTime strips the monotonic clock reading and converts the timestamp to UTC.
+func normalizeTime(t time.Time) time.Time {
+	return t.Round(0).UTC()
+}
+
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
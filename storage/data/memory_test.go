@@ -0,0 +1,79 @@
+// Copyright 2021 gorse Project Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MemoryTestSuite struct {
+	baseTestSuite
+}
+
+func (suite *MemoryTestSuite) SetupSuite() {
+	var err error
+	// create database
+	path := fmt.Sprintf("memory://%s/data.json", suite.T().TempDir())
+	suite.Database, err = Open(path, "")
+	suite.NoError(err)
+	// create schema
+	err = suite.Database.Init()
+	suite.NoError(err)
+}
+
+func (suite *MemoryTestSuite) TearDownSuite() {
+	suite.NoError(suite.Database.Close())
+}
+
+func TestMemory(t *testing.T) {
+	suite.Run(t, new(MemoryTestSuite))
+}
+
+func TestMemoryDatabase_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	path := fmt.Sprintf("memory://%s/data.json?snapshot_interval=1h", t.TempDir())
+	timestamp := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// insert data and close
+	database, err := Open(path, "")
+	assert.NoError(t, err)
+	err = database.BatchInsertItems(ctx, []Item{{ItemId: "1", Categories: []string{"a"}, Timestamp: timestamp, Labels: []any{"x"}}})
+	assert.NoError(t, err)
+	err = database.BatchInsertUsers(ctx, []User{{UserId: "1", Labels: map[string]any{"gender": "F"}}})
+	assert.NoError(t, err)
+	err = database.BatchInsertFeedback(ctx, []Feedback{{FeedbackKey: FeedbackKey{"star", "1", "1"}, Timestamp: timestamp, Value: 3}}, false, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, database.Close())
+
+	// reopen from snapshot
+	database, err = Open(path, "")
+	assert.NoError(t, err)
+	item, err := database.GetItem(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, Item{ItemId: "1", Categories: []string{"a"}, Timestamp: timestamp, Labels: []any{"x"}}, item)
+	user, err := database.GetUser(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, User{UserId: "1", Labels: map[string]any{"gender": "F"}}, user)
+	feedback, err := database.GetUserFeedback(ctx, "1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Feedback{{FeedbackKey: FeedbackKey{"star", "1", "1"}, Timestamp: timestamp, Value: 3}}, feedback)
+	assert.NoError(t, database.Close())
+}
@@ -112,11 +112,13 @@ func (d *SQLDatabase) Init() error {
 			Comment   string   `gorm:"column:comment;type:text;not null"`
 		}
 		type Feedback struct {
-			FeedbackType string    `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
-			UserId       string    `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id"`
-			ItemId       string    `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id"`
-			Timestamp    time.Time `gorm:"column:time_stamp;type:datetime;not null"`
-			Comment      string    `gorm:"column:comment;type:text;not null"`
+			FeedbackType  string    `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
+			UserId        string    `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id"`
+			ItemId        string    `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id"`
+			Timestamp     time.Time `gorm:"column:time_stamp;type:datetime;not null"`
+			Comment       string    `gorm:"column:comment;type:text;not null"`
+			Value         float64   `gorm:"column:value;type:double;not null;default:0"`
+			ContextLabels []string  `gorm:"column:context_labels;type:json"`
 		}
 		err := d.gormDB.Set("gorm:table_options", "ENGINE=InnoDB").AutoMigrate(Users{}, Items{}, Feedback{})
 		if err != nil {
@@ -139,11 +141,13 @@ func (d *SQLDatabase) Init() error {
 			Comment   string `gorm:"column:comment;type:text;not null;default:''"`
 		}
 		type Feedback struct {
-			FeedbackType string    `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
-			UserId       string    `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id_index"`
-			ItemId       string    `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id_index"`
-			Timestamp    time.Time `gorm:"column:time_stamp;type:timestamptz;not null"`
-			Comment      string    `gorm:"column:comment;type:text;not null;default:''"`
+			FeedbackType  string    `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
+			UserId        string    `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id_index"`
+			ItemId        string    `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id_index"`
+			Timestamp     time.Time `gorm:"column:time_stamp;type:timestamptz;not null"`
+			Comment       string    `gorm:"column:comment;type:text;not null;default:''"`
+			Value         float64   `gorm:"column:value;type:double precision;not null;default:0"`
+			ContextLabels string    `gorm:"column:context_labels;type:json;not null;default:'null'"`
 		}
 		err := d.gormDB.AutoMigrate(Users{}, Items{}, Feedback{})
 		if err != nil {
@@ -166,11 +170,13 @@ func (d *SQLDatabase) Init() error {
 			Comment   string `gorm:"column:comment;type:text;not null;default:''"`
 		}
 		type Feedback struct {
-			FeedbackType string `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
-			UserId       string `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id_index"`
-			ItemId       string `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id_index"`
-			Timestamp    string `gorm:"column:time_stamp;type:datetime;not null;default:'0001-01-01'"`
-			Comment      string `gorm:"column:comment;type:text;not null;default:''"`
+			FeedbackType  string  `gorm:"column:feedback_type;type:varchar(256);not null;primaryKey"`
+			UserId        string  `gorm:"column:user_id;type:varchar(256);not null;primaryKey;index:user_id_index"`
+			ItemId        string  `gorm:"column:item_id;type:varchar(256);not null;primaryKey;index:item_id_index"`
+			Timestamp     string  `gorm:"column:time_stamp;type:datetime;not null;default:'0001-01-01'"`
+			Comment       string  `gorm:"column:comment;type:text;not null;default:''"`
+			Value         float64 `gorm:"column:value;type:real;not null;default:0"`
+			ContextLabels string  `gorm:"column:context_labels;type:json;not null;default:'null'"`
 		}
 		err := d.gormDB.AutoMigrate(Users{}, Items{}, Feedback{})
 		if err != nil {
@@ -389,7 +395,7 @@ func (d *SQLDatabase) GetItemStream(ctx context.Context, batchSize int, timeLimi
 
 // GetItemFeedback returns feedback of a item from MySQL.
 func (d *SQLDatabase) GetItemFeedback(ctx context.Context, itemId string, feedbackTypes ...string) ([]Feedback, error) {
-	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).Select("user_id, item_id, feedback_type, time_stamp")
+	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).Select("user_id, item_id, feedback_type, time_stamp, comment, value, context_labels")
 	switch d.driver {
 	case SQLite:
 		tx.Where("time_stamp <= DATETIME() AND item_id = ?", itemId)
@@ -559,7 +565,7 @@ func (d *SQLDatabase) GetUserStream(ctx context.Context, batchSize int) (chan []
 // GetUserFeedback returns feedback of a user from MySQL.
 func (d *SQLDatabase) GetUserFeedback(ctx context.Context, userId string, endTime *time.Time, feedbackTypes ...string) ([]Feedback, error) {
 	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).
-		Select("feedback_type, user_id, item_id, time_stamp, comment").
+		Select("feedback_type, user_id, item_id, time_stamp, comment, value, context_labels").
 		Where("user_id = ?", userId)
 	if endTime != nil {
 		tx.Where("time_stamp <= ?", d.convertTimeZone(endTime))
@@ -677,7 +683,7 @@ func (d *SQLDatabase) BatchInsertFeedback(ctx context.Context, feedback []Feedba
 	err := tx.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "feedback_type"}, {Name: "user_id"}, {Name: "item_id"}},
 		DoNothing: !overwrite,
-		DoUpdates: lo.If(overwrite, clause.AssignmentColumns([]string{"time_stamp", "comment"})).Else(nil),
+		DoUpdates: lo.If(overwrite, clause.AssignmentColumns([]string{"time_stamp", "comment", "value", "context_labels"})).Else(nil),
 	}).Create(rows).Error
 	return errors.Trace(err)
 }
@@ -688,7 +694,7 @@ func (d *SQLDatabase) GetFeedback(ctx context.Context, cursor string, n int, beg
 	if err != nil {
 		return "", nil, errors.Trace(err)
 	}
-	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).Select("feedback_type, user_id, item_id, time_stamp, comment")
+	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).Select("feedback_type, user_id, item_id, time_stamp, comment, value, context_labels")
 	if len(buf) > 0 {
 		var cursorKey FeedbackKey
 		if err := json.Unmarshal(buf, &cursorKey); err != nil {
@@ -739,7 +745,7 @@ func (d *SQLDatabase) GetFeedbackStream(ctx context.Context, batchSize int, begi
 		defer close(errChan)
 		// send query
 		tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).
-			Select("feedback_type, user_id, item_id, time_stamp, comment").
+			Select("feedback_type, user_id, item_id, time_stamp, comment, value, context_labels").
 			Order("feedback_type, user_id, item_id")
 		if len(feedbackTypes) > 0 {
 			tx.Where("feedback_type IN ?", feedbackTypes)
@@ -781,7 +787,7 @@ func (d *SQLDatabase) GetFeedbackStream(ctx context.Context, batchSize int, begi
 // GetUserItemFeedback gets a feedback by user id and item id from MySQL.
 func (d *SQLDatabase) GetUserItemFeedback(ctx context.Context, userId, itemId string, feedbackTypes ...string) ([]Feedback, error) {
 	tx := d.gormDB.WithContext(ctx).Table(d.FeedbackTable()).
-		Select("feedback_type, user_id, item_id, time_stamp, comment").
+		Select("feedback_type, user_id, item_id, time_stamp, comment, value, context_labels").
 		Where("user_id = ? AND item_id = ?", userId, itemId)
 	if len(feedbackTypes) > 0 {
 		tx.Where("feedback_type IN ?", feedbackTypes)
@@ -4,6 +4,7 @@ import (
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestAppendURLParams(t *testing.T) {
@@ -16,3 +17,16 @@ func TestAppendURLParams(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, `sqlite.db?a=b`, url)
 }
+
+func TestParseMemoryDSN(t *testing.T) {
+	path, interval, err := ParseMemoryDSN("")
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+	assert.Equal(t, DefaultSnapshotInterval, interval)
+	path, interval, err = ParseMemoryDSN("/tmp/gorse.json?snapshot_interval=10s")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/gorse.json", path)
+	assert.Equal(t, 10*time.Second, interval)
+	_, _, err = ParseMemoryDSN("/tmp/gorse.json?snapshot_interval=-1s")
+	assert.Error(t, err)
+}
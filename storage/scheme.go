@@ -18,6 +18,7 @@ import (
 	"database/sql"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/juju/errors"
@@ -35,10 +36,34 @@ const (
 	PostgresPrefix   = "postgres://"
 	PostgreSQLPrefix = "postgresql://"
 	SQLitePrefix     = "sqlite://"
+	MemoryPrefix     = "memory://"
 	RedisPrefix      = "redis://"
 	RedissPrefix     = "rediss://"
 )
 
+// DefaultSnapshotInterval is the default interval to write snapshots of memory databases.
+const DefaultSnapshotInterval = time.Minute
+
+// ParseMemoryDSN parses the DSN of memory databases in the format of "path?snapshot_interval=1m". The path is empty
+// if snapshots are disabled.
+func ParseMemoryDSN(dsn string) (path string, interval time.Duration, err error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", 0, errors.Trace(err)
+	}
+	interval = DefaultSnapshotInterval
+	if value := query.Get("snapshot_interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			return "", 0, errors.Trace(err)
+		}
+		if interval <= 0 {
+			return "", 0, errors.NotValidf("snapshot interval %v", interval)
+		}
+	}
+	return path, interval, nil
+}
+
 func AppendURLParams(rawURL string, params []lo.Tuple2[string, string]) (string, error) {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
@@ -41,6 +41,7 @@ import (
 	"github.com/zhenghaoz/gorse/base/task"
 	"github.com/zhenghaoz/gorse/cmd/version"
 	"github.com/zhenghaoz/gorse/config"
+	"github.com/zhenghaoz/gorse/model/bandit"
 	"github.com/zhenghaoz/gorse/model/click"
 	"github.com/zhenghaoz/gorse/model/ranking"
 	"github.com/zhenghaoz/gorse/protocol"
@@ -457,6 +458,20 @@ func (w *Worker) Recommend(users []data.User) {
 	MemoryInuseBytesVec.WithLabelValues("item_cache").Set(float64(itemCache.Bytes()))
 	defer MemoryInuseBytesVec.WithLabelValues("item_cache").Set(0)
 
+	// load bandit state
+	banditState, err := bandit.LoadState(ctx, w.CacheClient)
+	if err != nil {
+		log.Logger().Error("failed to load bandit state", zap.Error(err))
+		return
+	}
+	// random generators and bandit states are owned by jobs
+	jobRandGenerators := make([]*rand.Rand, w.jobs)
+	jobBanditStates := make([]*bandit.State, w.jobs)
+	for i := 0; i < w.jobs; i++ {
+		jobRandGenerators[i] = rand.New(rand.NewSource(w.randGenerator.Int63()))
+		jobBanditStates[i] = banditState.Clone()
+	}
+
 	// progress tracker
 	completed := make(chan struct{}, 1000)
 	recommendTaskName := "Generate offline recommendation"
@@ -541,7 +556,7 @@ func (w *Worker) Recommend(users []data.User) {
 		popularRecommendSeconds       atomic.Float64
 	)
 
-	userFeedbackCache := NewFeedbackCache(w, w.Config.Recommend.DataSource.PositiveFeedbackTypes...)
+	offlineScenes := w.offlineScenes()
 	defer MemoryInuseBytesVec.WithLabelValues("user_feedback_cache").Set(0)
 	err = parallel.Parallel(len(users), w.jobs, func(workerId, jobId int) error {
 		defer func() {
@@ -550,7 +565,10 @@ func (w *Worker) Recommend(users []data.User) {
 		user := users[jobId]
 		userId := user.UserId
 		// skip inactive users before max recommend period
-		if !w.checkRecommendCacheTimeout(ctx, userId, itemCategories) {
+		scenes := lo.Filter(offlineScenes, func(scene offlineScene, _ int) bool {
+			return w.checkRecommendCacheTimeout(ctx, userId, itemCategories, scene)
+		})
+		if len(scenes) == 0 {
 			return nil
 		}
 		updateUserCount.Add(1)
@@ -563,253 +581,303 @@ func (w *Worker) Recommend(users []data.User) {
 				zap.String("user_id", userId), zap.Error(err))
 			return errors.Trace(err)
 		}
-
-		// load positive items
-		var positiveItems []string
-		if w.Config.Recommend.Offline.EnableItemBasedRecommend {
-			positiveItems, err = userFeedbackCache.GetUserFeedback(ctx, userId)
-			if err != nil {
-				log.Logger().Error("failed to pull user feedback",
-					zap.String("user_id", userId), zap.Error(err))
-				return errors.Trace(err)
+		negativeItems := w.negativeItems(feedbacks)
+
+		// generate offline recommendation for each scene
+		for _, scene := range scenes {
+			// load positive items
+			var positiveItems []string
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "item_based") {
+				positiveItems, err = scene.feedbackCache.GetUserFeedback(ctx, userId)
+				if err != nil {
+					log.Logger().Error("failed to pull user feedback",
+						zap.String("user_id", userId), zap.Error(err))
+					return errors.Trace(err)
+				}
+				MemoryInuseBytesVec.WithLabelValues("user_feedback_cache").Set(float64(scene.feedbackCache.Bytes()))
 			}
-			MemoryInuseBytesVec.WithLabelValues("user_feedback_cache").Set(float64(userFeedbackCache.Bytes()))
-		}
 
-		// create candidates container
-		candidates := make(map[string][][]string)
-		candidates[""] = make([][]string, 0)
-		for _, category := range itemCategories {
-			candidates[category] = make([][]string, 0)
-		}
+			// assign experiment variants
+			variants := scene.variants(w.Config.Recommend.AssignVariants(userId))
 
-		// Recommender #1: collaborative filtering.
-		collaborativeUsed := false
-		if w.Config.Recommend.Offline.EnableColRecommend && w.RankingModel != nil && !w.RankingModel.Invalid() {
-			if userIndex := w.RankingModel.GetUserIndex().ToNumber(userId); w.RankingModel.IsUserPredictable(userIndex) {
-				var recommend map[string][]string
-				var usedTime time.Duration
-				if w.Config.Recommend.Collaborative.EnableIndex && w.rankingIndex != nil {
-					recommend, usedTime, err = w.collaborativeRecommendHNSW(w.rankingIndex, userId, itemCategories, excludeSet, itemCache)
-				} else {
-					recommend, usedTime, err = w.collaborativeRecommendBruteForce(userId, itemCategories, excludeSet, itemCache)
+			// create candidates container
+			candidates := make(map[string][][]string)
+			candidates[""] = make([][]string, 0)
+			for _, category := range itemCategories {
+				candidates[category] = make([][]string, 0)
+			}
+			// sources record the first recommender produced each candidate and the item or user leading to it
+			sources := make(map[string]cache.Document)
+			addSource := func(source string, itemIds []string, reasons map[string]cache.Document) {
+				for _, itemId := range itemIds {
+					if _, exist := sources[itemId]; !exist {
+						sources[itemId] = cache.Document{Source: source, Reason: reasons[itemId].Id}
+					}
 				}
-				if err != nil {
-					log.Logger().Error("failed to recommend by collaborative filtering",
-						zap.String("user_id", userId), zap.Error(err))
-					return errors.Trace(err)
+			}
+
+			// Recommender #1: collaborative filtering.
+			collaborativeUsed := false
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "collaborative") && w.RankingModel != nil && !w.RankingModel.Invalid() {
+				if userIndex := w.RankingModel.GetUserIndex().ToNumber(userId); w.RankingModel.IsUserPredictable(userIndex) {
+					var recommend map[string][]string
+					var usedTime time.Duration
+					if w.Config.Recommend.Collaborative.EnableIndex && w.rankingIndex != nil {
+						recommend, usedTime, err = w.collaborativeRecommendHNSW(w.rankingIndex, userId, itemCategories, excludeSet, itemCache)
+					} else {
+						recommend, usedTime, err = w.collaborativeRecommendBruteForce(userId, itemCategories, excludeSet, itemCache)
+					}
+					if err != nil {
+						log.Logger().Error("failed to recommend by collaborative filtering",
+							zap.String("user_id", userId), zap.Error(err))
+						return errors.Trace(err)
+					}
+					for category, items := range recommend {
+						candidates[category] = append(candidates[category], items)
+						addSource("collaborative", items, nil)
+					}
+					collaborativeUsed = true
+					collaborativeRecommendSeconds.Add(usedTime.Seconds())
+				} else if !w.RankingModel.IsUserPredictable(userIndex) {
+					log.Logger().Debug("user is unpredictable", zap.String("user_id", userId))
 				}
-				for category, items := range recommend {
-					candidates[category] = append(candidates[category], items)
+			} else if w.RankingModel == nil || w.RankingModel.Invalid() {
+				log.Logger().Debug("no collaborative filtering model")
+			}
+
+			// Recommender #2: item-based.
+			itemNeighborDigests := mapset.NewSet[string]()
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "item_based") {
+				localStartTime := time.Now()
+				for _, category := range append([]string{""}, itemCategories...) {
+					// collect candidates
+					scores := make(map[string]float64)
+					reasons := make(map[string]cache.Document)
+					for _, itemId := range positiveItems {
+						// load similar items
+						similarItems, err := w.CacheClient.SearchDocuments(ctx, cache.ItemNeighbors, itemId, []string{category}, 0, w.Config.Recommend.CacheSize)
+						if err != nil {
+							log.Logger().Error("failed to load similar items", zap.Error(err))
+							return errors.Trace(err)
+						}
+						// add unseen items
+						for _, item := range similarItems {
+							if !excludeSet.Contains(item.Id) && itemCache.IsAvailable(item.Id) {
+								scores[item.Id] += item.Score
+								updateReason(reasons, item.Id, cache.Document{Id: itemId, Score: item.Score})
+							}
+						}
+						// load item neighbors digest
+						digest, err := w.CacheClient.Get(ctx, cache.Key(cache.ItemNeighborsDigest, itemId)).String()
+						if err != nil {
+							if !errors.Is(err, errors.NotFound) {
+								log.Logger().Error("failed to load item neighbors digest", zap.Error(err))
+								return errors.Trace(err)
+							}
+						}
+						itemNeighborDigests.Add(digest)
+					}
+					// collect top k
+					filter := heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
+					for id, score := range scores {
+						filter.Push(id, score)
+					}
+					ids, _ := filter.PopAll()
+					candidates[category] = append(candidates[category], ids)
+					addSource("item_based", ids, reasons)
 				}
-				collaborativeUsed = true
-				collaborativeRecommendSeconds.Add(usedTime.Seconds())
-			} else if !w.RankingModel.IsUserPredictable(userIndex) {
-				log.Logger().Debug("user is unpredictable", zap.String("user_id", userId))
+				itemBasedRecommendSeconds.Add(time.Since(localStartTime).Seconds())
 			}
-		} else if w.RankingModel == nil || w.RankingModel.Invalid() {
-			log.Logger().Debug("no collaborative filtering model")
-		}
 
-		// Recommender #2: item-based.
-		itemNeighborDigests := mapset.NewSet[string]()
-		if w.Config.Recommend.Offline.EnableItemBasedRecommend {
-			localStartTime := time.Now()
-			for _, category := range append([]string{""}, itemCategories...) {
-				// collect candidates
+			// Recommender #3: insert user-based items
+			userNeighborDigests := mapset.NewSet[string]()
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "user_based") {
+				localStartTime := time.Now()
 				scores := make(map[string]float64)
-				for _, itemId := range positiveItems {
-					// load similar items
-					similarItems, err := w.CacheClient.SearchDocuments(ctx, cache.ItemNeighbors, itemId, []string{category}, 0, w.Config.Recommend.CacheSize)
+				reasons := make(map[string]cache.Document)
+				// load similar users
+				similarUsers, err := w.CacheClient.SearchDocuments(ctx, cache.UserNeighbors, userId, []string{""}, 0, w.Config.Recommend.CacheSize)
+				if err != nil {
+					log.Logger().Error("failed to load similar users", zap.Error(err))
+					return errors.Trace(err)
+				}
+				for _, user := range similarUsers {
+					// load historical feedback
+					similarUserPositiveItems, err := scene.feedbackCache.GetUserFeedback(ctx, user.Id)
 					if err != nil {
-						log.Logger().Error("failed to load similar items", zap.Error(err))
+						log.Logger().Error("failed to pull user feedback",
+							zap.String("user_id", userId), zap.Error(err))
 						return errors.Trace(err)
 					}
+					MemoryInuseBytesVec.WithLabelValues("user_feedback_cache").Set(float64(scene.feedbackCache.Bytes()))
 					// add unseen items
-					for _, item := range similarItems {
-						if !excludeSet.Contains(item.Id) && itemCache.IsAvailable(item.Id) {
-							scores[item.Id] += item.Score
+					for _, itemId := range similarUserPositiveItems {
+						if !excludeSet.Contains(itemId) && itemCache.IsAvailable(itemId) {
+							scores[itemId] += user.Score
+							updateReason(reasons, itemId, cache.Document{Id: user.Id, Score: user.Score})
 						}
 					}
-					// load item neighbors digest
-					digest, err := w.CacheClient.Get(ctx, cache.Key(cache.ItemNeighborsDigest, itemId)).String()
+					// load user neighbors digest
+					digest, err := w.CacheClient.Get(ctx, cache.Key(cache.UserNeighborsDigest, user.Id)).String()
 					if err != nil {
 						if !errors.Is(err, errors.NotFound) {
-							log.Logger().Error("failed to load item neighbors digest", zap.Error(err))
+							log.Logger().Error("failed to load user neighbors digest", zap.Error(err))
 							return errors.Trace(err)
 						}
 					}
-					itemNeighborDigests.Add(digest)
+					userNeighborDigests.Add(digest)
 				}
 				// collect top k
-				filter := heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
+				filters := make(map[string]*heap.TopKFilter[string, float64])
+				filters[""] = heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
+				for _, category := range itemCategories {
+					filters[category] = heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
+				}
 				for id, score := range scores {
-					filter.Push(id, score)
+					filters[""].Push(id, score)
+					for _, category := range itemCache.GetCategory(id) {
+						filters[category].Push(id, score)
+					}
+				}
+				for category, filter := range filters {
+					ids, _ := filter.PopAll()
+					candidates[category] = append(candidates[category], ids)
+					addSource("user_based", ids, reasons)
 				}
-				ids, _ := filter.PopAll()
-				candidates[category] = append(candidates[category], ids)
+				userBasedRecommendSeconds.Add(time.Since(localStartTime).Seconds())
 			}
-			itemBasedRecommendSeconds.Add(time.Since(localStartTime).Seconds())
-		}
 
-		// Recommender #3: insert user-based items
-		userNeighborDigests := mapset.NewSet[string]()
-		if w.Config.Recommend.Offline.EnableUserBasedRecommend {
-			localStartTime := time.Now()
-			scores := make(map[string]float64)
-			// load similar users
-			similarUsers, err := w.CacheClient.SearchDocuments(ctx, cache.UserNeighbors, userId, []string{""}, 0, w.Config.Recommend.CacheSize)
-			if err != nil {
-				log.Logger().Error("failed to load similar users", zap.Error(err))
-				return errors.Trace(err)
-			}
-			for _, user := range similarUsers {
-				// load historical feedback
-				similarUserPositiveItems, err := userFeedbackCache.GetUserFeedback(ctx, user.Id)
-				if err != nil {
-					log.Logger().Error("failed to pull user feedback",
-						zap.String("user_id", userId), zap.Error(err))
-					return errors.Trace(err)
-				}
-				MemoryInuseBytesVec.WithLabelValues("user_feedback_cache").Set(float64(userFeedbackCache.Bytes()))
-				// add unseen items
-				for _, itemId := range similarUserPositiveItems {
-					if !excludeSet.Contains(itemId) && itemCache.IsAvailable(itemId) {
-						scores[itemId] += user.Score
-					}
-				}
-				// load user neighbors digest
-				digest, err := w.CacheClient.Get(ctx, cache.Key(cache.UserNeighborsDigest, user.Id)).String()
-				if err != nil {
-					if !errors.Is(err, errors.NotFound) {
-						log.Logger().Error("failed to load user neighbors digest", zap.Error(err))
+			// Recommender #4: latest items.
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "latest") {
+				localStartTime := time.Now()
+				for _, category := range append([]string{""}, itemCategories...) {
+					latestItems, err := w.CacheClient.SearchDocuments(ctx, cache.LatestItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
+					if err != nil {
+						log.Logger().Error("failed to load latest items", zap.Error(err))
 						return errors.Trace(err)
 					}
+					var recommend []string
+					for _, latestItem := range latestItems {
+						if !excludeSet.Contains(latestItem.Id) && itemCache.IsAvailable(latestItem.Id) {
+							recommend = append(recommend, latestItem.Id)
+						}
+					}
+					candidates[category] = append(candidates[category], recommend)
+					addSource("latest", recommend, nil)
 				}
-				userNeighborDigests.Add(digest)
+				latestRecommendSeconds.Add(time.Since(localStartTime).Seconds())
 			}
-			// collect top k
-			filters := make(map[string]*heap.TopKFilter[string, float64])
-			filters[""] = heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
-			for _, category := range itemCategories {
-				filters[category] = heap.NewTopKFilter[string, float64](w.Config.Recommend.CacheSize)
-			}
-			for id, score := range scores {
-				filters[""].Push(id, score)
-				for _, category := range itemCache.GetCategory(id) {
-					filters[category].Push(id, score)
+
+			// Recommender #5: popular items.
+			if w.Config.Recommend.GetEnableOfflineRecommender(scene.config, "popular") {
+				localStartTime := time.Now()
+				for _, category := range append([]string{""}, itemCategories...) {
+					popularItems, err := w.CacheClient.SearchDocuments(ctx, cache.PopularItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
+					if err != nil {
+						log.Logger().Error("failed to load popular items", zap.Error(err))
+						return errors.Trace(err)
+					}
+					var recommend []string
+					for _, popularItem := range popularItems {
+						if !excludeSet.Contains(popularItem.Id) && itemCache.IsAvailable(popularItem.Id) {
+							recommend = append(recommend, popularItem.Id)
+						}
+					}
+					candidates[category] = append(candidates[category], recommend)
+					addSource("popular", recommend, nil)
 				}
+				popularRecommendSeconds.Add(time.Since(localStartTime).Seconds())
 			}
-			for category, filter := range filters {
-				ids, _ := filter.PopAll()
-				candidates[category] = append(candidates[category], ids)
-			}
-			userBasedRecommendSeconds.Add(time.Since(localStartTime).Seconds())
-		}
 
-		// Recommender #4: latest items.
-		if w.Config.Recommend.Offline.EnableLatestRecommend {
-			localStartTime := time.Now()
-			for _, category := range append([]string{""}, itemCategories...) {
-				latestItems, err := w.CacheClient.SearchDocuments(ctx, cache.LatestItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
-				if err != nil {
-					log.Logger().Error("failed to load latest items", zap.Error(err))
-					return errors.Trace(err)
-				}
-				var recommend []string
-				for _, latestItem := range latestItems {
-					if !excludeSet.Contains(latestItem.Id) && itemCache.IsAvailable(latestItem.Id) {
-						recommend = append(recommend, latestItem.Id)
+			// rank items from different recommenders
+			// 1. If click-through rate prediction model is available, use it to rank items.
+			// 2. If collaborative filtering model is available, use it to rank items.
+			// 3. Otherwise, merge all recommenders' results randomly.
+			ctrUsed := false
+			results := make(map[string][]cache.Document)
+			for category, catCandidates := range candidates {
+				if w.Config.Recommend.GetEnableClickThroughPrediction(variants) && w.ClickModel != nil && !w.ClickModel.Invalid() {
+					results[category], err = w.rankByClickTroughRate(&user, catCandidates, itemCache)
+					if err != nil {
+						log.Logger().Error("failed to rank items", zap.Error(err))
+						return errors.Trace(err)
 					}
+					ctrUsed = true
+				} else if w.RankingModel != nil && !w.RankingModel.Invalid() &&
+					w.RankingModel.IsUserPredictable(w.RankingModel.GetUserIndex().ToNumber(userId)) {
+					results[category], err = w.rankByCollaborativeFiltering(userId, catCandidates)
+					if err != nil {
+						log.Logger().Error("failed to rank items", zap.Error(err))
+						return errors.Trace(err)
+					}
+				} else {
+					results[category] = w.mergeAndShuffle(catCandidates)
+				}
+				for i := range results[category] {
+					source := sources[results[category][i].Id]
+					results[category][i].Source = source.Source
+					results[category][i].Reason = source.Reason
 				}
-				candidates[category] = append(candidates[category], recommend)
 			}
-			latestRecommendSeconds.Add(time.Since(localStartTime).Seconds())
-		}
 
-		// Recommender #5: popular items.
-		if w.Config.Recommend.Offline.EnablePopularRecommend {
-			localStartTime := time.Now()
-			for _, category := range append([]string{""}, itemCategories...) {
-				popularItems, err := w.CacheClient.SearchDocuments(ctx, cache.PopularItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
-				if err != nil {
-					log.Logger().Error("failed to load popular items", zap.Error(err))
+			// replacement
+			if w.Config.Recommend.Replacement.EnableReplacement {
+				if results, err = w.replacement(results, &user, feedbacks, negativeItems, itemCache); err != nil {
+					log.Logger().Error("failed to replace items", zap.Error(err))
 					return errors.Trace(err)
 				}
-				var recommend []string
-				for _, popularItem := range popularItems {
-					if !excludeSet.Contains(popularItem.Id) && itemCache.IsAvailable(popularItem.Id) {
-						recommend = append(recommend, popularItem.Id)
-					}
-				}
-				candidates[category] = append(candidates[category], recommend)
 			}
-			popularRecommendSeconds.Add(time.Since(localStartTime).Seconds())
-		}
 
-		// rank items from different recommenders
-		// 1. If click-through rate prediction model is available, use it to rank items.
-		// 2. If collaborative filtering model is available, use it to rank items.
-		// 3. Otherwise, merge all recommenders' results randomly.
-		ctrUsed := false
-		results := make(map[string][]cache.Document)
-		for category, catCandidates := range candidates {
-			if w.Config.Recommend.Offline.EnableClickThroughPrediction && w.ClickModel != nil && !w.ClickModel.Invalid() {
-				results[category], err = w.rankByClickTroughRate(&user, catCandidates, itemCache)
-				if err != nil {
-					log.Logger().Error("failed to rank items", zap.Error(err))
+			// diversify
+			if w.Config.Recommend.Diversity.EnableDiversity {
+				if results, err = w.diversify(ctx, results, itemCache); err != nil {
+					log.Logger().Error("failed to diversify items", zap.Error(err))
 					return errors.Trace(err)
 				}
-				ctrUsed = true
-			} else if w.RankingModel != nil && !w.RankingModel.Invalid() &&
-				w.RankingModel.IsUserPredictable(w.RankingModel.GetUserIndex().ToNumber(userId)) {
-				results[category], err = w.rankByCollaborativeFiltering(userId, catCandidates)
+			}
+
+			// explore latest and popular
+			recommendTime := time.Now()
+			aggregator := cache.NewDocumentAggregator(recommendTime)
+			exploreExcludeSet := excludeSet
+			if w.Config.Recommend.Replacement.EnableReplacement {
+				// historical items except negative items are allowed to be explored if replacement is enabled
+				exploreExcludeSet = negativeItems
+			}
+			var recommend []cache.Document
+			for category, result := range results {
+				scores, err := w.exploreRecommend(result, exploreExcludeSet, category, variants, jobRandGenerators[workerId], jobBanditStates[workerId])
 				if err != nil {
-					log.Logger().Error("failed to rank items", zap.Error(err))
+					log.Logger().Error("failed to explore latest and popular items", zap.Error(err))
 					return errors.Trace(err)
 				}
-			} else {
-				results[category] = w.mergeAndShuffle(catCandidates)
+				aggregator.AddDocuments(category, scores)
+				recommend = append(recommend, scores...)
 			}
-		}
-
-		// replacement
-		if w.Config.Recommend.Replacement.EnableReplacement {
-			if results, err = w.replacement(results, &user, feedbacks, itemCache); err != nil {
-				log.Logger().Error("failed to replace items", zap.Error(err))
+			aggregator.Locate(itemCache.GetLocation)
+			if err = w.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, scene.subset(userId), aggregator.ToSlice()); err != nil {
+				log.Logger().Error("failed to cache recommendation", zap.Error(err))
 				return errors.Trace(err)
 			}
-		}
-
-		// explore latest and popular
-		recommendTime := time.Now()
-		aggregator := cache.NewDocumentAggregator(recommendTime)
-		for category, result := range results {
-			scores, err := w.exploreRecommend(result, excludeSet, category)
-			if err != nil {
-				log.Logger().Error("failed to explore latest and popular items", zap.Error(err))
-				return errors.Trace(err)
+			if w.Config.Recommend.Bandit.Enabled() {
+				if err = w.saveExploredItems(ctx, userId, recommend, recommendTime); err != nil {
+					log.Logger().Error("failed to cache explored items", zap.Error(err))
+					return errors.Trace(err)
+				}
+			}
+			if err = w.CacheClient.Set(
+				ctx,
+				cache.Time(cache.Key(cache.LastUpdateUserRecommendTime, scene.subset(userId)), recommendTime),
+				cache.String(cache.Key(cache.OfflineRecommendDigest, scene.subset(userId)), w.Config.OfflineRecommendDigest(
+					config.WithScene(scene.config),
+					config.WithCollaborative(collaborativeUsed),
+					config.WithRanking(ctrUsed),
+					config.WithVariants(variants),
+					config.WithItemNeighborDigest(strings.Join(itemNeighborDigests.ToSlice(), "-")),
+					config.WithUserNeighborDigest(strings.Join(userNeighborDigests.ToSlice(), "-")),
+				))); err != nil {
+				log.Logger().Error("failed to cache recommendation time", zap.Error(err))
 			}
-			aggregator.Add(category, lo.Map(scores, func(document cache.Document, _ int) string {
-				return document.Id
-			}), lo.Map(scores, func(document cache.Document, _ int) float64 {
-				return document.Score
-			}))
-		}
-		if err = w.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, userId, aggregator.ToSlice()); err != nil {
-			log.Logger().Error("failed to cache recommendation", zap.Error(err))
-			return errors.Trace(err)
-		}
-		if err = w.CacheClient.Set(
-			ctx,
-			cache.Time(cache.Key(cache.LastUpdateUserRecommendTime, userId), recommendTime),
-			cache.String(cache.Key(cache.OfflineRecommendDigest, userId), w.Config.OfflineRecommendDigest(
-				config.WithCollaborative(collaborativeUsed),
-				config.WithRanking(ctrUsed),
-				config.WithItemNeighborDigest(strings.Join(itemNeighborDigests.ToSlice(), "-")),
-				config.WithUserNeighborDigest(strings.Join(userNeighborDigests.ToSlice(), "-")),
-			))); err != nil {
-			log.Logger().Error("failed to cache recommendation time", zap.Error(err))
 		}
 		return nil
 	})
@@ -835,6 +903,14 @@ func (w *Worker) Recommend(users []data.User) {
 	OfflineRecommendStepSecondsVec.WithLabelValues("popular_recommend").Set(popularRecommendSeconds.Load())
 }
 
+// updateReason keeps the most similar item or user leading to a candidate as the reason. Ties are broken by IDs.
+func updateReason(reasons map[string]cache.Document, itemId string, contributor cache.Document) {
+	if reason, exist := reasons[itemId]; !exist || reason.Score < contributor.Score ||
+		(reason.Score == contributor.Score && contributor.Id < reason.Id) {
+		reasons[itemId] = contributor
+	}
+}
+
 func (w *Worker) collaborativeRecommendBruteForce(userId string, itemCategories []string, excludeSet mapset.Set[string], itemCache *ItemCache) (map[string][]string, time.Duration, error) {
 	ctx := context.Background()
 	userIndex := w.RankingModel.GetUserIndex().ToNumber(userId)
@@ -862,6 +938,7 @@ func (w *Worker) collaborativeRecommendBruteForce(userId string, itemCategories
 		recommend[category] = recommendItems
 		aggregator.Add(category, recommendItems, recommendScores)
 	}
+	aggregator.Locate(itemCache.GetLocation)
 	if err := w.CacheClient.AddDocuments(ctx, cache.CollaborativeRecommend, userId, aggregator.ToSlice()); err != nil {
 		log.Logger().Error("failed to cache collaborative filtering recommendation result", zap.String("user_id", userId), zap.Error(err))
 		return nil, 0, errors.Trace(err)
@@ -895,6 +972,7 @@ func (w *Worker) collaborativeRecommendHNSW(rankingIndex *search.HNSW, userId st
 		recommend[category] = recommendItems
 		aggregator.Add(category, recommendItems, recommendScores)
 	}
+	aggregator.Locate(itemCache.GetLocation)
 	if err := w.CacheClient.AddDocuments(ctx, cache.CollaborativeRecommend, userId, aggregator.ToSlice()); err != nil {
 		log.Logger().Error("failed to cache collaborative filtering recommendation result", zap.String("user_id", userId), zap.Error(err))
 		return nil, 0, errors.Trace(err)
@@ -991,32 +1069,33 @@ func (w *Worker) mergeAndShuffle(candidates [][]string) []cache.Document {
 	return recommend
 }
 
-func (w *Worker) exploreRecommend(exploitRecommend []cache.Document, excludeSet mapset.Set[string], category string) ([]cache.Document, error) {
-	var localExcludeSet mapset.Set[string]
+// exploreSources are sources of explored items in order.
+var exploreSources = []string{"popular", "latest", "trending"}
+
+// exploreCollections are collections of items of exploration sources.
+var exploreCollections = map[string]string{
+	"popular":  cache.PopularItems,
+	"latest":   cache.LatestItems,
+	"trending": cache.TrendingItems,
+}
+
+// exploreRecommend inserts items of exploration sources into the recommendation. Arms chosen by bandits are pulled
+// on the bandit state, so the random generator and the bandit state must not be shared by concurrent jobs.
+func (w *Worker) exploreRecommend(exploitRecommend []cache.Document, excludeSet mapset.Set[string], category string, variants []config.Variant, rng *rand.Rand, banditState *bandit.State) ([]cache.Document, error) {
+	localExcludeSet := excludeSet.Clone()
 	ctx := context.Background()
-	if w.Config.Recommend.Replacement.EnableReplacement {
-		localExcludeSet = mapset.NewSet[string]()
-	} else {
-		localExcludeSet = excludeSet.Clone()
-	}
-	// create thresholds
-	explorePopularThreshold := 0.0
-	if threshold, exist := w.Config.Recommend.Offline.GetExploreRecommend("popular"); exist {
-		explorePopularThreshold = threshold
-	}
-	exploreLatestThreshold := explorePopularThreshold
-	if threshold, exist := w.Config.Recommend.Offline.GetExploreRecommend("latest"); exist {
-		exploreLatestThreshold += threshold
-	}
-	// load popular items
-	popularItems, err := w.CacheClient.SearchDocuments(ctx, cache.PopularItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
-	if err != nil {
-		return nil, errors.Trace(err)
-	}
-	// load the latest items
-	latestItems, err := w.CacheClient.SearchDocuments(ctx, cache.LatestItems, "", []string{category}, 0, w.Config.Recommend.CacheSize)
-	if err != nil {
-		return nil, errors.Trace(err)
+	// load rates and items of exploration sources
+	exploreRates := make(map[string]float64)
+	exploreItems := make(map[string][]cache.Document)
+	for _, source := range exploreSources {
+		if rate, exist := w.Config.Recommend.GetExploreRecommend(variants, source); exist {
+			exploreRates[source] = rate
+		}
+		items, err := w.CacheClient.SearchDocuments(ctx, exploreCollections[source], "", []string{category}, 0, w.Config.Recommend.CacheSize)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		exploreItems[source] = items
 	}
 	// explore recommendation
 	var exploreRecommend []cache.Document
@@ -1025,18 +1104,13 @@ func (w *Worker) exploreRecommend(exploitRecommend []cache.Document, excludeSet
 		score += exploitRecommend[0].Score
 	}
 	for range exploitRecommend {
-		dice := w.randGenerator.Float64()
+		dice := rng.Float64()
 		var recommendItem cache.Document
-		if dice < explorePopularThreshold && len(popularItems) > 0 {
-			score -= 1e-5
-			recommendItem.Id = popularItems[0].Id
-			recommendItem.Score = score
-			popularItems = popularItems[1:]
-		} else if dice < exploreLatestThreshold && len(latestItems) > 0 {
+		if source := w.chooseExploreSource(rng, dice, exploreRates, exploreItems, banditState); source != "" {
 			score -= 1e-5
-			recommendItem.Id = latestItems[0].Id
+			recommendItem.Id = w.chooseExploreItem(rng, source, exploreItems, banditState)
 			recommendItem.Score = score
-			latestItems = latestItems[1:]
+			recommendItem.Source = "explore_" + source
 		} else if len(exploitRecommend) > 0 {
 			recommendItem = exploitRecommend[0]
 			exploitRecommend = exploitRecommend[1:]
@@ -1052,11 +1126,86 @@ func (w *Worker) exploreRecommend(exploitRecommend []cache.Document, excludeSet
 	return exploreRecommend, nil
 }
 
+// chooseExploreSource chooses the exploration source of an item by the dice. Items are explored if the dice is less than
+// the sum of exploration rates. The random policy chooses the source whose cumulative rate covers the dice and explores
+// nothing if the source is exhausted, while bandit policies choose the source from non-empty sources with positive rates
+// by their rewards and pull the chosen arm. It returns an empty string if no item is explored.
+func (w *Worker) chooseExploreSource(rng *rand.Rand, dice float64, exploreRates map[string]float64, exploreItems map[string][]cache.Document, banditState *bandit.State) string {
+	switch w.Config.Recommend.Bandit.Policy {
+	case bandit.ThompsonSampling, bandit.UCB:
+		var exploreRate float64
+		var candidates []string
+		for _, source := range exploreSources {
+			exploreRate += exploreRates[source]
+			if exploreRates[source] > 0 && len(exploreItems[source]) > 0 {
+				candidates = append(candidates, source)
+			}
+		}
+		if dice >= exploreRate {
+			return ""
+		}
+		if i := bandit.Choose(w.Config.Recommend.Bandit.Policy, rng, banditState.Sources, candidates); i >= 0 {
+			banditState.Sources[candidates[i]] = banditState.Sources[candidates[i]].Pull()
+			return candidates[i]
+		}
+		return ""
+	default:
+		var threshold float64
+		for _, source := range exploreSources {
+			threshold += exploreRates[source]
+			if dice < threshold {
+				if len(exploreItems[source]) == 0 {
+					// no item is explored if the source covering the dice is exhausted
+					return ""
+				}
+				return source
+			}
+		}
+		return ""
+	}
+}
+
+// chooseExploreItem pops an item from the exploration source. The latest items are chosen by bandits of items if new
+// items are explored and the chosen arm is pulled, otherwise items are popped in order.
+func (w *Worker) chooseExploreItem(rng *rand.Rand, source string, exploreItems map[string][]cache.Document, banditState *bandit.State) string {
+	items := exploreItems[source]
+	i := 0
+	if source == "latest" && w.Config.Recommend.Bandit.ExploreNewItems {
+		i = bandit.Choose(w.Config.Recommend.Bandit.Policy, rng, banditState.Items,
+			lo.Map(items, func(item cache.Document, _ int) string { return item.Id }))
+		banditState.Items[items[i].Id] = banditState.Items[items[i].Id].Pull()
+	}
+	itemId := items[i].Id
+	exploreItems[source] = append(items[:i:i], items[i+1:]...)
+	return itemId
+}
+
+// saveExploredItems records explored items in recommendation for a user with their exploration sources. Exploration
+// sources are rewarded by feedback on explored items.
+func (w *Worker) saveExploredItems(ctx context.Context, userId string, recommend []cache.Document, timestamp time.Time) error {
+	explored := make(map[string]cache.Document)
+	for _, document := range recommend {
+		if strings.HasPrefix(document.Source, "explore_") {
+			explored[document.Id] = cache.Document{
+				Id:         document.Id,
+				Score:      float64(timestamp.Unix()),
+				Categories: []string{""},
+				Timestamp:  timestamp,
+				Source:     strings.TrimPrefix(document.Source, "explore_"),
+			}
+		}
+	}
+	if len(explored) == 0 {
+		return nil
+	}
+	return w.CacheClient.AddDocuments(ctx, cache.ExploredItems, userId, lo.Values(explored))
+}
+
 // checkRecommendCacheTimeout checks if recommend cache stale.
 // 1. if cache is empty, stale.
 // 2. if active time > recommend time, stale.
 // 3. if recommend time + timeout < now, stale.
-func (w *Worker) checkRecommendCacheTimeout(ctx context.Context, userId string, categories []string) bool {
+func (w *Worker) checkRecommendCacheTimeout(ctx context.Context, userId string, categories []string, scene offlineScene) bool {
 	var (
 		activeTime    time.Time
 		recommendTime time.Time
@@ -1065,7 +1214,7 @@ func (w *Worker) checkRecommendCacheTimeout(ctx context.Context, userId string,
 	)
 	// check cache
 	for _, category := range append([]string{""}, categories...) {
-		items, err := w.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, userId, []string{category}, 0, -1)
+		items, err := w.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, scene.subset(userId), []string{category}, 0, -1)
 		if err != nil {
 			log.Logger().Error("failed to load offline recommendation", zap.String("user_id", userId), zap.Error(err))
 			return true
@@ -1074,14 +1223,16 @@ func (w *Worker) checkRecommendCacheTimeout(ctx context.Context, userId string,
 		}
 	}
 	// read digest
-	cacheDigest, err = w.CacheClient.Get(ctx, cache.Key(cache.OfflineRecommendDigest, userId)).String()
+	cacheDigest, err = w.CacheClient.Get(ctx, cache.Key(cache.OfflineRecommendDigest, scene.subset(userId))).String()
 	if err != nil {
 		if !errors.Is(err, errors.NotFound) {
 			log.Logger().Error("failed to load offline recommendation digest", zap.String("user_id", userId), zap.Error(err))
 		}
 		return true
 	}
-	if cacheDigest != w.Config.OfflineRecommendDigest() {
+	if cacheDigest != w.Config.OfflineRecommendDigest(
+		config.WithScene(scene.config),
+		config.WithVariants(scene.variants(w.Config.Recommend.AssignVariants(userId)))) {
 		return true
 	}
 	// read active time
@@ -1093,7 +1244,7 @@ func (w *Worker) checkRecommendCacheTimeout(ctx context.Context, userId string,
 		return true
 	}
 	// read recommend time
-	recommendTime, err = w.CacheClient.Get(ctx, cache.Key(cache.LastUpdateUserRecommendTime, userId)).Time()
+	recommendTime, err = w.CacheClient.Get(ctx, cache.Key(cache.LastUpdateUserRecommendTime, scene.subset(userId))).Time()
 	if err != nil {
 		if !errors.Is(err, errors.NotFound) {
 			log.Logger().Error("failed to read last update user recommend time", zap.Error(err))
@@ -1125,6 +1276,17 @@ func (w *Worker) loadUserHistoricalItems(database data.Database, userId string)
 	return items, feedbacks, nil
 }
 
+// negativeItems returns items with negative feedback, which are never recommended to the user again.
+func (w *Worker) negativeItems(feedbacks []data.Feedback) mapset.Set[string] {
+	negativeItems := mapset.NewSet[string]()
+	for _, feedback := range feedbacks {
+		if funk.ContainsString(w.Config.Recommend.DataSource.NegativeFeedbackTypes, feedback.FeedbackType) {
+			negativeItems.Add(feedback.ItemId)
+		}
+	}
+	return negativeItems
+}
+
 func (w *Worker) pullItems(ctx context.Context) (*ItemCache, []string, error) {
 	// pull items from database
 	itemCache := NewItemCache()
@@ -1133,7 +1295,7 @@ func (w *Worker) pullItems(ctx context.Context) (*ItemCache, []string, error) {
 	for batchItems := range itemChan {
 		for _, item := range batchItems {
 			itemCache.Set(item.ItemId, item)
-			itemCategories.Append(item.Categories...)
+			itemCategories.Append(data.ExpandCategories(item.Categories)...)
 		}
 	}
 	if err := <-errChan; err != nil {
@@ -1173,8 +1335,9 @@ func (w *Worker) pullUsers(peers []string, me string) ([]data.User, error) {
 	return users, nil
 }
 
-// replacement inserts historical items back to recommendation.
-func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.User, feedbacks []data.Feedback, itemCache *ItemCache) (map[string][]cache.Document, error) {
+// replacement inserts historical items back to recommendation. Items with negative feedback are never replaced.
+func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.User, feedbacks []data.Feedback, negativeItems mapset.Set[string], itemCache *ItemCache) (map[string][]cache.Document, error) {
+	variants := w.Config.Recommend.AssignVariants(user.UserId)
 	upperBounds := make(map[string]float64)
 	lowerBounds := make(map[string]float64)
 	newRecommend := make(map[string][]cache.Document)
@@ -1198,7 +1361,10 @@ func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.U
 	positiveItems := mapset.NewSet[string]()
 	distinctItems := mapset.NewSet[string]()
 	for _, feedback := range feedbacks {
-		if funk.ContainsString(w.Config.Recommend.DataSource.PositiveFeedbackTypes, feedback.FeedbackType) {
+		if negativeItems.Contains(feedback.ItemId) {
+			// items with negative feedback are never replaced
+			continue
+		} else if funk.ContainsString(w.Config.Recommend.DataSource.PositiveFeedbackTypes, feedback.FeedbackType) {
 			positiveItems.Add(feedback.ItemId)
 			distinctItems.Add(feedback.ItemId)
 		} else if funk.ContainsString(w.Config.Recommend.DataSource.ReadFeedbackTypes, feedback.FeedbackType) {
@@ -1213,7 +1379,7 @@ func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.U
 			// 2. If collaborative filtering model is available, use it.
 			// 3. Otherwise, give a random score.
 			var score float64
-			if w.Config.Recommend.Offline.EnableClickThroughPrediction && w.ClickModel != nil {
+			if w.Config.Recommend.GetEnableClickThroughPrediction(variants) && w.ClickModel != nil {
 				score = float64(w.ClickModel.Predict(user.UserId, itemId, data.FlattenLabels(user.Labels), data.FlattenLabels(item.Labels)))
 			} else if w.RankingModel != nil && !w.RankingModel.Invalid() && w.RankingModel.IsUserPredictable(w.RankingModel.GetUserIndex().ToNumber(user.UserId)) {
 				score = float64(w.RankingModel.Predict(user.UserId, itemId))
@@ -1242,7 +1408,7 @@ func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.U
 					}
 					score += lowerBound
 				}
-				newRecommend[category] = append(newRecommend[category], cache.Document{Id: itemId, Score: score})
+				newRecommend[category] = append(newRecommend[category], cache.Document{Id: itemId, Score: score, Source: "replacement"})
 			}
 		} else {
 			log.Logger().Warn("item doesn't exists in database", zap.String("item_id", itemId))
@@ -1256,6 +1422,61 @@ func (w *Worker) replacement(recommend map[string][]cache.Document, user *data.U
 	return newRecommend, nil
 }
 
+// diversify re-ranks recommendation by maximal marginal relevance. Items without category are re-ranked
+// and their scores are reassigned in the new order, so that an item has the same score in all categories.
+func (w *Worker) diversify(ctx context.Context, recommend map[string][]cache.Document, itemCache *ItemCache) (map[string][]cache.Document, error) {
+	documents := recommend[""]
+	if len(documents) == 0 {
+		return recommend, nil
+	}
+	itemIds := lo.Map(documents, func(document cache.Document, _ int) string {
+		return document.Id
+	})
+	scores := lo.Map(documents, func(document cache.Document, _ int) float64 {
+		return document.Score
+	})
+	var (
+		similarity         func(candidate, selected int) float64
+		neighborSimilarity *cache.NeighborSimilarity
+	)
+	switch w.Config.Recommend.Diversity.Similarity {
+	case "neighbors":
+		neighborSimilarity = cache.NewNeighborSimilarity(ctx, w.CacheClient, w.Config.Recommend.CacheSize)
+		similarity = func(candidate, selected int) float64 {
+			return neighborSimilarity.Similarity(itemIds[candidate], itemIds[selected])
+		}
+	default:
+		labels := lo.Map(itemIds, func(itemId string, _ int) mapset.Set[string] {
+			labels := mapset.NewSet[string]()
+			if item, exist := itemCache.Get(itemId); exist {
+				labels.Append(data.FlattenLabels(item.Labels)...)
+				labels.Append(item.Categories...)
+			}
+			return labels
+		})
+		similarity = func(candidate, selected int) float64 {
+			return base.Jaccard(labels[candidate], labels[selected])
+		}
+	}
+	selected := base.MMR(scores, len(documents), w.Config.Recommend.Diversity.Lambda, similarity)
+	if neighborSimilarity != nil && neighborSimilarity.Err() != nil {
+		return nil, errors.Trace(neighborSimilarity.Err())
+	}
+	newScores := make(map[string]float64, len(selected))
+	for i, j := range selected {
+		newScores[itemIds[j]] = scores[i]
+	}
+	for _, result := range recommend {
+		for i := range result {
+			if score, exist := newScores[result[i].Id]; exist {
+				result[i].Score = score
+			}
+		}
+		cache.SortDocuments(result)
+	}
+	return recommend, nil
+}
+
 type HealthStatus struct {
 	DataStoreError      error
 	CacheStoreError     error
@@ -1291,8 +1512,10 @@ func (c *ItemCache) Len() int {
 	return len(c.Data)
 }
 
+// Set adds an item to the cache. Categories of the item are expanded with their ancestors.
 func (c *ItemCache) Set(itemId string, item data.Item) {
 	if _, exist := c.Data[itemId]; !exist {
+		item.Categories = data.ExpandCategories(item.Categories)
 		c.Data[itemId] = &item
 		c.ByteCount += reflect.TypeOf(rune(0)).Size() * uintptr(len(itemId))
 		c.ByteCount += reflect.TypeOf(item.ItemId).Size() * uintptr(len(itemId))
@@ -1316,6 +1539,16 @@ func (c *ItemCache) GetCategory(itemId string) []string {
 	}
 }
 
+// GetLocation returns the location of an item, or nil if the item is not located.
+func (c *ItemCache) GetLocation(itemId string) *cache.Location {
+	if item, exist := c.Data[itemId]; exist {
+		if latitude, longitude, ok := data.ParseLocation(item.Labels); ok {
+			return &cache.Location{Latitude: latitude, Longitude: longitude}
+		}
+	}
+	return nil
+}
+
 // IsAvailable means the item exists in database and is not hidden.
 func (c *ItemCache) IsAvailable(itemId string) bool {
 	if item, exist := c.Data[itemId]; exist {
@@ -1329,6 +1562,43 @@ func (c *ItemCache) Bytes() int {
 	return int(c.ByteCount)
 }
 
+// offlineScene is the offline recommendation pipeline of a scene. The global offline recommendation is the scene
+// without name and configuration.
+type offlineScene struct {
+	name          string
+	config        *config.OfflineSceneConfig
+	feedbackCache *FeedbackCache
+}
+
+// offlineScenes returns the global offline recommendation followed by scenes with independent offline recommendation.
+func (w *Worker) offlineScenes() []offlineScene {
+	scenes := []offlineScene{{
+		feedbackCache: NewFeedbackCache(w, w.Config.Recommend.DataSource.PositiveFeedbackTypes...),
+	}}
+	for _, name := range w.Config.Recommend.OfflineScenes() {
+		sceneConfig := w.Config.Recommend.Scenes[name].Offline
+		scenes = append(scenes, offlineScene{
+			name:          name,
+			config:        sceneConfig,
+			feedbackCache: NewFeedbackCache(w, w.Config.Recommend.GetPositiveFeedbackTypes(sceneConfig)...),
+		})
+	}
+	return scenes
+}
+
+// subset returns the subset of offline recommendation for a user in the scene.
+func (scene offlineScene) subset(userId string) string {
+	return cache.Key(userId, scene.name)
+}
+
+// variants places options of the scene before experiment variants.
+func (scene offlineScene) variants(variants []config.Variant) []config.Variant {
+	if scene.config == nil {
+		return variants
+	}
+	return append([]config.Variant{scene.config.Variant(scene.name)}, variants...)
+}
+
 // FeedbackCache is the cache for user feedbacks.
 type FeedbackCache struct {
 	*config.Config
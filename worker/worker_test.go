@@ -38,6 +38,7 @@ import (
 	"github.com/zhenghaoz/gorse/base/parallel"
 	"github.com/zhenghaoz/gorse/config"
 	"github.com/zhenghaoz/gorse/model"
+	"github.com/zhenghaoz/gorse/model/bandit"
 	"github.com/zhenghaoz/gorse/model/click"
 	"github.com/zhenghaoz/gorse/model/ranking"
 	"github.com/zhenghaoz/gorse/protocol"
@@ -118,27 +119,27 @@ func (suite *WorkerTestSuite) TestCheckRecommendCacheTimeout() {
 	ctx := context.Background()
 
 	// empty cache
-	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 	err := suite.CacheClient.AddDocuments(ctx, cache.OfflineRecommend, "0", []cache.Document{{Id: "0", Score: 0, Categories: []string{""}}})
 	suite.NoError(err)
 
 	// digest mismatch
-	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 	err = suite.CacheClient.Set(ctx, cache.String(cache.Key(cache.OfflineRecommendDigest, "0"), suite.Config.OfflineRecommendDigest()))
 	suite.NoError(err)
 
 	err = suite.CacheClient.Set(ctx, cache.Time(cache.Key(cache.LastModifyUserTime, "0"), time.Now().Add(-time.Hour)))
 	suite.NoError(err)
-	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 	err = suite.CacheClient.Set(ctx, cache.Time(cache.Key(cache.LastUpdateUserRecommendTime, "0"), time.Now().Add(-time.Hour*100)))
 	suite.NoError(err)
-	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 	err = suite.CacheClient.Set(ctx, cache.Time(cache.Key(cache.LastUpdateUserRecommendTime, "0"), time.Now().Add(time.Hour*100)))
 	suite.NoError(err)
-	suite.False(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.False(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 	err = suite.CacheClient.DeleteDocuments(ctx, []string{cache.OfflineRecommend}, cache.DocumentCondition{Subset: proto.String("0")})
 	suite.NoError(err)
-	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil))
+	suite.True(suite.checkRecommendCacheTimeout(ctx, "0", nil, offlineScene{}))
 }
 
 type mockMatrixFactorizationForRecommend struct {
@@ -172,6 +173,10 @@ func (m *mockMatrixFactorizationForRecommend) GetItemFactor(itemId int32) []floa
 	return []float32{float32(itemId)}
 }
 
+func (m *mockMatrixFactorizationForRecommend) FoldIn(_ []int32) []float32 {
+	panic("implement me")
+}
+
 func (m *mockMatrixFactorizationForRecommend) Invalid() bool {
 	return false
 }
@@ -240,16 +245,16 @@ func (suite *WorkerTestSuite) TestRecommendMatrixFactorizationBruteForce() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "0", Score: 0, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "0", Score: 0, Categories: []string{""}, Timestamp: recommendTime, Source: "collaborative"},
 	}, recommends)
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{"*"}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime},
+		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
 	}, recommends)
 }
 
@@ -293,10 +298,10 @@ func (suite *WorkerTestSuite) TestRecommendMatrixFactorizationHNSW() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "0", Score: 0, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "3", Score: 3, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "2", Score: 2, Categories: []string{""}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "1", Score: 1, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "collaborative"},
+		{Id: "0", Score: 0, Categories: []string{""}, Timestamp: recommendTime, Source: "collaborative"},
 	}, recommends)
 }
 
@@ -364,15 +369,15 @@ func (suite *WorkerTestSuite) TestRecommendItemBased() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "29", Score: 29, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "28", Score: 28, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "27", Score: 27, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "29", Score: 29, Categories: []string{""}, Timestamp: recommendTime, Source: "item_based", Reason: "21"},
+		{Id: "28", Score: 28, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "item_based", Reason: "22"},
+		{Id: "27", Score: 27, Categories: []string{""}, Timestamp: recommendTime, Source: "item_based", Reason: "23"},
 	}, recommends)
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{"*"}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "28", Score: 28, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "26", Score: 26, Categories: []string{"", "*"}, Timestamp: recommendTime},
+		{Id: "28", Score: 28, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "item_based", Reason: "22"},
+		{Id: "26", Score: 26, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "item_based", Reason: "24"},
 	}, recommends)
 }
 
@@ -423,15 +428,15 @@ func (suite *WorkerTestSuite) TestRecommendUserBased() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "48", Score: 48, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "13", Score: 13, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "12", Score: 12, Categories: []string{"", "*"}, Timestamp: recommendTime},
+		{Id: "48", Score: 48, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "user_based", Reason: "2"},
+		{Id: "13", Score: 13, Categories: []string{""}, Timestamp: recommendTime, Source: "user_based", Reason: "3"},
+		{Id: "12", Score: 12, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "user_based", Reason: "2"},
 	}, recommends)
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{"*"}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "48", Score: 48, Categories: []string{"", "*"}, Timestamp: recommendTime},
-		{Id: "12", Score: 12, Categories: []string{"", "*"}, Timestamp: recommendTime},
+		{Id: "48", Score: 48, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "user_based", Reason: "2"},
+		{Id: "12", Score: 12, Categories: []string{"", "*"}, Timestamp: recommendTime, Source: "user_based", Reason: "2"},
 	}, recommends)
 }
 
@@ -470,16 +475,16 @@ func (suite *WorkerTestSuite) TestRecommendPopular() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "8", Score: 8, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
+		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
+		{Id: "8", Score: 8, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
 	}, recommends)
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{"*"}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "20", Score: 20, Categories: []string{"*"}, Timestamp: recommendTime},
-		{Id: "19", Score: 19, Categories: []string{"*"}, Timestamp: recommendTime},
-		{Id: "18", Score: 18, Categories: []string{"*"}, Timestamp: recommendTime},
+		{Id: "20", Score: 20, Categories: []string{"*"}, Timestamp: recommendTime, Source: "popular"},
+		{Id: "19", Score: 19, Categories: []string{"*"}, Timestamp: recommendTime, Source: "popular"},
+		{Id: "18", Score: 18, Categories: []string{"*"}, Timestamp: recommendTime, Source: "popular"},
 	}, recommends)
 }
 
@@ -519,17 +524,66 @@ func (suite *WorkerTestSuite) TestRecommendLatest() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "8", Score: 8, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "latest"},
+		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "latest"},
+		{Id: "8", Score: 8, Categories: []string{""}, Timestamp: recommendTime, Source: "latest"},
 	}, recommends)
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{"*"}, 0, -1)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "20", Score: 20, Categories: []string{"*"}, Timestamp: recommendTime},
-		{Id: "19", Score: 19, Categories: []string{"*"}, Timestamp: recommendTime},
-		{Id: "18", Score: 18, Categories: []string{"*"}, Timestamp: recommendTime},
+		{Id: "20", Score: 20, Categories: []string{"*"}, Timestamp: recommendTime, Source: "latest"},
+		{Id: "19", Score: 19, Categories: []string{"*"}, Timestamp: recommendTime, Source: "latest"},
+		{Id: "18", Score: 18, Categories: []string{"*"}, Timestamp: recommendTime, Source: "latest"},
+	}, recommends)
+}
+
+func (suite *WorkerTestSuite) TestRecommendScenes() {
+	ctx := context.Background()
+	suite.Config.Recommend.Offline.EnableColRecommend = false
+	suite.Config.Recommend.Offline.EnableLatestRecommend = true
+	suite.Config.Recommend.Scenes = map[string]config.SceneConfig{
+		"homepage": {Recommenders: []string{"offline"}},
+		"video":    {Offline: &config.OfflineSceneConfig{Recommenders: []string{"popular"}}},
+	}
+	// insert latest and popular items
+	err := suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{
+		{Id: "11", Score: 11, Categories: []string{""}},
+		{Id: "10", Score: 10, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	err = suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", []cache.Document{
+		{Id: "9", Score: 9, Categories: []string{""}},
+		{Id: "8", Score: 8, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	// insert items
+	err = suite.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "11"}, {ItemId: "10"}, {ItemId: "9"}, {ItemId: "8"}})
+	suite.NoError(err)
+	suite.RankingModel = newMockMatrixFactorizationForRecommend(1, 10)
+	suite.Recommend([]data.User{{UserId: "0"}})
+
+	// the global offline recommendation
+	recommendTime, err := suite.CacheClient.Get(ctx, cache.Key(cache.LastUpdateUserRecommendTime, "0")).Time()
+	suite.NoError(err)
+	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]cache.Document{
+		{Id: "11", Score: 11, Categories: []string{""}, Timestamp: recommendTime, Source: "latest"},
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "latest"},
+	}, recommends)
+	// the offline recommendation of the scene
+	recommendTime, err = suite.CacheClient.Get(ctx, cache.Key(cache.LastUpdateUserRecommendTime, "0", "video")).Time()
+	suite.NoError(err)
+	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, cache.Key("0", "video"), []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.Equal([]cache.Document{
+		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
+		{Id: "8", Score: 8, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
 	}, recommends)
+	// scenes without offline recommendation are not generated
+	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, cache.Key("0", "homepage"), []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.Empty(recommends)
 }
 
 func (suite *WorkerTestSuite) TestRecommendColdStart() {
@@ -604,7 +658,7 @@ func (suite *WorkerTestSuite) TestExploreRecommend() {
 		{Id: "3", Score: 3},
 		{Id: "2", Score: 2},
 		{Id: "1", Score: 1},
-	}, mapset.NewSet[string](), "")
+	}, mapset.NewSet[string](), "", nil, suite.randGenerator, bandit.NewState(time.Time{}))
 	suite.NoError(err)
 	items := lo.Map(recommend, func(d cache.Document, _ int) string { return d.Id })
 	suite.Contains(items, "latest")
@@ -618,6 +672,123 @@ func (suite *WorkerTestSuite) TestExploreRecommend() {
 	suite.Equal(8, len(recommend))
 }
 
+func (suite *WorkerTestSuite) TestExploreRecommend_Trending() {
+	ctx := context.Background()
+	suite.Config.Recommend.Offline.ExploreRecommend = map[string]float64{"trending": 0.6}
+	// insert trending items
+	err := suite.CacheClient.AddDocuments(ctx, cache.TrendingItems, "", []cache.Document{{Id: "trending", Score: 0, Categories: []string{""}, Timestamp: time.Now()}})
+	suite.NoError(err)
+
+	recommend, err := suite.exploreRecommend([]cache.Document{
+		{Id: "8", Score: 8},
+		{Id: "7", Score: 7},
+		{Id: "6", Score: 6},
+		{Id: "5", Score: 5},
+		{Id: "4", Score: 4},
+		{Id: "3", Score: 3},
+		{Id: "2", Score: 2},
+		{Id: "1", Score: 1},
+	}, mapset.NewSet[string](), "", nil, suite.randGenerator, bandit.NewState(time.Time{}))
+	suite.NoError(err)
+	sources := lo.Map(recommend, func(d cache.Document, _ int) string { return d.Source })
+	suite.Contains(sources, "explore_trending")
+	scores := lo.Map(recommend, func(d cache.Document, _ int) float64 { return d.Score })
+	suite.IsDecreasing(scores)
+	suite.Equal(8, len(recommend))
+}
+
+func (suite *WorkerTestSuite) TestChooseExploreSource() {
+	exploreRates := map[string]float64{"popular": 0.5, "latest": 0.5}
+	exploreItems := map[string][]cache.Document{"latest": {{Id: "latest"}}}
+	// the random policy explores nothing if the source covering the dice is exhausted
+	suite.Config.Recommend.Bandit.Policy = bandit.Random
+	suite.Empty(suite.chooseExploreSource(suite.randGenerator, 0.1, exploreRates, exploreItems, bandit.NewState(time.Time{})))
+	suite.Equal("latest", suite.chooseExploreSource(suite.randGenerator, 0.6, exploreRates, exploreItems, bandit.NewState(time.Time{})))
+	suite.Empty(suite.chooseExploreSource(suite.randGenerator, 1, exploreRates, exploreItems, bandit.NewState(time.Time{})))
+	// bandit policies choose from non-empty sources
+	suite.Config.Recommend.Bandit.Policy = bandit.UCB
+	suite.Equal("latest", suite.chooseExploreSource(suite.randGenerator, 0.1, exploreRates, exploreItems, bandit.NewState(time.Time{})))
+	suite.Empty(suite.chooseExploreSource(suite.randGenerator, 1, exploreRates, exploreItems, bandit.NewState(time.Time{})))
+}
+
+func (suite *WorkerTestSuite) TestSaveExploredItems() {
+	ctx := context.Background()
+	timestamp := time.Now().Truncate(time.Second)
+	err := suite.saveExploredItems(ctx, "0", []cache.Document{
+		{Id: "1", Source: "explore_popular"},
+		{Id: "2", Source: "cf"},
+		{Id: "3", Source: "explore_latest"},
+	}, timestamp)
+	suite.NoError(err)
+	// only explored items are saved with their exploration sources
+	exploredItems, err := suite.CacheClient.SearchDocuments(ctx, cache.ExploredItems, "0", []string{""}, 0, -1)
+	suite.NoError(err)
+	suite.ElementsMatch([]string{"1", "3"}, lo.Map(exploredItems, func(document cache.Document, _ int) string { return document.Id }))
+	suite.ElementsMatch([]string{"popular", "latest"}, lo.Map(exploredItems, func(document cache.Document, _ int) string { return document.Source }))
+}
+
+func (suite *WorkerTestSuite) TestExploreRecommend_Bandit() {
+	ctx := context.Background()
+	suite.Config.Recommend.Offline.ExploreRecommend = map[string]float64{"popular": 0.5, "latest": 0.5}
+	suite.Config.Recommend.Bandit.Policy = bandit.ThompsonSampling
+	suite.Config.Recommend.Bandit.ExploreNewItems = true
+	// insert popular items
+	var popularItems []cache.Document
+	for i := 0; i < 8; i++ {
+		popularItems = append(popularItems, cache.Document{Id: fmt.Sprintf("popular_%d", i), Score: float64(-i), Categories: []string{""}, Timestamp: time.Now()})
+	}
+	err := suite.CacheClient.AddDocuments(ctx, cache.PopularItems, "", popularItems)
+	suite.NoError(err)
+	// insert latest items
+	err = suite.CacheClient.AddDocuments(ctx, cache.LatestItems, "", []cache.Document{
+		{Id: "latest_0", Score: 0, Categories: []string{""}, Timestamp: time.Now()},
+		{Id: "latest_1", Score: -1, Categories: []string{""}, Timestamp: time.Now()},
+	})
+	suite.NoError(err)
+	exploit := []cache.Document{
+		{Id: "8", Score: 8},
+		{Id: "7", Score: 7},
+		{Id: "6", Score: 6},
+		{Id: "5", Score: 5},
+		{Id: "4", Score: 4},
+		{Id: "3", Score: 3},
+		{Id: "2", Score: 2},
+		{Id: "1", Score: 1},
+	}
+
+	// the source with higher rewards is explored
+	banditState := bandit.NewState(time.Now())
+	banditState.Sources["popular"] = bandit.Arm{Trials: 1000, Rewards: 900}
+	banditState.Sources["latest"] = bandit.Arm{Trials: 1000, Rewards: 10}
+	recommend, err := suite.exploreRecommend(exploit, mapset.NewSet[string](), "", nil, suite.randGenerator, banditState)
+	suite.NoError(err)
+	sources := lo.Map(recommend, func(d cache.Document, _ int) string { return d.Source })
+	suite.Equal([]string{"explore_popular"}, lo.Uniq(sources))
+	scores := lo.Map(recommend, func(d cache.Document, _ int) float64 { return d.Score })
+	suite.IsDecreasing(scores)
+	suite.Equal(8, len(recommend))
+
+	// new items with higher rewards are explored first
+	suite.Config.Recommend.Offline.ExploreRecommend = map[string]float64{"latest": 1}
+	banditState.Items["latest_0"] = bandit.Arm{Trials: 1000, Rewards: 10}
+	banditState.Items["latest_1"] = bandit.Arm{Trials: 1000, Rewards: 900}
+	recommend, err = suite.exploreRecommend(exploit, mapset.NewSet[string](), "", nil, suite.randGenerator, banditState)
+	suite.NoError(err)
+	explored := lo.Filter(recommend, func(d cache.Document, _ int) bool { return d.Source == "explore_latest" })
+	suite.Equal([]string{"latest_1", "latest_0"}, lo.Map(explored, func(d cache.Document, _ int) string { return d.Id }))
+
+	// sources are not chosen repeatedly by UCB since chosen arms are pulled
+	suite.Config.Recommend.Offline.ExploreRecommend = map[string]float64{"popular": 0.5, "latest": 0.5}
+	suite.Config.Recommend.Bandit.Policy = bandit.UCB
+	banditState = bandit.NewState(time.Now())
+	recommend, err = suite.exploreRecommend(exploit, mapset.NewSet[string](), "", nil, suite.randGenerator, banditState)
+	suite.NoError(err)
+	sources = lo.Map(recommend, func(d cache.Document, _ int) string { return d.Source })
+	suite.ElementsMatch([]string{"explore_popular", "explore_latest"}, lo.Uniq(sources))
+	suite.Positive(banditState.Sources["popular"].Trials)
+	suite.Positive(banditState.Sources["latest"].Trials)
+}
+
 func marshal(t *testing.T, v interface{}) string {
 	s, err := json.Marshal(v)
 	assert.NoError(t, err)
@@ -795,6 +966,10 @@ func (m mockFactorizationMachine) Predict(_, itemId string, _, _ []string) float
 	return float32(score)
 }
 
+func (m mockFactorizationMachine) PredictWithContext(userId, itemId string, userLabels, itemLabels, _ []string) float32 {
+	return m.Predict(userId, itemId, userLabels, itemLabels)
+}
+
 func (m mockFactorizationMachine) InternalPredict(_ []int32, _ []float32) float32 {
 	panic("implement me")
 }
@@ -851,6 +1026,22 @@ func (suite *WorkerTestSuite) TestRankByClickTroughRate() {
 	}))
 }
 
+func TestItemCache_GetLocation(t *testing.T) {
+	itemCache := NewItemCache()
+	itemCache.Set("1", data.Item{ItemId: "1", Labels: map[string]any{"location": "30,120"}})
+	itemCache.Set("2", data.Item{ItemId: "2", Labels: map[string]any{"city": "hangzhou"}})
+	assert.Equal(t, &cache.Location{Latitude: 30, Longitude: 120}, itemCache.GetLocation("1"))
+	assert.Nil(t, itemCache.GetLocation("2"))
+	assert.Nil(t, itemCache.GetLocation("3"))
+}
+
+func TestItemCache_GetCategory(t *testing.T) {
+	itemCache := NewItemCache()
+	itemCache.Set("1", data.Item{ItemId: "1", Categories: []string{"books/fiction", "music"}})
+	assert.Equal(t, []string{"books", "books/fiction", "music"}, itemCache.GetCategory("1"))
+	assert.Nil(t, itemCache.GetCategory("2"))
+}
+
 func (suite *WorkerTestSuite) TestReplacement_ClickThroughRate() {
 	ctx := context.Background()
 	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"p"}
@@ -882,8 +1073,8 @@ func (suite *WorkerTestSuite) TestReplacement_ClickThroughRate() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
 	}, recommends)
 
 	// 2. Insert historical items into non-empty recommendation.
@@ -911,9 +1102,9 @@ func (suite *WorkerTestSuite) TestReplacement_ClickThroughRate() {
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 7.4, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "7", Score: 7, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "9", Score: 7.4, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "7", Score: 7, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
 	}, recommends)
 }
 
@@ -947,8 +1138,8 @@ func (suite *WorkerTestSuite) TestReplacement_CollaborativeFiltering() {
 	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "9", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
 	}, recommends)
 
 	// 2. Insert historical items into non-empty recommendation.
@@ -975,12 +1166,79 @@ func (suite *WorkerTestSuite) TestReplacement_CollaborativeFiltering() {
 	recommends, err = suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
 	suite.NoError(err)
 	suite.Equal([]cache.Document{
-		{Id: "10", Score: 9, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "9", Score: 7.4, Categories: []string{""}, Timestamp: recommendTime},
-		{Id: "7", Score: 7, Categories: []string{""}, Timestamp: recommendTime},
+		{Id: "10", Score: 9, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "9", Score: 7.4, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
+		{Id: "7", Score: 7, Categories: []string{""}, Timestamp: recommendTime, Source: "popular"},
+	}, recommends)
+}
+
+func (suite *WorkerTestSuite) TestReplacement_NegativeFeedback() {
+	ctx := context.Background()
+	suite.Config.Recommend.DataSource.PositiveFeedbackTypes = []string{"p"}
+	suite.Config.Recommend.DataSource.ReadFeedbackTypes = []string{"n"}
+	suite.Config.Recommend.DataSource.NegativeFeedbackTypes = []string{"d"}
+	suite.Config.Recommend.Offline.EnableColRecommend = false
+	suite.Config.Recommend.Replacement.EnableReplacement = true
+
+	// insert items
+	err := suite.DataClient.BatchInsertItems(ctx, []data.Item{{ItemId: "10"}, {ItemId: "9"}, {ItemId: "8"}})
+	suite.NoError(err)
+	// insert feedback
+	err = suite.DataClient.BatchInsertFeedback(ctx, []data.Feedback{
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "p", UserId: "0", ItemId: "10"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "n", UserId: "0", ItemId: "9"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "d", UserId: "0", ItemId: "9"}},
+		{FeedbackKey: data.FeedbackKey{FeedbackType: "d", UserId: "0", ItemId: "8"}},
+	}, true, false, true)
+	suite.NoError(err)
+	suite.RankingModel = newMockMatrixFactorizationForRecommend(1, 10)
+	suite.Recommend([]data.User{{UserId: "0"}})
+	// read recommend time
+	recommendTime, err := suite.CacheClient.Get(ctx, cache.Key(cache.LastUpdateUserRecommendTime, "0")).Time()
+	suite.NoError(err)
+	// items with negative feedback are not replaced
+	recommends, err := suite.CacheClient.SearchDocuments(ctx, cache.OfflineRecommend, "0", []string{""}, 0, 3)
+	suite.NoError(err)
+	suite.Equal([]cache.Document{
+		{Id: "10", Score: 10, Categories: []string{""}, Timestamp: recommendTime, Source: "replacement"},
 	}, recommends)
 }
 
+func (suite *WorkerTestSuite) TestDiversify() {
+	ctx := context.Background()
+	suite.Config.Recommend.Diversity.Lambda = 0.5
+	newRecommend := func() map[string][]cache.Document {
+		return map[string][]cache.Document{
+			"":  {{Id: "10", Score: 10}, {Id: "9", Score: 9}, {Id: "8", Score: 8}},
+			"*": {{Id: "9", Score: 9}, {Id: "8", Score: 8}},
+		}
+	}
+	expected := map[string][]cache.Document{
+		"":  {{Id: "10", Score: 10}, {Id: "8", Score: 9}, {Id: "9", Score: 8}},
+		"*": {{Id: "8", Score: 9}, {Id: "9", Score: 8}},
+	}
+
+	// diversify by labels
+	itemCache := NewItemCache()
+	itemCache.Set("10", data.Item{ItemId: "10", Labels: []any{"a"}})
+	itemCache.Set("9", data.Item{ItemId: "9", Labels: []any{"a"}})
+	itemCache.Set("8", data.Item{ItemId: "8", Labels: []any{"b"}})
+	suite.Config.Recommend.Diversity.Similarity = "labels"
+	recommend, err := suite.diversify(ctx, newRecommend(), itemCache)
+	suite.NoError(err)
+	suite.Equal(expected, recommend)
+
+	// diversify by item neighbors
+	err = suite.CacheClient.AddDocuments(ctx, cache.ItemNeighbors, "10", []cache.Document{
+		{Id: "9", Score: 100, Categories: []string{""}},
+	})
+	suite.NoError(err)
+	suite.Config.Recommend.Diversity.Similarity = "neighbors"
+	recommend, err = suite.diversify(ctx, newRecommend(), NewItemCache())
+	suite.NoError(err)
+	suite.Equal(expected, recommend)
+}
+
 func (suite *WorkerTestSuite) TestHealth() {
 	// ready
 	req := httptest.NewRequest("GET", "https://example.com/", nil)